@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tiborv/kube-parcel/pkg/client"
+)
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	execMode, _ := cmd.Flags().GetString("exec-mode")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	image, _ := cmd.Flags().GetString("runner-image")
+	rbacRetries, _ := cmd.Flags().GetInt("rbac-retries")
+	asJob, _ := cmd.Flags().GetBool("as-job")
+
+	if execMode != "docker" && execMode != "k8s" {
+		log.Fatalf("❌ Unknown --exec-mode %q (expected docker or k8s)", execMode)
+	}
+
+	fmt.Printf("🩺 Checking the %s environment...\n", execMode)
+	checks := client.Doctor(context.Background(), client.DoctorSettings{
+		ExecMode:               execMode,
+		Namespace:              namespace,
+		Image:                  image,
+		AsJob:                  asJob,
+		PermissionCheckRetries: rbacRetries,
+	})
+
+	if printChecklist(os.Stdout, checks) {
+		fmt.Println("❌ Fix the failed checks above before running")
+		os.Exit(1)
+	}
+	fmt.Println("✅ Ready to run")
+}
+
+// printChecklist prints one line per check and reports whether any blocker failed
+func printChecklist(w io.Writer, checks []client.DoctorCheck) (blocked bool) {
+	for _, check := range checks {
+		mark := "✅"
+		switch {
+		case check.Passed:
+		case check.Blocker:
+			mark = "❌"
+			blocked = true
+		default:
+			mark = "⚠️ "
+		}
+		if check.Detail != "" {
+			fmt.Fprintf(w, "  %s %s: %s\n", mark, check.Name, check.Detail)
+		} else {
+			fmt.Fprintf(w, "  %s %s\n", mark, check.Name)
+		}
+	}
+	return blocked
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tiborv/kube-parcel/pkg/client"
+)
+
+func TestPrintChecklist(t *testing.T) {
+	checks := []client.DoctorCheck{
+		{Name: "Kubeconfig loads", Passed: true, Blocker: true, Detail: "/home/ci/.kube/config"},
+		{Name: `Namespace "ci" exists`, Detail: "not allowed to read namespaces, can't confirm"},
+		{Name: "Can delete pods", Passed: true},
+	}
+
+	var out bytes.Buffer
+	if printChecklist(&out, checks) {
+		t.Errorf("warnings alone reported as blocking:\n%s", out.String())
+	}
+	want := "  ✅ Kubeconfig loads: /home/ci/.kube/config\n" +
+		"  ⚠️  Namespace \"ci\" exists: not allowed to read namespaces, can't confirm\n" +
+		"  ✅ Can delete pods\n"
+	if out.String() != want {
+		t.Errorf("checklist =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	checks = append(checks, client.DoctorCheck{Name: "Can create pods", Blocker: true, Detail: "needed to launch the runner pod"})
+	out.Reset()
+	if !printChecklist(&out, checks) {
+		t.Error("failed blocker not reported")
+	}
+	if !strings.Contains(out.String(), "❌ Can create pods: needed to launch the runner pod") {
+		t.Errorf("failed blocker missing from checklist:\n%s", out.String())
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tiborv/kube-parcel/pkg/client"
+	"github.com/tiborv/kube-parcel/pkg/config"
+)
+
+// bundleEntry is a chart, image, binary or manifest in a parcel
+type bundleEntry struct {
+	name  string
+	files int
+	size  int64
+}
+
+// bundleSummary lists a parcel's contents, classified the way the runner extracts them
+type bundleSummary struct {
+	charts      map[string]*bundleEntry
+	images      []bundleEntry
+	binaries    []bundleEntry
+	manifests   []bundleEntry // Applied before the charts are installed
+	runManifest *bundleEntry  // The run-level manifest (--chart-order), not a chart
+	ignored     []bundleEntry // Entries the runner doesn't extract
+	size        int64         // Total size of the files
+}
+
+// summarizeBundle reads a parcel tar stream to the end and lists what it contains
+func summarizeBundle(r io.Reader) (*bundleSummary, error) {
+	summary := &bundleSummary{charts: make(map[string]*bundleEntry)}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		name := header.Name
+		summary.size += header.Size
+		switch {
+		case isBundledImage(name):
+			summary.images = append(summary.images, bundleEntry{name: name, files: 1, size: header.Size})
+		case strings.HasPrefix(name, "bin/"):
+			summary.binaries = append(summary.binaries, bundleEntry{name: name, files: 1, size: header.Size})
+		case name == "charts/"+config.RunManifestFile:
+			summary.runManifest = &bundleEntry{name: name, files: 1, size: header.Size}
+		case strings.HasPrefix(name, "manifests/"):
+			summary.manifests = append(summary.manifests, bundleEntry{name: name, files: 1, size: header.Size})
+		case strings.HasPrefix(name, "charts/"):
+			chart, _, _ := strings.Cut(strings.TrimPrefix(name, "charts/"), "/")
+			entry := summary.charts[chart]
+			if entry == nil {
+				entry = &bundleEntry{name: chart}
+				summary.charts[chart] = entry
+			}
+			entry.files++
+			entry.size += header.Size
+		default:
+			summary.ignored = append(summary.ignored, bundleEntry{name: name, files: 1, size: header.Size})
+		}
+	}
+	// Drain the end-of-archive padding so a writer feeding us through a pipe can finish
+	io.Copy(io.Discard, r)
+	return summary, nil
+}
+
+// isBundledImage reports whether the runner imports an entry as an image: a tar at the
+// top level or under images/
+func isBundledImage(name string) bool {
+	if !strings.HasSuffix(name, ".tar") && !strings.HasSuffix(name, ".tar.gz") && !strings.HasSuffix(name, ".tgz") {
+		return false
+	}
+	return strings.HasPrefix(name, "images/") || !strings.Contains(name, "/")
+}
+
+// runDryRun bundles to --dry-run-output (or nowhere) and prints what the parcel would
+// contain and which chart images it lacks, without launching a runner
+func runDryRun(ctx context.Context, cmd *cobra.Command, bundler *client.Bundler, chartDirs []string) {
+	output, _ := cmd.Flags().GetString("dry-run-output")
+
+	var dest io.Writer = io.Discard
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Fatalf("❌ Failed to create bundle file: %v", err)
+		}
+		defer f.Close()
+		dest = f
+	}
+
+	pr, pw := io.Pipe()
+	type result struct {
+		summary *bundleSummary
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		summary, err := summarizeBundle(pr)
+		pr.CloseWithError(err)
+		done <- result{summary, err}
+	}()
+
+	hash := sha256.New()
+	err := bundler.Bundle(ctx, io.MultiWriter(dest, hash, pw))
+	pw.CloseWithError(err)
+	res := <-done
+	if err == nil {
+		err = res.err
+	}
+	if err != nil {
+		if output != "" {
+			os.Remove(output)
+		}
+		log.Fatalf("❌ Bundling failed: %v", err)
+	}
+
+	printDryRun(res.summary, bundler, chartDirs)
+	if output != "" {
+		fmt.Printf("\n📦 Bundle written to %s (sha256 %s)\n", output, hex.EncodeToString(hash.Sum(nil)))
+	}
+	fmt.Println("🧪 Dry run: no runner was launched")
+}
+
+// printDryRun prints a parcel's contents and the images its charts reference
+func printDryRun(summary *bundleSummary, bundler *client.Bundler, chartDirs []string) {
+	fmt.Printf("\n📦 Bundle contents (%s):\n", formatBytes(summary.size))
+
+	fmt.Printf("\n🪖 Charts (%d):\n", len(summary.charts))
+	for _, name := range slices.Sorted(maps.Keys(summary.charts)) {
+		chart := summary.charts[name]
+		fmt.Printf("  %-30s %4d files  %10s\n", chart.name, chart.files, formatBytes(chart.size))
+	}
+	printEntries("🐳 Images", summary.images)
+	printEntries("🔧 Binaries", summary.binaries)
+	printEntries("📄 Manifests", summary.manifests)
+	if summary.runManifest != nil {
+		fmt.Printf("\n📋 Run manifest (install order): %s  %s\n", summary.runManifest.name, formatBytes(summary.runManifest.size))
+	}
+	if len(summary.ignored) > 0 {
+		printEntries("⚠️  Not extracted by the runner", summary.ignored)
+	}
+
+	missing, err := bundler.MissingImages()
+	if err != nil {
+		log.Printf("⚠️  Could not discover chart images: %v", err)
+		return
+	}
+	fmt.Println("\n🔎 Images referenced by chart values:")
+	for _, chartDir := range chartDirs {
+		images, err := client.ExtractImagesFromChart(chartDir, bundler.TagFallback)
+		if err != nil {
+			log.Printf("⚠️  Could not discover images of %s: %v", chartDir, err)
+			continue
+		}
+		for _, image := range images {
+			if slices.Contains(missing[chartDir], image) {
+				fmt.Printf("  ⚠️  %-40s %s (not bundled)\n", image, filepath.Base(chartDir))
+			} else {
+				fmt.Printf("  ✅ %-40s %s\n", image, filepath.Base(chartDir))
+			}
+		}
+	}
+}
+
+// printEntries prints a section of bundle entries with their sizes
+func printEntries(title string, entries []bundleEntry) {
+	fmt.Printf("\n%s (%d):\n", title, len(entries))
+	for _, entry := range entries {
+		fmt.Printf("  %-40s %10s\n", entry.name, formatBytes(entry.size))
+	}
+}
+
+// formatBytes renders a size in binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
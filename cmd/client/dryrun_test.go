@@ -0,0 +1,65 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+)
+
+func TestSummarizeBundle(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, size := range map[string]int{
+		"nginx.tar":                   100,
+		"images/redis.tar.gz":         50,
+		"bin/renderer":                10,
+		"manifests/00-crds.yaml":      40,
+		"manifests/app/config.yaml":   15,
+		"charts/web/Chart.yaml":       20,
+		"charts/web/templates/a.yaml": 30,
+		"charts/kube-parcel.yaml":     25,
+		"notes.txt":                   5,
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Size: int64(size), Mode: 0644})
+		tw.Write(make([]byte, size))
+	}
+	tw.WriteHeader(&tar.Header{Name: "charts/web/templates/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.Close()
+
+	summary, err := summarizeBundle(&buf)
+	if err != nil {
+		t.Fatalf("summarizeBundle() failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%d bytes left unread", buf.Len())
+	}
+	if len(summary.images) != 2 || len(summary.binaries) != 1 || len(summary.manifests) != 2 || len(summary.ignored) != 1 {
+		t.Errorf("got %d images, %d binaries, %d manifests, %d ignored; want 2, 1, 2, 1", len(summary.images), len(summary.binaries), len(summary.manifests), len(summary.ignored))
+	}
+	web := summary.charts["web"]
+	if web == nil || web.files != 2 || web.size != 50 {
+		t.Errorf("chart web = %+v, want 2 files of 50 bytes", web)
+	}
+	if _, ok := summary.charts["kube-parcel.yaml"]; ok || len(summary.charts) != 1 {
+		t.Errorf("expected only chart web, got %v", summary.charts)
+	}
+	if summary.runManifest == nil || summary.runManifest.size != 25 {
+		t.Errorf("run manifest = %+v, want charts/kube-parcel.yaml of 25 bytes", summary.runManifest)
+	}
+	if summary.size != 295 {
+		t.Errorf("size = %d, want 295", summary.size)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		512:     "512 B",
+		2048:    "2.0 KiB",
+		5 << 20: "5.0 MiB",
+		3 << 30: "3.0 GiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -1,24 +1,41 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	"unicode"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/tiborv/kube-parcel/pkg/client"
 	"github.com/tiborv/kube-parcel/pkg/config"
 	"github.com/tiborv/kube-parcel/pkg/shared"
+	corev1 "k8s.io/api/core/v1"
 )
 
 var (
 	cfgFile string
+	profile string
 	rootCmd = &cobra.Command{
 		Use:     "kube-parcel",
 		Short:   "CI-first integration testing for Helm charts",
@@ -31,44 +48,175 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: $HOME/.kube-parcel.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "named profile from the config file's 'profiles' section")
+	rootCmd.PersistentPreRunE = applyConfig
 
 	startCmd := &cobra.Command{
 		Use:   "start [chart-dirs...]",
 		Short: "Launch server and upload charts",
 		Long:  `Launch an ephemeral K3s server (locally via Docker or remotely in Kubernetes) and run tests`,
-		Args:  cobra.MinimumNArgs(1),
-		Run:   runStart,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if bundle, _ := cmd.Flags().GetString("from-bundle"); bundle != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		Run: runStart,
 	}
 	startCmd.Flags().String("exec-mode", "docker", "Execution mode: 'docker' (local) or 'k8s' (Kubernetes cluster)")
 	startCmd.Flags().String("namespace", "default", "Kubernetes namespace (for remote mode)")
 	startCmd.Flags().String("runner-image", "ghcr.io/tiborv/kube-parcel-runner:v"+config.MinorVersion, "Runner image to use")
-	startCmd.Flags().String("cpu", "", "CPU limit (e.g., 1000m)")
-	startCmd.Flags().String("memory", "", "Memory limit (e.g., 2Gi)")
+	startCmd.Flags().String("cpu", "", "CPU limit of the runner container (each, with --agents) or pod (e.g., 1000m)")
+	startCmd.Flags().String("memory", "", "Memory limit of the runner container (each, with --agents) or pod (e.g., 2Gi)")
 	startCmd.Flags().String("labels", "", "Comma-separated labels (key=value)")
 	startCmd.Flags().String("annotations", "", "Comma-separated annotations (key=value)")
+	startCmd.Flags().Bool("as-job", false, "Run the runner as a Kubernetes Job instead of a bare pod, so Kubernetes garbage-collects it (k8s mode)")
+	startCmd.Flags().Duration("job-ttl", 10*time.Minute, "With --as-job, delete the Job this long after it finished (ttlSecondsAfterFinished; 0 = keep it)")
+	startCmd.Flags().Int32("job-backoff-limit", 0, "With --as-job, replace a failed runner pod this many times before the Job fails")
+	startCmd.Flags().String("node-selector", "", "Comma-separated node labels (key=value) the runner pod must be scheduled on")
+	startCmd.Flags().StringArray("toleration", nil, "Taint the runner pod tolerates as key=value:Effect, key:Effect or key=value (repeatable)")
+	startCmd.Flags().Int("rbac-retries", 3, "Retries for transient API errors during the k8s permission pre-check")
+	startCmd.Flags().String("image-pull-policy", "IfNotPresent", "Runner image pull policy in k8s mode (Always, IfNotPresent, Never)")
+	startCmd.Flags().MarkDeprecated("image-pull-policy", "use --pull-policy, which also accepts Always, IfNotPresent and Never")
+	startCmd.Flags().String("pull-policy", client.PullPolicyMissing, "Runner image pull policy (always, missing, never, or Always, IfNotPresent, Never); in k8s mode it sets the pod's imagePullPolicy")
+	startCmd.Flags().StringArray("image-pull-secret", nil, "Secret in --namespace used to pull the runner image from a private registry in k8s mode (repeatable)")
 	startCmd.Flags().Bool("host-pid", true, "Use host PID namespace for better nested container support (default: true)")
+	startCmd.Flags().Int("image-gc-threshold", 0, "Prune images unused by any container before importing when containerd disk usage reaches this percent (0 = off)")
+	startCmd.Flags().Duration("idle-timeout", 0, "Shut the runner down after it has been idle this long once a run finished (0 = never)")
+	startCmd.Flags().Duration("ttl", 0, "Shut the runner down this long after it started, whatever it is doing; in k8s mode also the pod's activeDeadlineSeconds (0 = never)")
+	startCmd.Flags().Int("agents", 0, "Extra K3s agent nodes for multi-node testing (docker mode only)")
+	startCmd.Flags().String("token", "", "Require this bearer token on the runner's API and log stream (also KUBE_PARCEL_TOKEN)")
+	startCmd.Flags().Bool("tls", false, "Serve the runner's API over HTTPS on 8443 with a self-signed certificate (needs --insecure-skip-verify)")
+	startCmd.Flags().Bool("insecure-skip-verify", false, "Don't verify the runner's TLS certificate (for self-signed certificates)")
+	startCmd.Flags().Int("http-port", config.DefaultHTTPPort, "Port the runner's HTTP API listens on inside its container or pod (also KUBE_PARCEL_HTTP_PORT)")
+	startCmd.Flags().String("docker-network", "", "Existing Docker network the runner containers join, e.g. to reach other containers by name (docker mode only)")
+	startCmd.Flags().StringArray("add-host", nil, "Extra /etc/hosts entry for the runner containers as name:ip, ip may be host-gateway (repeatable, docker mode only)")
+	startCmd.Flags().Bool("skip-preflight", false, "Skip the Docker host check for privileged nested container support (docker mode)")
+	startCmd.Flags().String("from-bundle", "", "Upload a pre-built bundle file (from 'kube-parcel bundle') instead of bundling chart dirs")
+	startCmd.Flags().Bool("dry-run", false, "Bundle and list what the parcel would contain (charts, images, referenced images) without launching a runner")
+	startCmd.Flags().String("dry-run-output", "", "With --dry-run, also write the bundle to this file (default: discard it)")
 	startCmd.Flags().Bool("keep-alive", false, "Keep container running after tests complete")
 	startCmd.Flags().Bool("no-airgap", false, "Disable airgap mode (allow K3s to pull external images)")
-	startCmd.Flags().StringSlice("load-images", nil, "Image tars or OCI directories to load into the cluster")
+	startCmd.Flags().String("k3s-version", "", "K3s release to run (e.g. v1.30.2+k3s1); downloaded by the runner if its image ships another, which needs --no-airgap")
+	startCmd.Flags().StringSlice("k3s-disable", config.DefaultK3sDisable,
+		"Packaged K3s components to disable ("+strings.Join(config.K3sComponents, ", ")+"); airgap mode also disables metrics-server unless set explicitly")
+	startCmd.Flags().StringArray("k3s-arg", nil, "Extra \"k3s server\" arg, appended after the defaults so it can override them (repeatable; --disable values merge with --k3s-disable)")
+	startCmd.Flags().String("flannel-backend", "", "K3s flannel backend (vxlan, host-gw, wireguard-native, or none for a custom CNI)")
+	startCmd.Flags().Bool("disable-network-policy", false, "Disable K3s's embedded network policy controller")
+	startCmd.Flags().String("cni-manifest", "", "CNI manifest (path inside the runner image or URL) applied before installs")
+	startCmd.Flags().StringArray("registry-mirror", nil, "Registry mirror as registry=https://mirror (repeatable; \"*\" matches every registry); mirrors on public addresses need --no-airgap")
+	startCmd.Flags().StringArray("registry-auth", nil, "Registry credentials as host:user:pass (host may include a port; repeatable)")
+	startCmd.Flags().StringArray("pull-secret", nil, "Registry credentials as host:user:pass like --registry-auth, added as an image pull secret to the default serviceaccount of each chart namespace (repeatable; needs --no-airgap for public registries)")
+	startCmd.Flags().StringSlice("load-images", nil, "Image tars (plain or gzipped) or OCI directories to load into the cluster")
+	startCmd.Flags().Bool("auto-images", false, "Pull images referenced in the charts' values.yaml but missing from --load-images and add them to the bundle")
+	startCmd.Flags().String("image-tag-fallback", "", "Tag assumed for chart images with no tag in values.yaml and no appVersion in Chart.yaml (default: unknown)")
+	startCmd.Flags().Bool("strict-images", false, "Fail on the first image that can't be resolved or added (checked before bundling) instead of skipping it")
+	startCmd.Flags().String("platform", "", "Platform pulled for remote:// and --auto-images images and imported by the runner, e.g. linux/arm64 (default: linux/amd64)")
+	startCmd.Flags().String("temp-dir", "", "Directory for intermediate image tars while bundling (default: system temp dir)")
+	startCmd.Flags().Duration("helm-timeout", config.DefaultHelmTimeout, "Timeout for each chart's helm install (and helm test unless --test-timeout is set)")
+	startCmd.Flags().Duration("test-timeout", 0, "Timeout for each chart's helm test run (0 = same as --helm-timeout)")
+	startCmd.Flags().Int("test-parallel", 1, "Max number of charts whose helm tests run concurrently")
+	startCmd.Flags().Int("parallel", 1, "Max number of charts installed concurrently")
+	startCmd.Flags().String("post-renderer", "", "Helm post-renderer executable: a local file (shipped in the bundle) or a path inside the runner image")
+	startCmd.Flags().Bool("take-ownership", false, "Let helm install adopt existing resources not owned by a release (helm --take-ownership)")
+	startCmd.Flags().Bool("lint", false, "Run helm lint before installing each chart; lint errors fail the chart, warnings don't")
+	startCmd.Flags().Bool("fail-on-import-error", false, "Fail the run before installing charts when a bundled image fails to import")
+	startCmd.Flags().Bool("compress", false, "Gzip the upload stream (helps over slow links; image layers are mostly compressed already)")
+	startCmd.Flags().String("pause-on", "", "Pause the run for inspection at install, test or failure until POST /parcel/continue or /parcel/abort")
+	startCmd.Flags().String("artifacts-out", "", "Directory to download run artifacts (results.json, per-test pod logs) into")
+	startCmd.Flags().String("log-file", "", "Also write every runner log message to this file as JSON lines")
+	startCmd.Flags().String("min-level", "", "Only stream runner log messages at or above this level (debug, info, warn, error)")
+	startCmd.Flags().Duration("deadline", 0, "Abort the whole run (launch, upload, install, tests) after this long and clean up; the runner aborts in-flight helm commands too (0 = no deadline)")
+	startCmd.Flags().Int("reconnect-attempts", 5, "Reconnect attempts if the log stream drops before the run completes (0 = fail immediately)")
+	startCmd.Flags().String("k3s-log-level", "warn", "Only stream K3s server output at or above this level (debug, info, warn, error); the runner keeps all of it in "+config.DefaultK3sLogPath)
+	startCmd.Flags().Bool("log-spill", false, "Keep the runner's full log history (spilling old messages to disk) so late or reconnecting clients get everything")
+	startCmd.Flags().String("junit-out", "", "Write a JUnit XML report (one testcase per chart) to this file when the run ends")
+	startCmd.Flags().String("metrics-file", "", "Append per-chart install/test durations as JSON lines to this runner path (relative = in the artifacts directory)")
+	addChartFlags(startCmd)
 	viper.BindPFlags(startCmd.Flags())
 	rootCmd.AddCommand(startCmd)
 
 	uploadCmd := &cobra.Command{
 		Use:   "upload [chart-dirs...]",
-		Short: "Upload charts to existing server",
-		Args:  cobra.MinimumNArgs(1),
-		Run:   runUpload,
+		Short: "Upload charts (or a pre-built bundle) to existing server",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if bundle, _ := cmd.Flags().GetString("bundle"); bundle != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		Run: runUpload,
 	}
-	uploadCmd.Flags().String("server", "http://localhost:8080", "Server URL")
+	uploadCmd.Flags().String("server", "http://localhost:8080", "Server URL (when not set, the most recent server launched by 'start')")
+	uploadCmd.Flags().String("token", "", "Bearer token of a runner started with --token (also KUBE_PARCEL_TOKEN)")
+	uploadCmd.Flags().Bool("insecure-skip-verify", false, "Don't verify the runner's TLS certificate (for self-signed certificates)")
+	uploadCmd.Flags().String("bundle", "", "Upload a pre-built bundle file (from 'kube-parcel bundle') instead of bundling chart dirs")
+	uploadCmd.Flags().Bool("wait-for-idle", false, "If the runner is busy with another run, wait for it to finish and retry instead of failing")
+	uploadCmd.Flags().Bool("upgrade", false, "Reuse a --keep-alive runner's cluster: replace its parcel and helm upgrade --install the charts (a runner without a cluster does a normal run)")
+	uploadCmd.Flags().Bool("compress", false, "Gzip the upload stream (helps over slow links; image layers are mostly compressed already)")
+	uploadCmd.Flags().String("pause-on", "", "Pause the run for inspection at install, test or failure until POST /parcel/continue or /parcel/abort")
+	uploadCmd.Flags().String("artifacts-out", "", "Directory to download run artifacts (results.json, per-test pod logs) into")
+	uploadCmd.Flags().String("log-file", "", "Also write every runner log message to this file as JSON lines")
+	uploadCmd.Flags().String("min-level", "", "Only stream runner log messages at or above this level (debug, info, warn, error)")
+	uploadCmd.Flags().Duration("deadline", 0, "Abort the run (upload, install, tests) after this long; the runner aborts in-flight helm commands too (0 = no deadline)")
+	uploadCmd.Flags().Int("reconnect-attempts", 5, "Reconnect attempts if the log stream drops before the run completes (0 = fail immediately)")
+	uploadCmd.Flags().String("junit-out", "", "Write a JUnit XML report (one testcase per chart) to this file when the run ends")
+	addChartFlags(uploadCmd)
 	viper.BindPFlags(uploadCmd.Flags())
 	rootCmd.AddCommand(uploadCmd)
 
+	bundleCmd := &cobra.Command{
+		Use:   "bundle [chart-dirs...]",
+		Short: "Write a parcel bundle to a file without uploading it",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   runBundle,
+	}
+	bundleCmd.Flags().StringP("output", "o", "parcel.tar", "Output bundle file")
+	bundleCmd.Flags().StringSlice("load-images", nil, "Image tars (plain or gzipped) or OCI directories to include in the bundle")
+	bundleCmd.Flags().Bool("strict-images", false, "Fail on the first image that can't be resolved or added (checked before bundling) instead of skipping it")
+	bundleCmd.Flags().String("platform", "", "Platform pulled for remote:// images, e.g. linux/arm64 (default: linux/amd64)")
+	bundleCmd.Flags().String("temp-dir", "", "Directory for intermediate image tars while bundling (default: system temp dir)")
+	bundleCmd.Flags().String("output-dir", "", "Write the bundle into this directory, named by its content digest (parcel-<sha256>.tar)")
+	bundleCmd.Flags().Bool("reproducible", false, "Normalize tar headers (mtimes, owners) so identical inputs produce byte-identical bundles")
+	addChartFlags(bundleCmd)
+	viper.BindPFlags(bundleCmd.Flags())
+	rootCmd.AddCommand(bundleCmd)
+
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check Docker or cluster access, permissions and the runner image before a run",
+		Args:  cobra.NoArgs,
+		Run:   runDoctor,
+	}
+	doctorCmd.Flags().String("exec-mode", "docker", "Execution mode to check: 'docker' (local) or 'k8s' (Kubernetes cluster)")
+	doctorCmd.Flags().String("namespace", "default", "Kubernetes namespace (for remote mode)")
+	doctorCmd.Flags().String("runner-image", "ghcr.io/tiborv/kube-parcel-runner:v"+config.MinorVersion, "Runner image to check")
+	doctorCmd.Flags().Bool("as-job", false, "Check the permissions needed to run the runner as a Job (k8s mode)")
+	doctorCmd.Flags().Int("rbac-retries", 3, "Retries for transient API errors during the k8s permission check")
+	viper.BindPFlags(doctorCmd.Flags())
+	rootCmd.AddCommand(doctorCmd)
+
+	stopCmd := &cobra.Command{
+		Use:   "stop [name...]",
+		Short: "Remove runner containers or pods left running by --keep-alive",
+		Run:   runStop,
+	}
+	stopCmd.Flags().String("exec-mode", "docker", "Where the runners run: 'docker' (local) or 'k8s' (Kubernetes cluster)")
+	stopCmd.Flags().String("namespace", "default", "Kubernetes namespace (for remote mode)")
+	stopCmd.Flags().Bool("all", false, "Remove every kube-parcel runner instead of the most recent session's")
+	viper.BindPFlags(stopCmd.Flags())
+	rootCmd.AddCommand(stopCmd)
+
 	statusCmd := &cobra.Command{
 		Use:   "status",
 		Short: "Check server status",
 		Run:   runStatus,
 	}
-	statusCmd.Flags().String("server", "http://localhost:8080", "Server URL")
+	statusCmd.Flags().String("server", "http://localhost:8080", "Server URL (when not set, the most recent server launched by 'start')")
+	statusCmd.Flags().String("token", "", "Bearer token of a runner started with --token (also KUBE_PARCEL_TOKEN)")
+	statusCmd.Flags().Bool("insecure-skip-verify", false, "Don't verify the runner's TLS certificate (for self-signed certificates)")
+	statusCmd.Flags().Bool("json", false, "Print the raw status JSON (e.g. to save a snapshot for --diff)")
+	statusCmd.Flags().String("diff", "", "Compare the live status against a snapshot saved with --json and print the changes")
 	viper.BindPFlags(statusCmd.Flags())
 	rootCmd.AddCommand(statusCmd)
 }
@@ -92,6 +240,49 @@ func initConfig() {
 	viper.ReadInConfig() // Ignore errors if config file doesn't exist
 }
 
+// applyConfig merges the selected profile over the base config and applies the result
+// (plus KUBE_PARCEL_* env vars) to every flag not set explicitly on the command line
+func applyConfig(cmd *cobra.Command, args []string) error {
+	if profile == "" {
+		profile = viper.GetString("profile")
+	}
+	if profile != "" {
+		key := "profiles." + profile
+		if !viper.IsSet(key) {
+			var available []string
+			for name := range viper.GetStringMap("profiles") {
+				available = append(available, name)
+			}
+			sort.Strings(available)
+			return fmt.Errorf("unknown profile %q (available: %s)", profile, strings.Join(available, ", "))
+		}
+		if err := viper.MergeConfigMap(viper.GetStringMap(key)); err != nil {
+			return fmt.Errorf("failed to apply profile %q: %w", profile, err)
+		}
+		log.Printf("📋 Using profile: %s", profile)
+	}
+
+	var errs []string
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed || f.Name == "config" || f.Name == "profile" || !viper.IsSet(f.Name) {
+			return
+		}
+		var err error
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			err = sv.Replace(viper.GetStringSlice(f.Name))
+		} else {
+			err = f.Value.Set(viper.GetString(f.Name))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", f.Name, err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config values: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -99,7 +290,13 @@ func main() {
 }
 
 func runStart(cmd *cobra.Command, args []string) {
-	ctx := context.Background()
+	// Ctrl-C (or SIGTERM from a cancelled CI job) aborts the launch, upload and log stream,
+	// and the runner is still cleaned up below
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	interrupted := func() bool { return signalCtx.Err() != nil }
+	ctx, cancel := withDeadline(signalCtx, cmd)
+	defer cancel()
 	chartDirs := args
 
 	execMode, _ := cmd.Flags().GetString("exec-mode")
@@ -107,6 +304,59 @@ func runStart(cmd *cobra.Command, args []string) {
 	keepAlive, _ := cmd.Flags().GetBool("keep-alive")
 	noAirgap, _ := cmd.Flags().GetBool("no-airgap")
 	imagePaths, _ := cmd.Flags().GetStringSlice("load-images")
+	helmTimeout, _ := cmd.Flags().GetDuration("helm-timeout")
+	testTimeout, _ := cmd.Flags().GetDuration("test-timeout")
+	testParallel, _ := cmd.Flags().GetInt("test-parallel")
+	installParallel, _ := cmd.Flags().GetInt("parallel")
+	opts := uploadOpts(cmd)
+	agents, _ := cmd.Flags().GetInt("agents")
+	skipPreflight, _ := cmd.Flags().GetBool("skip-preflight")
+	httpPort, _ := cmd.Flags().GetInt("http-port")
+	if agents < 0 {
+		log.Fatalf("❌ --agents must not be negative")
+	}
+	dockerNetwork, _ := cmd.Flags().GetString("docker-network")
+	extraHosts, _ := cmd.Flags().GetStringArray("add-host")
+	if execMode != "docker" && (dockerNetwork != "" || len(extraHosts) > 0) {
+		log.Fatalf("❌ --docker-network and --add-host are only supported with --exec-mode docker")
+	}
+	if err := client.ValidateDockerNetworking(dockerNetwork, extraHosts); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if execMode == "docker" && cmd.Flags().Changed("image-pull-secret") {
+		log.Fatalf("❌ --image-pull-secret is only supported with --exec-mode k8s (docker mode pulls with your Docker credentials)")
+	}
+	pullPolicy, _ := cmd.Flags().GetString("pull-policy")
+	if cmd.Flags().Changed("image-pull-policy") {
+		// Deprecated alias: it only ever accepted the Kubernetes names, which --pull-policy takes too
+		if cmd.Flags().Changed("pull-policy") {
+			log.Fatalf("❌ --image-pull-policy is deprecated; pass only --pull-policy")
+		}
+		pullPolicy, _ = cmd.Flags().GetString("image-pull-policy")
+	}
+	localPullPolicy, perr := client.ParsePullPolicy(pullPolicy)
+	if perr != nil {
+		log.Fatalf("❌ %v", perr)
+	}
+	if httpPort < 1 || httpPort > 65535 {
+		log.Fatalf("❌ --http-port must be between 1 and 65535")
+	}
+	if httpPort == config.DefaultMetricsPort {
+		log.Fatalf("❌ --http-port %d is the runner's metrics port", httpPort)
+	}
+	useTLS, _ := cmd.Flags().GetBool("tls")
+	insecure, _ := cmd.Flags().GetBool("insecure-skip-verify")
+	if useTLS && !insecure {
+		log.Fatalf("❌ --tls serves a self-signed certificate; pass --insecure-skip-verify to accept it")
+	}
+	if useTLS && cmd.Flags().Changed("http-port") {
+		log.Fatalf("❌ --http-port can't be combined with --tls (the runner serves HTTPS on %d)", config.DefaultHTTPSPort)
+	}
+	client.SetInsecureSkipVerify(insecure)
+
+	if err := client.ValidateImageSpecs(imagePaths); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
 
 	var handle *client.ServerHandle
 	var err error
@@ -115,16 +365,212 @@ func runStart(cmd *cobra.Command, args []string) {
 	if noAirgap {
 		env["KUBE_PARCEL_AIRGAP"] = "false"
 	}
+	if cmd.Flags().Changed("k3s-disable") {
+		disable, _ := cmd.Flags().GetStringSlice("k3s-disable")
+		for _, component := range disable {
+			if !slices.Contains(config.K3sComponents, component) {
+				log.Fatalf("❌ Unknown K3s component %q (known: %s)", component, strings.Join(config.K3sComponents, ", "))
+			}
+		}
+		env["KUBE_PARCEL_K3S_DISABLE"] = strings.Join(disable, ",")
+	}
+	if k3sVersion, _ := cmd.Flags().GetString("k3s-version"); k3sVersion != "" {
+		env["KUBE_PARCEL_K3S_VERSION"] = k3sVersion
+	}
+	if k3sArgs, _ := cmd.Flags().GetStringArray("k3s-arg"); len(k3sArgs) > 0 {
+		for _, arg := range k3sArgs {
+			if strings.ContainsFunc(arg, unicode.IsSpace) {
+				log.Fatalf("❌ --k3s-arg %q: use --flag=value without spaces", arg)
+			}
+		}
+		env["KUBE_PARCEL_K3S_ARGS"] = strings.Join(k3sArgs, " ")
+	}
+	if flannelBackend, _ := cmd.Flags().GetString("flannel-backend"); flannelBackend != "" {
+		env["KUBE_PARCEL_FLANNEL_BACKEND"] = flannelBackend
+	}
+	if disableNetPol, _ := cmd.Flags().GetBool("disable-network-policy"); disableNetPol {
+		env["KUBE_PARCEL_DISABLE_NETWORK_POLICY"] = "true"
+	}
+	if cniManifest, _ := cmd.Flags().GetString("cni-manifest"); cniManifest != "" {
+		env["KUBE_PARCEL_CNI_MANIFEST"] = cniManifest
+	}
+	registryMirrors, _ := cmd.Flags().GetStringArray("registry-mirror")
+	registryAuths, _ := cmd.Flags().GetStringArray("registry-auth")
+	registries, err := client.ParseRegistryConfig(registryMirrors, registryAuths)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if registries != nil {
+		if len(registries.Mirrors) > 0 && !noAirgap {
+			log.Printf("⚠️  Airgap mode blocks public addresses; --registry-mirror only works for mirrors on private networks unless --no-airgap is set")
+		}
+		data, err := json.Marshal(registries)
+		if err != nil {
+			log.Fatalf("❌ Failed to encode registry config: %v", err)
+		}
+		env["KUBE_PARCEL_REGISTRIES"] = string(data)
+	}
+	pullSecrets, _ := cmd.Flags().GetStringArray("pull-secret")
+	pullAuths, err := client.ParsePullSecrets(pullSecrets)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if pullAuths != nil {
+		if !noAirgap {
+			log.Printf("⚠️  Airgap mode blocks public addresses; --pull-secret only helps for registries on private networks unless --no-airgap is set")
+		}
+		data, err := json.Marshal(pullAuths)
+		if err != nil {
+			log.Fatalf("❌ Failed to encode pull secrets: %v", err)
+		}
+		env["KUBE_PARCEL_PULL_SECRETS"] = string(data)
+	}
+	platform := platformFlag(cmd)
+	if platform != "" {
+		env["KUBE_PARCEL_IMPORT_PLATFORM"] = platform
+	}
+	// A pre-built bundle is uploaded as-is, so nothing can be added to it
+	fromBundle, _ := cmd.Flags().GetString("from-bundle")
+	var bundler *client.Bundler
+	if fromBundle != "" {
+		if cmd.Flags().Changed("load-images") || cmd.Flags().Changed("set") || cmd.Flags().Changed("values") || cmd.Flags().Changed("release-name") || cmd.Flags().Changed("chart-order") || cmd.Flags().Changed("manifests") {
+			log.Fatalf("❌ --load-images/--set/--values/--release-name/--chart-order/--manifests cannot be combined with --from-bundle (pass them to 'kube-parcel bundle')")
+		}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			log.Fatalf("❌ --dry-run cannot be combined with --from-bundle")
+		}
+		if _, err := os.Stat(fromBundle); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	} else {
+		tempDir, _ := cmd.Flags().GetString("temp-dir")
+		var cleanupCharts func()
+		chartDirs, cleanupCharts = resolveCharts(ctx, chartDirs, tempDir)
+		defer cleanupCharts()
+		bundler = client.NewBundler(chartDirs, imagePaths)
+		bundler.Overrides = chartOverrides(cmd, chartDirs)
+		bundler.ChartOrder = chartOrder(cmd, chartDirs)
+		bundler.Manifests = manifestsFlag(cmd)
+		bundler.TempDir = tempDir
+		bundler.StrictImages, _ = cmd.Flags().GetBool("strict-images")
+		bundler.TagFallback, _ = cmd.Flags().GetString("image-tag-fallback")
+		bundler.Platform = platform
+	}
+	if postRenderer, _ := cmd.Flags().GetString("post-renderer"); postRenderer != "" {
+		// A local executable is shipped in the bundle; anything else is a path in the runner image
+		if info, err := os.Stat(postRenderer); err == nil && info.Mode().IsRegular() {
+			if info.Mode().Perm()&0111 == 0 {
+				log.Fatalf("❌ Post-renderer %s is not executable", postRenderer)
+			}
+			if bundler == nil {
+				log.Fatalf("❌ A local --post-renderer can't be added to a --from-bundle parcel; pass a path inside the runner image")
+			}
+			bundler.AddBinary(postRenderer)
+			postRenderer = filepath.Base(postRenderer)
+		}
+		env["KUBE_PARCEL_POST_RENDERER"] = postRenderer
+	}
+	if bundler != nil {
+		autoImages, _ := cmd.Flags().GetBool("auto-images")
+		checkChartImages(bundler, autoImages, !noAirgap)
+		// Checked before the runner is launched, so a bad image never costs a cluster boot
+		if bundler.StrictImages {
+			if err := bundler.Validate(ctx); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			runDryRun(ctx, cmd, bundler, chartDirs)
+			return
+		}
+	}
+	if k3sLogLevel, _ := cmd.Flags().GetString("k3s-log-level"); k3sLogLevel != "" {
+		env["KUBE_PARCEL_K3S_LOG_LEVEL"] = k3sLogLevel
+	}
+	if logSpill, _ := cmd.Flags().GetBool("log-spill"); logSpill {
+		env["KUBE_PARCEL_LOG_SPILL_FILE"] = config.DefaultLogSpillPath
+	}
+	if takeOwnership, _ := cmd.Flags().GetBool("take-ownership"); takeOwnership {
+		env["KUBE_PARCEL_TAKE_OWNERSHIP"] = "true"
+	}
+	if lint, _ := cmd.Flags().GetBool("lint"); lint {
+		env["KUBE_PARCEL_LINT"] = "true"
+	}
+	if failOnImportError, _ := cmd.Flags().GetBool("fail-on-import-error"); failOnImportError {
+		env["KUBE_PARCEL_FAIL_ON_IMPORT_ERROR"] = "true"
+	}
+	if gcThreshold, _ := cmd.Flags().GetInt("image-gc-threshold"); gcThreshold > 0 {
+		env["KUBE_PARCEL_IMAGE_GC_THRESHOLD"] = strconv.Itoa(gcThreshold)
+	}
+	if metricsFile, _ := cmd.Flags().GetString("metrics-file"); metricsFile != "" {
+		env["KUBE_PARCEL_METRICS_FILE"] = metricsFile
+	}
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		env["KUBE_PARCEL_TOKEN"] = token
+		client.SetToken(token)
+	}
+	if idleTimeout, _ := cmd.Flags().GetDuration("idle-timeout"); idleTimeout > 0 {
+		env["KUBE_PARCEL_IDLE_TIMEOUT"] = idleTimeout.String()
+	}
+	ttl, _ := cmd.Flags().GetDuration("ttl")
+	if ttl < 0 {
+		log.Fatalf("❌ --ttl must not be negative")
+	}
+	if ttl > 0 {
+		env["KUBE_PARCEL_TTL"] = ttl.String()
+	}
+	env["KUBE_PARCEL_HELM_TIMEOUT"] = helmTimeout.String()
+	if testTimeout > 0 {
+		env["KUBE_PARCEL_TEST_TIMEOUT"] = testTimeout.String()
+	}
+	env["KUBE_PARCEL_TEST_PARALLEL"] = strconv.Itoa(testParallel)
+	env["KUBE_PARCEL_INSTALL_PARALLEL"] = strconv.Itoa(installParallel)
 
+	cpu, _ := cmd.Flags().GetString("cpu")
+	memory, _ := cmd.Flags().GetString("memory")
 	if execMode == "docker" {
-		handle, err = client.LaunchLocal(ctx, image, env)
+		handle, err = client.LaunchLocal(ctx, client.LocalSettings{
+			Image:  image,
+			Env:    env,
+			Agents: agents,
+
+			HTTPPort:      httpPort,
+			TLS:           useTLS,
+			SkipPreflight: skipPreflight,
+
+			CPU:    cpu,
+			Memory: memory,
+
+			PullPolicy: localPullPolicy,
+			Network:    dockerNetwork,
+			ExtraHosts: extraHosts,
+		})
 	} else {
+		if agents > 0 {
+			log.Fatalf("❌ --agents is only supported with --exec-mode docker")
+		}
 		namespace, _ := cmd.Flags().GetString("namespace")
-		cpu, _ := cmd.Flags().GetString("cpu")
-		memory, _ := cmd.Flags().GetString("memory")
 		labels, _ := cmd.Flags().GetString("labels")
 		annotations, _ := cmd.Flags().GetString("annotations")
 		hostPID, _ := cmd.Flags().GetBool("host-pid")
+		rbacRetries, _ := cmd.Flags().GetInt("rbac-retries")
+		pullSecrets, _ := cmd.Flags().GetStringArray("image-pull-secret")
+		asJob, _ := cmd.Flags().GetBool("as-job")
+		jobTTL, _ := cmd.Flags().GetDuration("job-ttl")
+		jobBackoffLimit, _ := cmd.Flags().GetInt32("job-backoff-limit")
+		if jobTTL < 0 || jobBackoffLimit < 0 {
+			log.Fatalf("❌ --job-ttl and --job-backoff-limit must not be negative")
+		}
+		nodeSelector, _ := cmd.Flags().GetString("node-selector")
+		tolerationFlags, _ := cmd.Flags().GetStringArray("toleration")
+		var tolerations []corev1.Toleration
+		for _, flag := range tolerationFlags {
+			toleration, err := client.ParseToleration(flag)
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			tolerations = append(tolerations, toleration)
+		}
 
 		settings := client.PodSettings{
 			Namespace:   namespace,
@@ -134,119 +580,589 @@ func runStart(cmd *cobra.Command, args []string) {
 			Labels:      parseMap(labels),
 			Annotations: parseMap(annotations),
 			HostPID:     hostPID,
+			Env:         envVars(env),
+
+			NodeSelector: parseMap(nodeSelector),
+			Tolerations:  tolerations,
+
+			AsJob:           asJob,
+			JobTTL:          jobTTL,
+			JobBackoffLimit: jobBackoffLimit,
+			TTL:             ttl,
+
+			ImagePullPolicy:        client.KubernetesPullPolicy(localPullPolicy),
+			ImagePullSecrets:       pullSecrets,
+			PermissionCheckRetries: rbacRetries,
+			HTTPPort:               httpPort,
+			TLS:                    useTLS,
 		}
 		handle, err = client.LaunchRemote(ctx, settings)
 	}
 
 	if err != nil {
-		log.Fatalf("❌ Failed to launch server: %v", err)
+		if interrupted() {
+			log.Println("🛑 Interrupted while launching the server")
+			os.Exit(130)
+		}
+		log.Printf("❌ Failed to launch server: %v", err)
+		os.Exit(exitInfraFailure)
 	}
 
-	// Only cleanup if not keeping alive or if tests pass
-	testFailed := false
+	// Only cleanup if not keeping alive or if tests pass. Failures set exitCode and return
+	// instead of exiting so this still runs, e.g. when the deadline passes.
+	exitCode := 0
 	defer func() {
-		if keepAlive && testFailed {
+		if interrupted() {
+			// A second Ctrl-C kills the client right away
+			stopSignals()
+			log.Println("🛑 Interrupted, cleaning up the runner...")
+			if err := handle.Cleanup(); err != nil {
+				log.Printf("⚠️  Cleanup failed: %v", err)
+			}
+			os.Exit(130)
+		}
+		if keepAlive && exitCode != 0 {
 			log.Println("🔒 Container kept alive for debugging")
 			log.Printf("   URL: %s", handle.URL())
-			return
+			if execMode == "docker" {
+				log.Printf("   Remove it with: kube-parcel stop %s", handle.Name())
+			} else {
+				namespace, _ := cmd.Flags().GetString("namespace")
+				log.Printf("   Remove it with: kube-parcel stop --exec-mode k8s --namespace %s %s", namespace, handle.Name())
+			}
+		} else {
+			handle.Cleanup()
+		}
+		if exitCode != 0 {
+			os.Exit(exitCode)
 		}
-		handle.Cleanup()
 	}()
 
-	if err := uploadToServer(ctx, handle.URL(), chartDirs, imagePaths); err != nil {
-		log.Fatalf("❌ Upload failed: %v", err)
+	started := time.Now()
+	if fromBundle != "" {
+		err = uploadBundleFile(ctx, handle.URL(), fromBundle, opts)
+	} else {
+		err = uploadToServer(ctx, handle.URL(), bundler, opts)
+	}
+	if interrupted() {
+		return
+	}
+	if err != nil {
+		reportDeadline(ctx, cmd)
+		log.Printf("❌ Upload failed: %v", err)
+		exitCode = uploadExitCode(ctx)
+		return
+	}
+
+	err = client.StreamLogs(ctx, handle.URL(), streamOpts(cmd))
+	if interrupted() {
+		return
+	}
+	reportDeadline(ctx, cmd)
+	printSummary(handle.URL(), err == nil, time.Since(started))
+	saveArtifacts(ctx, cmd, handle.URL())
+	saveJUnitReport(ctx, cmd, handle.URL())
+	if err != nil {
+		exitCode = streamExitCode(err)
+		reportFailure(exitCode)
+	}
+}
+
+// Exit codes of start and upload, so CI can retry infrastructure flakes but not real
+// test failures
+const (
+	exitTestFailure   = 1 // Charts failed to install or their tests failed
+	exitInfraFailure  = 2 // The runner never got to a verdict: launch, K3s, deadline, lost connection
+	exitUploadFailure = 3 // The parcel could not be bundled or sent to the runner
+)
+
+// streamExitCode maps a StreamLogs error to the exit code
+func streamExitCode(err error) int {
+	switch {
+	case errors.Is(err, client.ErrTestsFailed):
+		return exitTestFailure
+	case errors.Is(err, client.ErrParcelRejected):
+		return exitUploadFailure
 	}
+	return exitInfraFailure
+}
+
+// uploadExitCode is the exit code of a failed upload; running out of --deadline is a
+// timeout rather than an upload error
+func uploadExitCode(ctx context.Context) int {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return exitInfraFailure
+	}
+	return exitUploadFailure
+}
 
-	if err := client.StreamLogs(ctx, handle.URL()); err != nil {
-		testFailed = true
+// reportFailure logs why the run failed
+func reportFailure(code int) {
+	switch code {
+	case exitTestFailure:
 		log.Printf("❌ Tests failed")
-		os.Exit(1)
+	case exitUploadFailure:
+		log.Printf("❌ The runner could not unpack the parcel (exit code %d)", code)
+	default:
+		log.Printf("❌ Run failed before the tests could finish (infrastructure error, exit code %d)", code)
+	}
+}
+
+// withDeadline bounds ctx by --deadline, if set
+func withDeadline(ctx context.Context, cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	deadline, _ := cmd.Flags().GetDuration("deadline")
+	if deadline <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, deadline)
+}
+
+// reportDeadline logs that the run was cut short by --deadline
+func reportDeadline(ctx context.Context, cmd *cobra.Command) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		deadline, _ := cmd.Flags().GetDuration("deadline")
+		log.Printf("⏰ Run exceeded --deadline %s, aborting", deadline)
 	}
 }
 
 func runUpload(cmd *cobra.Command, args []string) {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
+	ctx, cancelDeadline := withDeadline(ctx, cmd)
+	defer cancelDeadline()
 
-	serverURL, _ := cmd.Flags().GetString("server")
+	serverURL := serverFlag(cmd)
+	bundlePath, _ := cmd.Flags().GetString("bundle")
+	token, _ := cmd.Flags().GetString("token")
+	client.SetToken(token)
+	insecure, _ := cmd.Flags().GetBool("insecure-skip-verify")
+	client.SetInsecureSkipVerify(insecure)
+
+	waitIdle, _ := cmd.Flags().GetBool("wait-for-idle")
 
-	if err := uploadToServer(ctx, serverURL, args, nil); err != nil {
-		log.Fatalf("❌ Upload failed: %v", err)
+	started := time.Now()
+	opts := uploadOpts(cmd)
+	var upload func() error
+	if bundlePath != "" {
+		if cmd.Flags().Changed("set") || cmd.Flags().Changed("values") || cmd.Flags().Changed("release-name") || cmd.Flags().Changed("chart-order") || cmd.Flags().Changed("manifests") {
+			log.Fatalf("❌ --set/--values/--release-name/--chart-order/--manifests cannot be combined with --bundle (pass them to 'kube-parcel bundle')")
+		}
+		upload = func() error {
+			return uploadBundleFile(ctx, serverURL, bundlePath, opts)
+		}
+	} else {
+		chartDirs, cleanupCharts := resolveCharts(ctx, args, "")
+		defer cleanupCharts()
+		overrides := chartOverrides(cmd, chartDirs)
+		order := chartOrder(cmd, chartDirs)
+		manifests := manifestsFlag(cmd)
+		upload = func() error {
+			bundler := client.NewBundler(chartDirs, nil)
+			bundler.Overrides = overrides
+			bundler.ChartOrder = order
+			bundler.Manifests = manifests
+			return uploadToServer(ctx, serverURL, bundler, opts)
+		}
+	}
+	var err error
+	for {
+		err = upload()
+		var busy *busyError
+		if !errors.As(err, &busy) {
+			break
+		}
+		if !waitIdle {
+			log.Printf("❌ Upload rejected, %v. Wait for the current run to finish, or pass --wait-for-idle", busy)
+			os.Exit(exitUploadFailure)
+		}
+		if err = waitForIdle(ctx, serverURL, opts.Upgrade, busy.retryAfter); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		reportDeadline(ctx, cmd)
+		log.Printf("❌ Upload failed: %v", err)
+		os.Exit(uploadExitCode(ctx))
 	}
 
-	if err := client.StreamLogs(ctx, serverURL); err != nil {
-		log.Printf("❌ Tests failed")
-		os.Exit(1)
+	err = client.StreamLogs(ctx, serverURL, streamOpts(cmd))
+	reportDeadline(ctx, cmd)
+	printSummary(serverURL, err == nil, time.Since(started))
+	saveArtifacts(ctx, cmd, serverURL)
+	saveJUnitReport(ctx, cmd, serverURL)
+	if err != nil {
+		code := streamExitCode(err)
+		reportFailure(code)
+		os.Exit(code)
 	}
 }
 
-func runStatus(cmd *cobra.Command, args []string) {
+// serverFlag returns --server, or the most recent session's URL when it wasn't set on the
+// command line, in the config file or in the environment
+func serverFlag(cmd *cobra.Command) string {
 	serverURL, _ := cmd.Flags().GetString("server")
+	if cmd.Flags().Changed("server") || viper.IsSet("server") {
+		return serverURL
+	}
+	if session, ok := client.LatestSession(); ok {
+		log.Printf("🔗 Using session %s (%s)", session.Name, session.URL)
+		return session.URL
+	}
+	return serverURL
+}
+
+func runBundle(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	output, _ := cmd.Flags().GetString("output")
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+	imagePaths, _ := cmd.Flags().GetStringSlice("load-images")
+	reproducible, _ := cmd.Flags().GetBool("reproducible")
 
-	resp, err := http.Get(serverURL + "/parcel/status")
+	if outputDir != "" && cmd.Flags().Changed("output") {
+		log.Fatalf("❌ --output and --output-dir are mutually exclusive")
+	}
+	if err := client.ValidateImageSpecs(imagePaths); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	tempDir, _ := cmd.Flags().GetString("temp-dir")
+	chartDirs, cleanupCharts := resolveCharts(ctx, args, tempDir)
+	defer cleanupCharts()
+	bundler := client.NewBundler(chartDirs, imagePaths)
+	bundler.Reproducible = reproducible
+	bundler.Overrides = chartOverrides(cmd, chartDirs)
+	bundler.ChartOrder = chartOrder(cmd, chartDirs)
+	bundler.Manifests = manifestsFlag(cmd)
+	bundler.TempDir = tempDir
+	bundler.StrictImages, _ = cmd.Flags().GetBool("strict-images")
+	bundler.Platform = platformFlag(cmd)
+	if bundler.StrictImages {
+		if err := bundler.Validate(ctx); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+
+	// With --output-dir the bundle is written to a temp file and renamed after its digest
+	var f *os.File
+	var err error
+	if outputDir != "" {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			log.Fatalf("❌ Failed to create output directory: %v", err)
+		}
+		f, err = os.CreateTemp(outputDir, ".parcel-*.tar")
+	} else {
+		f, err = os.Create(output)
+	}
 	if err != nil {
-		log.Fatalf("❌ Failed to fetch status: %v", err)
+		log.Fatalf("❌ Failed to create bundle file: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("❌ Server returned error: %d", resp.StatusCode)
+	hash := sha256.New()
+	if err := bundler.Bundle(ctx, io.MultiWriter(f, hash)); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		log.Fatalf("❌ Bundling failed: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("❌ Failed to write bundle file: %v", err)
 	}
 
-	var status shared.StatusResponse
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
-		log.Fatalf("❌ Failed to decode status: %v", err)
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if outputDir != "" {
+		output = filepath.Join(outputDir, "parcel-"+digest[:12]+".tar")
+		if err := os.Rename(f.Name(), output); err != nil {
+			os.Remove(f.Name())
+			log.Fatalf("❌ Failed to write bundle file: %v", err)
+		}
 	}
 
-	fmt.Printf("🌐 Server State: %s (Uptime: %ds)\n", status.State, status.Uptime)
-	fmt.Printf("☸️ Cluster Status: %s (K3s Ready: %v)\n", status.ClusterStatus, status.K3sReady)
-	fmt.Printf("📦 Content: %d Images, %d Charts\n", status.ImagesCount, status.ChartsCount)
+	if info, err := os.Stat(output); err == nil {
+		fmt.Printf("✅ Bundle written to %s (%d bytes, sha256:%s)\n", output, info.Size(), digest)
+	}
+}
 
-	if len(status.Charts) > 0 {
-		fmt.Println("\n🪖 Helm Charts:")
-		for name, chart := range status.Charts {
-			icon := "⏳"
-			switch chart.Phase {
-			case "Succeeded":
-				icon = "🎉"
-			case "Failed":
-				icon = "❌"
-			case "Deployed":
-				icon = "✅"
-			case "Testing":
-				icon = "🧪"
+// addChartFlags registers the per-chart override flags shared by start, upload and bundle
+func addChartFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("set", nil, "Set a helm value (key=value); prefix with 'chart:' to target one chart (repeatable)")
+	cmd.Flags().StringArrayP("values", "f", nil, "Helm values file bundled with the charts; prefix with 'chart:' to target one chart (repeatable)")
+	cmd.Flags().StringArray("release-name", nil, "Helm release name for a chart directory (dir=name, repeatable)")
+	cmd.Flags().StringArray("skip-tests", nil, "Install without running helm test: alone for every chart, or --skip-tests=chart for one (repeatable)")
+	cmd.Flags().Lookup("skip-tests").NoOptDefVal = "*"
+	cmd.Flags().StringArray("test-filter", nil, "helm test --filter expression (name=smoke, !name=soak); prefix with 'chart:' to target one chart (repeatable)")
+	cmd.Flags().StringSlice("chart-order", nil, "Install these charts (directory names, comma-separated) first, in this order; the rest follow in directory order")
+	cmd.Flags().StringArray("manifests", nil, "Kubernetes manifest file or directory (secrets, namespaces, RBAC) kubectl-applied before charts install (repeatable, applied in order)")
+}
+
+// platformFlag reads --platform, exiting on an invalid value
+func platformFlag(cmd *cobra.Command) string {
+	platform, _ := cmd.Flags().GetString("platform")
+	if platform == "" {
+		return ""
+	}
+	if err := client.ValidatePlatform(platform); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	return platform
+}
+
+// resolveCharts pulls oci:// chart references into temp dirs, exiting on failure. The
+// returned func removes the pulled charts.
+func resolveCharts(ctx context.Context, charts []string, tempDir string) ([]string, func()) {
+	chartDirs, cleanup, err := client.ResolveCharts(ctx, charts, tempDir)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	return chartDirs, cleanup
+}
+
+// chartOverrides parses the chart override flags for the given chart dirs, exiting on invalid input
+func chartOverrides(cmd *cobra.Command, chartDirs []string) map[string]client.ChartOverrides {
+	sets, _ := cmd.Flags().GetStringArray("set")
+	values, _ := cmd.Flags().GetStringArray("values")
+	releaseNames, _ := cmd.Flags().GetStringArray("release-name")
+	skipTests, _ := cmd.Flags().GetStringArray("skip-tests")
+	testFilters, _ := cmd.Flags().GetStringArray("test-filter")
+	overrides, err := client.ParseChartOverrides(chartDirs, sets, values, releaseNames, skipTests, testFilters)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	return overrides
+}
+
+// chartOrder reads --chart-order, exiting if it names a chart that isn't being bundled
+func chartOrder(cmd *cobra.Command, chartDirs []string) []string {
+	order, _ := cmd.Flags().GetStringSlice("chart-order")
+	if err := client.ValidateChartOrder(chartDirs, order); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	return order
+}
+
+// manifestsFlag reads --manifests, exiting if a path isn't a manifest file or directory
+func manifestsFlag(cmd *cobra.Command) []string {
+	manifests, _ := cmd.Flags().GetStringArray("manifests")
+	if err := client.ValidateManifests(manifests); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	return manifests
+}
+
+// saveArtifacts downloads run artifacts when --artifacts-out is set
+func saveArtifacts(ctx context.Context, cmd *cobra.Command, serverURL string) {
+	dir, _ := cmd.Flags().GetString("artifacts-out")
+	if dir == "" {
+		return
+	}
+	if err := client.DownloadArtifacts(ctx, serverURL, dir); err != nil {
+		log.Printf("⚠️ Failed to download artifacts: %v", err)
+	}
+}
+
+// saveJUnitReport downloads the JUnit XML report when --junit-out is set
+func saveJUnitReport(ctx context.Context, cmd *cobra.Command, serverURL string) {
+	path, _ := cmd.Flags().GetString("junit-out")
+	if path == "" {
+		return
+	}
+	if err := client.DownloadJUnitReport(ctx, serverURL, path); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+}
+
+// checkChartImages warns about images the charts' values reference that --load-images
+// doesn't provide, and with autoImages adds them to the bundle as remote:// pulls
+func checkChartImages(bundler *client.Bundler, autoImages, airgap bool) {
+	missing, err := bundler.MissingImages()
+	if err != nil {
+		log.Printf("⚠️  Could not discover chart images: %v", err)
+		return
+	}
+
+	pulled := make(map[string]bool)
+	for _, chartDir := range slices.Sorted(maps.Keys(missing)) {
+		images := missing[chartDir]
+		if autoImages {
+			// There's no telling which tag to pull for an image whose tag is unknown
+			unknown := slices.DeleteFunc(slices.Clone(images), func(image string) bool { return !client.HasUnknownTag(image) })
+			images = slices.DeleteFunc(images, client.HasUnknownTag)
+			if len(unknown) > 0 {
+				log.Printf("⚠️  Chart %s references images without a tag or appVersion, not pulling (bundle them with --load-images or set --image-tag-fallback): %s", chartDir, strings.Join(unknown, ", "))
 			}
-			fmt.Printf("  %s %-15s [%s] %s\n", icon, name, chart.Phase, chart.Message)
+			if len(images) == 0 {
+				continue
+			}
+			log.Printf("🔎 Chart %s references %d unbundled image(s), pulling: %s", chartDir, len(images), strings.Join(images, ", "))
+			for _, image := range images {
+				if !pulled[image] {
+					pulled[image] = true
+					bundler.AddImage(client.PrefixRemote + image)
+				}
+			}
+			continue
 		}
+		consequence := ""
+		if airgap {
+			consequence = " (airgap mode: these will fail to pull; bundle them with --load-images or pass --auto-images)"
+		}
+		log.Printf("⚠️  Chart %s references images not in --load-images%s: %s", chartDir, consequence, strings.Join(images, ", "))
 	}
 }
 
-func uploadToServer(ctx context.Context, serverURL string, chartDirs []string, imagePaths []string) error {
+// streamOpts reads the log streaming flags
+func streamOpts(cmd *cobra.Command) client.StreamOptions {
+	logFile, _ := cmd.Flags().GetString("log-file")
+	minLevel, _ := cmd.Flags().GetString("min-level")
+	reconnects, _ := cmd.Flags().GetInt("reconnect-attempts")
+	return client.StreamOptions{LogFile: logFile, MinLevel: minLevel, Reconnects: reconnects}
+}
+
+// uploadOptions is run metadata sent to the runner with the parcel
+type uploadOptions struct {
+	PauseOn  string // Where the runner pauses for inspection ("" = never)
+	Checksum string // Hex SHA-256 of the body if known up front ("" = computed while streaming)
+	Compress bool   // Gzip the body (Content-Encoding: gzip)
+	Size     int64  // Expected body size for progress reporting (0 = unknown)
+	Upgrade  bool   // Reuse the runner's cluster and upgrade releases in place
+}
+
+// uploadOpts reads the upload metadata flags, exiting on invalid input
+func uploadOpts(cmd *cobra.Command) uploadOptions {
+	pauseOn, _ := cmd.Flags().GetString("pause-on")
+	switch pauseOn {
+	case "", shared.PauseOnInstall, shared.PauseOnTest, shared.PauseOnFailure:
+	default:
+		log.Fatalf("❌ Unknown --pause-on %q (expected %s, %s or %s)", pauseOn, shared.PauseOnInstall, shared.PauseOnTest, shared.PauseOnFailure)
+	}
+	compress, _ := cmd.Flags().GetBool("compress")
+	// Only upload has --upgrade; start always launches a fresh runner
+	upgrade, _ := cmd.Flags().GetBool("upgrade")
+	return uploadOptions{PauseOn: pauseOn, Compress: compress, Upgrade: upgrade}
+}
+
+func uploadToServer(ctx context.Context, serverURL string, bundler *client.Bundler, opts uploadOptions) error {
 	fmt.Printf("📤 Streaming to: %s/parcel/upload\n", serverURL)
+	if size, complete := bundler.EstimateSize(); complete {
+		opts.Size = size
+	}
 
-	bundler := client.NewBundler(chartDirs, imagePaths)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	pr, pw := client.NewPipe()
 
+	done := make(chan struct{})
+	var bundleErr error
 	go func() {
-		defer pw.Close()
-		if err := bundler.Bundle(ctx, pw); err != nil {
-			log.Printf("❌ Bundling error: %v", err)
+		defer close(done)
+		err := bundler.Bundle(ctx, pw)
+		if err != nil && ctx.Err() == nil {
+			bundleErr = err
 		}
+		// A failed bundle must fail the request, not end the body as a truncated parcel
+		pw.CloseWithError(err)
 	}()
 
-	req, err := http.NewRequestWithContext(ctx, "POST", serverURL+"/parcel/upload", pr)
+	err := postParcel(ctx, serverURL, pr, -1, opts)
+	// A rejected or failed request leaves the bundler blocked on a pipe nobody reads
+	cancel()
+	pr.CloseWithError(context.Canceled)
+	<-done
+	if bundleErr != nil {
+		return fmt.Errorf("bundling failed: %w", bundleErr)
+	}
+	return err
+}
+
+// uploadBundleFile uploads a pre-built bundle file as-is
+func uploadBundleFile(ctx context.Context, serverURL, bundlePath string, opts uploadOptions) error {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// A compressed upload is hashed while streaming instead
+	if !opts.Compress {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		opts.Checksum = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	fmt.Printf("📤 Uploading bundle %s (%d bytes) to: %s/parcel/upload\n", bundlePath, info.Size(), serverURL)
+	opts.Size = info.Size()
+	return postParcel(ctx, serverURL, f, info.Size(), opts)
+}
+
+// postParcel POSTs a parcel tar stream to the server's upload endpoint.
+// A negative contentLength sends the body chunked. The runner verifies the SHA-256 sent
+// in a header (opts.Checksum) or, for chunked bodies, in a trailer computed while sending.
+// The checksum covers the bytes sent, so with opts.Compress it is of the gzipped stream.
+func postParcel(ctx context.Context, serverURL string, body io.Reader, contentLength int64, opts uploadOptions) error {
+	body = client.NewProgressReader(body, opts.Size)
+	if opts.Compress {
+		body = gzipStream(body)
+		contentLength = -1
+		opts.Checksum = ""
+	}
+
+	var trailer http.Header
+	if opts.Checksum == "" && contentLength < 0 {
+		trailer = http.Header{shared.HeaderChecksum: nil}
+		body = &checksumReader{r: body, hash: sha256.New(), trailer: trailer}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", serverURL+"/parcel/upload", body)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/x-tar")
+	client.Authorize(req.Header)
+	if opts.Compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if opts.PauseOn != "" {
+		req.Header.Set(shared.HeaderPauseOn, opts.PauseOn)
+	}
+	if opts.Checksum != "" {
+		req.Header.Set(shared.HeaderChecksum, opts.Checksum)
+	}
+	if opts.Upgrade {
+		req.Header.Set(shared.HeaderUpgrade, "true")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		// Sent as time remaining so the runner's clock doesn't need to agree with ours
+		req.Header.Set(shared.HeaderDeadline, max(time.Until(deadline), time.Second).Round(time.Second).String())
+	}
+	req.Trailer = trailer
+	if contentLength >= 0 {
+		req.ContentLength = contentLength
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.HTTPClient().Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusAccepted {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode == http.StatusConflict {
+			return &busyError{message: strings.TrimSpace(string(msg)), retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		}
+		if text := strings.TrimSpace(string(msg)); text != "" {
+			return fmt.Errorf("server returned %d: %s", resp.StatusCode, text)
+		}
 		return fmt.Errorf("server returned %d", resp.StatusCode)
 	}
 
@@ -254,6 +1170,120 @@ func uploadToServer(ctx context.Context, serverURL string, chartDirs []string, i
 	return nil
 }
 
+// busyError is returned when the runner rejects an upload (409) because it is still busy
+// with another parcel
+type busyError struct {
+	message    string        // The runner's reason
+	retryAfter time.Duration // Suggested wait from Retry-After (0 = none)
+}
+
+func (e *busyError) Error() string {
+	if e.message == "" {
+		return "server is busy with another run"
+	}
+	return "server is busy: " + e.message
+}
+
+// parseRetryAfter reads a Retry-After header in seconds (HTTP dates aren't sent by the runner)
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// waitForIdle polls the runner's status until it can take another upload, starting with
+// the wait the runner suggested. Uploads are accepted in IDLE and FAILED; an upgrade is
+// also accepted once the cluster's run has finished.
+func waitForIdle(ctx context.Context, serverURL string, upgrade bool, interval time.Duration) error {
+	if interval <= 0 {
+		interval = idlePollInterval
+	}
+	log.Printf("⏳ Server is busy; waiting for it to become idle (polling every %s)...", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		status, err := fetchStatus(serverURL)
+		if err != nil {
+			log.Printf("⚠️ %v", err)
+			continue
+		}
+		if status.K3sStartFailed {
+			return errors.New("K3s failed to start on the runner and can't be restarted; start a new runner")
+		}
+		if acceptsUpload(status, upgrade) {
+			log.Printf("✅ Server is %s, retrying the upload", status.State)
+			return nil
+		}
+	}
+}
+
+// idlePollInterval is how often waitForIdle polls when the runner suggested no wait
+const idlePollInterval = 5 * time.Second
+
+// acceptsUpload reports whether the runner looks ready for another upload. It can be
+// wrong (e.g. another client got there first); the upload is then rejected and we wait again.
+func acceptsUpload(status *shared.StatusResponse, upgrade bool) bool {
+	switch status.State {
+	case shared.StateIdle.String():
+		return true
+	case shared.StateFailed.String():
+		// A failed run's cluster is only reused by an upgrade, and K3s can't be started twice
+		if status.K3sStartFailed {
+			return false
+		}
+		return upgrade || !status.K3sReady
+	case shared.StateReady.String():
+		if !upgrade {
+			return false
+		}
+		for _, chart := range status.Charts {
+			switch chart.Phase {
+			case "Pending", "Installing", "Testing":
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipStream compresses r on the fly. BestSpeed keeps CPU from becoming the bottleneck,
+// since image layers are mostly compressed already.
+func gzipStream(r io.Reader) io.Reader {
+	pr, pw := client.NewPipe()
+	go func() {
+		gz, _ := gzip.NewWriterLevel(pw, gzip.BestSpeed)
+		_, err := io.Copy(gz, r)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// checksumReader hashes a streamed body and, once it's fully read, records the digest
+// in the request trailer sent after it
+type checksumReader struct {
+	r       io.Reader
+	hash    hash.Hash
+	trailer http.Header
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF {
+		c.trailer.Set(shared.HeaderChecksum, hex.EncodeToString(c.hash.Sum(nil)))
+	}
+	return n, err
+}
+
 func parseMap(s string) map[string]string {
 	if s == "" {
 		return nil
@@ -268,3 +1298,18 @@ func parseMap(s string) map[string]string {
 	}
 	return res
 }
+
+// envVars converts the runner environment map into a stable, sorted pod env list
+func envVars(env map[string]string) []corev1.EnvVar {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	vars := make([]corev1.EnvVar, 0, len(keys))
+	for _, k := range keys {
+		vars = append(vars, corev1.EnvVar{Name: k, Value: env[k]})
+	}
+	return vars
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tiborv/kube-parcel/pkg/client"
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+func runStatus(cmd *cobra.Command, args []string) {
+	serverURL := serverFlag(cmd)
+	token, _ := cmd.Flags().GetString("token")
+	client.SetToken(token)
+	insecure, _ := cmd.Flags().GetBool("insecure-skip-verify")
+	client.SetInsecureSkipVerify(insecure)
+
+	asJSON, _ := cmd.Flags().GetBool("json")
+	diffFile, _ := cmd.Flags().GetString("diff")
+
+	status, err := fetchStatus(serverURL)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	if diffFile != "" {
+		data, err := os.ReadFile(diffFile)
+		if err != nil {
+			log.Fatalf("❌ Failed to read snapshot: %v", err)
+		}
+		var snapshot shared.StatusResponse
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			log.Fatalf("❌ Failed to decode snapshot %s: %v", diffFile, err)
+		}
+
+		changes := diffStatus(&snapshot, status)
+		if len(changes) == 0 {
+			fmt.Println("✅ No differences")
+			return
+		}
+		fmt.Printf("🔍 %d change(s) since %s:\n", len(changes), diffFile)
+		for _, change := range changes {
+			fmt.Println("  " + change)
+		}
+		return
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(status); err != nil {
+			log.Fatalf("❌ Failed to encode status: %v", err)
+		}
+		return
+	}
+
+	if status.State == shared.StateFailed.String() {
+		fmt.Printf("%s❌ Server State: %s (Uptime: %ds)%s\n", colorRed, status.State, status.Uptime, colorReset)
+		if status.FailureReason != "" {
+			fmt.Printf("%s   Reason: %s%s\n", colorRed, status.FailureReason, colorReset)
+		}
+	} else {
+		fmt.Printf("🌐 Server State: %s (Uptime: %ds)\n", status.State, status.Uptime)
+	}
+	fmt.Printf("☸️ Cluster Status: %s (K3s Ready: %v, Nodes: %d)\n", status.ClusterStatus, status.K3sReady, status.Nodes)
+	if status.K3sVersion != "" {
+		fmt.Printf("🏷️ K3s Version: %s\n", status.K3sVersion)
+	}
+	fmt.Printf("📦 Content: %d Images, %d Charts\n", status.ImagesCount, status.ChartsCount)
+	if len(status.ImportErrors) > 0 {
+		fmt.Printf("%s⚠️ Image import errors:%s\n", colorYellow, colorReset)
+		for _, importErr := range status.ImportErrors {
+			fmt.Printf("%s   - %s%s\n", colorYellow, importErr, colorReset)
+		}
+	}
+	if status.LogsDropped > 0 {
+		fmt.Printf("%s⚠️ Log buffer truncated: %d earlier message(s) dropped (raise KUBE_PARCEL_LOG_BUFFER_SIZE)%s\n", colorYellow, status.LogsDropped, colorReset)
+	}
+	if status.Paused != "" {
+		fmt.Printf("⏸️ Paused %s (POST /parcel/continue or /parcel/abort)\n", status.Paused)
+	}
+	fmt.Println(airgapLine(status))
+	switch status.WorkloadHealth {
+	case "Healthy":
+		fmt.Println("💚 Workloads: Healthy")
+	case "Degraded":
+		fmt.Printf("🩹 Workloads: Degraded (%d unhealthy)\n", status.UnhealthyCount)
+		for _, r := range status.ClusterResources {
+			if r.Reason != "" && !r.IsTest {
+				fmt.Printf("  ❌ %s %s/%s: %s\n", r.Kind, r.Namespace, r.Name, r.Reason)
+			}
+		}
+	}
+
+	if len(status.Charts) > 0 {
+		fmt.Println("\n🪖 Helm Charts:")
+		for _, name := range sortedCharts(status.Charts) {
+			chart := status.Charts[name]
+			fmt.Printf("  %s %-15s [%s] %s%s\n", phaseIcon(chart.Phase), name, chart.Phase, chart.Message, chartTimings(chart))
+		}
+		fmt.Printf("  %s\n", phaseCounts(status.Charts))
+	}
+}
+
+// fetchStatus retrieves and decodes the runner's /parcel/status response
+func fetchStatus(serverURL string) (*shared.StatusResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, serverURL+"/parcel/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	client.Authorize(req.Header)
+	resp, err := client.HTTPClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned error: %d", resp.StatusCode)
+	}
+
+	var status shared.StatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode status: %w", err)
+	}
+	return &status, nil
+}
+
+// phaseIcon returns the icon used to render a chart phase
+func phaseIcon(phase string) string {
+	switch phase {
+	case "Succeeded":
+		return "🎉"
+	case "Skipped":
+		return "⏭️"
+	case "Pending":
+		return "🕒"
+	case "Failed", "LintFailed", "ReadinessFailed":
+		return "❌"
+	case "Deployed":
+		return "✅"
+	case "Testing":
+		return "🧪"
+	}
+	return "⏳"
+}
+
+// airgapLine describes the runner's network isolation: enforced, turned off with
+// --no-airgap, or requested but not (yet) enforced
+func airgapLine(status *shared.StatusResponse) string {
+	switch {
+	case status.AirgapEnforced:
+		return "🔒 Airgap: enforced (egress verified blocked)"
+	case status.AirgapDisabled:
+		return "🌐 Airgap: disabled (--no-airgap)"
+	}
+	return "🌐 Airgap: not enforced"
+}
+
+// seconds converts a duration in seconds, as reported by the runner, for display
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
+
+// chartTimings describes how long a chart took to install and test, or "" before it started
+func chartTimings(chart shared.ChartStatus) string {
+	if chart.InstallDuration == 0 {
+		return ""
+	}
+	timings := fmt.Sprintf(" (install %s", seconds(chart.InstallDuration))
+	if chart.TestDuration > 0 {
+		timings += fmt.Sprintf(", test %s", seconds(chart.TestDuration))
+	}
+	return timings + ")"
+}
+
+// sortedCharts returns chart names in a stable order for display
+func sortedCharts(charts map[string]shared.ChartStatus) []string {
+	names := make([]string, 0, len(charts))
+	for name := range charts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printSummary fetches the final status and prints a per-chart results table plus an
+// overall verdict, so CI logs end with an at-a-glance result
+func printSummary(serverURL string, passed bool, elapsed time.Duration) {
+	status, err := fetchStatus(serverURL)
+	if err != nil {
+		log.Printf("⚠️ Could not fetch results summary: %v", err)
+		return
+	}
+
+	names := sortedCharts(status.Charts)
+	nameWidth, phaseWidth := len("CHART"), len("PHASE")
+	for _, name := range names {
+		nameWidth = max(nameWidth, len(name))
+		phaseWidth = max(phaseWidth, len(status.Charts[name].Phase))
+	}
+
+	fmt.Println()
+	fmt.Printf("%-*s  %-*s  %8s  %8s  %8s  %s\n", nameWidth, "CHART", phaseWidth, "PHASE", "DURATION", "INSTALL", "TEST", "MESSAGE")
+	failed := 0
+	for _, name := range names {
+		chart := status.Charts[name]
+		if chart.Phase == "Failed" || chart.Phase == "LintFailed" || chart.Phase == "ReadinessFailed" {
+			failed++
+		}
+		phase := colorize(fmt.Sprintf("%-*s", phaseWidth, chart.Phase), phaseColor(chart.Phase))
+		fmt.Printf("%-*s  %s  %8s  %8s  %8s  %s\n", nameWidth, name, phase,
+			seconds(chart.Duration), seconds(chart.InstallDuration), seconds(chart.TestDuration), chart.Message)
+	}
+
+	verdict := colorize("PASS", colorGreen)
+	if !passed || failed > 0 {
+		verdict = colorize("FAIL", colorRed)
+	}
+	fmt.Printf("%s\t%d charts: %s\t%s\n", verdict, len(names), phaseCounts(status.Charts), elapsed.Round(time.Second))
+}
+
+// summaryPhases are the phases counted in summary lines, in display order; anything else
+// counts as in progress
+var summaryPhases = []string{"Succeeded", "Failed", "Skipped", "Pending"}
+
+// phaseCounts summarizes chart phases, e.g. "3 succeeded, 1 failed, 2 skipped"
+func phaseCounts(charts map[string]shared.ChartStatus) string {
+	counts := make(map[string]int)
+	inProgress := 0
+	for _, chart := range charts {
+		phase := chart.Phase
+		if phase == "LintFailed" || phase == "ReadinessFailed" { // Counted with install and test failures
+			phase = "Failed"
+		}
+		if slices.Contains(summaryPhases, phase) {
+			counts[phase]++
+		} else {
+			inProgress++
+		}
+	}
+
+	var parts []string
+	for _, phase := range summaryPhases {
+		if counts[phase] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[phase], strings.ToLower(phase)))
+		}
+	}
+	if inProgress > 0 {
+		parts = append(parts, fmt.Sprintf("%d in progress", inProgress))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// phaseColor returns the ANSI color for a terminal phase, or "" for in-progress phases
+func phaseColor(phase string) string {
+	switch phase {
+	case "Succeeded", "Deployed":
+		return colorGreen
+	case "Failed", "LintFailed", "ReadinessFailed":
+		return colorRed
+	case "Skipped":
+		return colorYellow
+	}
+	return ""
+}
+
+// colorize wraps s in the given ANSI color unless NO_COLOR is set
+func colorize(s, color string) string {
+	if color == "" || os.Getenv("NO_COLOR") != "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// diffStatus lists what changed between two status snapshots: server/cluster state,
+// chart phases, and resources that appeared, disappeared or changed status
+func diffStatus(before, after *shared.StatusResponse) []string {
+	var changes []string
+	field := func(name, old, new string) {
+		if old != new {
+			changes = append(changes, fmt.Sprintf("~ %s: %s → %s", name, old, new))
+		}
+	}
+	field("state", before.State, after.State)
+	field("failure reason", before.FailureReason, after.FailureReason)
+	field("cluster", before.ClusterStatus, after.ClusterStatus)
+	field("workloads", before.WorkloadHealth, after.WorkloadHealth)
+
+	for _, name := range sortedCharts(after.Charts) {
+		chart := after.Charts[name]
+		old, ok := before.Charts[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("+ chart %s [%s] %s", name, chart.Phase, chart.Message))
+		case old.Phase != chart.Phase:
+			changes = append(changes, fmt.Sprintf("~ chart %s: %s → %s (%s)", name, old.Phase, chart.Phase, chart.Message))
+		}
+	}
+	for _, name := range sortedCharts(before.Charts) {
+		if _, ok := after.Charts[name]; !ok {
+			changes = append(changes, fmt.Sprintf("- chart %s [%s]", name, before.Charts[name].Phase))
+		}
+	}
+
+	key := func(r shared.KubeResource) string {
+		return r.Kind + " " + r.Namespace + "/" + r.Name
+	}
+	describe := func(r shared.KubeResource) string {
+		if r.Reason != "" {
+			return r.Status + ", " + r.Reason
+		}
+		return r.Status
+	}
+	oldResources := make(map[string]shared.KubeResource, len(before.ClusterResources))
+	for _, r := range before.ClusterResources {
+		oldResources[key(r)] = r
+	}
+	newResources := make(map[string]shared.KubeResource, len(after.ClusterResources))
+	for _, r := range after.ClusterResources {
+		newResources[key(r)] = r
+	}
+
+	var resourceChanges []string
+	for k, r := range newResources {
+		old, ok := oldResources[k]
+		switch {
+		case !ok:
+			resourceChanges = append(resourceChanges, fmt.Sprintf("+ %s [%s]", k, describe(r)))
+		case describe(old) != describe(r):
+			resourceChanges = append(resourceChanges, fmt.Sprintf("~ %s: %s → %s", k, describe(old), describe(r)))
+		}
+	}
+	for k, r := range oldResources {
+		if _, ok := newResources[k]; !ok {
+			resourceChanges = append(resourceChanges, fmt.Sprintf("- %s [%s]", k, describe(r)))
+		}
+	}
+	sort.Slice(resourceChanges, func(i, j int) bool {
+		return resourceChanges[i][2:] < resourceChanges[j][2:]
+	})
+
+	return append(changes, resourceChanges...)
+}
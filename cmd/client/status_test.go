@@ -0,0 +1,104 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func TestDiffStatus(t *testing.T) {
+	before := &shared.StatusResponse{
+		State:          "Ready",
+		ClusterStatus:  "Ready",
+		WorkloadHealth: "Healthy",
+		Charts: map[string]shared.ChartStatus{
+			"api": {Phase: "Testing"},
+			"old": {Phase: "Deployed"},
+		},
+		ClusterResources: []shared.KubeResource{
+			{Kind: "Pod", Namespace: "default", Name: "api-0", Status: "Running"},
+			{Kind: "Pod", Namespace: "default", Name: "gone", Status: "Running"},
+		},
+	}
+	after := &shared.StatusResponse{
+		State:          "Ready",
+		ClusterStatus:  "Ready",
+		WorkloadHealth: "Degraded",
+		Charts: map[string]shared.ChartStatus{
+			"api": {Phase: "Failed", Message: "Tests failed"},
+			"new": {Phase: "Installing", Message: "Helm install started"},
+		},
+		ClusterResources: []shared.KubeResource{
+			{Kind: "Pod", Namespace: "default", Name: "api-0", Status: "Running", Reason: "CrashLoopBackOff"},
+			{Kind: "Job", Namespace: "default", Name: "migrate", Status: "Succeeded"},
+		},
+	}
+
+	want := []string{
+		"~ workloads: Healthy → Degraded",
+		"~ chart api: Testing → Failed (Tests failed)",
+		"+ chart new [Installing] Helm install started",
+		"- chart old [Deployed]",
+		"+ Job default/migrate [Succeeded]",
+		"~ Pod default/api-0: Running → Running, CrashLoopBackOff",
+		"- Pod default/gone [Running]",
+	}
+	if got := diffStatus(before, after); !slices.Equal(got, want) {
+		t.Errorf("diffStatus() =\n%v\nwant\n%v", got, want)
+	}
+
+	if got := diffStatus(after, after); len(got) != 0 {
+		t.Errorf("expected no differences for identical snapshots, got %v", got)
+	}
+}
+
+func TestPhaseCounts(t *testing.T) {
+	charts := map[string]shared.ChartStatus{
+		"a": {Phase: "Succeeded"}, "b": {Phase: "Succeeded"}, "c": {Phase: "Failed"},
+		"d": {Phase: "Skipped"}, "e": {Phase: "Pending"}, "f": {Phase: "Testing"}, "g": {Phase: "LintFailed"},
+		"h": {Phase: "ReadinessFailed"}, "i": {Phase: "WaitingReadiness"},
+	}
+	if got, want := phaseCounts(charts), "2 succeeded, 3 failed, 1 skipped, 1 pending, 2 in progress"; got != want {
+		t.Errorf("phaseCounts() = %q, want %q", got, want)
+	}
+	if got := phaseCounts(nil); got != "none" {
+		t.Errorf("phaseCounts(nil) = %q, want none", got)
+	}
+}
+
+func TestChartTimings(t *testing.T) {
+	tests := []struct {
+		name  string
+		chart shared.ChartStatus
+		want  string
+	}{
+		{"not started", shared.ChartStatus{Phase: "Pending"}, ""},
+		{"installing", shared.ChartStatus{Phase: "Installing", InstallDuration: 12.4}, " (install 12s)"},
+		{"tested", shared.ChartStatus{Phase: "Succeeded", InstallDuration: 65, TestDuration: 9.6}, " (install 1m5s, test 10s)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chartTimings(tt.chart); got != tt.want {
+				t.Errorf("chartTimings() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAirgapLine(t *testing.T) {
+	tests := []struct {
+		status shared.StatusResponse
+		want   string
+	}{
+		{shared.StatusResponse{AirgapEnforced: true}, "🔒 Airgap: enforced (egress verified blocked)"},
+		{shared.StatusResponse{AirgapDisabled: true}, "🌐 Airgap: disabled (--no-airgap)"},
+		{shared.StatusResponse{}, "🌐 Airgap: not enforced"},
+	}
+	for _, tt := range tests {
+		if got := airgapLine(&tt.status); got != tt.want {
+			t.Errorf("airgapLine(%+v) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/tiborv/kube-parcel/pkg/client"
+)
+
+func runStop(cmd *cobra.Command, args []string) {
+	execMode, _ := cmd.Flags().GetString("exec-mode")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	all, _ := cmd.Flags().GetBool("all")
+
+	if all && len(args) > 0 {
+		log.Fatalf("❌ --all cannot be combined with runner names")
+	}
+	if !all && len(args) == 0 {
+		session, ok := client.LatestSession()
+		if !ok {
+			log.Fatalf("❌ No recorded session; pass a runner name or --all")
+		}
+		log.Printf("🔗 Using session %s", session.Name)
+		args = []string{session.Name}
+		if !cmd.Flags().Changed("exec-mode") {
+			execMode = session.ExecMode
+		}
+		if !cmd.Flags().Changed("namespace") && session.Namespace != "" {
+			namespace = session.Namespace
+		}
+	}
+
+	if execMode != "docker" && execMode != "k8s" {
+		log.Fatalf("❌ Unknown --exec-mode %q (expected docker or k8s)", execMode)
+	}
+
+	removed, err := client.Stop(context.Background(), client.StopSettings{
+		ExecMode:  execMode,
+		Namespace: namespace,
+		Names:     args,
+	})
+	for _, name := range removed {
+		fmt.Printf("🗑️  Removed %s\n", name)
+	}
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if len(removed) == 0 {
+		fmt.Println("✅ Nothing to stop")
+		return
+	}
+	fmt.Printf("✅ Stopped %d runner(s)\n", len(removed))
+}
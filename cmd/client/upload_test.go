@@ -0,0 +1,207 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiborv/kube-parcel/pkg/client"
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func TestPostParcel_Checksum(t *testing.T) {
+	const payload = "parcel contents"
+	sum := sha256.Sum256([]byte(payload))
+	want := hex.EncodeToString(sum[:])
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		got = r.Header.Get(shared.HeaderChecksum)
+		if got == "" {
+			got = r.Trailer.Get(shared.HeaderChecksum)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	// Streamed bodies carry the digest in a trailer
+	if err := postParcel(context.Background(), srv.URL, strings.NewReader(payload), -1, uploadOptions{}); err != nil {
+		t.Fatalf("postParcel failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("streamed checksum = %q, want %q", got, want)
+	}
+
+	got = ""
+	err := postParcel(context.Background(), srv.URL, strings.NewReader(payload), int64(len(payload)), uploadOptions{Checksum: want})
+	if err != nil {
+		t.Fatalf("postParcel failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("header checksum = %q, want %q", got, want)
+	}
+}
+
+func TestPostParcel_Compress(t *testing.T) {
+	const payload = "parcel contents"
+
+	var body, checksum string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Content-Encoding = %q, want gzip", r.Header.Get("Content-Encoding"))
+		}
+		hash := sha256.New()
+		gz, err := gzip.NewReader(io.TeeReader(r.Body, hash))
+		if err != nil {
+			t.Errorf("body is not gzip: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(gz)
+		io.Copy(io.Discard, r.Body)
+		body = string(data)
+		if r.Trailer.Get(shared.HeaderChecksum) == hex.EncodeToString(hash.Sum(nil)) {
+			checksum = "ok"
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	opts := uploadOptions{Compress: true, Checksum: "ignored for compressed uploads"}
+	if err := postParcel(context.Background(), srv.URL, strings.NewReader(payload), int64(len(payload)), opts); err != nil {
+		t.Fatalf("postParcel failed: %v", err)
+	}
+	if body != payload {
+		t.Errorf("decompressed body = %q, want %q", body, payload)
+	}
+	if checksum != "ok" {
+		t.Error("trailer checksum doesn't match the compressed bytes sent")
+	}
+}
+
+func TestUploadToServer_Busy(t *testing.T) {
+	// A chart larger than the HTTP client buffers, so bundling outlasts the response
+	chart := filepath.Join(t.TempDir(), "app")
+	os.MkdirAll(filepath.Join(chart, "templates"), 0755)
+	os.WriteFile(filepath.Join(chart, "Chart.yaml"), []byte("apiVersion: v2\nname: app\nversion: 0.1.0\n"), 0644)
+	os.WriteFile(filepath.Join(chart, "templates", "big.yaml"), make([]byte, 16<<20), 0644)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "Server not in IDLE state", http.StatusConflict)
+	}))
+	defer srv.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- uploadToServer(context.Background(), srv.URL, client.NewBundler([]string{chart}, nil), uploadOptions{})
+	}()
+
+	select {
+	case err := <-done:
+		var busy *busyError
+		if !errors.As(err, &busy) {
+			t.Fatalf("uploadToServer() = %v, want a busyError", err)
+		}
+		if busy.retryAfter != 10*time.Second {
+			t.Errorf("retryAfter = %s, want 10s", busy.retryAfter)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("uploadToServer() hung on the bundler after a rejected upload")
+	}
+}
+
+func TestAcceptsUpload(t *testing.T) {
+	running := map[string]shared.ChartStatus{"web": {Phase: "Testing"}}
+	finished := map[string]shared.ChartStatus{"web": {Phase: "Succeeded"}}
+
+	tests := []struct {
+		state    shared.State
+		charts   map[string]shared.ChartStatus
+		k3sReady bool
+		k3sError bool
+		upgrade  bool
+		want     bool
+	}{
+		{shared.StateIdle, nil, false, false, false, true},
+		{shared.StateFailed, finished, false, false, false, true},
+		{shared.StateFailed, finished, true, false, false, false},
+		{shared.StateFailed, finished, true, false, true, true},
+		{shared.StateFailed, nil, false, true, false, false},
+		{shared.StateFailed, nil, false, true, true, false},
+		{shared.StateTransferring, nil, false, false, true, false},
+		{shared.StateReady, finished, true, false, false, false},
+		{shared.StateReady, running, true, false, true, false},
+		{shared.StateReady, finished, true, false, true, true},
+	}
+	for _, tt := range tests {
+		status := &shared.StatusResponse{State: tt.state.String(), Charts: tt.charts, K3sReady: tt.k3sReady, K3sStartFailed: tt.k3sError}
+		if got := acceptsUpload(status, tt.upgrade); got != tt.want {
+			t.Errorf("acceptsUpload(%s, %v, upgrade=%v) = %v, want %v", tt.state, tt.charts, tt.upgrade, got, tt.want)
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	if code := streamExitCode(client.ErrTestsFailed); code != exitTestFailure {
+		t.Errorf("streamExitCode(tests failed) = %d, want %d", code, exitTestFailure)
+	}
+	rejected := fmt.Errorf("%w: Extraction failed: unexpected EOF", client.ErrParcelRejected)
+	if code := streamExitCode(rejected); code != exitUploadFailure {
+		t.Errorf("streamExitCode(parcel rejected) = %d, want %d", code, exitUploadFailure)
+	}
+	lost := fmt.Errorf("%w: runner connection lost", client.ErrInfrastructure)
+	if code := streamExitCode(lost); code != exitInfraFailure {
+		t.Errorf("streamExitCode(connection lost) = %d, want %d", code, exitInfraFailure)
+	}
+
+	if code := uploadExitCode(context.Background()); code != exitUploadFailure {
+		t.Errorf("uploadExitCode() = %d, want %d", code, exitUploadFailure)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	<-ctx.Done()
+	if code := uploadExitCode(ctx); code != exitInfraFailure {
+		t.Errorf("uploadExitCode() past the deadline = %d, want %d", code, exitInfraFailure)
+	}
+}
+
+func TestUploadToServer_BundleError(t *testing.T) {
+	chart := filepath.Join(t.TempDir(), "app")
+	os.MkdirAll(chart, 0755)
+	os.WriteFile(filepath.Join(chart, "Chart.yaml"), []byte("apiVersion: v2\nname: app\nversion: 0.1.0\n"), 0644)
+
+	bodyErr := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.Copy(io.Discard, r.Body)
+		bodyErr <- err
+	}))
+	defer srv.Close()
+
+	bundler := client.NewBundler([]string{chart}, nil)
+	bundler.Manifests = []string{filepath.Join(t.TempDir(), "missing.yaml")}
+	err := uploadToServer(context.Background(), srv.URL, bundler, uploadOptions{})
+	if err == nil || !strings.Contains(err.Error(), "bundling failed") {
+		t.Fatalf("uploadToServer() = %v, want the bundling error", err)
+	}
+
+	select {
+	case err := <-bodyErr:
+		if err == nil {
+			t.Error("expected the server to see a broken body, not a complete parcel")
+		}
+	case <-time.After(5 * time.Second):
+		// The client aborted the request before the handler ran
+	}
+}
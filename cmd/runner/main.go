@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	_ "embed"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,11 +19,27 @@ import (
 var indexHTML string
 
 func main() {
-	log.Printf("🚀 kube-parcel runner v%s starting...", config.Version)
-	log.Printf("PID: %d", os.Getpid())
+	runner.SetupLogging()
+	slog.Info("kube-parcel runner starting", "version", config.Version, "pid", os.Getpid())
+
+	// Agent containers only join the server's cluster; they don't serve uploads
+	if serverURL := os.Getenv("KUBE_PARCEL_K3S_SERVER"); serverURL != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+		if err := runner.RunAgent(ctx, serverURL); err != nil {
+			slog.Error("Agent failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	srv := runner.NewServer()
 
+	if err := srv.RunPreStartHook(context.Background()); err != nil {
+		slog.Error("Pre-start hook failed, refusing to start", "error", err)
+		os.Exit(1)
+	}
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -30,34 +47,82 @@ func main() {
 		w.Write([]byte(indexHTML))
 	})
 
-	mux.HandleFunc("/parcel/upload", srv.HandleUpload)
-	mux.HandleFunc("/parcel/status", srv.HandleStatus)
-	mux.HandleFunc("/ws/logs", srv.HandleWebSocket)
+	mux.HandleFunc("/parcel/upload", srv.RequireToken(srv.HandleUpload))
+	mux.HandleFunc("/parcel/status", srv.RequireToken(srv.HandleStatus))
+	mux.HandleFunc("/healthz", srv.HandleHealthz)
+	mux.HandleFunc("/readyz", srv.HandleReadyz)
+	mux.HandleFunc("/parcel/artifacts/", srv.RequireToken(srv.HandleArtifacts))
+	mux.HandleFunc("/parcel/report", srv.RequireToken(srv.HandleReport))
+	mux.HandleFunc("/parcel/continue", srv.RequireToken(srv.HandleContinue))
+	mux.HandleFunc("/parcel/abort", srv.RequireToken(srv.HandleAbort))
+	mux.HandleFunc("/parcel/logs", srv.RequireToken(srv.HandleLogs))
+	mux.HandleFunc("/parcel/chart/", srv.RequireToken(srv.HandleChartLogs))
+	mux.HandleFunc("/ws/logs", srv.RequireToken(srv.HandleWebSocket))
+
+	tlsConfig, err := srv.TLSConfig()
+	if err != nil {
+		slog.Error("TLS setup failed", "error", err)
+		os.Exit(1)
+	}
 
 	httpServer := &http.Server{
-		Addr:    ":8080",
+		Addr:    fmt.Sprintf(":%d", srv.HTTPPort()),
 		Handler: mux,
 	}
+	if tlsConfig != nil {
+		httpServer.Addr = fmt.Sprintf(":%d", config.DefaultHTTPSPort)
+		httpServer.TLSConfig = tlsConfig
+	}
 
 	go func() {
-		log.Println("🌐 HTTP server listening on :8080")
-		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("HTTP server failed: %v", err)
+		var err error
+		if tlsConfig != nil {
+			slog.Info("HTTPS server listening", "addr", httpServer.Addr)
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			slog.Info("HTTP server listening", "addr", httpServer.Addr)
+			err = httpServer.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			slog.Error("HTTP server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Metrics get their own port so a scraper (e.g. a sidecar) needs no access to uploads
+	metricsMux := http.NewServeMux()
+	metricsMux.HandleFunc("/metrics", srv.HandleMetrics)
+	metricsServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", config.DefaultMetricsPort),
+		Handler: metricsMux,
+	}
+
+	go func() {
+		slog.Info("Metrics server listening", "addr", metricsServer.Addr)
+		if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+			slog.Warn("Metrics server failed", "error", err)
 		}
 	}()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 
-	sig := <-sigChan
-	log.Printf("Received signal: %s, initiating shutdown...", sig)
+	select {
+	case sig := <-sigChan:
+		slog.Info("Received signal, initiating shutdown", "signal", sig.String())
+	case <-srv.IdleShutdown():
+		slog.Info("Idle timeout reached, initiating shutdown")
+	case <-srv.TTLShutdown():
+		slog.Info("TTL reached, initiating shutdown")
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Printf("HTTP shutdown error: %v", err)
+		slog.Error("HTTP shutdown error", "error", err)
 	}
+	metricsServer.Shutdown(ctx)
 
-	log.Println("👋 Shutdown complete")
+	slog.Info("Shutdown complete")
 }
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+// DownloadArtifacts fetches results.json and every artifact it references from the
+// runner's /parcel/artifacts/ endpoint into dir, preserving relative paths
+func DownloadArtifacts(ctx context.Context, serverURL, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	resultsPath := filepath.Join(dir, "results.json")
+	if err := downloadArtifact(ctx, serverURL, "results.json", resultsPath); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(resultsPath)
+	if err != nil {
+		return err
+	}
+	var results shared.RunResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return fmt.Errorf("failed to decode results: %w", err)
+	}
+
+	count := 0
+	for chart, status := range results.Charts {
+		for _, rel := range status.Artifacts {
+			dest := filepath.Join(dir, filepath.FromSlash(rel))
+			if !strings.HasPrefix(dest, filepath.Clean(dir)+string(os.PathSeparator)) {
+				log.Printf("⚠️ Skipping artifact with unsafe path: %s", rel)
+				continue
+			}
+			if err := downloadArtifact(ctx, serverURL, rel, dest); err != nil {
+				log.Printf("⚠️ Failed to download artifact %s for %s: %v", rel, chart, err)
+				continue
+			}
+			count++
+		}
+	}
+
+	log.Printf("📁 Saved results and %d artifacts to %s", count, dir)
+	return nil
+}
+
+// DownloadJUnitReport saves the runner's JUnit XML report (/parcel/report?format=junit) to dest
+func DownloadJUnitReport(ctx context.Context, serverURL, dest string) error {
+	if err := download(ctx, serverURL+"/parcel/report?format=junit", dest); err != nil {
+		return fmt.Errorf("failed to fetch JUnit report: %w", err)
+	}
+	log.Printf("📝 Saved JUnit report to %s", dest)
+	return nil
+}
+
+// downloadArtifact saves a single artifact to dest
+func downloadArtifact(ctx context.Context, serverURL, rel, dest string) error {
+	if err := download(ctx, serverURL+"/parcel/artifacts/"+rel, dest); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", rel, err)
+	}
+	return nil
+}
+
+// download saves the body of a GET request to dest, creating parent directories
+func download(ctx context.Context, url, dest string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	Authorize(req.Header)
+	resp, err := runnerClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, resp.Body)
+	return err
+}
@@ -0,0 +1,19 @@
+package client
+
+import "net/http"
+
+// token is sent as a bearer token on every request to the runner ("" = none)
+var token string
+
+// SetToken sets the bearer token for runners started with KUBE_PARCEL_TOKEN. It applies
+// to every later request this package makes, and to those prepared with Authorize.
+func SetToken(t string) {
+	token = t
+}
+
+// Authorize adds the bearer token, if one is set, to the headers of a runner request
+func Authorize(header http.Header) {
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+}
@@ -3,15 +3,23 @@ package client
 import (
 	"archive/tar"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/tiborv/kube-parcel/pkg/shared"
 	"gopkg.in/yaml.v3"
 )
 
@@ -27,6 +35,16 @@ const (
 type Bundler struct {
 	chartDirs  []string
 	imagePaths []string // Paths with prefixes: oci://, tar://, remote://
+	binaries   []string // Local executables shipped under bin/
+
+	Reproducible bool                      // Normalize tar headers so identical inputs produce byte-identical bundles
+	Overrides    map[string]ChartOverrides // Values overrides by chart name ("" applies to all charts)
+	TempDir      string                    // Where intermediate image tars are written ("" = system temp dir)
+	StrictImages bool                      // Fail on the first image that can't be added instead of skipping it
+	TagFallback  string                    // Tag assumed for chart images without a tag or chart appVersion ("" = unknown)
+	Platform     string                    // Platform pulled for remote:// images, e.g. linux/arm64 ("" = linux/amd64)
+	ChartOrder   []string                  // Chart directory names installed first, in this order (nil = directory order)
+	Manifests    []string                  // Kubernetes manifest files or directories applied before charts install, in this order
 }
 
 // NewBundler creates a new bundler for charts and images
@@ -37,86 +55,220 @@ func NewBundler(chartDirs []string, imagePaths []string) *Bundler {
 	}
 }
 
+// writeHeader writes a tar header, normalizing it first in reproducible mode
+func (b *Bundler) writeHeader(tw *tar.Writer, header *tar.Header) error {
+	if b.Reproducible {
+		normalizeHeader(header)
+	}
+	return tw.WriteHeader(header)
+}
+
+// normalizeHeader strips host-specific metadata (times, owners) from a tar header,
+// keeping only the name, type, size and permission bits
+func normalizeHeader(header *tar.Header) {
+	header.ModTime = time.Unix(0, 0)
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+	header.PAXRecords = nil
+	header.Format = tar.FormatUnknown
+}
+
+// createTemp creates a temp file for an intermediate image tar in the configured temp dir
+func (b *Bundler) createTemp(pattern string) (*os.File, error) {
+	f, err := os.CreateTemp(b.TempDir, pattern)
+	if err != nil {
+		dir := b.TempDir
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		return nil, fmt.Errorf("failed to create temp file in %s (set --temp-dir to use another disk): %w", dir, err)
+	}
+	return f, nil
+}
+
+// AddImage adds an image spec (same forms as --load-images) to the bundle
+func (b *Bundler) AddImage(imageSpec string) {
+	b.imagePaths = append(b.imagePaths, imageSpec)
+}
+
+// AddBinary ships a local executable (e.g. a helm post-renderer) under bin/ in the bundle
+func (b *Bundler) AddBinary(path string) {
+	b.binaries = append(b.binaries, path)
+}
+
+// EstimateSize sums the sizes of everything that will be bundled (images, charts,
+// binaries, manifests) from file stats, without reading contents. Tar headers aren't counted.
+// complete is false when some input can't be sized up front, like a remote:// image.
+func (b *Bundler) EstimateSize() (size int64, complete bool) {
+	complete = true
+	paths := slices.Clone(b.chartDirs)
+	paths = append(paths, b.binaries...)
+	paths = append(paths, b.Manifests...)
+	for _, imageSpec := range b.imagePaths {
+		spec := parseImageSpec(imageSpec)
+		if spec.prefix == PrefixRemote {
+			complete = false
+			continue
+		}
+		paths = append(paths, spec.target)
+	}
+
+	for _, path := range paths {
+		n, err := pathSize(path)
+		if err != nil {
+			complete = false
+		}
+		size += n
+	}
+	return size, complete
+}
+
+// pathSize returns the size of a file, or of all regular files under a directory
+func pathSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Validate checks every image before anything is bundled: local specs must exist in a
+// supported format (see ValidateImageSpecs) and remote references must resolve in their
+// registry. Shipped binaries and manifests must exist too.
+func (b *Bundler) Validate(ctx context.Context) error {
+	if err := ValidateImageSpecs(b.imagePaths); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, imageSpec := range b.imagePaths {
+		spec := parseImageSpec(imageSpec)
+		if spec.prefix != PrefixRemote {
+			continue
+		}
+		if _, err := crane.Head(spec.target, crane.WithContext(ctx)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid image %q: cannot resolve: %w", imageSpec, err))
+		}
+	}
+	for _, binPath := range b.binaries {
+		if _, err := os.Stat(binPath); err != nil {
+			errs = append(errs, fmt.Errorf("binary %s: %w", binPath, err))
+		}
+	}
+	if err := ValidateManifests(b.Manifests); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
 // Bundle creates a tar stream containing images and charts
 func (b *Bundler) Bundle(ctx context.Context, w io.Writer) error {
 	log.Printf("📦 Bundling %d chart(s) and %d image(s)", len(b.chartDirs), len(b.imagePaths))
 
+	// The end-of-archive trailer is only written on success, so a failed bundle never
+	// reads as a complete parcel
 	tw := tar.NewWriter(w)
-	defer tw.Close()
 
 	for _, imageSpec := range b.imagePaths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err := b.addImageFromSpec(ctx, tw, imageSpec); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if b.StrictImages {
+				return fmt.Errorf("failed to add image %s: %w", imageSpec, err)
+			}
 			log.Printf("Warning: failed to add image %s: %v", imageSpec, err)
 		}
 	}
 
+	for _, binPath := range b.binaries {
+		if err := b.addBinary(tw, binPath); err != nil {
+			return fmt.Errorf("failed to add binary %s: %w", binPath, err)
+		}
+	}
+
+	if err := b.addManifests(tw); err != nil {
+		return fmt.Errorf("failed to add manifests: %w", err)
+	}
+
 	for _, chartDir := range b.chartDirs {
 		log.Printf("Processing chart: %s", chartDir)
 
-		if err := b.addChartTo(tw, chartDir); err != nil {
+		if err := b.addChartTo(ctx, tw, chartDir); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			log.Printf("Warning: failed to add chart %s: %v", chartDir, err)
 		}
+		if err := b.addChartOverrides(tw, filepath.Base(chartDir)); err != nil {
+			return fmt.Errorf("failed to add values overrides for %s: %w", chartDir, err)
+		}
+	}
+	if err := b.addRunManifest(tw); err != nil {
+		return fmt.Errorf("failed to add chart install order: %w", err)
 	}
 
+	if err := tw.Close(); err != nil {
+		return err
+	}
 	log.Println("✅ Bundle creation complete")
 	return nil
 }
 
 // addImageFromSpec adds an image based on its prefix
 func (b *Bundler) addImageFromSpec(ctx context.Context, tw *tar.Writer, imageSpec string) error {
-	var tag string
-	// Check for tag=path prefix
-	if parts := strings.SplitN(imageSpec, "=", 2); len(parts) == 2 {
-		if strings.HasPrefix(parts[1], "/") ||
-			strings.HasPrefix(parts[1], PrefixOCI) ||
-			strings.HasPrefix(parts[1], PrefixTar) ||
-			strings.HasPrefix(parts[1], PrefixOCITar) ||
-			strings.HasPrefix(parts[1], PrefixRemote) {
-			tag = parts[0]
-			imageSpec = parts[1]
-		}
-	}
-
-	switch {
-	case strings.HasPrefix(imageSpec, PrefixOCI):
-		path := strings.TrimPrefix(imageSpec, PrefixOCI)
-		return b.addOCIDirectory(tw, path, tag)
+	spec := parseImageSpec(imageSpec)
 
-	case strings.HasPrefix(imageSpec, PrefixTar):
-		path := strings.TrimPrefix(imageSpec, PrefixTar)
-		return b.addImageTar(tw, path)
+	switch spec.prefix {
+	case PrefixOCI:
+		return b.addOCIDirectory(ctx, tw, spec.target, spec.tag)
 
-	case strings.HasPrefix(imageSpec, PrefixOCITar):
-		path := strings.TrimPrefix(imageSpec, PrefixOCITar)
-		return b.addImageTar(tw, path)
+	case PrefixTar, PrefixOCITar:
+		return b.addImageTar(ctx, tw, spec.target)
 
-	case strings.HasPrefix(imageSpec, PrefixRemote):
-		ref := strings.TrimPrefix(imageSpec, PrefixRemote)
-		return b.addRemoteImage(ctx, tw, ref)
+	case PrefixRemote:
+		return b.addRemoteImage(ctx, tw, spec.target)
 
 	default:
-		return b.addImageFromPath(tw, imageSpec, tag)
+		return b.addImageFromPath(ctx, tw, spec.target, spec.tag)
 	}
 }
 
 // addImageFromPath auto-detects the image type from path
-func (b *Bundler) addImageFromPath(tw *tar.Writer, imagePath, tag string) error {
+func (b *Bundler) addImageFromPath(ctx context.Context, tw *tar.Writer, imagePath, tag string) error {
 	info, err := os.Stat(imagePath)
 	if err != nil {
 		return fmt.Errorf("image path not found: %w", err)
 	}
 
 	if info.IsDir() {
-		return b.addOCIDirectory(tw, imagePath, tag)
-	} else if strings.HasSuffix(imagePath, ".tar") {
-		return b.addImageTar(tw, imagePath)
+		return b.addOCIDirectory(ctx, tw, imagePath, tag)
+	} else if isImageTarFile(imagePath) {
+		return b.addImageTar(ctx, tw, imagePath)
 	}
 
-	return fmt.Errorf("unsupported image format: %s (expected .tar file or OCI directory, or use oci://, oci-tar://, remote:// prefix)", imagePath)
+	return fmt.Errorf("unsupported image format: %s (expected .tar, .tar.gz or .tgz file or OCI directory, or use oci://, oci-tar://, remote:// prefix)", imagePath)
 }
 
-// addImageTar adds an existing image tar file to the bundle
-func (b *Bundler) addImageTar(tw *tar.Writer, tarPath string) error {
+// addImageTar adds an existing image tar file to the bundle. Gzipped tars are passed
+// through compressed; the entry name keeps (or gains) a .gz suffix so the runner
+// decompresses them on import.
+func (b *Bundler) addImageTar(ctx context.Context, tw *tar.Writer, tarPath string) error {
 	log.Printf("Adding image tar: %s", tarPath)
 
 	file, err := os.Open(tarPath)
@@ -131,6 +283,11 @@ func (b *Bundler) addImageTar(tw *tar.Writer, tarPath string) error {
 	}
 
 	tarName := filepath.Base(tarPath)
+	if gzipped, err := isGzipped(file); err != nil {
+		return err
+	} else if gzipped && !strings.HasSuffix(tarName, ".gz") && !strings.HasSuffix(tarName, ".tgz") {
+		tarName += ".gz"
+	}
 
 	header := &tar.Header{
 		Name: tarName,
@@ -138,11 +295,11 @@ func (b *Bundler) addImageTar(tw *tar.Writer, tarPath string) error {
 		Mode: 0644,
 	}
 
-	if err := tw.WriteHeader(header); err != nil {
+	if err := b.writeHeader(tw, header); err != nil {
 		return err
 	}
 
-	written, err := io.Copy(tw, file)
+	written, err := io.Copy(tw, contextReader{ctx, file})
 	if err != nil {
 		return err
 	}
@@ -151,21 +308,52 @@ func (b *Bundler) addImageTar(tw *tar.Writer, tarPath string) error {
 	return nil
 }
 
+// contextReader fails reads once ctx is done, so a cancelled bundle stops in the middle of
+// copying a multi-GB image instead of finishing it
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// isGzipped reports whether a file starts with the gzip magic number, leaving it rewound
+func isGzipped(f *os.File) (bool, error) {
+	magic := make([]byte, 2)
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return n == 2 && magic[0] == 0x1f && magic[1] == 0x8b, nil
+}
+
 // addOCIDirectory tars an OCI directory and adds it to the bundle
-func (b *Bundler) addOCIDirectory(tw *tar.Writer, ociDir, tag string) error {
+func (b *Bundler) addOCIDirectory(ctx context.Context, tw *tar.Writer, ociDir, tag string) error {
 	log.Printf("Adding OCI directory: %s (tag: %s)", ociDir, tag)
 
-	tmpFile, err := os.CreateTemp("", "oci-*.tar")
+	tmpFile, err := b.createTemp("oci-*.tar")
 	if err != nil {
 		return err
 	}
 	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
 
 	ociTw := tar.NewWriter(tmpFile)
 	err = filepath.Walk(ociDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		relPath, err := filepath.Rel(ociDir, path)
 		if err != nil {
@@ -195,7 +383,7 @@ func (b *Bundler) addOCIDirectory(tw *tar.Writer, ociDir, tag string) error {
 		}
 		header.Name = relPath
 
-		if err := ociTw.WriteHeader(header); err != nil {
+		if err := b.writeHeader(ociTw, header); err != nil {
 			return err
 		}
 
@@ -209,25 +397,32 @@ func (b *Bundler) addOCIDirectory(tw *tar.Writer, ociDir, tag string) error {
 		}
 		defer file.Close()
 
-		_, err = io.Copy(ociTw, file)
+		_, err = io.Copy(ociTw, contextReader{ctx, file})
 		return err
 	})
 	if err != nil {
-		ociTw.Close()
 		return fmt.Errorf("failed to tar OCI directory: %w", err)
 	}
-	ociTw.Close()
-	tmpFile.Close()
+	// Flushing and closing is where a full disk surfaces; don't bundle a truncated tar
+	if err := ociTw.Close(); err != nil {
+		return fmt.Errorf("failed to write OCI tar: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write OCI tar: %w", err)
+	}
 
 	tarName := filepath.Base(ociDir) + ".tar"
 	if tag != "" {
 		tarName = strings.ReplaceAll(tag, ":", "_") + ".tar"
 		tarName = strings.ReplaceAll(tarName, "/", "_")
 	}
-	return b.addImageTarWithName(tw, tmpFile.Name(), tarName)
+	return b.addImageTarWithName(ctx, tw, tmpFile.Name(), tarName)
 }
 
-// writeModifiedIndex reads the index.json, injects the tag annotation, and writes to tar
+// writeModifiedIndex reads the index.json, injects the tag annotation, and writes to tar.
+// A layout listing one manifest per platform at the top level gets them wrapped in a
+// nested image index, so the tag names the whole multi-arch image instead of only the
+// first platform and containerd picks the node's manifest from it.
 func (b *Bundler) writeModifiedIndex(tw *tar.Writer, indexPath, tag string) error {
 	data, err := os.ReadFile(indexPath)
 	if err != nil {
@@ -239,6 +434,31 @@ func (b *Bundler) writeModifiedIndex(tw *tar.Writer, indexPath, tag string) erro
 		return fmt.Errorf("failed to parse index.json: %w", err)
 	}
 
+	if manifests, ok := index["manifests"].([]interface{}); ok && isPlatformManifestList(manifests) {
+		nested, err := json.Marshal(map[string]interface{}{
+			"schemaVersion": 2,
+			"mediaType":     ociIndexMediaType,
+			"manifests":     manifests,
+		})
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256(nested)
+		hexDigest := hex.EncodeToString(digest[:])
+		if err := b.writeHeader(tw, &tar.Header{Name: "blobs/sha256/" + hexDigest, Size: int64(len(nested)), Mode: 0644}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(nested); err != nil {
+			return err
+		}
+		log.Printf("Wrapping %d platform manifests in an image index for %s", len(manifests), tag)
+		index["manifests"] = []interface{}{map[string]interface{}{
+			"mediaType": ociIndexMediaType,
+			"digest":    "sha256:" + hexDigest,
+			"size":      len(nested),
+		}}
+	}
+
 	if manifests, ok := index["manifests"].([]interface{}); ok && len(manifests) > 0 {
 		if manifest, ok := manifests[0].(map[string]interface{}); ok {
 			annotations, ok := manifest["annotations"].(map[string]interface{})
@@ -261,7 +481,7 @@ func (b *Bundler) writeModifiedIndex(tw *tar.Writer, indexPath, tag string) erro
 		Mode: 0644,
 	}
 
-	if err := tw.WriteHeader(header); err != nil {
+	if err := b.writeHeader(tw, header); err != nil {
 		return err
 	}
 
@@ -269,19 +489,52 @@ func (b *Bundler) writeModifiedIndex(tw *tar.Writer, indexPath, tag string) erro
 	return err
 }
 
+// ociIndexMediaType is the media type of an OCI image index
+const ociIndexMediaType = "application/vnd.oci.image.index.v1+json"
+
+// isPlatformManifestList reports whether index.json lists several manifests that each
+// declare a platform, i.e. one multi-arch image spread over the top-level index
+func isPlatformManifestList(manifests []interface{}) bool {
+	if len(manifests) < 2 {
+		return false
+	}
+	for _, m := range manifests {
+		manifest, ok := m.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := manifest["platform"].(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // addRemoteImage pulls an image from a remote registry and adds it to the bundle
 func (b *Bundler) addRemoteImage(ctx context.Context, tw *tar.Writer, imageRef string) error {
-	log.Printf("Pulling remote image: %s", imageRef)
+	if b.Platform != "" {
+		log.Printf("Pulling remote image: %s (%s)", imageRef, b.Platform)
+	} else {
+		log.Printf("Pulling remote image: %s", imageRef)
+	}
 
-	tmpFile, err := os.CreateTemp("", "remote-img-*.tar")
+	tmpFile, err := b.createTemp("remote-img-*.tar")
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return err
 	}
 	tmpPath := tmpFile.Name()
 	tmpFile.Close() // crane.Save needs path
 	defer os.Remove(tmpPath)
 
-	img, err := crane.Pull(imageRef)
+	opts := []crane.Option{crane.WithContext(ctx)}
+	if b.Platform != "" {
+		platform, err := v1.ParsePlatform(b.Platform)
+		if err != nil {
+			return fmt.Errorf("invalid platform %q: %w", b.Platform, err)
+		}
+		opts = append(opts, crane.WithPlatform(platform))
+	}
+	img, err := crane.Pull(imageRef, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to pull image %s: %w", imageRef, err)
 	}
@@ -289,6 +542,7 @@ func (b *Bundler) addRemoteImage(ctx context.Context, tw *tar.Writer, imageRef s
 	// Save as a Docker-compatible tarball
 	// We use the original image ref as the tag in the tar
 	// Signature: Save(img v1.Image, tag, path string)
+	// Layers are fetched lazily while saving, with the context given to Pull
 	err = crane.Save(img, imageRef, tmpPath)
 	if err != nil {
 		return fmt.Errorf("failed to save image tar: %w", err)
@@ -299,11 +553,11 @@ func (b *Bundler) addRemoteImage(ctx context.Context, tw *tar.Writer, imageRef s
 	tarName := strings.ReplaceAll(imageRef, ":", "_") + ".tar"
 	tarName = strings.ReplaceAll(tarName, "/", "_")
 
-	return b.addImageTarWithName(tw, tmpPath, tarName)
+	return b.addImageTarWithName(ctx, tw, tmpPath, tarName)
 }
 
 // addImageTarWithName adds a tar file to the bundle with a custom name
-func (b *Bundler) addImageTarWithName(tw *tar.Writer, tarPath, tarName string) error {
+func (b *Bundler) addImageTarWithName(ctx context.Context, tw *tar.Writer, tarPath, tarName string) error {
 	file, err := os.Open(tarPath)
 	if err != nil {
 		return err
@@ -321,11 +575,11 @@ func (b *Bundler) addImageTarWithName(tw *tar.Writer, tarPath, tarName string) e
 		Mode: 0644,
 	}
 
-	if err := tw.WriteHeader(header); err != nil {
+	if err := b.writeHeader(tw, header); err != nil {
 		return err
 	}
 
-	written, err := io.Copy(tw, file)
+	written, err := io.Copy(tw, contextReader{ctx, file})
 	if err != nil {
 		return err
 	}
@@ -334,14 +588,59 @@ func (b *Bundler) addImageTarWithName(tw *tar.Writer, tarPath, tarName string) e
 	return nil
 }
 
-// addChartTo adds a chart directory to the tar
-func (b *Bundler) addChartTo(tw *tar.Writer, chartDir string) error {
+// addBinary writes an executable to bin/<name>, keeping its file mode
+func (b *Bundler) addBinary(tw *tar.Writer, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = "bin/" + filepath.Base(path)
+
+	if err := b.writeHeader(tw, header); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(tw, f); err != nil {
+		return err
+	}
+
+	log.Printf("Added binary: %s", header.Name)
+	return nil
+}
+
+// addChartTo adds a chart directory, including vendored subcharts under its charts/, to the tar
+func (b *Bundler) addChartTo(ctx context.Context, tw *tar.Writer, chartDir string) error {
 	log.Printf("Adding chart directory: %s", chartDir)
 
+	// The runner is airgapped by default, so subcharts it would have to download won't resolve
+	if missing, err := shared.MissingDependencies(chartDir); err != nil {
+		log.Printf("Warning: could not check dependencies of %s: %v", chartDir, err)
+	} else if len(missing) > 0 {
+		log.Printf("⚠️  %s declares dependencies not vendored under charts/ (%s); run 'helm dependency build %s' before bundling for airgapped runs",
+			chartDir, strings.Join(missing, ", "), chartDir)
+	}
+
 	return filepath.Walk(chartDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		// Create relative path
 		relPath, err := filepath.Rel(chartDir, path)
@@ -374,7 +673,7 @@ func (b *Bundler) addChartTo(tw *tar.Writer, chartDir string) error {
 		}
 		header.Name = tarPath
 
-		if err := tw.WriteHeader(header); err != nil {
+		if err := b.writeHeader(tw, header); err != nil {
 			return err
 		}
 
@@ -389,14 +688,25 @@ func (b *Bundler) addChartTo(tw *tar.Writer, chartDir string) error {
 		}
 		defer file.Close()
 
-		_, err = io.Copy(tw, file)
+		_, err = io.Copy(tw, contextReader{ctx, file})
 		return err
 	})
 }
 
+// UnknownTag stands in for the tag of an image whose values.yaml entry sets no tag when
+// the chart has no appVersion and no fallback tag is configured
+const UnknownTag = "<unknown>"
+
+// HasUnknownTag reports whether an extracted image reference has an undetermined tag
+func HasUnknownTag(ref string) bool {
+	return strings.HasSuffix(ref, ":"+UnknownTag)
+}
+
 // ExtractImagesFromChart extracts image references from a chart's values.yaml
-// This is exported for callers who want to discover which images need to be provided
-func ExtractImagesFromChart(chartDir string) ([]string, error) {
+// This is exported for callers who want to discover which images need to be provided.
+// A repository without a tag gets the chart's appVersion (which charts conventionally
+// default the tag to), else tagFallback, else UnknownTag.
+func ExtractImagesFromChart(chartDir, tagFallback string) ([]string, error) {
 	valuesPath := filepath.Join(chartDir, "values.yaml")
 
 	data, err := os.ReadFile(valuesPath)
@@ -412,29 +722,69 @@ func ExtractImagesFromChart(chartDir string) ([]string, error) {
 		return nil, fmt.Errorf("failed to parse values.yaml: %w", err)
 	}
 
+	defaultTag := chartAppVersion(chartDir)
+	if defaultTag == "" {
+		defaultTag = tagFallback
+	}
+	if defaultTag == "" {
+		defaultTag = UnknownTag
+	}
+
 	var images []string
-	extractImagesRecursive(values, &images)
+	extractImagesRecursive(values, defaultTag, &images)
 
-	return images, nil
+	slices.Sort(images)
+	return slices.Compact(images), nil
 }
 
-// extractImagesRecursive recursively extracts image references from a values tree
-func extractImagesRecursive(v interface{}, images *[]string) {
+// chartAppVersion returns the appVersion from a chart's Chart.yaml, or "" if it has none
+func chartAppVersion(chartDir string) string {
+	data, err := os.ReadFile(filepath.Join(chartDir, "Chart.yaml"))
+	if err != nil {
+		return ""
+	}
+	var meta struct {
+		AppVersion string `yaml:"appVersion"`
+	}
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return ""
+	}
+	return meta.AppVersion
+}
+
+// extractImagesRecursive recursively extracts image references from a values tree,
+// tagging repositories without a tag with defaultTag
+func extractImagesRecursive(v interface{}, defaultTag string, images *[]string) {
 	switch val := v.(type) {
 	case map[string]interface{}:
-		if repo, ok := val["repository"].(string); ok {
-			tag := "latest"
-			if t, ok := val["tag"].(string); ok {
-				tag = t
+		// Map form: repository + tag, with an optional registry (bitnami style) and digest
+		if repo, ok := val["repository"].(string); ok && repo != "" {
+			ref := repo
+			if registry, ok := val["registry"].(string); ok && registry != "" {
+				ref = strings.TrimSuffix(registry, "/") + "/" + repo
+			}
+			tag, _ := val["tag"].(string)
+			digest, _ := val["digest"].(string)
+			if tag != "" {
+				ref += ":" + tag
+			} else if digest == "" {
+				ref += ":" + defaultTag
 			}
-			*images = append(*images, fmt.Sprintf("%s:%s", repo, tag))
+			if digest != "" {
+				ref += "@" + digest
+			}
+			*images = append(*images, ref)
+		}
+		// Single-string form: image: "repo:tag"
+		if ref, ok := val["image"].(string); ok && ref != "" {
+			*images = append(*images, ref)
 		}
 		for _, value := range val {
-			extractImagesRecursive(value, images)
+			extractImagesRecursive(value, defaultTag, images)
 		}
 	case []interface{}:
 		for _, val := range val {
-			extractImagesRecursive(val, images)
+			extractImagesRecursive(val, defaultTag, images)
 		}
 	}
 }
@@ -0,0 +1,362 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundler_Reproducible(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "myapp")
+	os.MkdirAll(filepath.Join(chartDir, "templates"), 0755)
+	os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: myapp\nversion: 0.1.0\n"), 0644)
+	os.WriteFile(filepath.Join(chartDir, "templates", "cm.yaml"), []byte("kind: ConfigMap\n"), 0644)
+
+	bundle := func() []byte {
+		var buf bytes.Buffer
+		b := NewBundler([]string{chartDir}, nil)
+		b.Reproducible = true
+		if err := b.Bundle(context.Background(), &buf); err != nil {
+			t.Fatalf("Bundle failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	first := bundle()
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(chartDir, "Chart.yaml"), later, later)
+	second := bundle()
+
+	if !bytes.Equal(first, second) {
+		t.Fatal("expected byte-identical bundles after touching mtimes")
+	}
+
+	tr := tar.NewReader(bytes.NewReader(first))
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if !header.ModTime.Equal(time.Unix(0, 0)) || header.Uid != 0 || header.Gid != 0 || header.Uname != "" {
+			t.Errorf("header %s not normalized: mtime=%v uid=%d gid=%d uname=%q",
+				header.Name, header.ModTime, header.Uid, header.Gid, header.Uname)
+		}
+	}
+}
+
+func TestBundler_TempDir(t *testing.T) {
+	ociDir := filepath.Join(t.TempDir(), "image")
+	os.MkdirAll(filepath.Join(ociDir, "blobs"), 0755)
+	os.WriteFile(filepath.Join(ociDir, "index.json"), []byte(`{"schemaVersion":2,"manifests":[]}`), 0644)
+	os.WriteFile(filepath.Join(ociDir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644)
+
+	tempDir := t.TempDir()
+	b := NewBundler(nil, nil)
+	b.TempDir = tempDir
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := b.addOCIDirectory(context.Background(), tw, ociDir, ""); err != nil {
+		t.Fatalf("addOCIDirectory failed: %v", err)
+	}
+	tw.Close()
+
+	header, err := tar.NewReader(&buf).Next()
+	if err != nil || header.Name != "image.tar" {
+		t.Fatalf("expected image.tar in bundle, got %v (err: %v)", header, err)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("expected temp dir to be cleaned up, found %d entries", len(entries))
+	}
+
+	b.TempDir = filepath.Join(tempDir, "missing")
+	if err := b.addOCIDirectory(context.Background(), tar.NewWriter(io.Discard), ociDir, ""); err == nil {
+		t.Error("expected an error for a missing temp dir")
+	}
+}
+
+func TestBundler_MultiArchOCIDirectory(t *testing.T) {
+	ociDir := filepath.Join(t.TempDir(), "image")
+	os.MkdirAll(filepath.Join(ociDir, "blobs", "sha256"), 0755)
+	os.WriteFile(filepath.Join(ociDir, "index.json"), []byte(`{"schemaVersion":2,"manifests":[
+		{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:a1","size":10,"platform":{"os":"linux","architecture":"amd64"}},
+		{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:a2","size":10,"platform":{"os":"linux","architecture":"arm64"}}]}`), 0644)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := NewBundler(nil, nil).addOCIDirectory(context.Background(), tw, ociDir, "myapp:v1"); err != nil {
+		t.Fatalf("addOCIDirectory failed: %v", err)
+	}
+	tw.Close()
+
+	outer := tar.NewReader(&buf)
+	if _, err := outer.Next(); err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	inner := tar.NewReader(outer)
+	for {
+		header, err := inner.Next()
+		if err != nil {
+			break
+		}
+		files[header.Name], _ = io.ReadAll(inner)
+	}
+
+	var index struct {
+		Manifests []struct {
+			MediaType   string            `json:"mediaType"`
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(files["index.json"], &index); err != nil {
+		t.Fatalf("invalid index.json: %v", err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].MediaType != ociIndexMediaType {
+		t.Fatalf("expected a single nested index, got %+v", index.Manifests)
+	}
+	if got := index.Manifests[0].Annotations["org.opencontainers.image.ref.name"]; got != "myapp:v1" {
+		t.Errorf("expected the tag on the nested index, got %q", got)
+	}
+	nested := files["blobs/sha256/"+strings.TrimPrefix(index.Manifests[0].Digest, "sha256:")]
+	if !strings.Contains(string(nested), "sha256:a1") || !strings.Contains(string(nested), "sha256:a2") {
+		t.Errorf("nested index should list both platform manifests, got %s", nested)
+	}
+}
+
+func TestBundler_GzippedImageTar(t *testing.T) {
+	dir := t.TempDir()
+	var gzData bytes.Buffer
+	gz := gzip.NewWriter(&gzData)
+	gz.Write([]byte("image tar contents"))
+	gz.Close()
+	os.WriteFile(filepath.Join(dir, "app.tar.gz"), gzData.Bytes(), 0644)
+	os.WriteFile(filepath.Join(dir, "misnamed.tar"), gzData.Bytes(), 0644)
+	os.WriteFile(filepath.Join(dir, "plain.tar"), []byte("image tar contents"), 0644)
+
+	b := NewBundler(nil, []string{
+		filepath.Join(dir, "app.tar.gz"),
+		"tar://" + filepath.Join(dir, "misnamed.tar"),
+		filepath.Join(dir, "plain.tar"),
+	})
+	b.StrictImages = true
+	var buf bytes.Buffer
+	if err := b.Bundle(context.Background(), &buf); err != nil {
+		t.Fatalf("Bundle failed: %v", err)
+	}
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		entries[header.Name], _ = io.ReadAll(tr)
+	}
+	// Gzipped tars pass through compressed, named so the runner decompresses them
+	for _, name := range []string{"app.tar.gz", "misnamed.tar.gz"} {
+		if !bytes.Equal(entries[name], gzData.Bytes()) {
+			t.Errorf("expected %s to be bundled compressed, got entries %v", name, slices.Collect(maps.Keys(entries)))
+		}
+	}
+	if string(entries["plain.tar"]) != "image tar contents" {
+		t.Errorf("expected plain.tar to be bundled unchanged")
+	}
+}
+
+func TestBundler_StrictImages(t *testing.T) {
+	missing := "tar://" + filepath.Join(t.TempDir(), "missing.tar")
+
+	b := NewBundler(nil, []string{missing})
+	if err := b.Validate(context.Background()); err == nil {
+		t.Error("expected Validate to reject a missing image tar")
+	}
+	if err := b.Bundle(context.Background(), io.Discard); err != nil {
+		t.Errorf("expected a missing image to be skipped without --strict-images, got %v", err)
+	}
+
+	b.StrictImages = true
+	if err := b.Bundle(context.Background(), io.Discard); err == nil || !strings.Contains(err.Error(), "missing.tar") {
+		t.Errorf("expected strict bundling to fail on the missing image, got %v", err)
+	}
+}
+
+// cancelAfter cancels a context once more than limit bytes were written to it
+type cancelAfter struct {
+	limit   int
+	written int
+	cancel  context.CancelFunc
+}
+
+func (w *cancelAfter) Write(p []byte) (int, error) {
+	w.written += len(p)
+	if w.written > w.limit {
+		w.cancel()
+	}
+	return len(p), nil
+}
+
+func TestBundler_Cancelled(t *testing.T) {
+	imageTar := filepath.Join(t.TempDir(), "app.tar")
+	os.WriteFile(imageTar, make([]byte, 8<<20), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &cancelAfter{limit: 64 << 10, cancel: cancel}
+	err := NewBundler(nil, []string{imageTar}).Bundle(ctx, w)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Bundle() = %v, want context.Canceled", err)
+	}
+	if w.written >= 8<<20 {
+		t.Errorf("expected the image copy to stop once cancelled, wrote %d bytes", w.written)
+	}
+}
+
+func TestExtractImagesFromChart(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   string
+		chart    string // Chart.yaml contents ("" = none)
+		fallback string
+		want     []string
+	}{
+		{
+			name:   "repository and tag",
+			values: "image:\n  repository: nginx\n  tag: \"1.25\"\n",
+			want:   []string{"nginx:1.25"},
+		},
+		{
+			name:   "missing tag is unknown",
+			values: "image:\n  repository: nginx\n",
+			want:   []string{"nginx:<unknown>"},
+		},
+		{
+			name:   "missing tag defaults to appVersion",
+			values: "image:\n  repository: nginx\n",
+			chart:  "apiVersion: v2\nname: web\nversion: 0.1.0\nappVersion: 1.27.0\n",
+			want:   []string{"nginx:1.27.0"},
+		},
+		{
+			name:     "missing tag without appVersion uses the fallback",
+			values:   "image:\n  repository: nginx\n",
+			chart:    "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+			fallback: "latest",
+			want:     []string{"nginx:latest"},
+		},
+		{
+			name:   "bitnami registry, repository and tag",
+			values: "image:\n  registry: docker.io\n  repository: bitnami/postgresql\n  tag: 16.2.0-debian-12-r6\n  digest: \"\"\n",
+			want:   []string{"docker.io/bitnami/postgresql:16.2.0-debian-12-r6"},
+		},
+		{
+			name:   "digest pinned",
+			values: "image:\n  registry: docker.io\n  repository: bitnami/redis\n  tag: \"7.2\"\n  digest: sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef\n",
+			want:   []string{"docker.io/bitnami/redis:7.2@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		},
+		{
+			name:   "flat string",
+			values: "image: nginx:1.25\nsidecar:\n  image: busybox:1.36\n",
+			want:   []string{"busybox:1.36", "nginx:1.25"},
+		},
+		{
+			name:   "lists and duplicates",
+			values: "containers:\n  - image: nginx:1.25\n  - image: nginx:1.25\nproxy:\n  image:\n    repository: nginx\n    tag: \"1.25\"\n",
+			want:   []string{"nginx:1.25"},
+		},
+		{
+			name:   "no images",
+			values: "replicaCount: 1\n",
+			want:   nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			chartDir := t.TempDir()
+			os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(tc.values), 0644)
+			if tc.chart != "" {
+				os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte(tc.chart), 0644)
+			}
+
+			got, err := ExtractImagesFromChart(chartDir, tc.fallback)
+			if err != nil {
+				t.Fatalf("ExtractImagesFromChart failed: %v", err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBundler_EstimateSize(t *testing.T) {
+	dir := t.TempDir()
+	chartDir := filepath.Join(dir, "app")
+	os.MkdirAll(filepath.Join(chartDir, "templates"), 0755)
+	os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), make([]byte, 100), 0644)
+	os.WriteFile(filepath.Join(chartDir, "templates", "pod.yaml"), make([]byte, 50), 0644)
+	imageTar := filepath.Join(dir, "app.tar")
+	os.WriteFile(imageTar, make([]byte, 1000), 0644)
+
+	b := NewBundler([]string{chartDir}, []string{"app:v1=" + imageTar})
+	if size, complete := b.EstimateSize(); size != 1150 || !complete {
+		t.Errorf("EstimateSize() = %d, %v; want 1150, true", size, complete)
+	}
+
+	b.AddImage(PrefixRemote + "nginx:1.25")
+	if size, complete := b.EstimateSize(); size != 1150 || complete {
+		t.Errorf("EstimateSize() with a remote image = %d, %v; want 1150, false", size, complete)
+	}
+}
+
+func TestBundler_Manifests(t *testing.T) {
+	dir := t.TempDir()
+	secrets := filepath.Join(dir, "secrets")
+	os.MkdirAll(filepath.Join(secrets, "tls"), 0755)
+	os.WriteFile(filepath.Join(secrets, "db.yaml"), []byte("kind: Secret\n"), 0644)
+	os.WriteFile(filepath.Join(secrets, "tls", "cert.yml"), []byte("kind: Secret\n"), 0644)
+	os.WriteFile(filepath.Join(secrets, "README.md"), []byte("notes\n"), 0644)
+	namespace := filepath.Join(dir, "namespace.yaml")
+	os.WriteFile(namespace, []byte("kind: Namespace\n"), 0644)
+
+	if err := ValidateManifests([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected a missing manifests path to be rejected")
+	}
+	if err := ValidateManifests([]string{filepath.Join(secrets, "README.md")}); err == nil {
+		t.Error("expected a non-manifest file to be rejected")
+	}
+
+	var buf bytes.Buffer
+	b := NewBundler(nil, nil)
+	b.Manifests = []string{namespace, secrets}
+	if err := b.Bundle(context.Background(), &buf); err != nil {
+		t.Fatalf("Bundle failed: %v", err)
+	}
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+	want := []string{"manifests/00-namespace.yaml", "manifests/01-secrets/db.yaml", "manifests/01-secrets/tls/cert.yml"}
+	if !slices.Equal(names, want) {
+		t.Errorf("bundled %v, want %v", names, want)
+	}
+}
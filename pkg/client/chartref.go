@@ -0,0 +1,195 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// helmChartLayerMediaType is the layer holding the packaged chart in a Helm OCI artifact
+const helmChartLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+// IsOCIChart reports whether a chart argument is an OCI registry reference
+// (oci://registry/repo/chart:version) rather than a local directory
+func IsOCIChart(chart string) bool {
+	return strings.HasPrefix(chart, PrefixOCI)
+}
+
+// IsPackagedChart reports whether a chart argument is a chart archive written by helm
+// package (e.g. myapp-1.2.3.tgz) rather than a chart directory
+func IsPackagedChart(chart string) bool {
+	if !strings.HasSuffix(chart, ".tgz") && !strings.HasSuffix(chart, ".tar.gz") {
+		return false
+	}
+	info, err := os.Stat(chart)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// ResolveCharts turns chart arguments into local chart directories. Directories are
+// returned as-is; oci:// references are pulled from their registry and packaged charts
+// (.tgz) are unpacked, into temp dirs under tempDir ("" = system temp dir) which cleanup
+// removes. Registry credentials come from the Docker config, like remote:// images.
+func ResolveCharts(ctx context.Context, charts []string, tempDir string) (chartDirs []string, cleanup func(), err error) {
+	var unpacked []string
+	cleanup = func() {
+		for _, dir := range unpacked {
+			os.RemoveAll(dir)
+		}
+	}
+
+	for _, chart := range charts {
+		var unpack func(dir string) (string, error)
+		switch {
+		case IsOCIChart(chart):
+			unpack = func(dir string) (string, error) {
+				return pullOCIChart(ctx, strings.TrimPrefix(chart, PrefixOCI), dir)
+			}
+		case IsPackagedChart(chart):
+			unpack = func(dir string) (string, error) {
+				return unpackChart(chart, dir)
+			}
+		default:
+			chartDirs = append(chartDirs, chart)
+			continue
+		}
+
+		dir, err := os.MkdirTemp(tempDir, "kube-parcel-chart-*")
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to create temp dir for %s: %w", chart, err)
+		}
+		unpacked = append(unpacked, dir)
+
+		chartDir, err := unpack(dir)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("chart %s: %w", chart, err)
+		}
+		log.Printf("Unpacked chart %s into %s", chart, chartDir)
+		chartDirs = append(chartDirs, chartDir)
+	}
+	return chartDirs, cleanup, nil
+}
+
+// unpackChart unpacks a packaged chart file into dir, returning the chart's directory
+func unpackChart(path, dir string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return untarChart(f, dir)
+}
+
+// pullOCIChart downloads the chart layer of a Helm OCI artifact and unpacks it into dir,
+// returning the chart's directory (named after the chart, as helm package lays it out)
+func pullOCIChart(ctx context.Context, ref, dir string) (string, error) {
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid reference: %w", err)
+	}
+
+	data, err := crane.Manifest(ref, crane.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	var chartLayer *v1.Descriptor
+	for i, layer := range manifest.Layers {
+		if layer.MediaType == helmChartLayerMediaType {
+			chartLayer = &manifest.Layers[i]
+			break
+		}
+	}
+	if chartLayer == nil {
+		return "", fmt.Errorf("not a Helm chart (no %s layer)", helmChartLayerMediaType)
+	}
+
+	layer, err := crane.PullLayer(parsed.Context().Digest(chartLayer.Digest.String()).String(), crane.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	return untarChart(rc, dir)
+}
+
+// untarChart unpacks a packaged chart (.tgz) into dir and returns the chart's directory.
+// Every entry must live under a single top-level directory, as helm package writes it.
+func untarChart(r io.Reader, dir string) (string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return "", fmt.Errorf("invalid chart archive: %w", err)
+	}
+	defer gz.Close()
+
+	top := ""
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid chart archive: %w", err)
+		}
+
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return "", fmt.Errorf("invalid chart archive: unsafe path %q", header.Name)
+		}
+		root, rest, _ := strings.Cut(filepath.ToSlash(name), "/")
+		if rest == "" && header.Typeflag != tar.TypeDir {
+			return "", fmt.Errorf("invalid chart archive: %s is not inside the chart directory", header.Name)
+		}
+		if top == "" {
+			top = root
+		} else if root != top {
+			return "", fmt.Errorf("invalid chart archive: entries outside %s/", top)
+		}
+
+		path := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return "", err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return "", err
+			}
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	if top == "" {
+		return "", fmt.Errorf("invalid chart archive: empty")
+	}
+	return filepath.Join(dir, top), nil
+}
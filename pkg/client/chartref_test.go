@@ -0,0 +1,179 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"maps"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// packageChart builds a .tgz laid out like helm package output
+func packageChart(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for path, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: path, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// pushChart pushes a chart archive to the registry as a Helm OCI artifact
+func pushChart(t *testing.T, ref string, chart []byte) {
+	t.Helper()
+	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, "application/vnd.cncf.helm.config.v1+json")
+	img, err := mutate.AppendLayers(img, static.NewLayer(chart, helmChartLayerMediaType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveCharts(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	pushChart(t, host+"/charts/web:1.2.3", packageChart(t, map[string]string{
+		"web/Chart.yaml":                "apiVersion: v2\nname: web\nversion: 1.2.3\n",
+		"web/templates/deployment.yaml": "kind: Deployment\n",
+	}))
+
+	chartDirs, cleanup, err := ResolveCharts(context.Background(), []string{"./charts/local", "oci://" + host + "/charts/web:1.2.3"}, t.TempDir())
+	if err != nil {
+		t.Fatalf("ResolveCharts: %v", err)
+	}
+	if len(chartDirs) != 2 || chartDirs[0] != "./charts/local" {
+		t.Fatalf("unexpected chart dirs %v", chartDirs)
+	}
+	if filepath.Base(chartDirs[1]) != "web" {
+		t.Errorf("pulled chart dir %s is not named after the chart", chartDirs[1])
+	}
+	data, err := os.ReadFile(filepath.Join(chartDirs[1], "templates", "deployment.yaml"))
+	if err != nil || string(data) != "kind: Deployment\n" {
+		t.Errorf("chart not unpacked: %q, %v", data, err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(chartDirs[1]); !os.IsNotExist(err) {
+		t.Errorf("expected pulled chart to be removed, got %v", err)
+	}
+}
+
+func TestResolveCharts_NotAChart(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	tag, _ := name.NewTag(host + "/images/app:v1")
+	if err := remote.Write(tag, empty.Image); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := ResolveCharts(context.Background(), []string{"oci://" + host + "/images/app:v1"}, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "not a Helm chart") {
+		t.Fatalf("expected not a Helm chart error, got %v", err)
+	}
+}
+
+func TestUntarChart_RejectsUnsafePaths(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"traversal": {"web/Chart.yaml": "", "../evil": ""},
+		"two roots": {"web/Chart.yaml": "", "api/Chart.yaml": ""},
+		"top-level": {"Chart.yaml": ""},
+	} {
+		if _, err := untarChart(bytes.NewReader(packageChart(t, files)), t.TempDir()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// bundledFiles bundles charts and returns the contents of the regular files in the bundle
+func bundledFiles(t *testing.T, charts []string) map[string]string {
+	t.Helper()
+	chartDirs, cleanup, err := ResolveCharts(context.Background(), charts, t.TempDir())
+	if err != nil {
+		t.Fatalf("ResolveCharts: %v", err)
+	}
+	defer cleanup()
+
+	var buf bytes.Buffer
+	if err := NewBundler(chartDirs, nil).Bundle(context.Background(), &buf); err != nil {
+		t.Fatalf("Bundle failed: %v", err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if header.Typeflag == tar.TypeReg {
+			data, _ := io.ReadAll(tr)
+			files[header.Name] = string(data)
+		}
+	}
+	return files
+}
+
+func TestResolveCharts_Packaged(t *testing.T) {
+	files := map[string]string{
+		"Chart.yaml":        "apiVersion: v2\nname: myapp\nversion: 1.2.3\n",
+		"values.yaml":       "image: nginx:1.27\n",
+		"templates/cm.yaml": "kind: ConfigMap\n",
+	}
+	chartDir := filepath.Join(t.TempDir(), "myapp")
+	packaged := make(map[string]string)
+	for path, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(chartDir, path)), 0755)
+		os.WriteFile(filepath.Join(chartDir, path), []byte(content), 0644)
+		packaged["myapp/"+path] = content
+	}
+	archive := filepath.Join(t.TempDir(), "myapp-1.2.3.tgz")
+	os.WriteFile(archive, packageChart(t, packaged), 0644)
+
+	if !IsPackagedChart(archive) || IsPackagedChart(chartDir) {
+		t.Fatal("IsPackagedChart misclassified the chart")
+	}
+
+	fromDir, fromArchive := bundledFiles(t, []string{chartDir}), bundledFiles(t, []string{archive})
+	if len(fromDir) != len(files) {
+		t.Fatalf("expected %d files from the chart directory, got %v", len(files), fromDir)
+	}
+	if !maps.Equal(fromDir, fromArchive) {
+		t.Errorf("packaged chart bundled differently:\ndir:     %v\narchive: %v", fromDir, fromArchive)
+	}
+	if _, ok := fromArchive["charts/myapp/templates/cm.yaml"]; !ok {
+		t.Errorf("expected the chart under charts/myapp/, got %v", fromArchive)
+	}
+}
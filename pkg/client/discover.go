@@ -0,0 +1,168 @@
+package client
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// MissingImages returns, per chart directory, the images referenced in the chart's
+// values.yaml that none of the --load-images entries provide. In airgap mode these
+// can't be pulled. Entries whose image names can't be determined provide nothing.
+// An image with an unknown tag (see ExtractImagesFromChart) counts as provided by any
+// bundled image of the same repository.
+func (b *Bundler) MissingImages() (map[string][]string, error) {
+	bundled := make(map[string]bool)
+	bundledRepos := make(map[string]bool)
+	for _, imageSpec := range b.imagePaths {
+		for _, ref := range bundledImageRefs(imageSpec) {
+			bundled[canonicalImageRef(ref)] = true
+			bundledRepos[canonicalRepository(ref)] = true
+		}
+	}
+
+	missing := make(map[string][]string)
+	for _, chartDir := range b.chartDirs {
+		images, err := ExtractImagesFromChart(chartDir, b.TagFallback)
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range images {
+			provided := bundled[canonicalImageRef(image)]
+			if HasUnknownTag(image) {
+				provided = bundledRepos[canonicalRepository(strings.TrimSuffix(image, ":"+UnknownTag))]
+			}
+			if !provided && !slices.Contains(missing[chartDir], image) {
+				missing[chartDir] = append(missing[chartDir], image)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// canonicalRepository returns the expanded repository of a reference, without its tag
+// or digest ("nginx:1.27" becomes "index.docker.io/library/nginx")
+func canonicalRepository(ref string) string {
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return ref
+	}
+	return parsed.Context().Name()
+}
+
+// canonicalImageRef expands a reference (default registry, library/ and :latest) so
+// "nginx" and "docker.io/library/nginx:latest" compare equal
+func canonicalImageRef(ref string) string {
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return ref
+	}
+	return parsed.Name()
+}
+
+// bundledImageRefs returns the image names an image spec provides: the tag of a tag=path
+// spec, a remote reference, or the names recorded in an image tar or OCI layout
+func bundledImageRefs(imageSpec string) []string {
+	spec := parseImageSpec(imageSpec)
+	if spec.tag != "" {
+		return []string{spec.tag}
+	}
+
+	switch spec.prefix {
+	case PrefixRemote:
+		return []string{spec.target}
+	case PrefixOCI:
+		return ociLayoutRefs(spec.target)
+	case PrefixTar, PrefixOCITar:
+		return imageTarRefs(spec.target)
+	}
+
+	if info, err := os.Stat(spec.target); err == nil && info.IsDir() {
+		return ociLayoutRefs(spec.target)
+	}
+	return imageTarRefs(spec.target)
+}
+
+// ociLayoutRefs reads the image names annotated in an OCI layout's index.json
+func ociLayoutRefs(dir string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil
+	}
+	return indexRefs(data)
+}
+
+// indexRefs returns the image names in an OCI index: the containerd image name, or a
+// ref.name annotation that is a full reference rather than just a tag
+func indexRefs(data []byte) []string {
+	var index struct {
+		Manifests []struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"manifests"`
+	}
+	if json.Unmarshal(data, &index) != nil {
+		return nil
+	}
+
+	var refs []string
+	for _, m := range index.Manifests {
+		if ref := m.Annotations["io.containerd.image.name"]; ref != "" {
+			refs = append(refs, ref)
+		} else if ref := m.Annotations["org.opencontainers.image.ref.name"]; strings.ContainsAny(ref, ":/") {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// imageTarRefs reads the image names from a docker save tar (manifest.json RepoTags)
+// or an OCI archive (index.json annotations), either of them optionally gzipped
+func imageTarRefs(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped, err := isGzipped(f); err == nil && gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var refs []string
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			return refs
+		}
+
+		switch filepath.Clean(header.Name) {
+		case "manifest.json":
+			var manifest []struct {
+				RepoTags []string `json:"RepoTags"`
+			}
+			if json.NewDecoder(tr).Decode(&manifest) == nil {
+				for _, m := range manifest {
+					refs = append(refs, m.RepoTags...)
+				}
+			}
+		case "index.json":
+			if data, err := io.ReadAll(tr); err == nil {
+				refs = append(refs, indexRefs(data)...)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBundler_MissingImages(t *testing.T) {
+	dir := t.TempDir()
+	chartDir := filepath.Join(dir, "app")
+	os.MkdirAll(chartDir, 0755)
+	os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(`
+image:
+  repository: nginx
+  tag: "1.25"
+sidecar:
+  image: docker.io/library/busybox:1.36
+worker:
+  image: ghcr.io/acme/worker:v2
+cache:
+  image: redis:7
+`), 0644)
+
+	// docker save tar providing redis:7
+	tarPath := filepath.Join(dir, "redis.tar")
+	f, _ := os.Create(tarPath)
+	tw := tar.NewWriter(f)
+	manifest := []byte(`[{"Config":"config.json","RepoTags":["redis:7"],"Layers":[]}]`)
+	tw.WriteHeader(&tar.Header{Name: "manifest.json", Size: int64(len(manifest)), Mode: 0644})
+	tw.Write(manifest)
+	tw.Close()
+	f.Close()
+
+	b := NewBundler([]string{chartDir}, []string{
+		"nginx:1.25=" + filepath.Join(dir, "nginx"),
+		PrefixRemote + "busybox:1.36",
+		tarPath,
+	})
+	missing, err := b.MissingImages()
+	if err != nil {
+		t.Fatalf("MissingImages failed: %v", err)
+	}
+	if got := missing[chartDir]; !slices.Equal(got, []string{"ghcr.io/acme/worker:v2"}) {
+		t.Errorf("missing = %v, want only the worker image", got)
+	}
+}
+
+func TestBundler_MissingImages_UnknownTag(t *testing.T) {
+	dir := t.TempDir()
+	chartDir := filepath.Join(dir, "app")
+	os.MkdirAll(chartDir, 0755)
+	os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(`
+image:
+  repository: nginx
+worker:
+  image:
+    repository: ghcr.io/acme/worker
+`), 0644)
+
+	b := NewBundler([]string{chartDir}, []string{"nginx:1.27=" + filepath.Join(dir, "nginx")})
+	missing, err := b.MissingImages()
+	if err != nil {
+		t.Fatalf("MissingImages failed: %v", err)
+	}
+	if got := missing[chartDir]; !slices.Equal(got, []string{"ghcr.io/acme/worker:<unknown>"}) {
+		t.Errorf("missing = %v, want only the worker image with an unknown tag", got)
+	}
+
+	b.TagFallback = "latest"
+	missing, err = b.MissingImages()
+	if err != nil {
+		t.Fatalf("MissingImages failed: %v", err)
+	}
+	if got := missing[chartDir]; !slices.Equal(got, []string{"ghcr.io/acme/worker:latest", "nginx:latest"}) {
+		t.Errorf("missing = %v, want both images at the fallback tag", got)
+	}
+}
+
+func TestImageTarRefs_Gzipped(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	manifest := []byte(`[{"Config":"config.json","RepoTags":["redis:7"],"Layers":[]}]`)
+	tw.WriteHeader(&tar.Header{Name: "manifest.json", Size: int64(len(manifest)), Mode: 0644})
+	tw.Write(manifest)
+	tw.Close()
+	gz.Close()
+
+	path := filepath.Join(t.TempDir(), "redis.tar.gz")
+	os.WriteFile(path, buf.Bytes(), 0644)
+	if got := imageTarRefs(path); !slices.Equal(got, []string{"redis:7"}) {
+		t.Errorf("imageTarRefs = %v, want [redis:7]", got)
+	}
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/crane"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DoctorCheck is one item of the environment checklist
+type DoctorCheck struct {
+	Name    string
+	Passed  bool
+	Blocker bool   // A failed blocker means a run can't succeed; other failures are warnings
+	Detail  string // What was found, or how to fix a failure
+}
+
+// DoctorSettings selects which environment Doctor checks
+type DoctorSettings struct {
+	ExecMode               string // "docker" or "k8s"
+	Namespace              string // Namespace the runner pod would be created in (k8s mode)
+	Image                  string
+	AsJob                  bool // Check the permissions needed to run the runner as a Job (k8s mode)
+	PermissionCheckRetries int
+}
+
+// Doctor checks everything a run needs up front (Docker or cluster access, permissions,
+// the runner image) and returns one result per check. Checks that depend on a failed
+// blocker are skipped.
+func Doctor(ctx context.Context, settings DoctorSettings) []DoctorCheck {
+	if settings.ExecMode == "docker" {
+		return doctorDocker(ctx, settings)
+	}
+	return doctorKubernetes(ctx, settings)
+}
+
+// doctorDocker checks the local Docker daemon and the runner image
+func doctorDocker(ctx context.Context, settings DoctorSettings) []DoctorCheck {
+	var checks []DoctorCheck
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err == nil {
+		_, err = cli.Ping(ctx)
+	}
+	if err != nil {
+		return append(checks, DoctorCheck{Name: "Docker daemon reachable", Blocker: true, Detail: err.Error()})
+	}
+	checks = append(checks, DoctorCheck{Name: "Docker daemon reachable", Passed: true, Detail: cli.DaemonHost()})
+
+	info, err := cli.Info(ctx)
+	if err != nil {
+		checks = append(checks, DoctorCheck{Name: "Privileged nested containers", Blocker: true, Detail: fmt.Sprintf("failed to query daemon info: %v", err)})
+	} else {
+		problems, warnings := preflightProblems(info)
+		checks = append(checks, DoctorCheck{
+			Name:    "Privileged nested containers",
+			Passed:  len(problems) == 0,
+			Blocker: true,
+			Detail:  strings.Join(problems, "; "),
+		})
+		for _, w := range warnings {
+			checks = append(checks, DoctorCheck{Name: "Docker host", Detail: w})
+		}
+	}
+
+	// LaunchLocal pulls a missing image unless --pull-policy is never
+	if _, err := cli.ImageInspect(ctx, settings.Image); err != nil {
+		check := DoctorCheck{Name: "Runner image available", Blocker: true, Detail: err.Error()}
+		if client.IsErrNotFound(err) {
+			check.Blocker = false
+			check.Detail = fmt.Sprintf("not present locally; 'start' pulls it unless --pull-policy is never (or run: docker pull %s)", settings.Image)
+		}
+		checks = append(checks, check)
+	} else {
+		checks = append(checks, DoctorCheck{Name: "Runner image available", Passed: true, Detail: settings.Image})
+	}
+
+	return checks
+}
+
+// doctorKubernetes checks cluster access, the namespace, permissions and the runner image
+func doctorKubernetes(ctx context.Context, settings DoctorSettings) []DoctorCheck {
+	var checks []DoctorCheck
+
+	config, source, err := loadKubeConfig()
+	if err != nil {
+		return append(checks, DoctorCheck{Name: "Kubeconfig loads", Blocker: true, Detail: err.Error()})
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return append(checks, DoctorCheck{Name: "Kubeconfig loads", Blocker: true, Detail: err.Error()})
+	}
+	checks = append(checks, DoctorCheck{Name: "Kubeconfig loads", Passed: true, Detail: fmt.Sprintf("%s (%s)", source, config.Host)})
+
+	nsCheck := DoctorCheck{Name: fmt.Sprintf("Namespace %q exists", settings.Namespace), Blocker: true}
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, settings.Namespace, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		nsCheck.Passed = true
+	case apierrors.IsForbidden(err):
+		// Namespaced service accounts often can't read namespaces; the pod checks below still tell
+		nsCheck.Blocker = false
+		nsCheck.Detail = "not allowed to read namespaces, can't confirm"
+	case apierrors.IsNotFound(err):
+		nsCheck.Detail = fmt.Sprintf("create it with: kubectl create namespace %s", settings.Namespace)
+	default:
+		// The API server is unreachable; nothing else can be checked
+		nsCheck.Detail = err.Error()
+		return append(checks, nsCheck)
+	}
+	checks = append(checks, nsCheck)
+
+	results, err := CheckPermissions(ctx, clientset, settings.Namespace, settings.AsJob, settings.PermissionCheckRetries)
+	if err != nil {
+		checks = append(checks, DoctorCheck{Name: "Permissions", Detail: fmt.Sprintf("could not check: %v", err)})
+	}
+	for _, perm := range results {
+		check := DoctorCheck{
+			Name:    fmt.Sprintf("Can %s %s", perm.Verb, perm.Resource),
+			Passed:  perm.Allowed,
+			Blocker: perm.Required,
+		}
+		if !perm.Allowed {
+			check.Detail = fmt.Sprintf("needed to %s", perm.Reason)
+		}
+		checks = append(checks, check)
+	}
+
+	// Resolved with the local registry credentials; nodes may use different ones
+	if digest, err := crane.Digest(settings.Image, crane.WithContext(ctx)); err != nil {
+		checks = append(checks, DoctorCheck{Name: "Runner image pullable", Blocker: true, Detail: err.Error()})
+	} else {
+		checks = append(checks, DoctorCheck{Name: "Runner image pullable", Passed: true, Detail: settings.Image + "@" + digest})
+	}
+
+	return checks
+}
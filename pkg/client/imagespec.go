@@ -0,0 +1,157 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// imageSpec is a parsed --load-images entry: [tag=]<prefix><target> or a bare path
+type imageSpec struct {
+	tag    string // Optional tag from the tag=path form
+	prefix string // One of the Prefix* constants, or "" for a bare path
+	target string // Path or remote reference with the prefix removed
+}
+
+// imagePrefixes are the supported --load-images source prefixes
+var imagePrefixes = []string{PrefixOCI, PrefixTar, PrefixOCITar, PrefixRemote}
+
+// parseImageSpec splits an image spec into its tag, prefix and target
+func parseImageSpec(spec string) imageSpec {
+	var parsed imageSpec
+	// Check for tag=path prefix
+	if parts := strings.SplitN(spec, "=", 2); len(parts) == 2 && hasImageSource(parts[1]) {
+		parsed.tag = parts[0]
+		spec = parts[1]
+	}
+
+	parsed.target = spec
+	for _, prefix := range imagePrefixes {
+		if strings.HasPrefix(spec, prefix) {
+			parsed.prefix = prefix
+			parsed.target = strings.TrimPrefix(spec, prefix)
+			break
+		}
+	}
+	return parsed
+}
+
+// hasImageSource reports whether s looks like the path side of a tag=path spec
+func hasImageSource(s string) bool {
+	if strings.HasPrefix(s, "/") {
+		return true
+	}
+	for _, prefix := range imagePrefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateImageSpecs checks every --load-images entry up front (form, prefix, and that
+// local files/directories exist) so typos fail before a cluster is booted
+func ValidateImageSpecs(specs []string) error {
+	var errs []error
+	for _, spec := range specs {
+		if err := validateImageSpec(spec); err != nil {
+			errs = append(errs, fmt.Errorf("invalid image %q: %w", spec, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidatePlatform checks a --platform value (os/arch or os/arch/variant, e.g. linux/arm64)
+func ValidatePlatform(platform string) error {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return fmt.Errorf("invalid platform %q: expected os/arch[/variant], e.g. linux/arm64", platform)
+	}
+	return nil
+}
+
+func validateImageSpec(spec string) error {
+	if strings.TrimSpace(spec) == "" {
+		return fmt.Errorf("empty image spec")
+	}
+
+	parsed := parseImageSpec(spec)
+	if strings.Contains(spec, "=") && parsed.tag == "" && parsed.prefix == "" {
+		if _, err := os.Stat(spec); err != nil {
+			return fmt.Errorf("in tag=path form the path must be absolute or start with %s", strings.Join(imagePrefixes, ", "))
+		}
+	}
+	if strings.HasPrefix(spec, "=") {
+		return fmt.Errorf("missing tag before '='")
+	}
+	if parsed.prefix == "" && strings.Contains(parsed.target, "://") {
+		return fmt.Errorf("unknown prefix (supported: %s)", strings.Join(imagePrefixes, ", "))
+	}
+	if parsed.target == "" {
+		return fmt.Errorf("missing path or reference after %s", parsed.prefix)
+	}
+
+	switch parsed.prefix {
+	case PrefixRemote:
+		if _, err := name.ParseReference(parsed.target); err != nil {
+			return fmt.Errorf("invalid remote reference: %w", err)
+		}
+		return nil
+
+	case PrefixOCI:
+		return checkOCILayout(parsed.target)
+
+	case PrefixTar, PrefixOCITar:
+		return checkImageTar(parsed.target)
+	}
+
+	info, err := os.Stat(parsed.target)
+	if err != nil {
+		return fmt.Errorf("image path not found: %s", parsed.target)
+	}
+	if info.IsDir() {
+		return checkOCILayout(parsed.target)
+	}
+	if !isImageTarFile(parsed.target) {
+		return fmt.Errorf("unsupported image format (expected .tar, .tar.gz or .tgz file or OCI directory, or use %s prefix)", strings.Join(imagePrefixes, ", "))
+	}
+	return nil
+}
+
+// isImageTarFile reports whether a path names an image tar, plain or gzipped. Gzipped
+// tars are bundled as-is and decompressed by the runner on import.
+func isImageTarFile(path string) bool {
+	return strings.HasSuffix(path, ".tar") || strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// checkOCILayout verifies dir is an OCI image layout
+func checkOCILayout(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("OCI directory not found: %s", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory (use tar:// for image tars)", dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.json")); err != nil {
+		return fmt.Errorf("%s is not an OCI image layout (no index.json)", dir)
+	}
+	return nil
+}
+
+// checkImageTar verifies path is an existing regular file
+func checkImageTar(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("image tar not found: %s", path)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory (use oci:// for OCI layouts)", path)
+	}
+	return nil
+}
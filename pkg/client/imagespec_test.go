@@ -0,0 +1,80 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseImageSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want imageSpec
+	}{
+		{"oci:///img", imageSpec{prefix: PrefixOCI, target: "/img"}},
+		{"myapp:v1=oci:///img", imageSpec{tag: "myapp:v1", prefix: PrefixOCI, target: "/img"}},
+		{"myapp:v1=/tmp/app.tar", imageSpec{tag: "myapp:v1", target: "/tmp/app.tar"}},
+		{"tar://./app.tar", imageSpec{prefix: PrefixTar, target: "./app.tar"}},
+		{"remote://nginx:1.25", imageSpec{prefix: PrefixRemote, target: "nginx:1.25"}},
+		{"./relative.tar", imageSpec{target: "./relative.tar"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseImageSpec(tt.spec); got != tt.want {
+			t.Errorf("parseImageSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestValidateImageSpecs(t *testing.T) {
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "app.tar")
+	os.WriteFile(tarPath, []byte("tar"), 0644)
+	gzPath := filepath.Join(dir, "app.tar.gz")
+	os.WriteFile(gzPath, []byte("gz"), 0644)
+	tgzPath := filepath.Join(dir, "app.tgz")
+	os.WriteFile(tgzPath, []byte("gz"), 0644)
+	ociDir := filepath.Join(dir, "layout")
+	os.MkdirAll(ociDir, 0755)
+	os.WriteFile(filepath.Join(ociDir, "index.json"), []byte("{}"), 0644)
+
+	valid := []string{
+		tarPath,
+		gzPath,
+		tgzPath,
+		"tar://" + tarPath,
+		"oci-tar://" + tarPath,
+		"oci://" + ociDir,
+		"myapp:v1=oci://" + ociDir,
+		ociDir,
+		"remote://nginx:1.25",
+	}
+	if err := ValidateImageSpecs(valid); err != nil {
+		t.Errorf("expected valid specs, got: %v", err)
+	}
+
+	invalid := map[string]string{
+		"tar://" + filepath.Join(dir, "missing.tar"): "image tar not found",
+		"oci://" + dir:                "not an OCI image layout",
+		"oci://" + tarPath:            "is not a directory",
+		"myapp:v1=./relative/image":   "tag=path form",
+		"=oci://" + ociDir:            "missing tag",
+		"docker://nginx":              "unknown prefix",
+		"remote://":                   "missing path or reference",
+		"remote://Bad Ref":            "invalid remote reference",
+		filepath.Join(dir, "app.zip"): "image path not found",
+		"":                            "empty image spec",
+	}
+	for spec, wantMsg := range invalid {
+		err := ValidateImageSpecs([]string{spec})
+		if err == nil || !strings.Contains(err.Error(), wantMsg) {
+			t.Errorf("ValidateImageSpecs(%q) = %v, want error containing %q", spec, err, wantMsg)
+		}
+	}
+
+	err := ValidateImageSpecs([]string{"docker://a", "docker://b"})
+	if err == nil || strings.Count(err.Error(), "unknown prefix") != 2 {
+		t.Errorf("expected one error per bad spec, got: %v", err)
+	}
+}
@@ -4,22 +4,33 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"net"
 	"net/http"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 
 	parcelconfig "github.com/tiborv/kube-parcel/pkg/config"
 
-	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -27,16 +38,27 @@ import (
 	"k8s.io/client-go/util/homedir"
 )
 
+// Runner containers and pods carry this label so Stop can find them again
+const (
+	runnerLabel      = "app"
+	runnerLabelValue = "kube-parcel"
+
+	// runnerNamePrefix starts every generated container, network and pod name
+	runnerNamePrefix = "kube-parcel-"
+)
+
 // generateUniqueName creates a unique name for containers/pods to enable parallel execution
 func generateUniqueName() string {
 	b := make([]byte, 4)
 	rand.Read(b)
-	return fmt.Sprintf("kube-parcel-%s", hex.EncodeToString(b))
+	return runnerNamePrefix + hex.EncodeToString(b)
 }
 
 // ServerHandle represents a running server instance
 type ServerHandle struct {
 	mode        string
+	name        string
+	namespace   string
 	url         string
 	cleanup     func() error
 	dockerCli   *client.Client
@@ -48,16 +70,62 @@ func (h *ServerHandle) URL() string {
 	return h.url
 }
 
-// Cleanup stops the server
+// Name returns the server container or pod name
+func (h *ServerHandle) Name() string {
+	return h.name
+}
+
+// Cleanup stops the server and forgets its session
 func (h *ServerHandle) Cleanup() error {
-	if h.cleanup != nil {
-		return h.cleanup()
+	if h.cleanup == nil {
+		return nil
 	}
-	return nil
+	err := h.cleanup()
+	if err == nil {
+		forgetSessions(h.name)
+	}
+	return err
 }
 
-// LaunchLocal starts the server using Docker
-func LaunchLocal(ctx context.Context, image string, env map[string]string) (*ServerHandle, error) {
+// session describes the handle for the session file
+func (h *ServerHandle) session() Session {
+	execMode := "docker"
+	if h.mode == "remote" {
+		execMode = "k8s"
+	}
+	return Session{
+		Name:      h.name,
+		ExecMode:  execMode,
+		URL:       h.url,
+		ID:        h.containerID,
+		Namespace: h.namespace,
+		Started:   time.Now(),
+	}
+}
+
+// LocalSettings defines the Docker containers started by LaunchLocal
+type LocalSettings struct {
+	Image  string
+	Env    map[string]string
+	Agents int // Extra runner containers joined to the server as K3s agent nodes
+
+	HTTPPort int  // Port the runner listens on inside the container (default 8080)
+	TLS      bool // Serve the runner's API over HTTPS (self-signed) on 8443
+
+	SkipPreflight bool // Don't check the Docker host for privileged nested container support
+
+	CPU    string // CPU limit per container in Kubernetes notation, e.g. 1000m or 2 ("" = unlimited)
+	Memory string // Memory limit per container in Kubernetes notation, e.g. 2Gi ("" = unlimited)
+
+	PullPolicy string // When to pull the runner image: always, missing or never ("" = missing)
+
+	Network    string   // Existing Docker network the containers join ("" = default bridge, or a dedicated network with agents)
+	ExtraHosts []string // Extra /etc/hosts entries as name:ip, e.g. db.local:10.0.0.5 or host.docker.internal:host-gateway
+}
+
+// LaunchLocal starts the server using Docker. With agents, the server and agent containers
+// share a dedicated Docker network so agents can reach the server's K3s API by name.
+func LaunchLocal(ctx context.Context, settings LocalSettings) (*ServerHandle, error) {
 	log.Println("🐳 Launching server locally with Docker...")
 
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
@@ -65,89 +133,351 @@ func LaunchLocal(ctx context.Context, image string, env map[string]string) (*Ser
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
 	}
 
-	// Note: Add image pull logic if needed
+	if !settings.SkipPreflight {
+		if err := checkDockerHost(ctx, cli); err != nil {
+			return nil, err
+		}
+	}
 
-	var envList []string
-	for k, v := range env {
-		envList = append(envList, fmt.Sprintf("%s=%s", k, v))
+	resources, err := dockerResources(settings.CPU, settings.Memory)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureRunnerImage(ctx, cli, settings.Image, settings.PullPolicy); err != nil {
+		return nil, err
+	}
+
+	containerName := generateUniqueName()
+	env := maps.Clone(settings.Env)
+	if env == nil {
+		env = make(map[string]string)
+	}
+	httpPort, scheme, apiEnv := runnerAPI(settings.HTTPPort, settings.TLS)
+	apiPort := nat.Port(fmt.Sprintf("%d/tcp", httpPort))
+	maps.Copy(env, apiEnv)
+
+	// Everything created from here on is torn down again if the launch fails or ctx is
+	// cancelled (Ctrl-C) before the handle is returned
+	var containerIDs []string
+	var networkID string
+	cleanup := func() error {
+		if len(containerIDs) == 0 && networkID == "" {
+			return nil
+		}
+		log.Println("Stopping container...")
+		// Cleanup also runs after the run's deadline has passed or it was interrupted
+		ctx := context.WithoutCancel(ctx)
+		timeout := 10
+		var firstErr error
+		for i := len(containerIDs) - 1; i >= 0; i-- {
+			if err := cli.ContainerStop(ctx, containerIDs[i], container.StopOptions{Timeout: &timeout}); err != nil && !client.IsErrNotFound(err) && firstErr == nil {
+				firstErr = err
+			}
+			if err := cli.ContainerRemove(ctx, containerIDs[i], container.RemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if networkID != "" {
+			if err := cli.NetworkRemove(ctx, networkID); err != nil {
+				log.Printf("Warning: failed to remove network %s: %v", containerName, err)
+			}
+		}
+		return firstErr
+	}
+	launched := false
+	defer func() {
+		if !launched {
+			cleanup()
+		}
+	}()
+
+	var networkingConfig *network.NetworkingConfig
+	var token string
+	networkName := settings.Network
+	if networkName == "" && settings.Agents > 0 {
+		created, err := cli.NetworkCreate(ctx, containerName, network.CreateOptions{Driver: "bridge"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create network: %w", err)
+		}
+		networkID, networkName = created.ID, containerName
+	}
+	if networkName != "" {
+		networkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{networkName: {}},
+		}
+	}
+
+	if settings.Agents > 0 {
+
+		b := make([]byte, 16)
+		rand.Read(b)
+		token = hex.EncodeToString(b)
+		env["KUBE_PARCEL_AGENTS"] = strconv.Itoa(settings.Agents)
+		env["KUBE_PARCEL_K3S_TOKEN"] = token
 	}
 
 	containerConfig := &container.Config{
-		Image:      image,
+		Image:      settings.Image,
+		Hostname:   containerName,
 		Entrypoint: []string{"/app/runner"},
 		Cmd:        []string{},
-		Env:        envList,
+		Env:        envList(env),
+		Labels:     map[string]string{runnerLabel: runnerLabelValue},
 		ExposedPorts: nat.PortSet{
-			"8080/tcp": struct{}{},
+			apiPort:    struct{}{},
 			"9090/tcp": struct{}{},
 		},
 	}
 
-	hostConfig := &container.HostConfig{
-		Privileged:   true,
-		CgroupnsMode: "host",
-		Tmpfs: map[string]string{
-			"/run":     "",
-			"/var/run": "",
+	hostConfig := runnerHostConfig(resources, networkName, settings.ExtraHosts)
+	hostConfig.PortBindings = nat.PortMap{
+		apiPort: []nat.PortBinding{
+			{HostIP: "", HostPort: "0"}, // Dynamic port for parallel execution
 		},
-		// No cgroup mount - K3s will handle internally
-		Binds: []string{},
-		PortBindings: nat.PortMap{
-			"8080/tcp": []nat.PortBinding{
-				{HostIP: "", HostPort: "0"}, // Dynamic port for parallel execution
-			},
-			"9090/tcp": []nat.PortBinding{
-				{HostIP: "", HostPort: "0"}, // Dynamic port for parallel execution
-			},
+		"9090/tcp": []nat.PortBinding{
+			{HostIP: "", HostPort: "0"}, // Dynamic port for parallel execution
 		},
 	}
 
-	containerName := generateUniqueName()
 	log.Printf("Creating container: %s", containerName)
 
-	resp, err := cli.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, containerName)
+	resp, err := cli.ContainerCreate(ctx, containerConfig, hostConfig, networkingConfig, nil, containerName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create container: %w", err)
 	}
+	containerIDs = append(containerIDs, resp.ID)
 
 	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
 		return nil, fmt.Errorf("failed to start container: %w", err)
 	}
 
+	// Agents keep retrying until the server starts K3s (after the upload), so they can start now
+	serverURL := fmt.Sprintf("https://%s:%d", containerName, parcelconfig.K3sAPIPort)
+	for i := 1; i <= settings.Agents; i++ {
+		agentName := fmt.Sprintf("%s-agent-%d", containerName, i)
+		agentEnv := map[string]string{
+			"KUBE_PARCEL_K3S_SERVER": serverURL,
+			"KUBE_PARCEL_K3S_TOKEN":  token,
+		}
+		// Agent nodes pull through their own containerd, so they need the same view of the
+		// network, and must run the server's K3s version
+		for _, key := range []string{"KUBE_PARCEL_AIRGAP", "KUBE_PARCEL_REGISTRIES", "KUBE_PARCEL_K3S_VERSION"} {
+			if value, ok := env[key]; ok {
+				agentEnv[key] = value
+			}
+		}
+		agentConfig := &container.Config{
+			Image:      settings.Image,
+			Hostname:   agentName,
+			Entrypoint: []string{"/app/runner"},
+			Cmd:        []string{},
+			Env:        envList(agentEnv),
+			Labels:     map[string]string{runnerLabel: runnerLabelValue},
+		}
+
+		log.Printf("Creating agent container: %s", agentName)
+		agent, err := cli.ContainerCreate(ctx, agentConfig, runnerHostConfig(resources, networkName, settings.ExtraHosts), networkingConfig, nil, agentName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create agent container: %w", err)
+		}
+		containerIDs = append(containerIDs, agent.ID)
+		if err := cli.ContainerStart(ctx, agent.ID, container.StartOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to start agent container: %w", err)
+		}
+	}
+
 	inspect, err := cli.ContainerInspect(ctx, resp.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
-	ports := inspect.NetworkSettings.Ports["8080/tcp"]
+	ports := inspect.NetworkSettings.Ports[apiPort]
 	if len(ports) == 0 {
-		return nil, fmt.Errorf("no port binding found for 8080/tcp")
+		return nil, fmt.Errorf("no port binding found for %s", apiPort)
 	}
 	hostPort := ports[0].HostPort
-	serverURL := fmt.Sprintf("http://localhost:%s", hostPort)
+	url := fmt.Sprintf("%s://localhost:%s", scheme, hostPort)
 
 	log.Printf("✅ Container started: %s (port %s)", containerName, hostPort)
 	log.Println("Waiting for server to be ready...")
 
-	if err := waitForServer(ctx, serverURL); err != nil {
+	if err := waitForServer(ctx, url); err != nil {
 		return nil, fmt.Errorf("server failed to become ready: %w", err)
 	}
 
 	handle := &ServerHandle{
 		mode:        "local",
-		url:         serverURL,
+		name:        containerName,
+		url:         url,
 		dockerCli:   cli,
 		containerID: resp.ID,
-		cleanup: func() error {
-			log.Println("Stopping container...")
-			timeout := 10
-			return cli.ContainerStop(ctx, resp.ID, container.StopOptions{Timeout: &timeout})
-		},
+		cleanup:     cleanup,
 	}
 
+	launched = true
+	recordSession(handle.session())
 	return handle, nil
 }
 
+// Runner image pull policies (--pull-policy), after Kubernetes's ImagePullPolicy
+const (
+	PullPolicyAlways  = "always"  // Pull before every run, picking up re-pushed tags
+	PullPolicyMissing = "missing" // Pull only when the image isn't present locally
+	PullPolicyNever   = "never"   // Never pull; the image must be present locally
+)
+
+// ParsePullPolicy validates a --pull-policy name: always, missing or never, or one of the
+// Kubernetes names Always, IfNotPresent and Never, which map onto them
+func ParsePullPolicy(s string) (string, error) {
+	switch s {
+	case PullPolicyAlways, PullPolicyMissing, PullPolicyNever:
+		return s, nil
+	case string(corev1.PullAlways):
+		return PullPolicyAlways, nil
+	case string(corev1.PullIfNotPresent):
+		return PullPolicyMissing, nil
+	case string(corev1.PullNever):
+		return PullPolicyNever, nil
+	}
+	return "", fmt.Errorf("invalid --pull-policy %q (expected always, missing or never, or Always, IfNotPresent or Never)", s)
+}
+
+// ensureRunnerImage pulls the runner image as the pull policy requires, so containers are
+// only created once the image is present
+func ensureRunnerImage(ctx context.Context, cli *client.Client, ref, policy string) error {
+	if policy != PullPolicyAlways {
+		_, err := cli.ImageInspect(ctx, ref)
+		if err == nil {
+			return nil
+		}
+		if !client.IsErrNotFound(err) {
+			return fmt.Errorf("failed to inspect runner image %s: %w", ref, err)
+		}
+		if policy == PullPolicyNever {
+			return fmt.Errorf("runner image %s is not present locally and --pull-policy is never; run: docker pull %s", ref, ref)
+		}
+	}
+
+	log.Printf("⬇️  Pulling runner image %s...", ref)
+	progress, err := cli.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull runner image %s: %w", ref, err)
+	}
+	defer progress.Close()
+	if err := logPullProgress(progress); err != nil {
+		return fmt.Errorf("failed to pull runner image %s: %w", ref, err)
+	}
+	log.Printf("✅ Pulled runner image %s", ref)
+	return nil
+}
+
+// logPullProgress logs the overall steps and finished layers of a Docker image pull from
+// its JSON message stream, skipping per-chunk progress, and returns the error the daemon
+// reported, if any. The pull is only complete once the stream ends.
+func logPullProgress(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case msg.Error != "":
+			return errors.New(msg.Error)
+		case msg.ID == "" || strings.HasPrefix(msg.Status, "Pulling from"):
+			log.Printf("   %s", msg.Status)
+		case msg.Status == "Pull complete":
+			log.Printf("   %s: %s", msg.ID, msg.Status)
+		}
+	}
+}
+
+// runnerHostConfig is the host configuration shared by server and agent containers
+func runnerHostConfig(resources container.Resources, networkName string, extraHosts []string) *container.HostConfig {
+	return &container.HostConfig{
+		Privileged:   true,
+		CgroupnsMode: "host",
+		Resources:    resources,
+		NetworkMode:  container.NetworkMode(networkName),
+		ExtraHosts:   extraHosts,
+		Tmpfs: map[string]string{
+			"/run":     "",
+			"/var/run": "",
+		},
+		// No cgroup mount - K3s will handle internally
+		Binds: []string{},
+	}
+}
+
+// ValidateDockerNetworking checks --docker-network and --add-host: the runner's ports must
+// be published, so the host and none networks can't be used, and extra hosts are name:ip
+// (ip may be host-gateway for the Docker host)
+func ValidateDockerNetworking(networkName string, extraHosts []string) error {
+	switch networkName {
+	case "host", "none":
+		return fmt.Errorf("--docker-network %s: the runner's API port must be published, use a bridge network", networkName)
+	}
+	for _, entry := range extraHosts {
+		name, ip, ok := strings.Cut(entry, ":")
+		if !ok || name == "" || (ip != "host-gateway" && net.ParseIP(ip) == nil) {
+			return fmt.Errorf("--add-host %q: expected name:ip (or name:host-gateway)", entry)
+		}
+	}
+	return nil
+}
+
+// dockerResources converts --cpu and --memory limits in Kubernetes quantity notation
+// (1000m, 2Gi) into Docker's units: NanoCPUs and bytes
+func dockerResources(cpu, memory string) (container.Resources, error) {
+	var resources container.Resources
+	if cpu != "" {
+		q, err := resource.ParseQuantity(cpu)
+		if err != nil || q.Sign() <= 0 {
+			return resources, fmt.Errorf("invalid --cpu %q: expected a positive quantity like 1000m or 2", cpu)
+		}
+		resources.NanoCPUs = q.MilliValue() * 1_000_000
+	}
+	if memory != "" {
+		q, err := resource.ParseQuantity(memory)
+		if err != nil || q.Sign() <= 0 {
+			return resources, fmt.Errorf("invalid --memory %q: expected a positive quantity like 512Mi or 2Gi", memory)
+		}
+		resources.Memory = q.Value()
+	}
+	return resources, nil
+}
+
+// runnerAPI returns the port and URL scheme of the runner's API, and the env that
+// configures the runner to serve it there
+func runnerAPI(httpPort int, useTLS bool) (port int, scheme string, env map[string]string) {
+	env = make(map[string]string)
+	switch {
+	case useTLS:
+		env["KUBE_PARCEL_TLS"] = "true"
+		return parcelconfig.DefaultHTTPSPort, "https", env
+	case httpPort == 0 || httpPort == parcelconfig.DefaultHTTPPort:
+		return parcelconfig.DefaultHTTPPort, "http", env
+	}
+	env["KUBE_PARCEL_HTTP_PORT"] = strconv.Itoa(httpPort)
+	return httpPort, "http", env
+}
+
+// envList converts an env map to KEY=value entries
+func envList(env map[string]string) []string {
+	var list []string
+	for k, v := range env {
+		list = append(list, fmt.Sprintf("%s=%s", k, v))
+	}
+	return list
+}
+
 // PodSettings defines customizations for the master pod
 type PodSettings struct {
 	Namespace   string
@@ -160,6 +490,165 @@ type PodSettings struct {
 	Args        []string
 	Env         []corev1.EnvVar
 	HostPID     bool // Use host PID namespace for better nested container support
+
+	NodeSelector map[string]string   // Node labels the runner pod must be scheduled on
+	Tolerations  []corev1.Toleration // Taints the runner pod tolerates, e.g. of dedicated CI nodes
+	Affinity     *corev1.Affinity    // Scheduling affinity of the runner pod (nil = none)
+
+	AsJob           bool          // Run the runner as a batch/v1 Job, so Kubernetes retries and garbage-collects it
+	JobTTL          time.Duration // ttlSecondsAfterFinished of the Job (0 = keep finished Jobs)
+	JobBackoffLimit int32         // Retries of the Job's pod before the Job fails
+
+	TTL time.Duration // Hard lifetime of the runner pod (activeDeadlineSeconds; 0 = unlimited)
+
+	ImagePullPolicy  corev1.PullPolicy // Runner image pull policy (default IfNotPresent)
+	ImagePullSecrets []string          // Secrets in Namespace used to pull the runner image from a private registry
+
+	HTTPPort int  // Port the runner listens on in the pod (default 8080)
+	TLS      bool // Serve the runner's API over HTTPS (self-signed) on 8443
+
+	PermissionCheckRetries int // Retries for transient errors during the RBAC pre-check
+}
+
+// ParseToleration parses a --toleration flag: key=value:Effect tolerates a taint with that
+// value, key:Effect any value of the key, and key=value or key every effect. Effect is
+// NoSchedule, PreferNoSchedule or NoExecute.
+func ParseToleration(s string) (corev1.Toleration, error) {
+	spec, effect, hasEffect := strings.Cut(s, ":")
+	key, value, hasValue := strings.Cut(spec, "=")
+	if key == "" {
+		return corev1.Toleration{}, fmt.Errorf("--toleration %q: expected key=value:Effect", s)
+	}
+
+	toleration := corev1.Toleration{Key: key, Operator: corev1.TolerationOpExists}
+	if hasValue {
+		toleration.Operator, toleration.Value = corev1.TolerationOpEqual, value
+	}
+	if hasEffect {
+		switch e := corev1.TaintEffect(effect); e {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+			toleration.Effect = e
+		default:
+			return corev1.Toleration{}, fmt.Errorf("--toleration %q: effect must be NoSchedule, PreferNoSchedule or NoExecute", s)
+		}
+	}
+	return toleration, nil
+}
+
+// jobNameLabel is set by the Job controller on the pods it creates
+const jobNameLabel = "job-name"
+
+// expiresAtAnnotation records when a runner with --ttl is killed, for humans and scripts
+// sweeping leaked runners
+const expiresAtAnnotation = "kube-parcel/expires-at"
+
+// applyTTL bounds the runner pod's lifetime: Kubernetes kills it ttl after it started
+// (activeDeadlineSeconds), even if the client that launched it is gone
+func applyTTL(pod *corev1.Pod, ttl time.Duration, now time.Time) {
+	if ttl <= 0 {
+		return
+	}
+	deadline := int64(ttl.Seconds())
+	pod.Spec.ActiveDeadlineSeconds = &deadline
+
+	annotations := maps.Clone(pod.Annotations)
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[expiresAtAnnotation] = now.Add(ttl).UTC().Format(time.RFC3339)
+	pod.Annotations = annotations
+}
+
+// runnerJob wraps the runner pod in a Job. The pod spec keeps RestartPolicy Never, so a
+// failed runner is replaced by a new pod (up to JobBackoffLimit times) rather than restarted.
+func runnerJob(pod *corev1.Pod, settings PodSettings) *batchv1.Job {
+	backoffLimit := settings.JobBackoffLimit
+	job := &batchv1.Job{
+		ObjectMeta: pod.ObjectMeta,
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: pod.Labels, Annotations: pod.Annotations},
+				Spec:       pod.Spec,
+			},
+		},
+	}
+	if settings.JobTTL > 0 {
+		ttl := int32(settings.JobTTL.Seconds())
+		job.Spec.TTLSecondsAfterFinished = &ttl
+	}
+	if settings.TTL > 0 {
+		// Also bound the Job as a whole, so retried pods can't extend the lifetime
+		deadline := int64(settings.TTL.Seconds())
+		job.Spec.ActiveDeadlineSeconds = &deadline
+	}
+	return job
+}
+
+// newestPod returns the most recently created pod, or nil if there is none
+func newestPod(pods []corev1.Pod) *corev1.Pod {
+	var newest *corev1.Pod
+	for i := range pods {
+		if newest == nil || newest.CreationTimestamp.Before(&pods[i].CreationTimestamp) {
+			newest = &pods[i]
+		}
+	}
+	return newest
+}
+
+// jobFinished describes why a Job has finished (its Failed or Complete condition), or
+// returns "" while it is still running
+func jobFinished(job *batchv1.Job) string {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue || (c.Type != batchv1.JobFailed && c.Type != batchv1.JobComplete) {
+			continue
+		}
+		if c.Message != "" {
+			return fmt.Sprintf("%s: %s", c.Type, c.Message)
+		}
+		return string(c.Type)
+	}
+	return ""
+}
+
+// imagePullFailure returns why the pod's runner image can't be pulled once kubelet has given
+// up retrying for now (ImagePullBackOff) or the reference is invalid, or "" otherwise
+func imagePullFailure(pod *corev1.Pod) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if w := cs.State.Waiting; w != nil && (w.Reason == "ImagePullBackOff" || w.Reason == "InvalidImageName") {
+			if w.Message != "" {
+				return w.Reason + ": " + w.Message
+			}
+			return w.Reason
+		}
+	}
+	return ""
+}
+
+// KubernetesPullPolicy maps a docker-mode pull policy (--pull-policy) to the equivalent
+// Kubernetes ImagePullPolicy
+func KubernetesPullPolicy(policy string) corev1.PullPolicy {
+	switch policy {
+	case PullPolicyAlways:
+		return corev1.PullAlways
+	case PullPolicyNever:
+		return corev1.PullNever
+	}
+	return corev1.PullIfNotPresent
+}
+
+// loadKubeConfig uses the in-cluster configuration if available, falling back to
+// ~/.kube/config. source describes which one was used.
+func loadKubeConfig() (config *rest.Config, source string, err error) {
+	if config, err := rest.InClusterConfig(); err == nil {
+		return config, "in-cluster configuration", nil
+	}
+	kubeconfig := filepath.Join(homedir.HomeDir(), ".kube", "config")
+	config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return config, kubeconfig, nil
 }
 
 // LaunchRemote starts the server using Kubernetes
@@ -169,47 +658,37 @@ func LaunchRemote(ctx context.Context, settings PodSettings) (*ServerHandle, err
 	if len(settings.Command) == 0 {
 		settings.Command = []string{"/app/runner"}
 	}
-
-	var config *rest.Config
-	var err error
-	config, err = rest.InClusterConfig()
-	if err == nil {
-		log.Println("✅ Using in-cluster configuration")
+	if settings.ImagePullPolicy == "" {
+		settings.ImagePullPolicy = corev1.PullIfNotPresent
+	}
+	httpPort, scheme, apiEnv := runnerAPI(settings.HTTPPort, settings.TLS)
+	settings.Env = slices.Clone(settings.Env)
+	for _, name := range slices.Sorted(maps.Keys(apiEnv)) {
+		settings.Env = append(settings.Env, corev1.EnvVar{Name: name, Value: apiEnv[name]})
+	}
+	probeScheme := corev1.URISchemeHTTP
+	if settings.TLS {
+		probeScheme = corev1.URISchemeHTTPS
 	}
+
+	config, source, err := loadKubeConfig()
 	if err != nil {
-		log.Println("Not running in-cluster, falling back to kubeconfig...")
-		kubeconfig := filepath.Join(homedir.HomeDir(), ".kube", "config")
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
-		}
+		return nil, err
 	}
+	log.Printf("✅ Using %s", source)
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
 
-	ssar := &authorizationv1.SelfSubjectAccessReview{
-		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
-			ResourceAttributes: &authorizationv1.ResourceAttributes{
-				Namespace: settings.Namespace,
-				Verb:      "create",
-				Resource:  "pods",
-			},
-		},
-	}
-
-	review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, ssar, metav1.CreateOptions{})
-	if err != nil {
-		log.Printf("⚠️  Warning: Failed to verify permissions (SelfSubjectAccessReview): %v", err)
-	} else if !review.Status.Allowed {
-		return nil, fmt.Errorf("❌ Missing permission: Cannot create Pods in namespace %q. Please ensure the CI service account has 'create' access to 'pods'.", settings.Namespace)
+	if err := verifyRemotePermissions(ctx, clientset, settings.Namespace, settings.AsJob, settings.PermissionCheckRetries); err != nil {
+		return nil, err
 	}
 
 	privileged := true
-	podName := generateUniqueName()
-	log.Printf("Creating pod: %s in namespace %s", podName, settings.Namespace)
+	runnerName := generateUniqueName()
+	podName := runnerName // A Job's pod gets a generated name, found once it exists
 
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -219,31 +698,57 @@ func LaunchRemote(ctx context.Context, settings PodSettings) (*ServerHandle, err
 			Annotations: settings.Annotations,
 		},
 		Spec: corev1.PodSpec{
-			HostPID: settings.HostPID,
+			HostPID:      settings.HostPID,
+			NodeSelector: settings.NodeSelector,
+			Tolerations:  settings.Tolerations,
+			Affinity:     settings.Affinity,
+			// The runner exits on its own (e.g. after an idle timeout); let the pod complete
+			RestartPolicy: corev1.RestartPolicyNever,
 			Containers: []corev1.Container{
 				{
 					Name:            "orchestrator",
 					Image:           settings.Image,
-					ImagePullPolicy: corev1.PullIfNotPresent,
+					ImagePullPolicy: settings.ImagePullPolicy,
 					Command:         settings.Command,
 					Args:            settings.Args,
 					SecurityContext: &corev1.SecurityContext{
 						Privileged: &privileged,
 					},
 					Ports: []corev1.ContainerPort{
-						{Name: "http", ContainerPort: 8080},
-						{Name: "grpc", ContainerPort: 9090},
+						{Name: scheme, ContainerPort: int32(httpPort)},
+						{Name: "metrics", ContainerPort: parcelconfig.DefaultMetricsPort},
 					},
 					Env: settings.Env,
+					// Readiness follows the runner's state, so the pod drops out of
+					// Ready while a parcel is being processed and after a failed run
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{Path: "/readyz", Port: intstr.FromString(scheme), Scheme: probeScheme},
+						},
+						PeriodSeconds: 2,
+					},
+					LivenessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromString(scheme), Scheme: probeScheme},
+						},
+						InitialDelaySeconds: 10,
+						PeriodSeconds:       10,
+						FailureThreshold:    3,
+					},
 				},
 			},
 		},
 	}
 
+	for _, secret := range settings.ImagePullSecrets {
+		pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: secret})
+	}
+
 	if pod.Labels == nil {
 		pod.Labels = make(map[string]string)
 	}
-	pod.Labels["app"] = "kube-parcel"
+	pod.Labels[runnerLabel] = runnerLabelValue
+	applyTTL(pod, settings.TTL, time.Now())
 
 	if settings.CPU != "" || settings.Memory != "" {
 		resources := corev1.ResourceRequirements{
@@ -258,23 +763,81 @@ func LaunchRemote(ctx context.Context, settings PodSettings) (*ServerHandle, err
 		pod.Spec.Containers[0].Resources = resources
 	}
 
-	_, err = clientset.CoreV1().Pods(settings.Namespace).Create(ctx, pod, metav1.CreateOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create pod: %w", err)
+	cleanup := func() error {
+		if settings.AsJob {
+			log.Println("Stopping remote job...")
+			// Deleting the Job also deletes its pod
+			propagation := metav1.DeletePropagationBackground
+			return clientset.BatchV1().Jobs(settings.Namespace).Delete(context.WithoutCancel(ctx), runnerName, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		}
+		log.Println("Stopping remote pod...")
+		return clientset.CoreV1().Pods(settings.Namespace).Delete(context.WithoutCancel(ctx), podName, metav1.DeleteOptions{})
 	}
 
+	var uid string
+	if settings.AsJob {
+		log.Printf("Creating job: %s in namespace %s", runnerName, settings.Namespace)
+		created, err := clientset.BatchV1().Jobs(settings.Namespace).Create(ctx, runnerJob(pod, settings), metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create job: %w", err)
+		}
+		uid = string(created.UID)
+	} else {
+		log.Printf("Creating pod: %s in namespace %s", podName, settings.Namespace)
+		created, err := clientset.CoreV1().Pods(settings.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create pod: %w", err)
+		}
+		uid = string(created.UID)
+	}
+	// Don't leave the runner behind if it never becomes ready or the launch is interrupted
+	launched := false
+	defer func() {
+		if !launched {
+			cleanup()
+		}
+	}()
+
 	var podIP string
 	var lastRestartCount int32
 
 	log.Printf("⏳ Waiting for pod %s to be fully ready...", podName)
 	err = wait.PollUntilContextTimeout(ctx, 1*time.Second, 5*time.Minute, true, func(ctx context.Context) (bool, error) {
-		p, err := clientset.CoreV1().Pods(settings.Namespace).Get(ctx, podName, metav1.GetOptions{})
-		if err != nil {
-			return false, err
+		var p *corev1.Pod
+		if settings.AsJob {
+			pods, err := clientset.CoreV1().Pods(settings.Namespace).List(ctx, metav1.ListOptions{LabelSelector: jobNameLabel + "=" + runnerName})
+			if err != nil {
+				return false, err
+			}
+			if p = newestPod(pods.Items); p == nil {
+				fmt.Print(".")
+				return false, nil
+			}
+			podName = p.Name
+		} else {
+			var err error
+			if p, err = clientset.CoreV1().Pods(settings.Namespace).Get(ctx, podName, metav1.GetOptions{}); err != nil {
+				return false, err
+			}
 		}
 
 		if p.Status.Phase == corev1.PodFailed || p.Status.Phase == corev1.PodSucceeded {
-			return false, fmt.Errorf("pod reached terminal state: %s", p.Status.Phase)
+			if !settings.AsJob {
+				return false, fmt.Errorf("pod reached terminal state: %s", p.Status.Phase)
+			}
+			// The Job replaces a failed pod until its backoff limit is reached
+			job, err := clientset.BatchV1().Jobs(settings.Namespace).Get(ctx, runnerName, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			if finished := jobFinished(job); finished != "" {
+				return false, fmt.Errorf("job %s finished before the runner became ready: %s", runnerName, finished)
+			}
+			fmt.Print(".")
+			return false, nil
+		}
+		if reason := imagePullFailure(p); reason != "" {
+			return false, fmt.Errorf("runner image %s can't be pulled (%s); check the image name, or pass --image-pull-secret for a private registry", settings.Image, reason)
 		}
 
 		if p.Status.Phase == corev1.PodRunning {
@@ -311,26 +874,26 @@ func LaunchRemote(ctx context.Context, settings PodSettings) (*ServerHandle, err
 	}
 	log.Printf("📍 Confirmed stable pod IP: %s (restarts: %d)", podIP, lastRestartCount)
 
-	url := fmt.Sprintf("http://localhost:%d", parcelconfig.DefaultHTTPPort)
+	url := fmt.Sprintf("%s://localhost:%d", scheme, httpPort)
 	inCluster := false
 	if _, err := rest.InClusterConfig(); err == nil {
 		inCluster = true
-		url = fmt.Sprintf("http://%s:%d", podIP, parcelconfig.DefaultHTTPPort)
+		url = fmt.Sprintf("%s://%s:%d", scheme, podIP, httpPort)
 		log.Printf("✅ Running in-cluster, using Pod IP: %s", url)
 	}
 	if !inCluster {
-		log.Printf("👉 Please run: kubectl port-forward pod/%s %d:%d -n %s", podName, parcelconfig.DefaultHTTPPort, parcelconfig.DefaultHTTPPort, settings.Namespace)
+		log.Printf("👉 Please run: kubectl port-forward pod/%s %d:%d -n %s", podName, httpPort, httpPort, settings.Namespace)
 	}
 
 	log.Printf("✅ Pod is running!")
 
 	handle := &ServerHandle{
-		mode: "remote",
-		url:  url,
-		cleanup: func() error {
-			log.Println("Stopping remote pod...")
-			return clientset.CoreV1().Pods(settings.Namespace).Delete(ctx, podName, metav1.DeleteOptions{})
-		},
+		mode:        "remote",
+		name:        runnerName,
+		namespace:   settings.Namespace,
+		url:         url,
+		containerID: uid,
+		cleanup:     cleanup,
 	}
 
 	log.Printf("Waiting for server readiness (polling %s)...", url)
@@ -363,7 +926,7 @@ func LaunchRemote(ctx context.Context, settings PodSettings) (*ServerHandle, err
 					newIP := p.Status.PodIP
 					if newIP != "" && newIP != podIP {
 						log.Printf("⚠️ Pod IP changed: %s → %s", podIP, newIP)
-						url = fmt.Sprintf("http://%s:%d", newIP, parcelconfig.DefaultHTTPPort)
+						url = fmt.Sprintf("%s://%s:%d", scheme, newIP, httpPort)
 						handle.url = url
 
 						log.Printf("🔄 Verifying new pod IP: %s...", url)
@@ -386,26 +949,38 @@ func LaunchRemote(ctx context.Context, settings PodSettings) (*ServerHandle, err
 		}
 	}
 
+	launched = true
+	recordSession(handle.session())
 	return handle, nil
 
 }
 
 func waitForServer(ctx context.Context, baseURL string) error {
 	httpClient := &http.Client{
-		Timeout: 2 * time.Second,
+		Transport: runnerClient.Transport,
+		Timeout:   2 * time.Second,
 	}
 	url := fmt.Sprintf("%s/parcel/status", baseURL)
 
 	log.Printf("Polling %s...", baseURL)
 
 	err := wait.PollUntilContextTimeout(ctx, 500*time.Millisecond, parcelconfig.ServerReadinessTimeout, true, func(ctx context.Context) (bool, error) {
-		resp, err := httpClient.Get(url)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false, err
+		}
+		Authorize(req.Header)
+		resp, err := httpClient.Do(req)
 		if err != nil {
 			fmt.Print(".") // Visual feedback
 			return false, nil
 		}
 		resp.Body.Close()
 
+		if resp.StatusCode == http.StatusUnauthorized {
+			fmt.Println()
+			return false, errUnauthorized
+		}
 		if resp.StatusCode == http.StatusOK {
 			fmt.Println()
 			log.Println("✅ Server is ready!")
@@ -0,0 +1,334 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDockerResources(t *testing.T) {
+	tests := []struct {
+		cpu, memory string
+		nanoCPUs    int64
+		bytes       int64
+		wantErr     bool
+	}{
+		{"", "", 0, 0, false},
+		{"1000m", "2Gi", 1_000_000_000, 2 << 30, false},
+		{"1.5", "512Mi", 1_500_000_000, 512 << 20, false},
+		{"250m", "", 250_000_000, 0, false},
+		{"lots", "", 0, 0, true},
+		{"", "-1Gi", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		resources, err := dockerResources(tt.cpu, tt.memory)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("dockerResources(%q, %q): expected an error", tt.cpu, tt.memory)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("dockerResources(%q, %q) failed: %v", tt.cpu, tt.memory, err)
+			continue
+		}
+		if resources.NanoCPUs != tt.nanoCPUs || resources.Memory != tt.bytes {
+			t.Errorf("dockerResources(%q, %q) = %d CPUs, %d bytes; want %d, %d", tt.cpu, tt.memory, resources.NanoCPUs, resources.Memory, tt.nanoCPUs, tt.bytes)
+		}
+	}
+}
+
+func TestValidateDockerNetworking(t *testing.T) {
+	if err := ValidateDockerNetworking("ci-net", []string{"db.local:10.0.0.5", "host.docker.internal:host-gateway", "v6.local:fd00::1"}); err != nil {
+		t.Errorf("expected valid networking flags, got %v", err)
+	}
+	for _, tt := range []struct {
+		network string
+		hosts   []string
+	}{
+		{network: "host"},
+		{network: "none"},
+		{hosts: []string{"db.local"}},
+		{hosts: []string{"db.local:not-an-ip"}},
+		{hosts: []string{":10.0.0.5"}},
+	} {
+		if err := ValidateDockerNetworking(tt.network, tt.hosts); err == nil {
+			t.Errorf("expected %q %v to be rejected", tt.network, tt.hosts)
+		}
+	}
+}
+
+func TestParsePullPolicy(t *testing.T) {
+	for policy, want := range map[string]string{
+		"always":       PullPolicyAlways,
+		"missing":      PullPolicyMissing,
+		"never":        PullPolicyNever,
+		"Always":       PullPolicyAlways,
+		"IfNotPresent": PullPolicyMissing,
+		"Never":        PullPolicyNever,
+	} {
+		if got, err := ParsePullPolicy(policy); err != nil || got != want {
+			t.Errorf("ParsePullPolicy(%q) = %q, %v, want %q", policy, got, err, want)
+		}
+	}
+	for _, policy := range []string{"", "ifnotpresent", "sometimes"} {
+		if _, err := ParsePullPolicy(policy); err == nil {
+			t.Errorf("expected %q to be rejected", policy)
+		}
+	}
+}
+
+func TestLogPullProgress(t *testing.T) {
+	stream := `{"status":"Pulling from tiborv/kube-parcel-runner","id":"v0.0"}
+{"status":"Pulling fs layer","id":"a1"}
+{"status":"Downloading","progressDetail":{"current":10,"total":20},"progress":"[=>  ]","id":"a1"}
+{"status":"Pull complete","id":"a1"}
+{"status":"Digest: sha256:abc"}
+{"status":"Status: Downloaded newer image for ghcr.io/tiborv/kube-parcel-runner:v0.0"}
+`
+	if err := logPullProgress(strings.NewReader(stream)); err != nil {
+		t.Errorf("expected a successful pull, got %v", err)
+	}
+
+	stream = `{"status":"Pulling from tiborv/kube-parcel-runner","id":"v0.0"}
+{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}
+`
+	if err := logPullProgress(strings.NewReader(stream)); err == nil || err.Error() != "manifest unknown" {
+		t.Errorf("expected the daemon's error, got %v", err)
+	}
+}
+
+func TestKubernetesPullPolicy(t *testing.T) {
+	for policy, want := range map[string]corev1.PullPolicy{
+		PullPolicyAlways:  corev1.PullAlways,
+		PullPolicyMissing: corev1.PullIfNotPresent,
+		PullPolicyNever:   corev1.PullNever,
+	} {
+		if got := KubernetesPullPolicy(policy); got != want {
+			t.Errorf("KubernetesPullPolicy(%q) = %s, want %s", policy, got, want)
+		}
+	}
+}
+
+func TestImagePullFailure(t *testing.T) {
+	waiting := func(reason, message string) *corev1.Pod {
+		return &corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: message}},
+		}}}}
+	}
+
+	if got := imagePullFailure(waiting("ImagePullBackOff", "Back-off pulling image")); got != "ImagePullBackOff: Back-off pulling image" {
+		t.Errorf("unexpected failure: %q", got)
+	}
+	if got := imagePullFailure(waiting("InvalidImageName", "")); got != "InvalidImageName" {
+		t.Errorf("unexpected failure: %q", got)
+	}
+	for _, reason := range []string{"ContainerCreating", "ErrImagePull"} {
+		if got := imagePullFailure(waiting(reason, "")); got != "" {
+			t.Errorf("expected %s not to fail the launch, got %q", reason, got)
+		}
+	}
+	if got := imagePullFailure(&corev1.Pod{}); got != "" {
+		t.Errorf("expected no failure without container statuses, got %q", got)
+	}
+}
+
+func TestParseToleration(t *testing.T) {
+	tests := []struct {
+		flag string
+		want corev1.Toleration
+	}{
+		{"dedicated=ci:NoSchedule", corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "ci", Effect: corev1.TaintEffectNoSchedule}},
+		{"gpu:NoExecute", corev1.Toleration{Key: "gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute}},
+		{"dedicated=ci", corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "ci"}},
+		{"spot", corev1.Toleration{Key: "spot", Operator: corev1.TolerationOpExists}},
+	}
+	for _, tt := range tests {
+		got, err := ParseToleration(tt.flag)
+		if err != nil {
+			t.Errorf("ParseToleration(%q) failed: %v", tt.flag, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseToleration(%q) = %+v, want %+v", tt.flag, got, tt.want)
+		}
+	}
+
+	for _, flag := range []string{"", "=ci:NoSchedule", "dedicated=ci:Never"} {
+		if _, err := ParseToleration(flag); err == nil {
+			t.Errorf("expected %q to be rejected", flag)
+		}
+	}
+}
+
+func TestRunnerJob(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-parcel-abc", Namespace: "ci", Labels: map[string]string{runnerLabel: runnerLabelValue}},
+		Spec:       corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever, NodeSelector: map[string]string{"pool": "ci"}},
+	}
+
+	job := runnerJob(pod, PodSettings{JobTTL: 10 * time.Minute, JobBackoffLimit: 2})
+	if job.Name != "kube-parcel-abc" || job.Namespace != "ci" || job.Labels[runnerLabel] != runnerLabelValue {
+		t.Errorf("unexpected job metadata: %+v", job.ObjectMeta)
+	}
+	if job.Spec.TTLSecondsAfterFinished == nil || *job.Spec.TTLSecondsAfterFinished != 600 {
+		t.Errorf("expected ttlSecondsAfterFinished 600, got %v", job.Spec.TTLSecondsAfterFinished)
+	}
+	if *job.Spec.BackoffLimit != 2 {
+		t.Errorf("expected backoffLimit 2, got %d", *job.Spec.BackoffLimit)
+	}
+	if job.Spec.Template.Labels[runnerLabel] != runnerLabelValue || job.Spec.Template.Spec.NodeSelector["pool"] != "ci" {
+		t.Errorf("expected the pod's labels and spec in the template, got %+v", job.Spec.Template)
+	}
+
+	if job := runnerJob(pod, PodSettings{}); job.Spec.TTLSecondsAfterFinished != nil {
+		t.Errorf("expected no TTL without --job-ttl, got %d", *job.Spec.TTLSecondsAfterFinished)
+	}
+	if job := runnerJob(pod, PodSettings{TTL: 30 * time.Minute}); job.Spec.ActiveDeadlineSeconds == nil || *job.Spec.ActiveDeadlineSeconds != 1800 {
+		t.Errorf("expected activeDeadlineSeconds 1800 with --ttl, got %v", job.Spec.ActiveDeadlineSeconds)
+	}
+}
+
+func TestApplyTTL(t *testing.T) {
+	annotations := map[string]string{"team": "ci"}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	applyTTL(pod, 0, now)
+	if pod.Spec.ActiveDeadlineSeconds != nil || len(pod.Annotations) != 1 {
+		t.Errorf("expected no changes without a TTL, got %+v", pod)
+	}
+
+	applyTTL(pod, 30*time.Minute, now)
+	if pod.Spec.ActiveDeadlineSeconds == nil || *pod.Spec.ActiveDeadlineSeconds != 1800 {
+		t.Errorf("expected activeDeadlineSeconds 1800, got %v", pod.Spec.ActiveDeadlineSeconds)
+	}
+	if got := pod.Annotations[expiresAtAnnotation]; got != "2025-01-02T03:34:05Z" {
+		t.Errorf("expected expires-at annotation, got %q", got)
+	}
+	if pod.Annotations["team"] != "ci" {
+		t.Errorf("expected existing annotations to be kept, got %v", pod.Annotations)
+	}
+	if _, ok := annotations[expiresAtAnnotation]; ok {
+		t.Error("expected the caller's annotation map to be left untouched")
+	}
+}
+
+func TestNewestPod(t *testing.T) {
+	if newestPod(nil) != nil {
+		t.Error("expected no pod from an empty list")
+	}
+	now := time.Now()
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "first", CreationTimestamp: metav1.NewTime(now.Add(-time.Minute))}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "retry", CreationTimestamp: metav1.NewTime(now)}},
+	}
+	if got := newestPod(pods); got.Name != "retry" {
+		t.Errorf("expected the retried pod, got %s", got.Name)
+	}
+}
+
+func TestJobFinished(t *testing.T) {
+	job := &batchv1.Job{}
+	if got := jobFinished(job); got != "" {
+		t.Errorf("expected a running job, got %q", got)
+	}
+	job.Status.Conditions = []batchv1.JobCondition{
+		{Type: batchv1.JobFailed, Status: corev1.ConditionFalse},
+		{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "Job has reached the specified backoff limit"},
+	}
+	if got := jobFinished(job); got != "Failed: Job has reached the specified backoff limit" {
+		t.Errorf("unexpected finished reason: %q", got)
+	}
+}
+
+// fakeDocker serves the parts of the Docker API LaunchLocal uses and tracks which
+// containers exist
+type fakeDocker struct {
+	t          *testing.T
+	runnerPort string
+
+	mu         sync.Mutex
+	containers map[string]bool
+}
+
+var dockerAPIVersion = regexp.MustCompile(`^/v[0-9.]+`)
+
+func (d *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := dockerAPIVersion.ReplaceAllString(r.URL.Path, "")
+	w.Header().Set("Api-Version", "1.45")
+	switch {
+	case path == "/_ping":
+		fmt.Fprint(w, "OK")
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/images/"):
+		fmt.Fprint(w, `{"Id":"sha256:runner"}`)
+	case r.Method == http.MethodPost && path == "/containers/create":
+		id := fmt.Sprintf("c%d", len(d.containers))
+		d.containers[id] = true
+		fmt.Fprintf(w, `{"Id":%q}`, id)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/start"),
+		r.Method == http.MethodPost && strings.HasSuffix(path, "/stop"):
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/json"):
+		json.NewEncoder(w).Encode(map[string]any{
+			"Id": strings.Split(path, "/")[2],
+			"NetworkSettings": map[string]any{
+				"Ports": map[string]any{"8080/tcp": []map[string]string{{"HostIp": "", "HostPort": d.runnerPort}}},
+			},
+		})
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/containers/"):
+		delete(d.containers, strings.TrimPrefix(path, "/containers/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		d.t.Errorf("unexpected Docker API call %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"not found"}`)
+	}
+}
+
+func TestLaunchLocal_InterruptedRemovesContainer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The runner never becomes ready; Ctrl-C arrives while the client waits for it
+	runner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer runner.Close()
+
+	docker := &fakeDocker{t: t, runnerPort: runner.URL[strings.LastIndex(runner.URL, ":")+1:], containers: make(map[string]bool)}
+	api := httptest.NewServer(docker)
+	defer api.Close()
+	t.Setenv("DOCKER_HOST", "tcp://"+api.Listener.Addr().String())
+	t.Setenv("DOCKER_API_VERSION", "")
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+	t.Setenv("DOCKER_CERT_PATH", "")
+
+	handle, err := LaunchLocal(ctx, LocalSettings{Image: "kube-parcel:test", SkipPreflight: true})
+	if err == nil {
+		handle.Cleanup()
+		t.Fatal("expected the interrupted launch to fail")
+	}
+
+	docker.mu.Lock()
+	defer docker.mu.Unlock()
+	if len(docker.containers) != 0 {
+		t.Errorf("expected the runner container to be removed, still have %v", docker.containers)
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// isManifestFile reports whether a file is a Kubernetes manifest kubectl apply can read
+func isManifestFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// ValidateManifests checks that every --manifests path exists and is a manifest file or a
+// directory
+func ValidateManifests(paths []string) error {
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("manifests %s: %w", p, err)
+		}
+		if !info.IsDir() && !isManifestFile(p) {
+			return fmt.Errorf("manifests %s: not a .yaml, .yml or .json file", p)
+		}
+	}
+	return nil
+}
+
+// addManifests bundles the Kubernetes manifests under manifests/, which the runner applies
+// before installing charts. Each path gets a numbered prefix (manifests/00-secrets/...) so
+// they apply in the order given; files in a directory apply in lexical order.
+func (b *Bundler) addManifests(tw *tar.Writer) error {
+	for i, root := range b.Manifests {
+		prefix := fmt.Sprintf("manifests/%02d-%s", i, filepath.Base(root))
+		var count int
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() || !isManifestFile(p) {
+				return nil
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			name := prefix
+			if rel != "." {
+				name = path.Join(prefix, filepath.ToSlash(rel))
+			}
+			count++
+			return b.addManifestFile(tw, p, name)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", root, err)
+		}
+		log.Printf("Added %d manifest file(s) from %s", count, root)
+	}
+	return nil
+}
+
+// addManifestFile writes one manifest file to the tar under name
+func (b *Bundler) addManifestFile(tw *tar.Writer, p, name string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := b.writeHeader(tw, header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"archive/tar"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+	"github.com/tiborv/kube-parcel/pkg/shared"
+	"gopkg.in/yaml.v3"
+)
+
+// ChartOverrides are the --set, --values, --release-name and test selection flags applied to a chart
+type ChartOverrides struct {
+	Set         []string // key=value, as passed to helm --set
+	Values      []string // Local values file paths, in precedence order
+	ReleaseName string   // Helm release name ("" derives it from the chart directory)
+	SkipTests   bool     // Install without running helm test
+	TestFilter  []string // helm test --filter expressions (e.g. name=smoke)
+}
+
+// ParseChartOverrides groups --set, --values, --release-name, --skip-tests and --test-filter flags
+// by chart name. A --set, --values or --test-filter flag can be scoped to one chart with a "chart:"
+// prefix (e.g. --set web:replicas=2); unscoped flags are keyed by "" and apply to every chart.
+// Release names are given as dir=name; --skip-tests names a chart, or "*" for every chart.
+func ParseChartOverrides(chartDirs, sets, values, releaseNames, skipTests, testFilters []string) (map[string]ChartOverrides, error) {
+	charts := make(map[string]bool, len(chartDirs))
+	for _, dir := range chartDirs {
+		charts[filepath.Base(dir)] = true
+	}
+
+	overrides := make(map[string]ChartOverrides)
+
+	for _, set := range sets {
+		chart, expr := "", set
+		if colon, eq := strings.Index(set, ":"), strings.Index(set, "="); colon > 0 && (eq < 0 || colon < eq) {
+			chart, expr = set[:colon], set[colon+1:]
+			if !charts[chart] {
+				return nil, fmt.Errorf("--set %q: unknown chart %q", set, chart)
+			}
+		}
+		if !strings.Contains(expr, "=") {
+			return nil, fmt.Errorf("--set %q: expected key=value", set)
+		}
+		o := overrides[chart]
+		o.Set = append(o.Set, expr)
+		overrides[chart] = o
+	}
+
+	for _, value := range values {
+		chart, file := "", value
+		if prefix, rest, ok := strings.Cut(value, ":"); ok && charts[prefix] {
+			chart, file = prefix, rest
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("--values %q: %w", value, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("--values %q: not a regular file", value)
+		}
+		o := overrides[chart]
+		o.Values = append(o.Values, file)
+		overrides[chart] = o
+	}
+
+	for _, mapping := range releaseNames {
+		chart, name, ok := strings.Cut(mapping, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("--release-name %q: expected dir=name", mapping)
+		}
+		if !charts[chart] {
+			return nil, fmt.Errorf("--release-name %q: unknown chart %q", mapping, chart)
+		}
+		o := overrides[chart]
+		o.ReleaseName = name
+		overrides[chart] = o
+	}
+
+	for _, chart := range skipTests {
+		if chart == "*" {
+			chart = ""
+		} else if !charts[chart] {
+			return nil, fmt.Errorf("--skip-tests %q: unknown chart", chart)
+		}
+		o := overrides[chart]
+		o.SkipTests = true
+		overrides[chart] = o
+	}
+
+	for _, filter := range testFilters {
+		chart, expr := "", filter
+		if colon, eq := strings.Index(filter, ":"), strings.Index(filter, "="); colon > 0 && (eq < 0 || colon < eq) {
+			chart, expr = filter[:colon], filter[colon+1:]
+			if !charts[chart] {
+				return nil, fmt.Errorf("--test-filter %q: unknown chart %q", filter, chart)
+			}
+		}
+		if !strings.Contains(expr, "=") {
+			return nil, fmt.Errorf("--test-filter %q: expected name=value or !name=value", filter)
+		}
+		o := overrides[chart]
+		o.TestFilter = append(o.TestFilter, expr)
+		overrides[chart] = o
+	}
+
+	return overrides, nil
+}
+
+// addChartOverrides bundles the chart's overrides under charts/CHARTNAME/.kube-parcel/: each
+// values file as values-N.yaml plus an index the runner turns into helm -f/--set arguments and
+// the release name. Chart-scoped overrides come after the unscoped ones so they take precedence.
+func (b *Bundler) addChartOverrides(tw *tar.Writer, chartName string) error {
+	all, scoped := b.Overrides[""], b.Overrides[chartName]
+	sets := append(append([]string{}, all.Set...), scoped.Set...)
+	files := append(append([]string{}, all.Values...), scoped.Values...)
+	filters := append(append([]string{}, all.TestFilter...), scoped.TestFilter...)
+	skipTests := all.SkipTests || scoped.SkipTests
+	if len(sets) == 0 && len(files) == 0 && scoped.ReleaseName == "" && !skipTests && len(filters) == 0 {
+		return nil
+	}
+
+	dir := path.Join("charts", chartName, config.ChartOverridesDir)
+	index := shared.ChartOverrides{Set: sets, ReleaseName: scoped.ReleaseName, SkipTests: skipTests, TestFilter: filters}
+
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("values-%d.yaml", i)
+		if err := b.writeFile(tw, path.Join(dir, name), data); err != nil {
+			return err
+		}
+		index.Values = append(index.Values, name)
+	}
+
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := b.writeFile(tw, path.Join(dir, config.ChartOverridesFile), data); err != nil {
+		return err
+	}
+
+	log.Printf("Added overrides for %s (%d values file(s), %d --set)", chartName, len(files), len(sets))
+	return nil
+}
+
+// writeFile writes an in-memory file to the tar
+func (b *Bundler) writeFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name: name,
+		Size: int64(len(data)),
+		Mode: 0644,
+	}
+	if err := b.writeHeader(tw, header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// ValidateChartOrder checks that a --chart-order lists bundled charts (by directory name, or
+// dir/case for one case of a chart), each at most once
+func ValidateChartOrder(chartDirs, order []string) error {
+	charts := make(map[string]bool, len(chartDirs))
+	for _, dir := range chartDirs {
+		charts[filepath.Base(dir)] = true
+	}
+	seen := make(map[string]bool, len(order))
+	for _, chart := range order {
+		if dir, _, _ := strings.Cut(chart, "/"); !charts[dir] {
+			return fmt.Errorf("--chart-order: unknown chart %q", chart)
+		}
+		if seen[chart] {
+			return fmt.Errorf("--chart-order: chart %q listed more than once", chart)
+		}
+		seen[chart] = true
+	}
+	return nil
+}
+
+// addRunManifest bundles the run-level manifest (the install order) at the top of charts/
+func (b *Bundler) addRunManifest(tw *tar.Writer) error {
+	if len(b.ChartOrder) == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(shared.RunManifest{Order: b.ChartOrder})
+	if err != nil {
+		return err
+	}
+	if err := b.writeFile(tw, path.Join("charts", config.RunManifestFile), data); err != nil {
+		return err
+	}
+	log.Printf("Added chart install order: %s", strings.Join(b.ChartOrder, ", "))
+	return nil
+}
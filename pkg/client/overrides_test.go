@@ -0,0 +1,144 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseChartOverrides(t *testing.T) {
+	dir := t.TempDir()
+	valuesFile := filepath.Join(dir, "ci.yaml")
+	os.WriteFile(valuesFile, []byte("replicas: 1\n"), 0644)
+	charts := []string{filepath.Join(dir, "web"), filepath.Join(dir, "db")}
+
+	overrides, err := ParseChartOverrides(charts,
+		[]string{"image.tag=repo:1.0", "web:replicas=2"},
+		[]string{valuesFile, "db:" + valuesFile},
+		[]string{"web=frontend"},
+		[]string{"db"},
+		[]string{"name=smoke", "web:!name=soak"})
+	if err != nil {
+		t.Fatalf("ParseChartOverrides failed: %v", err)
+	}
+	if !slices.Equal(overrides[""].Set, []string{"image.tag=repo:1.0"}) {
+		t.Errorf("unexpected unscoped --set: %v", overrides[""].Set)
+	}
+	if !slices.Equal(overrides["web"].Set, []string{"replicas=2"}) {
+		t.Errorf("unexpected web --set: %v", overrides["web"].Set)
+	}
+	if !slices.Equal(overrides[""].Values, []string{valuesFile}) || !slices.Equal(overrides["db"].Values, []string{valuesFile}) {
+		t.Errorf("unexpected --values: %+v", overrides)
+	}
+	if overrides["web"].ReleaseName != "frontend" {
+		t.Errorf("expected web release name frontend, got %q", overrides["web"].ReleaseName)
+	}
+
+	if !overrides["db"].SkipTests || overrides["web"].SkipTests || overrides[""].SkipTests {
+		t.Errorf("expected only db to skip tests: %+v", overrides)
+	}
+	if !slices.Equal(overrides[""].TestFilter, []string{"name=smoke"}) || !slices.Equal(overrides["web"].TestFilter, []string{"!name=soak"}) {
+		t.Errorf("unexpected --test-filter: %+v", overrides)
+	}
+	if all, err := ParseChartOverrides(charts, nil, nil, nil, []string{"*"}, nil); err != nil || !all[""].SkipTests {
+		t.Errorf("expected --skip-tests=* to skip every chart, got %+v, %v", all, err)
+	}
+
+	for _, tt := range []struct {
+		sets, values, releaseNames, skipTests, testFilters []string
+	}{
+		{sets: []string{"noequals"}},
+		{sets: []string{"cache:size=1"}},
+		{values: []string{filepath.Join(dir, "missing.yaml")}},
+		{releaseNames: []string{"web"}},
+		{releaseNames: []string{"cache=cache"}},
+		{skipTests: []string{"cache"}},
+		{testFilters: []string{"smoke"}},
+		{testFilters: []string{"cache:name=smoke"}},
+	} {
+		if _, err := ParseChartOverrides(charts, tt.sets, tt.values, tt.releaseNames, tt.skipTests, tt.testFilters); err == nil {
+			t.Errorf("expected error for %+v", tt)
+		}
+	}
+}
+
+func TestBundler_ChartOverrides(t *testing.T) {
+	dir := t.TempDir()
+	chartDir := filepath.Join(dir, "web")
+	os.MkdirAll(chartDir, 0755)
+	os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: web\nversion: 0.1.0\n"), 0644)
+	valuesFile := filepath.Join(dir, "ci.yaml")
+	os.WriteFile(valuesFile, []byte("replicas: 1\n"), 0644)
+
+	var buf bytes.Buffer
+	b := NewBundler([]string{chartDir}, nil)
+	b.Overrides = map[string]ChartOverrides{
+		"":    {Set: []string{"a=1"}},
+		"web": {Set: []string{"b=2"}, Values: []string{valuesFile}, ReleaseName: "frontend"},
+	}
+	if err := b.Bundle(context.Background(), &buf); err != nil {
+		t.Fatalf("Bundle failed: %v", err)
+	}
+
+	files := make(map[string]string)
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data, _ := io.ReadAll(tr)
+		files[header.Name] = string(data)
+	}
+
+	if files["charts/web/.kube-parcel/values-0.yaml"] != "replicas: 1\n" {
+		t.Errorf("values file not bundled: %v", files)
+	}
+	want := "values:\n    - values-0.yaml\nset:\n    - a=1\n    - b=2\nreleaseName: frontend\n"
+	if got := files["charts/web/.kube-parcel/overrides.yaml"]; got != want {
+		t.Errorf("unexpected overrides index:\n%s", got)
+	}
+	if _, ok := files["charts/kube-parcel.yaml"]; ok {
+		t.Error("expected no run manifest without a chart order")
+	}
+
+	buf.Reset()
+	b.ChartOrder = []string{"web"}
+	if err := b.Bundle(context.Background(), &buf); err != nil {
+		t.Fatalf("Bundle failed: %v", err)
+	}
+	tr = tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data, _ := io.ReadAll(tr)
+		files[header.Name] = string(data)
+	}
+	if got := files["charts/kube-parcel.yaml"]; got != "order:\n    - web\n" {
+		t.Errorf("unexpected run manifest:\n%s", got)
+	}
+}
+
+func TestValidateChartOrder(t *testing.T) {
+	chartDirs := []string{"./charts/web", "/tmp/db"}
+	if err := ValidateChartOrder(chartDirs, []string{"db", "web/postgres"}); err != nil {
+		t.Errorf("expected a valid order, got %v", err)
+	}
+	if err := ValidateChartOrder(chartDirs, nil); err != nil {
+		t.Errorf("expected an empty order to be valid, got %v", err)
+	}
+	if err := ValidateChartOrder(chartDirs, []string{"api"}); err == nil || !strings.Contains(err.Error(), `unknown chart "api"`) {
+		t.Errorf("expected an unknown chart error, got %v", err)
+	}
+	if err := ValidateChartOrder(chartDirs, []string{"web", "web"}); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("expected a duplicate chart error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+)
+
+// minKernelMajor is the oldest kernel major version nested K3s is expected to run on
+const minKernelMajor = 4
+
+// checkDockerHost inspects the Docker daemon for what a privileged nested K3s needs and
+// returns an error with guidance if the host can't run the runner container
+func checkDockerHost(ctx context.Context, cli *client.Client) error {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query Docker daemon info: %w", err)
+	}
+
+	problems, warnings := preflightProblems(info)
+	for _, w := range warnings {
+		log.Printf("⚠️  Preflight: %s", w)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("this Docker host can't run the kube-parcel runner:")
+	for _, p := range problems {
+		b.WriteString("\n  - " + p)
+	}
+	b.WriteString("\n(use --exec-mode k8s to run in a cluster instead, or --skip-preflight to try anyway)")
+	return fmt.Errorf("%s", b.String())
+}
+
+// preflightProblems returns blocking problems and non-fatal warnings for a Docker daemon
+func preflightProblems(info system.Info) (problems, warnings []string) {
+	if info.OSType != "" && info.OSType != "linux" {
+		problems = append(problems, fmt.Sprintf("the daemon runs %s containers; switch Docker to Linux containers", info.OSType))
+	}
+
+	for _, opt := range info.SecurityOptions {
+		switch {
+		case slices.Contains(strings.Split(opt, ","), "name=rootless"):
+			problems = append(problems, "Docker runs rootless, so privileged containers lack the privileges K3s needs; use a rootful Docker daemon")
+		case slices.Contains(strings.Split(opt, ","), "name=userns"):
+			problems = append(problems, "user namespace remapping (userns-remap) is enabled, which blocks privileged containers; disable it for the daemon")
+		}
+	}
+
+	if !info.MemoryLimit {
+		problems = append(problems, "the memory cgroup controller is unavailable; the kubelet refuses to start without it (enable cgroup memory, e.g. cgroup_enable=memory on the kernel command line)")
+	}
+	if !info.PidsLimit {
+		warnings = append(warnings, "the pids cgroup controller is unavailable; K3s may log cgroup errors")
+	}
+	if info.CgroupVersion == "1" {
+		warnings = append(warnings, "the host uses cgroup v1; the runner is tested on cgroup v2 and nested K3s may fail to start")
+	}
+
+	if !info.IPv4Forwarding {
+		problems = append(problems, "IPv4 forwarding is disabled; pod networking needs it (sysctl -w net.ipv4.ip_forward=1)")
+	}
+
+	if major, ok := kernelMajor(info.KernelVersion); ok && major < minKernelMajor {
+		problems = append(problems, fmt.Sprintf("kernel %s is too old for K3s (need %d.x or newer)", info.KernelVersion, minKernelMajor))
+	}
+
+	return problems, warnings
+}
+
+// kernelMajor parses the major version from a kernel release string like "6.8.0-45-generic"
+func kernelMajor(version string) (int, bool) {
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	return n, err == nil
+}
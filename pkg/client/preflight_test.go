@@ -0,0 +1,63 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/system"
+)
+
+func TestPreflightProblems(t *testing.T) {
+	healthy := system.Info{
+		OSType:         "linux",
+		KernelVersion:  "6.8.0-45-generic",
+		CgroupVersion:  "2",
+		MemoryLimit:    true,
+		PidsLimit:      true,
+		IPv4Forwarding: true,
+		SecurityOptions: []string{
+			"name=seccomp,profile=builtin",
+			"name=cgroupns",
+		},
+	}
+
+	problems, warnings := preflightProblems(healthy)
+	if len(problems) != 0 || len(warnings) != 0 {
+		t.Fatalf("expected a healthy host, got problems=%v warnings=%v", problems, warnings)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*system.Info)
+		problem string
+		warning string
+	}{
+		{"rootless", func(i *system.Info) { i.SecurityOptions = append(i.SecurityOptions, "name=rootless") }, "rootless", ""},
+		{"userns", func(i *system.Info) { i.SecurityOptions = append(i.SecurityOptions, "name=userns") }, "userns-remap", ""},
+		{"windows containers", func(i *system.Info) { i.OSType = "windows" }, "Linux containers", ""},
+		{"no memory cgroup", func(i *system.Info) { i.MemoryLimit = false }, "memory cgroup", ""},
+		{"no ip forwarding", func(i *system.Info) { i.IPv4Forwarding = false }, "forwarding", ""},
+		{"old kernel", func(i *system.Info) { i.KernelVersion = "3.10.0-1160.el7.x86_64" }, "too old", ""},
+		{"cgroup v1", func(i *system.Info) { i.CgroupVersion = "1" }, "", "cgroup v1"},
+		{"no pids cgroup", func(i *system.Info) { i.PidsLimit = false }, "", "pids"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := healthy
+			info.SecurityOptions = append([]string{}, healthy.SecurityOptions...)
+			tt.modify(&info)
+			problems, warnings := preflightProblems(info)
+
+			if tt.problem != "" && (len(problems) != 1 || !strings.Contains(problems[0], tt.problem)) {
+				t.Errorf("expected one problem mentioning %q, got %v", tt.problem, problems)
+			}
+			if tt.problem == "" && len(problems) != 0 {
+				t.Errorf("expected no problems, got %v", problems)
+			}
+			if tt.warning != "" && (len(warnings) != 1 || !strings.Contains(warnings[0], tt.warning)) {
+				t.Errorf("expected one warning mentioning %q, got %v", tt.warning, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// progressInterval is how often upload progress is logged
+const progressInterval = 2 * time.Second
+
+// ProgressReader logs how many bytes have been read through it, with throughput and,
+// when the total is known, a percentage and ETA
+type ProgressReader struct {
+	r     io.Reader
+	total int64 // Expected size, 0 if unknown
+	read  int64
+
+	start    time.Time
+	lastLog  time.Time
+	interval time.Duration
+}
+
+// NewProgressReader wraps r, expecting about total bytes (0 = unknown)
+func NewProgressReader(r io.Reader, total int64) *ProgressReader {
+	now := time.Now()
+	return &ProgressReader{r: r, total: total, start: now, lastLog: now, interval: progressInterval}
+}
+
+func (p *ProgressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+
+	now := time.Now()
+	if err == io.EOF {
+		elapsed := now.Sub(p.start)
+		log.Printf("📤 Sent %.1f MB in %s (%.1f MB/s)", mb(p.read), elapsed.Round(time.Second), mb(p.read)/max(elapsed.Seconds(), 0.001))
+	} else if now.Sub(p.lastLog) >= p.interval {
+		p.lastLog = now
+		log.Print(p.status(now))
+	}
+	return n, err
+}
+
+// status describes the progress so far
+func (p *ProgressReader) status(now time.Time) string {
+	elapsed := now.Sub(p.start).Seconds()
+	rate := float64(p.read) / max(elapsed, 0.001)
+	if p.total <= 0 {
+		return fmt.Sprintf("📤 Sent %.1f MB (%.1f MB/s)", mb(p.read), mb(int64(rate)))
+	}
+
+	// The estimate excludes tar headers, so don't claim more than 99% before the end
+	percent := min(float64(p.read)/float64(p.total)*100, 99)
+	eta := "unknown"
+	if remaining := p.total - p.read; remaining > 0 && rate > 0 {
+		eta = time.Duration(float64(remaining) / rate * float64(time.Second)).Round(time.Second).String()
+	} else if remaining <= 0 {
+		eta = "almost done"
+	}
+	return fmt.Sprintf("📤 Sent %.1f MB of ~%.1f MB (%.0f%%, %.1f MB/s, ETA %s)", mb(p.read), mb(p.total), percent, mb(int64(rate)), eta)
+}
+
+func mb(n int64) float64 {
+	return float64(n) / (1024 * 1024)
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressReader_Status(t *testing.T) {
+	start := time.Now()
+	p := NewProgressReader(strings.NewReader(""), 100*1024*1024)
+	p.start = start
+	p.read = 25 * 1024 * 1024
+
+	want := "📤 Sent 25.0 MB of ~100.0 MB (25%, 5.0 MB/s, ETA 15s)"
+	if got := p.status(start.Add(5 * time.Second)); got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+
+	p.read = 120 * 1024 * 1024
+	if got := p.status(start.Add(5 * time.Second)); !strings.Contains(got, "(99%,") || !strings.Contains(got, "almost done") {
+		t.Errorf("overshooting the estimate should cap at 99%%, got %q", got)
+	}
+
+	p.total = 0
+	if got := p.status(start.Add(5 * time.Second)); got != "📤 Sent 120.0 MB (24.0 MB/s)" {
+		t.Errorf("status without a total = %q", got)
+	}
+}
+
+func TestProgressReader_PassesThrough(t *testing.T) {
+	data, err := io.ReadAll(NewProgressReader(strings.NewReader("parcel"), 6))
+	if err != nil || string(data) != "parcel" {
+		t.Errorf("ReadAll = %q, %v", data, err)
+	}
+}
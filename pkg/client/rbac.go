@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// PermissionResult is the outcome of a single SelfSubjectAccessReview
+type PermissionResult struct {
+	Verb        string
+	Group       string // API group of Resource ("" = core)
+	Resource    string
+	Subresource string // e.g. "exec" for pods/exec
+	Required    bool   // Optional permissions only produce a warning when denied
+	Reason      string // Why the permission is needed
+	Allowed     bool
+}
+
+// resourceName is the resource as written in RBAC rules, including any subresource
+func (p PermissionResult) resourceName() string {
+	if p.Subresource != "" {
+		return p.Resource + "/" + p.Subresource
+	}
+	return p.Resource
+}
+
+// remotePermissions are the verbs the client needs on the runner pod
+var remotePermissions = []PermissionResult{
+	{Verb: "create", Resource: "pods", Required: true, Reason: "launch the runner pod"},
+	{Verb: "get", Resource: "pods", Required: true, Reason: "wait for the runner pod to become ready"},
+	{Verb: "create", Resource: "pods", Subresource: "portforward", Required: true, Reason: "port-forward to the runner pod"},
+	{Verb: "create", Resource: "pods", Subresource: "exec", Required: true, Reason: "exec into the runner pod"},
+	{Verb: "delete", Resource: "pods", Reason: "clean up the runner pod after the run"},
+}
+
+// jobPermissions are the verbs the client needs to run the runner as a Job (--as-job): the
+// Job itself, and its pod, which is found by label
+var jobPermissions = []PermissionResult{
+	{Verb: "create", Group: "batch", Resource: "jobs", Required: true, Reason: "launch the runner job"},
+	{Verb: "get", Group: "batch", Resource: "jobs", Required: true, Reason: "check whether the runner job failed"},
+	{Verb: "list", Resource: "pods", Required: true, Reason: "find the runner job's pod"},
+	{Verb: "get", Resource: "pods", Required: true, Reason: "wait for the runner pod to become ready"},
+	{Verb: "create", Resource: "pods", Subresource: "portforward", Required: true, Reason: "port-forward to the runner pod"},
+	{Verb: "create", Resource: "pods", Subresource: "exec", Required: true, Reason: "exec into the runner pod"},
+	{Verb: "delete", Group: "batch", Resource: "jobs", Reason: "clean up the runner job after the run"},
+}
+
+// CheckPermissions runs a SelfSubjectAccessReview for every permission the client needs in
+// namespace (to run a Job with asJob), retrying transient API errors up to retries times.
+// The returned error is only non-nil when the check itself could not be performed.
+func CheckPermissions(ctx context.Context, clientset kubernetes.Interface, namespace string, asJob bool, retries int) ([]PermissionResult, error) {
+	backoff := wait.Backoff{
+		Steps:    max(retries, 0) + 1,
+		Duration: 500 * time.Millisecond,
+		Factor:   2.0,
+		Jitter:   0.1,
+	}
+
+	perms := remotePermissions
+	if asJob {
+		perms = jobPermissions
+	}
+	results := make([]PermissionResult, 0, len(perms))
+	for _, perm := range perms {
+		ssar := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   namespace,
+					Verb:        perm.Verb,
+					Group:       perm.Group,
+					Resource:    perm.Resource,
+					Subresource: perm.Subresource,
+				},
+			},
+		}
+
+		var review *authorizationv1.SelfSubjectAccessReview
+		err := retry.OnError(backoff, isTransientAPIError, func() error {
+			var err error
+			review, err = clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, ssar, metav1.CreateOptions{})
+			if err != nil && isTransientAPIError(err) {
+				log.Printf("⚠️  Permission check for %s %s failed transiently, retrying: %v", perm.Verb, perm.resourceName(), err)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("SelfSubjectAccessReview for %s %s failed: %w", perm.Verb, perm.resourceName(), err)
+		}
+
+		perm.Allowed = review.Status.Allowed
+		results = append(results, perm)
+	}
+
+	return results, nil
+}
+
+// isTransientAPIError reports whether an API error is worth retrying
+func isTransientAPIError(err error) bool {
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+// currentSubject returns the authenticated user name, or a placeholder if it can't be determined
+func currentSubject(ctx context.Context, clientset kubernetes.Interface) string {
+	review, err := clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil || review.Status.UserInfo.Username == "" {
+		return ""
+	}
+	return review.Status.UserInfo.Username
+}
+
+// permissionDiagnostic builds an actionable message listing the denied permissions and a
+// sample Role/RoleBinding granting them
+func permissionDiagnostic(namespace, subject string, denied []PermissionResult) string {
+	var b strings.Builder
+
+	who := "the current identity"
+	if subject != "" {
+		who = subject
+	}
+	fmt.Fprintf(&b, "missing Kubernetes permissions for %s in namespace %q:\n", who, namespace)
+
+	// One rule per resource, in the order the resources were first denied
+	var resources []PermissionResult
+	verbs := make(map[PermissionResult][]string)
+	for _, perm := range denied {
+		fmt.Fprintf(&b, "  - %s %s (needed to %s)\n", perm.Verb, perm.resourceName(), perm.Reason)
+		key := PermissionResult{Group: perm.Group, Resource: perm.resourceName()}
+		if _, ok := verbs[key]; !ok {
+			resources = append(resources, key)
+		}
+		verbs[key] = append(verbs[key], fmt.Sprintf("%q", perm.Verb))
+	}
+	var rules strings.Builder
+	for _, r := range resources {
+		fmt.Fprintf(&rules, "  - apiGroups: [%q]\n    resources: [%q]\n    verbs: [%s]\n", r.Group, r.Resource, strings.Join(verbs[r], ", "))
+	}
+
+	subjectBlock := "  - kind: ServiceAccount\n    name: <your-ci-service-account>\n    namespace: " + namespace
+	if sa, ok := strings.CutPrefix(subject, "system:serviceaccount:"); ok {
+		if ns, name, found := strings.Cut(sa, ":"); found {
+			subjectBlock = fmt.Sprintf("  - kind: ServiceAccount\n    name: %s\n    namespace: %s", name, ns)
+		}
+	} else if subject != "" {
+		subjectBlock = fmt.Sprintf("  - kind: User\n    name: %s\n    apiGroup: rbac.authorization.k8s.io", subject)
+	}
+
+	fmt.Fprintf(&b, `
+Grant them with a Role and RoleBinding, for example:
+
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: kube-parcel-client
+  namespace: %[1]s
+rules:
+%[2]s---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: kube-parcel-client
+  namespace: %[1]s
+subjects:
+%[3]s
+roleRef:
+  kind: Role
+  name: kube-parcel-client
+  apiGroup: rbac.authorization.k8s.io
+`, namespace, rules.String(), subjectBlock)
+
+	return b.String()
+}
+
+// verifyRemotePermissions fails when required permissions are denied, warns about missing
+// optional ones, and only proceeds without a verdict when the check itself can't run
+func verifyRemotePermissions(ctx context.Context, clientset kubernetes.Interface, namespace string, asJob bool, retries int) error {
+	results, err := CheckPermissions(ctx, clientset, namespace, asJob, retries)
+	if err != nil {
+		log.Printf("⚠️  Warning: could not verify permissions, continuing: %v", err)
+		return nil
+	}
+
+	var denied, deniedOptional []PermissionResult
+	for _, perm := range results {
+		switch {
+		case perm.Allowed:
+		case perm.Required:
+			denied = append(denied, perm)
+		default:
+			deniedOptional = append(deniedOptional, perm)
+		}
+	}
+
+	if len(denied) > 0 {
+		return fmt.Errorf("❌ %s", permissionDiagnostic(namespace, currentSubject(ctx, clientset), append(denied, deniedOptional...)))
+	}
+	for _, perm := range deniedOptional {
+		log.Printf("⚠️  Warning: missing permission %s %s in namespace %q; the client won't be able to %s", perm.Verb, perm.resourceName(), namespace, perm.Reason)
+	}
+
+	log.Println("✅ Permissions verified")
+	return nil
+}
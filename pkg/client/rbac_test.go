@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeAccessReviews returns a clientset answering SelfSubjectAccessReviews with allow, and
+// records the resources (with subresource) that were checked
+func fakeAccessReviews(allow func(attrs *authorizationv1.ResourceAttributes) (bool, error)) (*fake.Clientset, *[]string) {
+	var checked []string
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		allowed, err := allow(attrs)
+		if err != nil {
+			return true, nil, err
+		}
+		resource := attrs.Resource
+		if attrs.Subresource != "" {
+			resource += "/" + attrs.Subresource
+		}
+		checked = append(checked, attrs.Verb+" "+resource)
+		review.Status.Allowed = allowed
+		return true, review, nil
+	})
+	return clientset, &checked
+}
+
+func TestCheckPermissions_Allowed(t *testing.T) {
+	for _, asJob := range []bool{false, true} {
+		clientset, checked := fakeAccessReviews(func(*authorizationv1.ResourceAttributes) (bool, error) { return true, nil })
+		results, err := CheckPermissions(context.Background(), clientset, "testing", asJob, 0)
+		if err != nil {
+			t.Fatalf("CheckPermissions(asJob=%v) failed: %v", asJob, err)
+		}
+		for _, perm := range results {
+			if !perm.Allowed {
+				t.Errorf("expected %s %s to be allowed", perm.Verb, perm.resourceName())
+			}
+		}
+		got := strings.Join(*checked, ",")
+		for _, want := range []string{"create pods/portforward", "create pods/exec"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("asJob=%v: expected %q to be checked, got %s", asJob, want, got)
+			}
+		}
+		if err := verifyRemotePermissions(context.Background(), clientset, "testing", asJob, 0); err != nil {
+			t.Errorf("verifyRemotePermissions(asJob=%v) = %v, want nil", asJob, err)
+		}
+	}
+}
+
+func TestCheckPermissions_Denied(t *testing.T) {
+	clientset, _ := fakeAccessReviews(func(attrs *authorizationv1.ResourceAttributes) (bool, error) {
+		return attrs.Subresource != "exec", nil
+	})
+	err := verifyRemotePermissions(context.Background(), clientset, "testing", false, 0)
+	if err == nil {
+		t.Fatal("expected denied pods/exec to fail the check")
+	}
+	if !strings.Contains(err.Error(), "create pods/exec") || !strings.Contains(err.Error(), `resources: ["pods/exec"]`) {
+		t.Errorf("diagnostic does not name pods/exec: %v", err)
+	}
+
+	// A denied optional permission only warns
+	clientset, _ = fakeAccessReviews(func(attrs *authorizationv1.ResourceAttributes) (bool, error) {
+		return attrs.Verb != "delete", nil
+	})
+	if err := verifyRemotePermissions(context.Background(), clientset, "testing", false, 0); err != nil {
+		t.Errorf("verifyRemotePermissions() with delete denied = %v, want nil", err)
+	}
+}
+
+func TestCheckPermissions_TransientRetry(t *testing.T) {
+	failures := 1
+	clientset, checked := fakeAccessReviews(func(*authorizationv1.ResourceAttributes) (bool, error) {
+		if failures > 0 {
+			failures--
+			return false, apierrors.NewServiceUnavailable("apiserver restarting")
+		}
+		return true, nil
+	})
+	results, err := CheckPermissions(context.Background(), clientset, "testing", false, 1)
+	if err != nil {
+		t.Fatalf("CheckPermissions() after a transient error failed: %v", err)
+	}
+	if len(results) != len(remotePermissions) || len(*checked) != len(remotePermissions) {
+		t.Errorf("got %d results from %d reviews, want %d", len(results), len(*checked), len(remotePermissions))
+	}
+
+	// Out of retries, the check can't run: the error is returned and the launch continues
+	clientset, _ = fakeAccessReviews(func(*authorizationv1.ResourceAttributes) (bool, error) {
+		return false, apierrors.NewServiceUnavailable("apiserver down")
+	})
+	if _, err := CheckPermissions(context.Background(), clientset, "testing", false, 0); !apierrors.IsServiceUnavailable(err) {
+		t.Errorf("CheckPermissions() = %v, want the service unavailable error", err)
+	}
+	if err := verifyRemotePermissions(context.Background(), clientset, "testing", false, 0); err != nil {
+		t.Errorf("verifyRemotePermissions() = %v, want nil when the check can't run", err)
+	}
+
+	// Non-transient errors are not retried
+	calls := 0
+	clientset, _ = fakeAccessReviews(func(*authorizationv1.ResourceAttributes) (bool, error) {
+		calls++
+		return false, apierrors.NewForbidden(authorizationv1.Resource("selfsubjectaccessreviews"), "", nil)
+	})
+	if _, err := CheckPermissions(context.Background(), clientset, "testing", false, 3); err == nil || calls != 1 {
+		t.Errorf("CheckPermissions() = %v after %d calls, want a forbidden error after 1", err, calls)
+	}
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+// ParseRegistryConfig builds the runner's registry setup from --registry-mirror flags
+// (registry=https://mirror, repeatable per registry; "*" matches every registry) and
+// --registry-auth flags (host:user:pass, where host may carry a port and the password
+// may contain colons). It returns nil when no flags were given.
+func ParseRegistryConfig(mirrors, auths []string) (*shared.RegistryConfig, error) {
+	if len(mirrors) == 0 && len(auths) == 0 {
+		return nil, nil
+	}
+	cfg := &shared.RegistryConfig{Mirrors: make(map[string][]string), Auth: make(map[string]shared.RegistryAuth)}
+
+	for _, mirror := range mirrors {
+		registry, endpoint, ok := strings.Cut(mirror, "=")
+		if !ok || registry == "" || endpoint == "" {
+			return nil, fmt.Errorf("--registry-mirror %q: expected registry=https://mirror", mirror)
+		}
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("--registry-mirror %q: endpoint must be an http(s) URL", mirror)
+		}
+		cfg.Mirrors[registry] = append(cfg.Mirrors[registry], endpoint)
+	}
+
+	for _, auth := range auths {
+		host, creds, err := parseRegistryAuth("--registry-auth", auth)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Auth[host] = creds
+	}
+
+	return cfg, nil
+}
+
+// ParsePullSecrets parses --pull-secret flags, which use the --registry-auth syntax, into
+// the credentials of the pull secret the runner creates in each chart namespace. It returns
+// nil when no flags were given.
+func ParsePullSecrets(secrets []string) (map[string]shared.RegistryAuth, error) {
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+	auths := make(map[string]shared.RegistryAuth, len(secrets))
+	for _, secret := range secrets {
+		host, creds, err := parseRegistryAuth("--pull-secret", secret)
+		if err != nil {
+			return nil, err
+		}
+		auths[host] = creds
+	}
+	return auths, nil
+}
+
+// parseRegistryAuth parses one host:user:pass credential of the named flag
+func parseRegistryAuth(flag, auth string) (string, shared.RegistryAuth, error) {
+	parts := strings.SplitN(auth, ":", 3)
+	if len(parts) < 3 {
+		return "", shared.RegistryAuth{}, fmt.Errorf("%s %q: expected host:user:pass", flag, auth)
+	}
+	host, user, pass := parts[0], parts[1], parts[2]
+	// host:port:user:pass
+	if _, err := strconv.Atoi(user); err == nil {
+		rest := strings.SplitN(pass, ":", 2)
+		if len(rest) < 2 {
+			return "", shared.RegistryAuth{}, fmt.Errorf("%s %q: expected host:port:user:pass", flag, auth)
+		}
+		host, user, pass = host+":"+user, rest[0], rest[1]
+	}
+	if host == "" || user == "" {
+		return "", shared.RegistryAuth{}, fmt.Errorf("%s %q: host and user must not be empty", flag, auth)
+	}
+	return host, shared.RegistryAuth{Username: user, Password: pass}, nil
+}
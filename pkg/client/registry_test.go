@@ -0,0 +1,69 @@
+package client
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func TestParseRegistryConfig(t *testing.T) {
+	cfg, err := ParseRegistryConfig(
+		[]string{"docker.io=https://mirror.internal", "docker.io=https://backup.internal:5000", "*=http://cache:5000"},
+		[]string{"mirror.internal:ci:s3cr:et", "backup.internal:5000:ci:pw"})
+	if err != nil {
+		t.Fatalf("ParseRegistryConfig failed: %v", err)
+	}
+	if !slices.Equal(cfg.Mirrors["docker.io"], []string{"https://mirror.internal", "https://backup.internal:5000"}) {
+		t.Errorf("unexpected docker.io mirrors: %v", cfg.Mirrors["docker.io"])
+	}
+	if !slices.Equal(cfg.Mirrors["*"], []string{"http://cache:5000"}) {
+		t.Errorf("unexpected wildcard mirrors: %v", cfg.Mirrors["*"])
+	}
+	if got := cfg.Auth["mirror.internal"]; got != (shared.RegistryAuth{Username: "ci", Password: "s3cr:et"}) {
+		t.Errorf("unexpected mirror.internal auth: %+v", got)
+	}
+	if got := cfg.Auth["backup.internal:5000"]; got != (shared.RegistryAuth{Username: "ci", Password: "pw"}) {
+		t.Errorf("unexpected backup.internal:5000 auth: %+v", got)
+	}
+
+	if cfg, err := ParseRegistryConfig(nil, nil); cfg != nil || err != nil {
+		t.Errorf("expected nil config without flags, got %+v, %v", cfg, err)
+	}
+
+	for _, tt := range []struct {
+		mirrors, auths []string
+	}{
+		{mirrors: []string{"docker.io"}},
+		{mirrors: []string{"docker.io=mirror.internal"}},
+		{auths: []string{"mirror.internal:ci"}},
+		{auths: []string{"mirror.internal:5000:ci"}},
+		{auths: []string{":ci:pw"}},
+	} {
+		if _, err := ParseRegistryConfig(tt.mirrors, tt.auths); err == nil {
+			t.Errorf("expected error for mirrors=%v auths=%v", tt.mirrors, tt.auths)
+		}
+	}
+}
+
+func TestParsePullSecrets(t *testing.T) {
+	auths, err := ParsePullSecrets([]string{"ghcr.io:ci:t0k:en", "registry.internal:5000:bot:pw"})
+	if err != nil {
+		t.Fatalf("ParsePullSecrets failed: %v", err)
+	}
+	if got := auths["ghcr.io"]; got != (shared.RegistryAuth{Username: "ci", Password: "t0k:en"}) {
+		t.Errorf("unexpected ghcr.io auth: %+v", got)
+	}
+	if got := auths["registry.internal:5000"]; got != (shared.RegistryAuth{Username: "bot", Password: "pw"}) {
+		t.Errorf("unexpected registry.internal:5000 auth: %+v", got)
+	}
+
+	if auths, err := ParsePullSecrets(nil); auths != nil || err != nil {
+		t.Errorf("expected nil without flags, got %+v, %v", auths, err)
+	}
+	for _, secret := range []string{"ghcr.io", "ghcr.io:ci", ":ci:pw", "ghcr.io::pw", "ghcr.io=ci:pw"} {
+		if _, err := ParsePullSecrets([]string{secret}); err == nil {
+			t.Errorf("expected %q to be rejected", secret)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// maxSessions bounds the session file; runners killed without cleanup leave entries behind
+const maxSessions = 20
+
+// Session records a launched server so later invocations (upload, status, stop) can
+// target it without --server
+type Session struct {
+	Name      string    `json:"name"`                // Server container or pod name
+	ExecMode  string    `json:"exec_mode"`           // "docker" or "k8s"
+	URL       string    `json:"url"`                 // Server URL, as used by the launching client
+	ID        string    `json:"id,omitempty"`        // Container ID or pod UID
+	Namespace string    `json:"namespace,omitempty"` // Pod namespace (k8s mode)
+	Started   time.Time `json:"started"`
+}
+
+// sessionsPath is where sessions are recorded: $HOME/.kube-parcel/sessions.json
+func sessionsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-parcel", "sessions.json"), nil
+}
+
+// LoadSessions returns the recorded sessions, oldest first
+func LoadSessions() ([]Session, error) {
+	path, err := sessionsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("invalid session file %s: %w", path, err)
+	}
+	return sessions, nil
+}
+
+// LatestSession returns the most recently launched server that is still recorded
+func LatestSession() (Session, bool) {
+	sessions, err := LoadSessions()
+	if err != nil || len(sessions) == 0 {
+		return Session{}, false
+	}
+	return sessions[len(sessions)-1], true
+}
+
+// saveSessions replaces the session file, through a rename so concurrent readers never
+// see a partial file
+func saveSessions(sessions []Session) error {
+	path, err := sessionsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "sessions-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// recordSession adds a launched server to the session file. Failures only cost the
+// convenience, so they are logged rather than returned.
+func recordSession(session Session) {
+	sessions, err := LoadSessions()
+	if err != nil {
+		log.Printf("⚠️  Not recording session: %v", err)
+		return
+	}
+	sessions = slices.DeleteFunc(sessions, func(s Session) bool { return s.Name == session.Name })
+	sessions = append(sessions, session)
+	if len(sessions) > maxSessions {
+		sessions = sessions[len(sessions)-maxSessions:]
+	}
+	if err := saveSessions(sessions); err != nil {
+		log.Printf("⚠️  Not recording session: %v", err)
+	}
+}
+
+// forgetSessions removes the named servers from the session file
+func forgetSessions(names ...string) {
+	sessions, err := LoadSessions()
+	if err != nil || len(sessions) == 0 {
+		return
+	}
+	kept := slices.DeleteFunc(slices.Clone(sessions), func(s Session) bool { return slices.Contains(names, s.Name) })
+	if len(kept) == len(sessions) {
+		return
+	}
+	if err := saveSessions(kept); err != nil {
+		log.Printf("⚠️  Failed to update session file: %v", err)
+	}
+}
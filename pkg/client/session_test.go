@@ -0,0 +1,46 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSessions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if _, ok := LatestSession(); ok {
+		t.Fatal("expected no session before anything was recorded")
+	}
+
+	recordSession(Session{Name: "kube-parcel-aaaa", ExecMode: "docker", URL: "http://localhost:1111"})
+	recordSession(Session{Name: "kube-parcel-bbbb", ExecMode: "k8s", URL: "http://localhost:8080", Namespace: "ci"})
+
+	latest, ok := LatestSession()
+	if !ok || latest.Name != "kube-parcel-bbbb" || latest.Namespace != "ci" {
+		t.Fatalf("LatestSession() = %+v, %v, want kube-parcel-bbbb in ci", latest, ok)
+	}
+
+	// Recording a server again moves it to the end instead of duplicating it
+	recordSession(Session{Name: "kube-parcel-aaaa", ExecMode: "docker", URL: "http://localhost:2222"})
+	sessions, err := LoadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[1].URL != "http://localhost:2222" {
+		t.Fatalf("sessions = %+v, want kube-parcel-aaaa last with the new URL", sessions)
+	}
+
+	forgetSessions("kube-parcel-aaaa", "kube-parcel-unknown")
+	latest, _ = LatestSession()
+	if latest.Name != "kube-parcel-bbbb" {
+		t.Errorf("after forgetting, LatestSession() = %q, want kube-parcel-bbbb", latest.Name)
+	}
+
+	for i := range maxSessions + 5 {
+		recordSession(Session{Name: fmt.Sprintf("kube-parcel-%04d", i)})
+	}
+	sessions, _ = LoadSessions()
+	if len(sessions) != maxSessions || sessions[0].Name != "kube-parcel-0005" {
+		t.Errorf("got %d sessions starting at %q, want %d starting at kube-parcel-0005", len(sessions), sessions[0].Name, maxSessions)
+	}
+}
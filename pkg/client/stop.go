@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// StopSettings selects which runners Stop tears down
+type StopSettings struct {
+	ExecMode  string   // "docker" or "k8s"
+	Namespace string   // Namespace searched for runner pods (k8s mode)
+	Names     []string // Only these servers (with their agents); empty means every runner
+}
+
+// Stop removes runners left behind by --keep-alive and returns the names of the removed
+// containers, Jobs or pods. Server handles aren't persisted between invocations, so runners
+// are rediscovered: containers by their kube-parcel- name, Jobs and pods by the
+// app=kube-parcel label.
+// Sessions of removed servers are forgotten, as are requested servers that no longer exist.
+func Stop(ctx context.Context, settings StopSettings) ([]string, error) {
+	var removed []string
+	var err error
+	if settings.ExecMode == "docker" {
+		removed, err = stopLocal(ctx, settings.Names)
+	} else {
+		removed, err = stopRemote(ctx, settings.Namespace, settings.Names)
+	}
+
+	forget := removed
+	if err == nil {
+		forget = append(forget, settings.Names...)
+	}
+	forgetSessions(forget...)
+	return removed, err
+}
+
+// stopLocal force-removes runner containers and the networks created for their agents
+func stopLocal(ctx context.Context, names []string) ([]string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", runnerNamePrefix)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var removed []string
+	for _, c := range containers {
+		name, ok := runnerContainerName(c, names)
+		if !ok {
+			continue
+		}
+		if err := cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true}); err != nil {
+			return removed, fmt.Errorf("failed to remove container %s: %w", name, err)
+		}
+		removed = append(removed, name)
+	}
+
+	// LaunchLocal names the agent network after the server container
+	networks, err := cli.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", runnerNamePrefix)),
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to list networks: %w", err)
+	}
+	for _, n := range networks {
+		if !slices.Contains(removed, n.Name) {
+			continue
+		}
+		if err := cli.NetworkRemove(ctx, n.ID); err != nil {
+			return removed, fmt.Errorf("failed to remove network %s: %w", n.Name, err)
+		}
+	}
+
+	return removed, nil
+}
+
+// runnerContainerName returns the name of a container started by LaunchLocal and whether
+// it should be stopped. Containers from older clients have no label, so the runner
+// entrypoint identifies them too. Agents go with their server.
+func runnerContainerName(c container.Summary, names []string) (string, bool) {
+	if len(c.Names) == 0 {
+		return "", false
+	}
+	name := strings.TrimPrefix(c.Names[0], "/")
+	if !strings.HasPrefix(name, runnerNamePrefix) {
+		return "", false
+	}
+	if c.Labels[runnerLabel] != runnerLabelValue && !strings.HasPrefix(c.Command, "/app/runner") {
+		return "", false
+	}
+	return name, matchesRunner(name, names)
+}
+
+// matchesRunner reports whether a server or agent name belongs to one of the requested
+// servers (any server if none were requested)
+func matchesRunner(name string, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	server, _, _ := strings.Cut(name, "-agent-")
+	return slices.Contains(names, server)
+}
+
+// stopRemote deletes runner Jobs and pods in the namespace
+func stopRemote(ctx context.Context, namespace string, names []string) ([]string, error) {
+	config, _, err := loadKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	// Runners started with --as-job; without permission to list Jobs there can't be any
+	var removed []string
+	jobs, err := clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: runnerLabel + "=" + runnerLabelValue,
+	})
+	if err != nil && !apierrors.IsForbidden(err) {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	if err == nil {
+		propagation := metav1.DeletePropagationBackground
+		for _, job := range jobs.Items {
+			if !matchesRunner(job.Name, names) {
+				continue
+			}
+			if err := clientset.BatchV1().Jobs(namespace).Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+				return removed, fmt.Errorf("failed to delete job %s: %w", job.Name, err)
+			}
+			removed = append(removed, job.Name)
+		}
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: runnerLabel + "=" + runnerLabelValue,
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		// A Job's pods go with their Job
+		if _, ok := pod.Labels[jobNameLabel]; ok || !matchesRunner(pod.Name, names) {
+			continue
+		}
+		if err := clientset.CoreV1().Pods(namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+			return removed, fmt.Errorf("failed to delete pod %s: %w", pod.Name, err)
+		}
+		removed = append(removed, pod.Name)
+	}
+	return removed, nil
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestRunnerContainerName(t *testing.T) {
+	labels := map[string]string{"app": "kube-parcel"}
+	tests := []struct {
+		name      string
+		container container.Summary
+		names     []string
+		want      string
+		stop      bool
+	}{
+		{"labeled server", container.Summary{Names: []string{"/kube-parcel-1a2b3c4d"}, Labels: labels}, nil, "kube-parcel-1a2b3c4d", true},
+		{"unlabeled runner", container.Summary{Names: []string{"/kube-parcel-1a2b3c4d"}, Command: "/app/runner"}, nil, "kube-parcel-1a2b3c4d", true},
+		{"agent of requested server", container.Summary{Names: []string{"/kube-parcel-1a2b3c4d-agent-1"}, Labels: labels}, []string{"kube-parcel-1a2b3c4d"}, "kube-parcel-1a2b3c4d-agent-1", true},
+		{"other server", container.Summary{Names: []string{"/kube-parcel-1a2b3c4d"}, Labels: labels}, []string{"kube-parcel-ffffffff"}, "kube-parcel-1a2b3c4d", false},
+		{"unrelated name", container.Summary{Names: []string{"/my-kube-parcel-1a2b"}, Labels: labels}, nil, "", false},
+		{"unrelated image", container.Summary{Names: []string{"/kube-parcel-dev"}, Command: "nginx"}, nil, "", false},
+		{"no names", container.Summary{Labels: labels}, nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stop := runnerContainerName(tt.container, tt.names)
+			if stop != tt.stop || (stop && got != tt.want) {
+				t.Errorf("runnerContainerName() = %q, %v, want %q, %v", got, stop, tt.want, tt.stop)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// runnerClient makes every HTTP request to the runner
+var runnerClient = http.DefaultClient
+
+// SetInsecureSkipVerify turns off verification of the runner's TLS certificate, for
+// runners serving a self-signed one. It applies to every later request this package
+// makes, and to those sent with HTTPClient.
+func SetInsecureSkipVerify(skip bool) {
+	if !skip {
+		runnerClient = http.DefaultClient
+		return
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	runnerClient = &http.Client{Transport: transport}
+}
+
+// HTTPClient returns the client for requests to the runner
+func HTTPClient() *http.Client {
+	return runnerClient
+}
+
+// logDialer returns the WebSocket dialer for the runner's log stream
+func logDialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	if transport, ok := runnerClient.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+	}
+	return &dialer
+}
@@ -1,85 +1,269 @@
 package client
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/tiborv/kube-parcel/pkg/shared"
 )
 
+// errUnauthorized is returned when the runner rejects our token; retrying won't help
+var errUnauthorized = errors.New("runner rejected the request: missing or invalid token (--token / KUBE_PARCEL_TOKEN)")
+
+// StreamLogs errors, so callers can tell a genuine test failure from a run that never got
+// to a verdict (and is worth retrying)
+var (
+	// ErrTestsFailed means the run completed and charts failed to install or their tests
+	// failed, or the bundled manifests were rejected
+	ErrTestsFailed = errors.New("tests failed")
+	// ErrParcelRejected means the runner could not unpack the uploaded parcel
+	ErrParcelRejected = errors.New("parcel rejected")
+	// ErrInfrastructure means the run ended without a verdict: the runner failed before
+	// installing charts (extraction, K3s, image import), hit its deadline, was aborted, or
+	// the log stream was lost
+	ErrInfrastructure = errors.New("infrastructure failure")
+)
+
 // NewPipe creates an io.Pipe
 func NewPipe() (*io.PipeReader, *io.PipeWriter) {
 	return io.Pipe()
 }
 
-// StreamLogs connects to the server and prints logs, returns error if tests fail
-func StreamLogs(ctx context.Context, serverURL string) error {
-	wsURL := strings.Replace(serverURL, "http", "ws", 1) + "/ws/logs"
-	log.Printf("📡 Connecting to log stream: %s", wsURL)
+// StreamOptions configures StreamLogs
+type StreamOptions struct {
+	LogFile    string // Every received message is also written here as a JSON line ("" = off)
+	MinLevel   string // Only stream messages at or above this level (debug, info, warn, error; "" = all)
+	Reconnects int    // Reconnect attempts after the stream drops before giving up (0 = none)
+}
 
-	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
-	if err != nil {
-		log.Printf("❌ Failed to connect to logs: %v", err)
-		return err
+// Backoff between reconnect attempts, doubling from reconnectBackoff up to maxReconnectBackoff
+const (
+	reconnectBackoff    = time.Second
+	maxReconnectBackoff = 30 * time.Second
+)
+
+// StreamLogs connects to the server and prints logs, returns error if tests fail.
+// If the connection drops before the run completes it reconnects with exponential backoff;
+// the runner replays its log buffer on connect, and already-seen messages are skipped.
+// If the runner (or a proxy in front of it) refuses the WebSocket upgrade, it follows
+// /parcel/logs over plain HTTP instead.
+func StreamLogs(ctx context.Context, serverURL string, opts StreamOptions) error {
+	stream := &logStream{}
+	if opts.LogFile != "" {
+		f, err := os.Create(opts.LogFile)
+		if err != nil {
+			return fmt.Errorf("failed to create log file: %w", err)
+		}
+		defer f.Close()
+		stream.record = json.NewEncoder(f)
 	}
-	defer c.Close()
 
-	testFailed := false
-	lastMessage := ""
-	messageCount := 0
+	// Only the current run: a reused runner still holds earlier runs' results
+	query := url.Values{"run": {"current"}}
+	if opts.MinLevel != "" {
+		query.Set("min_level", opts.MinLevel)
+	}
+	wsURL := strings.Replace(serverURL, "http", "ws", 1) + "/ws/logs?" + query.Encode()
+	log.Printf("📡 Connecting to log stream: %s", wsURL)
+	query.Set("follow", "true")
+	httpURL := serverURL + "/parcel/logs?" + query.Encode()
 
-	for {
-		select {
-		case <-ctx.Done():
-			if testFailed {
-				return fmt.Errorf("tests failed")
+	useHTTP := false
+	backoff := reconnectBackoff
+	for attempt := 0; ; attempt++ {
+		var next func() ([]byte, error)
+		var conn io.Closer
+		var err error
+		if !useHTTP {
+			var c *websocket.Conn
+			var resp *http.Response
+			header := http.Header{}
+			Authorize(header)
+			c, resp, err = logDialer().DialContext(ctx, wsURL, header)
+			if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+				return errUnauthorized
 			}
-			return ctx.Err()
-
-		default:
-			_, message, err := c.ReadMessage()
-			if err != nil {
-				// Connection closed - determine the appropriate error
-				if testFailed {
-					return fmt.Errorf("tests failed")
-				}
-				// If we received messages and they indicate progress, provide context
-				if messageCount > 0 {
-					log.Printf("❌ Connection lost after %d messages. Last: %s", messageCount, lastMessage)
-					return fmt.Errorf("runner connection lost during execution (last message: %s)", lastMessage)
+			if errors.Is(err, websocket.ErrBadHandshake) {
+				log.Printf("⚠️ WebSocket upgrade refused, following %s over HTTP instead", httpURL)
+				useHTTP = true
+			} else if err == nil {
+				conn = c
+				next = func() ([]byte, error) {
+					_, message, err := c.ReadMessage()
+					return message, err
 				}
-				log.Printf("❌ Log stream closed unexpectedly: %v", err)
-				return fmt.Errorf("runner connection closed before completion: %w", err)
 			}
-
-			messageCount++
-			msg, err := parseLogMessage(message)
-			if err != nil {
-				fmt.Printf("kube-parcel-runner: 🚀 %s\n", string(message))
-				lastMessage = string(message)
-				continue
+		}
+		if useHTTP {
+			next, conn, err = followLogs(ctx, httpURL)
+			if errors.Is(err, errUnauthorized) {
+				return err
 			}
+		}
 
-			lastMessage = msg.Message
-			printLogMessage(msg)
+		if err == nil {
+			if attempt > 0 {
+				log.Printf("🔌 Reconnected to log stream")
+			}
+			seen := stream.messageCount
+			// Closing the connection unblocks a read waiting for the next message
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			done, runErr := stream.read(ctx, next)
+			stop()
+			conn.Close()
+			if done {
+				return runErr
+			}
+			err = runErr
+			// Only a connection that delivered new messages resets the retry budget
+			if stream.messageCount > seen {
+				attempt, backoff = 0, reconnectBackoff
+			}
+		}
 
-			if result := checkCompletion(msg.Message); result != nil {
-				return result.err
+		if ctx.Err() != nil {
+			if stream.testFailed {
+				return ErrTestsFailed
 			}
+			return fmt.Errorf("%w: %w", ErrInfrastructure, ctx.Err())
+		}
+		if attempt >= opts.Reconnects {
+			return stream.lost(err)
+		}
+
+		log.Printf("⚠️ Log stream dropped (%v); reconnecting in %s (attempt %d/%d)", err, backoff, attempt+1, opts.Reconnects)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxReconnectBackoff)
+	}
+}
+
+// logStream is the state of a StreamLogs call, kept across reconnects
+type logStream struct {
+	record       *json.Encoder
+	testFailed   bool
+	lastMessage  string
+	messageCount int
+
+	lastSeq uint64 // Seq of the newest message printed; the runner sends them in seq order
+}
 
-			if isTestFailure(msg.Message) {
-				testFailed = true
-				fmt.Printf("kube-parcel-runner: ❌ TEST FAILURE DETECTED: %s\n", msg.Message)
+// followLogs opens /parcel/logs?follow=true and returns a reader of its JSON lines
+func followLogs(ctx context.Context, logsURL string) (next func() ([]byte, error), body io.Closer, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, logsURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	Authorize(req.Header)
+	resp, err := runnerClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, nil, errUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("log endpoint returned %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	next = func() ([]byte, error) {
+		if scanner.Scan() {
+			return scanner.Bytes(), nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	return next, resp.Body, nil
+}
+
+// read prints messages from one connection, reading each with next. done is true once the
+// run completed (err is then its result); otherwise err is why the connection ended.
+func (s *logStream) read(ctx context.Context, next func() ([]byte, error)) (done bool, err error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		message, err := next()
+		if err != nil {
+			return false, err
+		}
+
+		msg, err := parseLogMessage(message)
+		if err != nil {
+			s.messageCount++
+			fmt.Printf("kube-parcel-runner: 🚀 %s\n", string(message))
+			s.lastMessage = string(message)
+			if s.record != nil {
+				s.record.Encode(shared.LogMessage{Timestamp: time.Now(), Level: "info", Message: string(message)})
 			}
+			continue
+		}
+		if s.replayed(msg) {
+			continue
+		}
+
+		s.messageCount++
+		if s.record != nil {
+			s.record.Encode(msg)
+		}
+		s.lastMessage = msg.Message
+		printLogMessage(msg)
+
+		if result := checkCompletion(msg.Message); result != nil {
+			return true, result.err
+		}
+
+		if isTestFailure(msg.Message) {
+			s.testFailed = true
+			fmt.Printf("kube-parcel-runner: ❌ TEST FAILURE DETECTED: %s\n", msg.Message)
 		}
 	}
 }
 
+// replayed reports whether msg was already printed before a reconnect, recording it otherwise.
+// Messages without a seq (the replay's truncation marker) are only printed before anything else.
+func (s *logStream) replayed(msg shared.LogMessage) bool {
+	if s.lastSeq > 0 && msg.Seq <= s.lastSeq {
+		return true
+	}
+	s.lastSeq = max(s.lastSeq, msg.Seq)
+	return false
+}
+
+// lost reports a stream that ended for good without a completion message
+func (s *logStream) lost(err error) error {
+	if s.testFailed {
+		return ErrTestsFailed
+	}
+	// If we received messages and they indicate progress, provide context
+	if s.messageCount > 0 {
+		log.Printf("❌ Connection lost after %d messages. Last: %s", s.messageCount, s.lastMessage)
+		return fmt.Errorf("%w: runner connection lost during execution (last message: %s)", ErrInfrastructure, s.lastMessage)
+	}
+	log.Printf("❌ Log stream closed unexpectedly: %v", err)
+	return fmt.Errorf("%w: runner connection closed before completion: %w", ErrInfrastructure, err)
+}
+
 // parseLogMessage attempts to parse a JSON log message
 func parseLogMessage(data []byte) (shared.LogMessage, error) {
 	var msg shared.LogMessage
@@ -116,10 +300,21 @@ func checkCompletion(message string) *completionResult {
 		return nil
 	}
 
+	reason, failed := strings.CutPrefix(message, "COMPLETE:FAILED:")
 	switch {
+	case failed && reason == shared.FailureManifests:
+		fmt.Printf("kube-parcel-runner: ❌ Run failed: %s\n", reason)
+		return &completionResult{err: fmt.Errorf("%w: %s", ErrTestsFailed, reason)}
+	case failed && strings.HasPrefix(reason, shared.FailureExtraction):
+		fmt.Printf("kube-parcel-runner: ❌ Run failed: %s\n", reason)
+		return &completionResult{err: fmt.Errorf("%w: %s", ErrParcelRejected, reason)}
+	case failed && reason != shared.FailureTests:
+		// The runner failed before it could install and test the charts
+		fmt.Printf("kube-parcel-runner: ❌ Run failed: %s\n", reason)
+		return &completionResult{err: fmt.Errorf("%w: %s", ErrInfrastructure, reason)}
 	case strings.Contains(message, "COMPLETE:FAILED"):
 		fmt.Printf("kube-parcel-runner: ❌ Tests completed with failures\n")
-		return &completionResult{err: fmt.Errorf("tests failed")}
+		return &completionResult{err: ErrTestsFailed}
 	case strings.Contains(message, "COMPLETE:SUCCESS"):
 		fmt.Printf("kube-parcel-runner: ✅ All tests passed!\n")
 		return &completionResult{err: nil}
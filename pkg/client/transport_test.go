@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func TestLogStream_Replayed(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	msg := func(seq uint64, at time.Duration, text string) shared.LogMessage {
+		return shared.LogMessage{Timestamp: t0.Add(at), Source: "runner", Level: "info", Message: text, Seq: seq}
+	}
+	marker := shared.LogMessage{Timestamp: t0, Source: "runner", Level: "warning", Message: "[truncated 5 earlier log line(s)]"}
+
+	// Concurrent broadcasters stamp their messages before the buffer orders them, so a later
+	// seq may carry an earlier timestamp
+	s := &logStream{}
+	first := []shared.LogMessage{marker, msg(6, 0, "a"), msg(7, 2, "b"), msg(8, 1, "c")}
+	for _, m := range first {
+		if s.replayed(m) {
+			t.Fatalf("first delivery of %q reported as replayed", m.Message)
+		}
+	}
+
+	// A reconnect replays the buffer: everything up to the last seq is a duplicate
+	for _, m := range first {
+		if !s.replayed(m) {
+			t.Errorf("replay of %q not detected", m.Message)
+		}
+	}
+	if s.replayed(msg(9, 0, "d")) {
+		t.Error("newer message with an older timestamp reported as replayed")
+	}
+}
+
+func TestStreamLogs_Reconnect(t *testing.T) {
+	t0 := time.Now()
+	history := []shared.LogMessage{
+		{Timestamp: t0, Source: "runner", Level: "info", Message: "installing", Seq: 1},
+		{Timestamp: t0.Add(time.Millisecond), Source: "runner", Level: "info", Message: "COMPLETE:SUCCESS", Seq: 2},
+	}
+
+	// The first connection drops after one message; the second replays everything
+	var connects atomic.Int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		msgs := history
+		if connects.Add(1) == 1 {
+			msgs = history[:1]
+		}
+		for _, m := range msgs {
+			c.WriteJSON(m)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := StreamLogs(ctx, srv.URL, StreamOptions{Reconnects: 2}); err != nil {
+		t.Fatalf("StreamLogs() = %v, want success after reconnect", err)
+	}
+	if n := connects.Load(); n != 2 {
+		t.Errorf("connects = %d, want 2", n)
+	}
+
+	connects.Store(0)
+	if err := StreamLogs(ctx, srv.URL, StreamOptions{}); !errors.Is(err, ErrInfrastructure) {
+		t.Errorf("StreamLogs() without reconnects on a dropped stream = %v, want ErrInfrastructure", err)
+	}
+}
+
+func TestStreamLogs_HTTPFallback(t *testing.T) {
+	var followed atomic.Bool
+	mux := http.NewServeMux()
+	// Like a proxy that strips the upgrade: the WebSocket endpoint answers with plain HTTP
+	mux.HandleFunc("/ws/logs", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upgrade not allowed", http.StatusForbidden)
+	})
+	mux.HandleFunc("/parcel/logs", func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(r.URL.Query().Get("follow") == "true" && r.URL.Query().Get("run") == "current")
+		enc := json.NewEncoder(w)
+		enc.Encode(shared.LogMessage{Timestamp: time.Now(), Source: "runner", Level: "info", Message: "installing"})
+		enc.Encode(shared.LogMessage{Timestamp: time.Now(), Source: "runner", Level: "complete", Message: "COMPLETE:FAILED:Tests failed"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := StreamLogs(ctx, srv.URL, StreamOptions{})
+	if !errors.Is(err, ErrTestsFailed) {
+		t.Errorf("StreamLogs() = %v, want the run's failure", err)
+	}
+	if !followed.Load() {
+		t.Error("expected the fallback to follow /parcel/logs for the current run")
+	}
+}
+
+func TestStreamLogs_Unauthorized(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.Header.Get("Authorization") != "Bearer right" {
+			http.Error(w, "Missing or invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "unexpected", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	SetToken("wrong")
+	defer SetToken("")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := StreamLogs(ctx, srv.URL, StreamOptions{Reconnects: 3})
+	if !errors.Is(err, errUnauthorized) {
+		t.Errorf("StreamLogs() = %v, want errUnauthorized", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("expected a rejected token to end the stream without retries, got %d requests", n)
+	}
+}
+
+func TestStreamLogs_TLS(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.WriteJSON(shared.LogMessage{Timestamp: time.Now(), Source: "runner", Level: "complete", Message: "COMPLETE:SUCCESS"})
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The test server's certificate is self-signed
+	if err := StreamLogs(ctx, srv.URL, StreamOptions{}); err == nil {
+		t.Error("StreamLogs() accepted an unverified certificate")
+	}
+
+	SetInsecureSkipVerify(true)
+	defer SetInsecureSkipVerify(false)
+	if err := StreamLogs(ctx, srv.URL, StreamOptions{}); err != nil {
+		t.Errorf("StreamLogs() over wss:// = %v, want success", err)
+	}
+}
+
+func TestCheckCompletion(t *testing.T) {
+	tests := []struct {
+		message string
+		want    error
+	}{
+		{"COMPLETE:SUCCESS:All tests passed", nil},
+		{"COMPLETE:FAILED:" + shared.FailureTests, ErrTestsFailed},
+		{"COMPLETE:FAILED:" + shared.FailureManifests, ErrTestsFailed},
+		{"COMPLETE:FAILED:" + shared.FailureExtraction + ": unexpected EOF", ErrParcelRejected},
+		{"COMPLETE:FAILED:K3s startup failed", ErrInfrastructure},
+		{"COMPLETE:FAILED:Run deadline exceeded", ErrInfrastructure},
+	}
+	for _, tt := range tests {
+		result := checkCompletion(tt.message)
+		if result == nil {
+			t.Errorf("checkCompletion(%q) = nil, want a result", tt.message)
+			continue
+		}
+		if !errors.Is(result.err, tt.want) || (tt.want == nil && result.err != nil) {
+			t.Errorf("checkCompletion(%q) = %v, want %v", tt.message, result.err, tt.want)
+		}
+	}
+	if checkCompletion("installing") != nil {
+		t.Error("expected no result for a regular message")
+	}
+}
@@ -27,11 +27,45 @@ const (
 	// DefaultChartsDir is where extracted Helm charts are stored
 	DefaultChartsDir = "/tmp/parcel/charts"
 
+	// DefaultBinDir is where executables shipped under bin/ in the bundle are stored
+	DefaultBinDir = "/tmp/parcel/bin"
+
+	// DefaultManifestsDir is where Kubernetes manifests shipped under manifests/ in the bundle are stored
+	DefaultManifestsDir = "/tmp/parcel/manifests"
+
+	// DefaultArtifactsDir is where run artifacts (test logs, results) are written
+	DefaultArtifactsDir = "/tmp/parcel/artifacts"
+
+	// DefaultLogSpillPath is where messages evicted from the runner's log buffer are kept
+	// when spilling is enabled, so late or reconnecting clients get the full history
+	DefaultLogSpillPath = "/tmp/parcel-logs.jsonl"
+
+	// DefaultK3sLogPath is where the runner keeps the full K3s server output
+	DefaultK3sLogPath = "/tmp/k3s.log"
+
+	// DefaultLogBufferSize is how many log messages the runner keeps in memory for replay
+	DefaultLogBufferSize = 1000
+
+	// RunManifestFile is the run-level manifest the client bundles at the top of charts/
+	RunManifestFile = "kube-parcel.yaml"
+
+	// ChartOverridesDir is the directory inside a bundled chart holding command-line overrides
+	ChartOverridesDir = ".kube-parcel"
+
+	// ChartOverridesFile is the overrides index inside ChartOverridesDir
+	ChartOverridesFile = "overrides.yaml"
+
 	// ContainerdSocket is the K3s containerd socket path
 	ContainerdSocket = "/run/k3s/containerd/containerd.sock"
 
+	// ContainerdRoot is the K3s containerd state directory (image content and snapshots)
+	ContainerdRoot = "/var/lib/rancher/k3s/agent/containerd"
+
 	// ContainerdNamespace is the Kubernetes containerd namespace
 	ContainerdNamespace = "k8s.io"
+
+	// DefaultImagePlatform is the platform unpacked when importing bundled images
+	DefaultImagePlatform = "linux/amd64"
 )
 
 // Network configuration
@@ -39,8 +73,18 @@ const (
 	// DefaultHTTPPort is the default HTTP server port
 	DefaultHTTPPort = 8080
 
+	// DefaultHTTPSPort is the runner's HTTP server port when TLS is enabled
+	DefaultHTTPSPort = 8443
+
 	// DefaultGRPCPort is the default gRPC server port
 	DefaultGRPCPort = 9090
+
+	// DefaultMetricsPort serves the runner's Prometheus metrics (the exposed gRPC port,
+	// which nothing else listens on)
+	DefaultMetricsPort = 9090
+
+	// AirgapProbeAddress is a well-known public endpoint that must be unreachable in airgap mode
+	AirgapProbeAddress = "1.1.1.1:443"
 )
 
 // Timeout configuration
@@ -51,15 +95,39 @@ const (
 	// K3sReadinessTimeout is the max time to wait for K3s API to be ready
 	K3sReadinessTimeout = 5 * time.Minute
 
+	// NodeReadyTimeout is the max time to wait for all nodes (and thus the CNI) to be Ready
+	NodeReadyTimeout = 5 * time.Minute
+
 	// PodWaitTimeout is the max time to wait for a pod to be ready
 	PodWaitTimeout = 5 * time.Minute
 
 	// ServerReadinessTimeout is the max time to wait for server HTTP readiness
 	ServerReadinessTimeout = 300 * time.Second
+
+	// DefaultHelmTimeout is the default timeout passed to helm install and helm test
+	DefaultHelmTimeout = 15 * time.Minute
+
+	// AirgapProbeTimeout is how long the airgap egress probe waits for a connection
+	AirgapProbeTimeout = 3 * time.Second
 )
 
 // K3s configuration
 const (
 	// K3sBinary is the path to the K3s binary
 	K3sBinary = "/bin/k3s"
+
+	// K3sRegistriesPath is the K3s containerd registry configuration file
+	K3sRegistriesPath = "/etc/rancher/k3s/registries.yaml"
+
+	// K3sAPIPort is the port agents use to join the K3s server
+	K3sAPIPort = 6443
+
+	// K3sReleaseURL is the base URL of K3s release assets, followed by /<tag>/<asset>
+	K3sReleaseURL = "https://github.com/k3s-io/k3s/releases/download"
 )
+
+// K3sComponents are the packaged K3s components that can be skipped with --disable
+var K3sComponents = []string{"coredns", "servicelb", "traefik", "local-storage", "metrics-server", "runtimes"}
+
+// DefaultK3sDisable is the default K3s disable list; airgap mode adds metrics-server
+var DefaultK3sDisable = []string{"traefik", "servicelb"}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"slices"
 	"testing"
 	"time"
 )
@@ -14,6 +15,11 @@ func TestPathConstants(t *testing.T) {
 		{"DefaultKubeconfigPath", DefaultKubeconfigPath, "/tmp/kubeconfig.yaml"},
 		{"DefaultImagesDir", DefaultImagesDir, "/tmp/parcel/images"},
 		{"DefaultChartsDir", DefaultChartsDir, "/tmp/parcel/charts"},
+		{"DefaultBinDir", DefaultBinDir, "/tmp/parcel/bin"},
+		{"DefaultManifestsDir", DefaultManifestsDir, "/tmp/parcel/manifests"},
+		{"DefaultArtifactsDir", DefaultArtifactsDir, "/tmp/parcel/artifacts"},
+		{"DefaultLogSpillPath", DefaultLogSpillPath, "/tmp/parcel-logs.jsonl"},
+		{"DefaultK3sLogPath", DefaultK3sLogPath, "/tmp/k3s.log"},
 		{"ContainerdSocket", ContainerdSocket, "/run/k3s/containerd/containerd.sock"},
 		{"ContainerdNamespace", ContainerdNamespace, "k8s.io"},
 	}
@@ -34,6 +40,9 @@ func TestNetworkConstants(t *testing.T) {
 	if DefaultGRPCPort != 9090 {
 		t.Errorf("DefaultGRPCPort = %d, expected 9090", DefaultGRPCPort)
 	}
+	if AirgapProbeAddress != "1.1.1.1:443" {
+		t.Errorf("AirgapProbeAddress = %q, expected \"1.1.1.1:443\"", AirgapProbeAddress)
+	}
 }
 
 func TestTimeoutConstants(t *testing.T) {
@@ -44,8 +53,11 @@ func TestTimeoutConstants(t *testing.T) {
 	}{
 		{"ImageImportTimeout", ImageImportTimeout, 2 * time.Minute},
 		{"K3sReadinessTimeout", K3sReadinessTimeout, 5 * time.Minute},
+		{"NodeReadyTimeout", NodeReadyTimeout, 5 * time.Minute},
 		{"PodWaitTimeout", PodWaitTimeout, 5 * time.Minute},
 		{"ServerReadinessTimeout", ServerReadinessTimeout, 300 * time.Second},
+		{"DefaultHelmTimeout", DefaultHelmTimeout, 15 * time.Minute},
+		{"AirgapProbeTimeout", AirgapProbeTimeout, 3 * time.Second},
 	}
 
 	for _, tc := range tests {
@@ -61,4 +73,9 @@ func TestK3sConstants(t *testing.T) {
 	if K3sBinary != "/bin/k3s" {
 		t.Errorf("K3sBinary = %q, expected \"/bin/k3s\"", K3sBinary)
 	}
+	for _, component := range DefaultK3sDisable {
+		if !slices.Contains(K3sComponents, component) {
+			t.Errorf("default disabled component %q is not a known K3s component", component)
+		}
+	}
 }
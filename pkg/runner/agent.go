@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// RunAgent runs the runner as a K3s agent node joining serverURL instead of serving
+// uploads. It blocks until the agent exits or ctx is cancelled.
+func RunAgent(ctx context.Context, serverURL string) error {
+	km := NewK3sManager()
+	if airgapEnv := os.Getenv("KUBE_PARCEL_AIRGAP"); airgapEnv == "false" || airgapEnv == "0" {
+		km.Airgap = false
+	}
+	km.Token = os.Getenv("KUBE_PARCEL_K3S_TOKEN")
+	km.Version = os.Getenv("KUBE_PARCEL_K3S_VERSION")
+	if registries := os.Getenv("KUBE_PARCEL_REGISTRIES"); registries != "" {
+		if err := json.Unmarshal([]byte(registries), &km.Registries); err != nil {
+			slog.Warn("Ignoring KUBE_PARCEL_REGISTRIES", "error", err)
+		}
+	}
+
+	if err := km.StartAgent(ctx, serverURL, os.Stdout); err != nil {
+		return err
+	}
+
+	if err := km.Wait(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("k3s agent exited: %w", err)
+	}
+	slog.Info("K3s agent stopped")
+	return nil
+}
@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+)
+
+// bundledManifests lists the manifest files shipped under manifests/ in the parcel, in
+// lexical path order (the order they are applied in). A missing directory has none.
+func bundledManifests(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == dir {
+			return fs.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && isManifestFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// isManifestFile reports whether kubectl apply can read the file
+func isManifestFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// ApplyManifests applies the manifests bundled under manifests/ (namespaces, RBAC, secrets
+// the charts expect to exist) with kubectl apply before any chart is installed. It returns
+// how many files were applied; a parcel without manifests applies none.
+func (hm *HelmManager) ApplyManifests(ctx context.Context) (int, error) {
+	files, err := bundledManifests(hm.manifestsDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list manifests: %w", err)
+	}
+	if len(files) == 0 {
+		return 0, nil
+	}
+
+	args := []string{"apply"}
+	for _, file := range files {
+		args = append(args, "--filename", file)
+	}
+	slog.Info("Applying bundled manifests", "files", len(files))
+	fmt.Fprintf(hm.logger, "Applying %d bundled manifest file(s)\n", len(files))
+
+	output := &chartOutput{}
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+	cmd.Stdout = io.MultiWriter(hm.logger, output)
+	cmd.Stderr = io.MultiWriter(hm.logger, output)
+	if err := cmd.Run(); err != nil {
+		return 0, errors.New(withOutputTail(fmt.Sprintf("kubectl apply: %v", err), output))
+	}
+
+	fmt.Fprintf(hm.logger, "✅ Applied %d bundled manifest file(s)\n", len(files))
+	return len(files), nil
+}
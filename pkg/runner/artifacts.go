@@ -0,0 +1,236 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+const (
+	// resultsFile is the name of the run results document in the artifacts directory
+	resultsFile = "results.json"
+
+	// maxManifestArtifactBytes bounds the rendered manifest saved for a failed install
+	maxManifestArtifactBytes = 1 << 20
+
+	// maxStderrTailBytes bounds the helm stderr kept to identify the failing resource
+	maxStderrTailBytes = 16 << 10
+)
+
+// failedResourcePattern matches helm/kubectl error references like `Deployment.apps "web"`
+// or `Service "web"`
+var failedResourcePattern = regexp.MustCompile(`\b([A-Z][A-Za-z]+)(?:\.[a-z0-9.-]+)? "([a-z0-9][a-z0-9.-]*)"`)
+
+// releaseHooks is the subset of `helm status -o json` needed to find test pods
+type releaseHooks struct {
+	Namespace string `json:"namespace"`
+	Hooks     []struct {
+		Name   string   `json:"name"`
+		Kind   string   `json:"kind"`
+		Events []string `json:"events"`
+	} `json:"hooks"`
+}
+
+// testPods returns the names of the release's helm test pods
+func testPods(releaseName, releaseNamespace string) (namespace string, pods []string, err error) {
+	cmd := exec.Command("helm", "status", releaseName, "--namespace", releaseNamespace, "-o", "json")
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("helm status failed: %w", err)
+	}
+
+	var rel releaseHooks
+	if err := json.Unmarshal(out, &rel); err != nil {
+		return "", nil, fmt.Errorf("failed to decode helm status: %w", err)
+	}
+	for _, hook := range rel.Hooks {
+		if hook.Kind == "Pod" && slices.Contains(hook.Events, "test") {
+			pods = append(pods, hook.Name)
+		}
+	}
+	return rel.Namespace, pods, nil
+}
+
+// collectTestLogs saves each test pod's complete log to <ArtifactsDir>/<chart>/<pod>.log
+// and records the files as artifacts of the chart. Pods already removed by a hook
+// delete policy are skipped.
+func (hm *HelmManager) collectTestLogs(chart chartSpec) {
+	if hm.ArtifactsDir == "" {
+		return
+	}
+	chartName := chart.Name
+
+	namespace, pods, err := testPods(chart.Release, chart.Namespace)
+	if err != nil {
+		slog.Warn("Could not list test pods for artifacts", "release", chart.Release, "error", err)
+		return
+	}
+
+	dir := filepath.Join(hm.ArtifactsDir, chartName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		slog.Warn("Failed to create artifacts directory", "dir", dir, "error", err)
+		return
+	}
+
+	var artifacts []string
+	for _, pod := range pods {
+		args := []string{"logs", pod, "--all-containers"}
+		if namespace != "" {
+			args = append(args, "-n", namespace)
+		}
+		cmd := exec.Command("kubectl", args...)
+		cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+		out, err := cmd.Output()
+		if err != nil {
+			slog.Warn("Failed to fetch test pod logs", "pod", pod, "error", err)
+			continue
+		}
+
+		rel := filepath.Join(chartName, pod+".log")
+		if err := os.WriteFile(filepath.Join(hm.ArtifactsDir, rel), out, 0644); err != nil {
+			slog.Warn("Failed to write test log artifact", "path", rel, "error", err)
+			continue
+		}
+		artifacts = append(artifacts, filepath.ToSlash(rel))
+		slog.Info("Saved test pod log", "chart", chartName, "pod", pod, "artifact", rel)
+	}
+
+	hm.mu.Lock()
+	hm.artifacts[chartName] = artifacts
+	hm.mu.Unlock()
+}
+
+// writeResults writes the final chart statuses to results.json in the artifacts directory
+func (s *Server) writeResults(passed bool) {
+	dir := s.helm.ArtifactsDir
+	if dir == "" {
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		slog.Warn("Failed to create artifacts directory", "dir", dir, "error", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(shared.RunResults{
+		RunID:      s.runID,
+		Passed:     passed,
+		FinishedAt: time.Now(),
+		Charts:     s.helm.GetChartsStatus(),
+	}, "", "  ")
+	if err != nil {
+		slog.Warn("Failed to encode results", "error", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, resultsFile), data, 0644); err != nil {
+		slog.Warn("Failed to write results", "error", err)
+	}
+}
+
+// HandleArtifacts serves files from the artifacts directory under /parcel/artifacts/
+func (s *Server) HandleArtifacts(w http.ResponseWriter, r *http.Request) {
+	if s.helm.ArtifactsDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+	http.StripPrefix("/parcel/artifacts/", http.FileServer(http.Dir(s.helm.ArtifactsDir))).ServeHTTP(w, r)
+}
+
+// tailBuffer keeps the last maxStderrTailBytes written to it
+type tailBuffer struct {
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > maxStderrTailBytes {
+		t.buf = t.buf[len(t.buf)-maxStderrTailBytes:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
+
+// renderChart renders a chart's manifests with helm template using the given values arguments
+func (hm *HelmManager) renderChart(chart chartSpec, valueArgs []string) ([]byte, error) {
+	args := append([]string{"template", chart.Release, chart.Path, "--namespace", chart.Namespace}, valueArgs...)
+	cmd := exec.Command("helm", args...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+	return cmd.Output()
+}
+
+// captureFailedManifest renders the chart with the same values via helm template and saves
+// the (size-bounded) manifest as <chart>/rendered-manifest.yaml. If the install error names
+// a resource found in the manifest, that document is also saved as <chart>/failed-resource.yaml.
+// It returns a short description for the chart's failure message.
+func (hm *HelmManager) captureFailedManifest(chart chartSpec, valueArgs []string, installErr string) string {
+	if hm.ArtifactsDir == "" {
+		return ""
+	}
+	chartName := chart.Name
+
+	manifest, err := hm.renderChart(chart, valueArgs)
+	if err != nil {
+		slog.Warn("Failed to render manifest for failed install", "chart", chartName, "error", err)
+		return ""
+	}
+
+	dir := filepath.Join(hm.ArtifactsDir, chartName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		slog.Warn("Failed to create artifacts directory", "dir", dir, "error", err)
+		return ""
+	}
+
+	saved := manifest
+	if len(saved) > maxManifestArtifactBytes {
+		saved = append(saved[:maxManifestArtifactBytes:maxManifestArtifactBytes], []byte("\n# ... truncated ...\n")...)
+	}
+	rel := filepath.Join(chartName, "rendered-manifest.yaml")
+	if err := os.WriteFile(filepath.Join(hm.ArtifactsDir, rel), saved, 0644); err != nil {
+		slog.Warn("Failed to write manifest artifact", "path", rel, "error", err)
+		return ""
+	}
+	artifacts := []string{filepath.ToSlash(rel)}
+	detail := "rendered manifest saved to artifacts"
+
+	if kind, name, doc := findFailedResource(string(manifest), installErr); doc != "" {
+		rel := filepath.Join(chartName, "failed-resource.yaml")
+		if err := os.WriteFile(filepath.Join(hm.ArtifactsDir, rel), []byte(doc), 0644); err == nil {
+			artifacts = append(artifacts, filepath.ToSlash(rel))
+		}
+		detail = fmt.Sprintf("failing resource: %s/%s; %s", kind, name, detail)
+	}
+
+	hm.mu.Lock()
+	hm.artifacts[chartName] = append(hm.artifacts[chartName], artifacts...)
+	hm.mu.Unlock()
+	return detail
+}
+
+// findFailedResource looks for a resource named in the install error and returns the
+// matching document from the rendered manifest
+func findFailedResource(manifest, installErr string) (kind, name, doc string) {
+	docs := strings.Split(manifest, "\n---")
+	for _, match := range failedResourcePattern.FindAllStringSubmatch(installErr, -1) {
+		kind, name := match[1], match[2]
+		for _, d := range docs {
+			if strings.Contains(d, "kind: "+kind+"\n") && strings.Contains(d, "name: "+name+"\n") {
+				return kind, name, strings.TrimSpace(strings.TrimPrefix(d, "-\n")) + "\n"
+			}
+		}
+	}
+	return "", "", ""
+}
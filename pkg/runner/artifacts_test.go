@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func TestServer_WriteAndServeResults(t *testing.T) {
+	s := newTestServer()
+	s.helm = NewHelmManager(nil)
+	s.helm.ArtifactsDir = t.TempDir()
+
+	s.helm.artifacts["myapp"] = []string{"myapp/myapp-test.log"}
+	s.helm.updateStatus("myapp", "Succeeded", "All tests passed")
+	os.MkdirAll(filepath.Join(s.helm.ArtifactsDir, "myapp"), 0755)
+	os.WriteFile(filepath.Join(s.helm.ArtifactsDir, "myapp", "myapp-test.log"), []byte("ok\n"), 0644)
+
+	s.writeResults(true)
+
+	rec := httptest.NewRecorder()
+	s.HandleArtifacts(rec, httptest.NewRequest("GET", "/parcel/artifacts/results.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for results.json, got %d", rec.Code)
+	}
+
+	var results shared.RunResults
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("failed to decode results: %v", err)
+	}
+	if !results.Passed {
+		t.Error("expected passed results")
+	}
+	chart := results.Charts["myapp"]
+	if len(chart.Artifacts) != 1 || chart.Artifacts[0] != "myapp/myapp-test.log" {
+		t.Errorf("unexpected artifacts: %v", chart.Artifacts)
+	}
+
+	rec = httptest.NewRecorder()
+	s.HandleArtifacts(rec, httptest.NewRequest("GET", "/parcel/artifacts/myapp/myapp-test.log", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+		t.Errorf("expected test log artifact, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFindFailedResource(t *testing.T) {
+	manifest := `---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: -1
+`
+	installErr := `Error: INSTALLATION FAILED: Deployment.apps "web" is invalid: spec.replicas: Invalid value: -1`
+
+	kind, name, doc := findFailedResource(manifest, installErr)
+	if kind != "Deployment" || name != "web" {
+		t.Fatalf("expected Deployment/web, got %s/%s", kind, name)
+	}
+	if !strings.Contains(doc, "replicas: -1") || strings.Contains(doc, "kind: Service") {
+		t.Errorf("unexpected document:\n%s", doc)
+	}
+
+	if _, _, doc := findFailedResource(manifest, "Error: context deadline exceeded"); doc != "" {
+		t.Errorf("expected no match, got:\n%s", doc)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	var tb tailBuffer
+	tb.Write([]byte(strings.Repeat("a", maxStderrTailBytes)))
+	tb.Write([]byte("tail"))
+	if len(tb.String()) != maxStderrTailBytes || !strings.HasSuffix(tb.String(), "tail") {
+		t.Errorf("expected bounded buffer ending in tail, got len %d", len(tb.String()))
+	}
+}
@@ -0,0 +1,31 @@
+package runner
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// RequireToken wraps a handler so it only serves requests carrying the runner's token
+// (KUBE_PARCEL_TOKEN) as "Authorization: Bearer <token>". Without a token every request
+// is served.
+func (s *Server) RequireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && !validToken(r.Header.Get("Authorization"), s.token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kube-parcel"`)
+			http.Error(w, "Missing or invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// validToken reports whether an Authorization header carries the bearer token. The
+// comparison takes constant time so the token can't be guessed byte by byte.
+func validToken(header, token string) bool {
+	scheme, value, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(value)), []byte(token)) == 1
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+)
+
+// Capabilities describes optional cluster features detected once K3s is ready.
+// Chart manifest overlays are evaluated against them.
+type Capabilities struct {
+	DefaultStorageClass bool     // A StorageClass is marked as the cluster default
+	IngressController   bool     // At least one IngressClass exists
+	MetricsServer       bool     // The metrics.k8s.io API is served
+	APIVersions         []string // Output of kubectl api-versions
+}
+
+// capabilityNames lists the named checks usable in an overlay's "when"
+var capabilityNames = []string{"defaultStorageClass", "ingressController", "metricsServer"}
+
+// DetectCapabilities queries the cluster for the features overlays can depend on
+func DetectCapabilities() (Capabilities, error) {
+	var caps Capabilities
+
+	out, err := kubectl("get", "storageclass", "-o", "json")
+	if err != nil {
+		return caps, fmt.Errorf("failed to list storage classes: %w", err)
+	}
+	var storageClasses struct {
+		Items []struct {
+			Metadata struct {
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &storageClasses); err != nil {
+		return caps, fmt.Errorf("failed to decode storage classes: %w", err)
+	}
+	for _, sc := range storageClasses.Items {
+		if sc.Metadata.Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+			caps.DefaultStorageClass = true
+		}
+	}
+
+	out, err = kubectl("get", "ingressclass", "-o", "name")
+	if err != nil {
+		return caps, fmt.Errorf("failed to list ingress classes: %w", err)
+	}
+	caps.IngressController = strings.TrimSpace(string(out)) != ""
+
+	out, err = kubectl("api-versions")
+	if err != nil {
+		return caps, fmt.Errorf("failed to list API versions: %w", err)
+	}
+	caps.APIVersions = strings.Fields(string(out))
+	caps.MetricsServer = slices.Contains(caps.APIVersions, "metrics.k8s.io/v1beta1")
+
+	return caps, nil
+}
+
+// Has evaluates a capability check: one of capabilityNames or "api:<group/version>",
+// optionally negated with a leading "!"
+func (c Capabilities) Has(check string) (bool, error) {
+	name, negate := strings.CutPrefix(strings.TrimSpace(check), "!")
+
+	var result bool
+	switch {
+	case name == "defaultStorageClass":
+		result = c.DefaultStorageClass
+	case name == "ingressController":
+		result = c.IngressController
+	case name == "metricsServer":
+		result = c.MetricsServer
+	case strings.HasPrefix(name, "api:"):
+		result = slices.Contains(c.APIVersions, strings.TrimPrefix(name, "api:"))
+	default:
+		return false, fmt.Errorf("unknown capability %q (available: %s, api:<group/version>)", name, strings.Join(capabilityNames, ", "))
+	}
+
+	return result != negate, nil
+}
+
+// kubectl runs kubectl against the K3s cluster and returns its stdout
+func kubectl(args ...string) ([]byte, error) {
+	cmd := exec.Command("kubectl", args...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+	return cmd.Output()
+}
@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// maxChartOutputBytes bounds the helm output kept per chart; the oldest output is dropped
+	maxChartOutputBytes = 1 << 20
+
+	// chartOutputTailLines is how many lines of helm output a failure message quotes
+	chartOutputTailLines = 3
+
+	// maxChartOutputTailBytes bounds the quoted output so status messages stay one-liners
+	maxChartOutputTailBytes = 500
+)
+
+// chartOutput holds the combined stdout and stderr of a chart's helm install and test.
+// helm writes both streams from separate goroutines, so writes are serialized.
+type chartOutput struct {
+	mu        sync.Mutex
+	buf       []byte
+	truncated bool
+}
+
+func (o *chartOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, p...)
+	if len(o.buf) > maxChartOutputBytes {
+		o.buf = o.buf[len(o.buf)-maxChartOutputBytes:]
+		o.truncated = true
+	}
+	return len(p), nil
+}
+
+func (o *chartOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return string(o.buf)
+}
+
+// lastLines returns up to n of the last non-empty lines of output
+func lastLines(output string, n int) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines[max(0, len(lines)-n):]
+}
+
+// tail returns the last few non-empty lines of output joined into a single line, for
+// failure messages; helm prints the error that ended a command last
+func (o *chartOutput) tail() string {
+	tail := strings.Join(lastLines(o.String(), chartOutputTailLines), " | ")
+	if len(tail) > maxChartOutputTailBytes {
+		// Start the cut at a rune boundary so multi-byte characters aren't split
+		cut := len(tail) - maxChartOutputTailBytes
+		for cut < len(tail) && !utf8.RuneStart(tail[cut]) {
+			cut++
+		}
+		tail = "..." + tail[cut:]
+	}
+	return tail
+}
+
+// chartOutputWriter returns the writer capturing a chart's helm output, creating it on first use
+func (hm *HelmManager) chartOutputWriter(chart string) *chartOutput {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	out, ok := hm.outputs[chart]
+	if !ok {
+		out = &chartOutput{}
+		hm.outputs[chart] = out
+	}
+	return out
+}
+
+// ChartOutput returns the helm install and test output captured for a chart in the current
+// run, and whether the chart has run any helm command yet
+func (hm *HelmManager) ChartOutput(chart string) (output string, truncated, ok bool) {
+	hm.mu.RLock()
+	out, ok := hm.outputs[chart]
+	hm.mu.RUnlock()
+	if !ok {
+		return "", false, false
+	}
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	return string(out.buf), out.truncated, true
+}
+
+// withOutputTail appends the tail of a chart's helm output to a failure message
+func withOutputTail(msg string, out *chartOutput) string {
+	if tail := out.tail(); tail != "" {
+		return msg + ": " + tail
+	}
+	return msg
+}
+
+// HandleChartLogs serves the captured helm output of a chart as plain text at
+// /parcel/chart/{name}/logs (a case's name is <dir>/<case>)
+func (s *Server) HandleChartLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/parcel/chart/"), "/logs")
+	if !ok || name == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	output, truncated, ok := s.helm.ChartOutput(name)
+	if !ok {
+		http.Error(w, "No helm output for chart "+name, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if truncated {
+		w.Header().Set("X-Kube-Parcel-Truncated", "true")
+	}
+	io.WriteString(w, output)
+}
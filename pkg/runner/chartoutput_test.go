@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChartOutput_Tail(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"empty", "", ""},
+		{"single line", "Error: INSTALLATION FAILED: timed out\n", "Error: INSTALLATION FAILED: timed out"},
+		{"last lines only", "NAME: web\n\nSTATUS: failed\nwaiting for pods\n\nError: context deadline exceeded\n",
+			"STATUS: failed | waiting for pods | Error: context deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &chartOutput{}
+			io.WriteString(out, tt.output)
+			if got := out.tail(); got != tt.want {
+				t.Errorf("tail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	out := &chartOutput{}
+	io.WriteString(out, "Error: "+strings.Repeat("x", 2*maxChartOutputTailBytes))
+	if got := out.tail(); len(got) != maxChartOutputTailBytes+len("...") || !strings.HasPrefix(got, "...") {
+		t.Errorf("expected a long tail to be cut to %d bytes, got %d", maxChartOutputTailBytes, len(got))
+	}
+
+	// A cut landing inside a multi-byte rune moves forward to the next rune
+	out = &chartOutput{}
+	io.WriteString(out, "Error: "+strings.Repeat("é", maxChartOutputTailBytes)+"x")
+	if got := out.tail(); !utf8.ValidString(got) || len(got) != maxChartOutputTailBytes-1+len("...") || !strings.HasSuffix(got, "éx") {
+		t.Errorf("expected a long tail to be cut at a rune boundary, got %d bytes (valid UTF-8: %v)", len(got), utf8.ValidString(got))
+	}
+}
+
+func TestChartOutput_Bounded(t *testing.T) {
+	out := &chartOutput{}
+	line := strings.Repeat("x", 1023) + "\n"
+	for range maxChartOutputBytes/len(line) + 10 {
+		io.WriteString(out, line)
+	}
+	io.WriteString(out, "last\n")
+	if got := len(out.String()); got != maxChartOutputBytes {
+		t.Errorf("expected output capped at %d bytes, got %d", maxChartOutputBytes, got)
+	}
+	if !out.truncated || !strings.HasSuffix(out.String(), "last\n") {
+		t.Error("expected the oldest output to be dropped")
+	}
+}
+
+func TestServer_HandleChartLogs(t *testing.T) {
+	s := newTestServer()
+	s.helm = NewHelmManager(io.Discard)
+	fmt.Fprintln(s.helm.chartOutputWriter("web"), "Release \"web\" has been installed.")
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/parcel/chart/web/logs", http.StatusOK, "Release \"web\" has been installed.\n"},
+		{"/parcel/chart/db/logs", http.StatusNotFound, ""},
+		{"/parcel/chart/web", http.StatusNotFound, ""},
+		{"/parcel/chart/a/b/logs", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.HandleChartLogs(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.code, w.Code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+	}
+
+	s.helm.resetStatus()
+	w := httptest.NewRecorder()
+	s.HandleChartLogs(w, httptest.NewRequest(http.MethodGet, "/parcel/chart/web/logs", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected output of a previous run to be forgotten, got %d", w.Code)
+	}
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envDuration reads a duration (e.g. "20m") from the environment, falling back to def
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("Invalid environment value, using default", "var", name, "value", value, "default", def)
+		return def
+	}
+	return d
+}
+
+// envInt reads a positive integer from the environment, falling back to def
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		slog.Warn("Invalid environment value, using default", "var", name, "value", value, "default", def)
+		return def
+	}
+	return n
+}
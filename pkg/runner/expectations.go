@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+// hasExpectations reports whether the manifest declares outcomes beyond "helm test passed"
+func (m *ChartManifest) hasExpectations() bool {
+	return m.ExpectTestExitCode != nil || len(m.ExpectResources) > 0
+}
+
+// evaluateExpectations checks a chart's declared outcomes against the helm test result,
+// the names of its test pods and the cluster's resources, returning one message per mismatch.
+// Without expectTestExitCode the tests must pass as usual.
+func (m *ChartManifest) evaluateExpectations(namespace string, testErr error, testPodNames []string, resources []shared.KubeResource) []string {
+	var mismatches []string
+
+	if want := m.ExpectTestExitCode; want != nil {
+		found := 0
+		for _, r := range resources {
+			if r.Kind != "Pod" || r.Namespace != namespace || !slices.Contains(testPodNames, r.Name) {
+				continue
+			}
+			found++
+			switch {
+			case r.ExitCode == nil:
+				mismatches = append(mismatches, fmt.Sprintf("test pod %s has no exit code (status %s), expected %d", r.Name, r.Status, *want))
+			case *r.ExitCode != *want:
+				mismatches = append(mismatches, fmt.Sprintf("test pod %s exited with %d, expected %d", r.Name, *r.ExitCode, *want))
+			}
+		}
+		if found == 0 {
+			mismatches = append(mismatches, fmt.Sprintf("no test pods found to check for exit code %d (removed by a hook delete policy?)", *want))
+		}
+	} else if testErr != nil {
+		mismatches = append(mismatches, fmt.Sprintf("tests failed: %v", testErr))
+	}
+
+	for i, exp := range m.ExpectResources {
+		if exp.Kind == "" || exp.Name == "" || exp.Status == "" {
+			mismatches = append(mismatches, fmt.Sprintf("expectResources[%d] needs kind, name and status", i))
+			continue
+		}
+		ns := exp.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		ref := fmt.Sprintf("%s %s/%s", exp.Kind, ns, exp.Name)
+
+		idx := slices.IndexFunc(resources, func(r shared.KubeResource) bool {
+			return strings.EqualFold(r.Kind, exp.Kind) && r.Namespace == ns && r.Name == exp.Name
+		})
+		if idx < 0 {
+			mismatches = append(mismatches, fmt.Sprintf("%s not found, expected %s", ref, exp.Status))
+			continue
+		}
+		if r := resources[idx]; !resourceInState(r, exp.Status) {
+			actual := r.Status
+			if r.Reason != "" {
+				actual += " (" + r.Reason + ")"
+			}
+			mismatches = append(mismatches, fmt.Sprintf("%s is %s, expected %s", ref, actual, exp.Status))
+		}
+	}
+	return mismatches
+}
+
+// resourceInState matches a resource's status, or its health for Healthy/Unhealthy
+func resourceInState(r shared.KubeResource, status string) bool {
+	switch {
+	case strings.EqualFold(status, "Healthy"):
+		return r.Reason == ""
+	case strings.EqualFold(status, "Unhealthy"):
+		return r.Reason != ""
+	}
+	return strings.EqualFold(r.Status, status)
+}
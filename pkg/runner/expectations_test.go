@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func TestChartManifest_EvaluateExpectations(t *testing.T) {
+	one, zero := 1, 0
+	resources := []shared.KubeResource{
+		{Kind: "Pod", Name: "web-test", Namespace: "apps", Status: "Failed", IsTest: true, ExitCode: &one, Reason: "Failed"},
+		{Kind: "Pod", Name: "other-test", Namespace: "default", Status: "Succeeded", IsTest: true, ExitCode: &zero},
+		{Kind: "Deployment", Name: "web", Namespace: "apps", Status: "Active"},
+		{Kind: "Job", Name: "migrate", Namespace: "apps", Status: "Failed", Reason: "Failed"},
+	}
+	testErr := errors.New("exit status 1")
+
+	tests := []struct {
+		name     string
+		manifest ChartManifest
+		testErr  error
+		want     []string // Substrings of the expected mismatches, in order
+	}{
+		{
+			name:     "negative test passes on expected exit code",
+			manifest: ChartManifest{ExpectTestExitCode: &one},
+			testErr:  testErr,
+		},
+		{
+			name:     "wrong exit code",
+			manifest: ChartManifest{ExpectTestExitCode: &zero},
+			want:     []string{"test pod web-test exited with 1, expected 0"},
+		},
+		{
+			name:     "resource states",
+			manifest: ChartManifest{ExpectResources: []ResourceExpectation{{Kind: "deployment", Name: "web", Status: "Healthy"}, {Kind: "Job", Name: "migrate", Status: "failed"}}},
+		},
+		{
+			name: "resource mismatches",
+			manifest: ChartManifest{ExpectResources: []ResourceExpectation{
+				{Kind: "Job", Name: "migrate", Status: "Succeeded"},
+				{Kind: "Deployment", Name: "web", Namespace: "default", Status: "Healthy"},
+				{Kind: "Job", Name: "migrate"},
+			}},
+			want: []string{"Job apps/migrate is Failed (Failed), expected Succeeded", "Deployment default/web not found", "expectResources[2] needs kind, name and status"},
+		},
+		{
+			name:     "tests must still pass without an exit code expectation",
+			manifest: ChartManifest{ExpectResources: []ResourceExpectation{{Kind: "Deployment", Name: "web", Status: "Healthy"}}},
+			testErr:  testErr,
+			want:     []string{"tests failed: exit status 1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.manifest.evaluateExpectations("apps", tc.testErr, []string{"web-test"}, resources)
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %d mismatch(es), got %q", len(tc.want), got)
+			}
+			for i, want := range tc.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("mismatch %d = %q, expected it to contain %q", i, got[i], want)
+				}
+			}
+		})
+	}
+
+	m := ChartManifest{ExpectTestExitCode: &one}
+	if got := m.evaluateExpectations("apps", testErr, nil, resources); len(got) != 1 || !strings.Contains(got[0], "no test pods found") {
+		t.Errorf("expected a missing test pod mismatch, got %q", got)
+	}
+}
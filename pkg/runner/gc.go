@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"syscall"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+)
+
+// diskUsage returns the used percentage and free bytes of the filesystem holding path
+func diskUsage(path string) (usedPercent float64, free uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	total := st.Blocks * uint64(st.Bsize)
+	free = st.Bavail * uint64(st.Bsize)
+	if total == 0 {
+		return 0, free, nil
+	}
+	return float64(total-free) / float64(total) * 100, free, nil
+}
+
+// pruneUnusedImages removes the images no container uses with crictl
+func pruneUnusedImages() ([]byte, error) {
+	return exec.Command(config.K3sBinary, "crictl", "rmi", "--prune").CombinedOutput()
+}
+
+// collectImages prunes images not used by any container when containerd's disk usage is
+// at or above the configured threshold. It runs before bundled images are imported so a
+// long-lived runner doesn't grow without bound; images needed later must be re-uploaded.
+func (s *Server) collectImages() {
+	if s.imageGCThreshold <= 0 {
+		return
+	}
+
+	used, freeBefore, err := s.imageDiskUsage(config.ContainerdRoot)
+	if err != nil {
+		slog.Warn("Image GC: failed to read disk usage", "path", config.ContainerdRoot, "error", err)
+		return
+	}
+	if used < float64(s.imageGCThreshold) {
+		slog.Debug("Image GC: below threshold", "used_percent", used, "threshold", s.imageGCThreshold)
+		return
+	}
+
+	s.broadcastLog("runner", "info", fmt.Sprintf("Image GC: disk %.0f%% used (threshold %d%%), pruning unused images...", used, s.imageGCThreshold))
+	if out, err := s.pruneImages(); err != nil {
+		slog.Warn("Image GC failed", "error", err, "output", string(out))
+		s.broadcastLog("runner", "warning", fmt.Sprintf("Image GC failed: %v", err))
+		return
+	}
+
+	usedAfter, freeAfter, err := s.imageDiskUsage(config.ContainerdRoot)
+	if err != nil {
+		slog.Warn("Image GC: failed to read disk usage", "path", config.ContainerdRoot, "error", err)
+		return
+	}
+	var reclaimed uint64
+	if freeAfter > freeBefore {
+		reclaimed = freeAfter - freeBefore
+	}
+	slog.Info("Image GC complete", "reclaimed_bytes", reclaimed, "used_percent", usedAfter)
+	s.broadcastLog("runner", "info", fmt.Sprintf("Image GC reclaimed %.1f MB (disk now %.0f%% used)", float64(reclaimed)/(1024*1024), usedAfter))
+}
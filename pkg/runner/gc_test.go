@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	used, free, err := diskUsage(t.TempDir())
+	if err != nil {
+		t.Fatalf("diskUsage failed: %v", err)
+	}
+	if used < 0 || used > 100 {
+		t.Errorf("used percent out of range: %v", used)
+	}
+	if free == 0 {
+		t.Error("expected some free space on the temp filesystem")
+	}
+
+	if _, _, err := diskUsage("/nonexistent/path"); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
+
+func TestServer_CollectImagesDisabled(t *testing.T) {
+	s := newTestServer()
+	s.collectImages()
+	if msgs := s.logBuffer.GetAll(); len(msgs) != 0 {
+		t.Errorf("expected no GC activity when disabled, got %v", msgs)
+	}
+}
+
+// newGCTestServer returns a server whose disk usage reads from usage (one value per call)
+// and whose prune command only counts its runs
+func newGCTestServer(threshold int, usage ...float64) (*Server, *int) {
+	s := newTestServer()
+	s.imageGCThreshold = threshold
+	s.imageDiskUsage = func(string) (float64, uint64, error) {
+		used := usage[0]
+		usage = usage[1:]
+		return used, uint64((100 - used) * 1024 * 1024), nil
+	}
+	prunes := 0
+	s.pruneImages = func() ([]byte, error) {
+		prunes++
+		return nil, nil
+	}
+	return s, &prunes
+}
+
+func TestServer_CollectImagesThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		used      float64
+		wantPrune bool
+	}{
+		{"below threshold", 80, 79.5, false},
+		{"at threshold", 80, 80, true},
+		{"above threshold", 80, 95, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, prunes := newGCTestServer(tt.threshold, tt.used, 50)
+			s.collectImages()
+			if pruned := *prunes == 1; pruned != tt.wantPrune {
+				t.Errorf("pruned = %v at %.1f%% used with threshold %d%%, want %v", pruned, tt.used, tt.threshold, tt.wantPrune)
+			}
+			var reclaimed bool
+			for _, msg := range s.logBuffer.GetAll() {
+				reclaimed = reclaimed || strings.Contains(msg.Message, "Image GC reclaimed")
+			}
+			if reclaimed != tt.wantPrune {
+				t.Errorf("reclaimed message logged = %v, want %v", reclaimed, tt.wantPrune)
+			}
+		})
+	}
+}
+
+func TestServer_CollectImagesPruneFailure(t *testing.T) {
+	s, _ := newGCTestServer(80, 95)
+	s.pruneImages = func() ([]byte, error) {
+		return []byte("crictl: not found"), errors.New("exit status 1")
+	}
+	s.collectImages()
+
+	msgs := s.logBuffer.GetAll()
+	if last := msgs[len(msgs)-1]; last.Level != "warning" || !strings.Contains(last.Message, "Image GC failed") {
+		t.Errorf("expected a warning about the failed prune, got %+v", last)
+	}
+}
@@ -1,15 +1,22 @@
 package runner
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -33,9 +40,42 @@ type Server struct {
 	extractor *TarExtractor
 	startTime time.Time
 	logBuffer *LogBuffer
-	wsClients map[*websocket.Conn]bool
+	wsClients map[*websocket.Conn]int // Connected log clients and their minimum level rank
+	followers int                     // Clients following /parcel/logs; guarded by wsMutex
 	wsMutex   sync.Mutex
 	debug     bool
+
+	k3sLogFile  string // K3s output is also written here ("" = off)
+	k3sLogLevel int    // Minimum level rank of K3s output sent to clients
+
+	importOpts        ImportOptions
+	importErrors      []string // Per-image import failures of the current run; guarded by wsMutex
+	failOnImportError bool     // Fail the run before installing charts when any image failed to import
+
+	idle        idleTracker
+	idleTimeout time.Duration
+	ttl         time.Duration // Shut down this long after start regardless of run state (0 = off)
+
+	imageGCThreshold int                                        // Prune unused images before import at this disk usage percent (0 = off)
+	imageDiskUsage   func(path string) (float64, uint64, error) // Used percent and free bytes of containerd's filesystem
+	pruneImages      func() (output []byte, err error)          // Removes images no container uses
+
+	pauseGate pauseGate // Halts the run for inspection where the upload asked (X-Kube-Parcel-Pause-On)
+
+	runID       string    // Identifies the current run in results and metrics
+	runStarted  time.Time // When the current run's upload arrived; guarded by wsMutex
+	runDeadline time.Time // When the current run's helm work is aborted (zero = no deadline)
+	bundleBytes int64     // Size of the uploaded parcel
+	metricsFile string    // Per-chart metrics are appended here after each run ("" = off)
+
+	metrics *promMetrics // Served to Prometheus on /metrics
+
+	httpPort int    // Port the parcel API listens on
+	token    string // Bearer token required on the parcel API and log stream ("" = no auth)
+
+	tlsEnabled  bool   // Serve the parcel API over HTTPS on DefaultHTTPSPort
+	tlsCertFile string // PEM certificate ("" = self-signed)
+	tlsKeyFile  string // PEM private key for tlsCertFile
 }
 
 // NewServer creates a new orchestrator server
@@ -44,7 +84,22 @@ func NewServer() *Server {
 
 	if airgapEnv := os.Getenv("KUBE_PARCEL_AIRGAP"); airgapEnv == "false" || airgapEnv == "0" {
 		k3s.Airgap = false
-		log.Println("🌐 Online mode enabled via KUBE_PARCEL_AIRGAP=false")
+		slog.Info("Online mode enabled via KUBE_PARCEL_AIRGAP=false")
+	}
+	k3s.FlannelBackend = os.Getenv("KUBE_PARCEL_FLANNEL_BACKEND")
+	k3s.DisableNetworkPolicy = os.Getenv("KUBE_PARCEL_DISABLE_NETWORK_POLICY") == "true"
+	k3s.CNIManifest = os.Getenv("KUBE_PARCEL_CNI_MANIFEST")
+	if disable, ok := os.LookupEnv("KUBE_PARCEL_K3S_DISABLE"); ok {
+		k3s.Disable = strings.Split(disable, ",")
+	}
+	k3s.ExtraArgs = splitK3sArgs(os.Getenv("KUBE_PARCEL_K3S_ARGS"))
+	k3s.Agents = envInt("KUBE_PARCEL_AGENTS", 0)
+	k3s.Token = os.Getenv("KUBE_PARCEL_K3S_TOKEN")
+	k3s.Version = os.Getenv("KUBE_PARCEL_K3S_VERSION")
+	if registries := os.Getenv("KUBE_PARCEL_REGISTRIES"); registries != "" {
+		if err := json.Unmarshal([]byte(registries), &k3s.Registries); err != nil {
+			slog.Warn("Ignoring KUBE_PARCEL_REGISTRIES", "error", err)
+		}
 	}
 
 	s := &Server{
@@ -52,21 +107,99 @@ func NewServer() *Server {
 		k3s:       k3s,
 		extractor: NewTarExtractor(),
 		startTime: time.Now(),
-		logBuffer: NewLogBuffer(1000),
-		wsClients: make(map[*websocket.Conn]bool),
+		logBuffer: NewLogBuffer(envInt("KUBE_PARCEL_LOG_BUFFER_SIZE", config.DefaultLogBufferSize)),
+		wsClients: make(map[*websocket.Conn]int),
 		debug:     os.Getenv("KUBE_PARCEL_DEBUG") == "true",
+
+		importOpts:     DefaultImportOptions(),
+		imageDiskUsage: diskUsage,
+		pruneImages:    pruneUnusedImages,
+		metrics:        newPromMetrics(),
+		httpPort:       envInt("KUBE_PARCEL_HTTP_PORT", config.DefaultHTTPPort),
+		token:          os.Getenv("KUBE_PARCEL_TOKEN"),
+
+		tlsCertFile: os.Getenv("KUBE_PARCEL_TLS_CERT"),
+		tlsKeyFile:  os.Getenv("KUBE_PARCEL_TLS_KEY"),
+	}
+	s.tlsEnabled = os.Getenv("KUBE_PARCEL_TLS") == "true" || s.tlsCertFile != "" || s.tlsKeyFile != ""
+	// Charts' hooks and post-renderers run as children of the runner; keep the token from them
+	os.Unsetenv("KUBE_PARCEL_TOKEN")
+	if spill := os.Getenv("KUBE_PARCEL_LOG_SPILL_FILE"); spill != "" {
+		if err := s.logBuffer.SpillTo(spill); err != nil {
+			slog.Warn("Could not enable log spilling, older messages will be dropped", "path", spill, "error", err)
+		}
+	}
+	if platform := os.Getenv("KUBE_PARCEL_IMPORT_PLATFORM"); platform != "" {
+		s.importOpts.Platform = platform
+	}
+	s.importOpts.AllPlatforms = os.Getenv("KUBE_PARCEL_IMPORT_ALL_PLATFORMS") == "true"
+	s.importOpts.NoUnpack = os.Getenv("KUBE_PARCEL_IMPORT_NO_UNPACK") == "true"
+	s.importOpts.OnProgress = s.broadcastImportProgress
+	s.failOnImportError = os.Getenv("KUBE_PARCEL_FAIL_ON_IMPORT_ERROR") == "true"
+	s.idleTimeout = envDuration("KUBE_PARCEL_IDLE_TIMEOUT", 0)
+	s.ttl = envDuration("KUBE_PARCEL_TTL", 0)
+	s.metricsFile = os.Getenv("KUBE_PARCEL_METRICS_FILE")
+	s.imageGCThreshold = envInt("KUBE_PARCEL_IMAGE_GC_THRESHOLD", 0)
+	s.k3sLogFile = config.DefaultK3sLogPath
+	if path, ok := os.LookupEnv("KUBE_PARCEL_K3S_LOG_FILE"); ok {
+		s.k3sLogFile = path
+	}
+	s.k3sLogLevel = logLevelRank["warning"]
+	if level, ok := os.LookupEnv("KUBE_PARCEL_K3S_LOG_LEVEL"); ok {
+		rank, err := parseMinLevel(level)
+		if err != nil {
+			slog.Warn("Ignoring KUBE_PARCEL_K3S_LOG_LEVEL", "error", err)
+		} else {
+			s.k3sLogLevel = rank
+		}
 	}
 
 	helmWriter := &SourceLogWriter{buffer: s.logBuffer, source: "helm", broadcast: s.broadcastLog}
 	s.helm = NewHelmManager(io.MultiWriter(os.Stdout, helmWriter))
+	s.helm.InstallTimeout = envDuration("KUBE_PARCEL_HELM_TIMEOUT", s.helm.InstallTimeout)
+	// helm test defaults to the same timeout unless configured separately
+	s.helm.TestTimeout = envDuration("KUBE_PARCEL_TEST_TIMEOUT", s.helm.InstallTimeout)
+	s.helm.TestParallelism = envInt("KUBE_PARCEL_TEST_PARALLEL", s.helm.TestParallelism)
+	s.helm.InstallParallelism = envInt("KUBE_PARCEL_INSTALL_PARALLEL", s.helm.InstallParallelism)
+	s.helm.Airgap = k3s.Airgap
+	s.helm.TakeOwnership = os.Getenv("KUBE_PARCEL_TAKE_OWNERSHIP") == "true"
+	s.helm.Lint = os.Getenv("KUBE_PARCEL_LINT") == "true"
+	if secrets := os.Getenv("KUBE_PARCEL_PULL_SECRETS"); secrets != "" {
+		if err := json.Unmarshal([]byte(secrets), &s.helm.PullSecrets); err != nil {
+			slog.Warn("Ignoring KUBE_PARCEL_PULL_SECRETS", "error", err)
+		}
+	}
+	if dir, ok := os.LookupEnv("KUBE_PARCEL_ARTIFACTS_DIR"); ok {
+		s.helm.ArtifactsDir = dir
+	}
+	if renderer := os.Getenv("KUBE_PARCEL_POST_RENDERER"); renderer != "" {
+		// A bare name refers to an executable shipped under bin/ in the bundle
+		if !strings.Contains(renderer, "/") {
+			renderer = filepath.Join(config.DefaultBinDir, renderer)
+		}
+		s.helm.PostRenderer = renderer
+	}
+
+	s.helm.OnCheckpoint(s.pause)
+	s.helm.OnPhase(func(chart, from, to string) {
+		s.metrics.observePhase(s.helm, chart, from, to)
+	})
+
+	if lines := envInt("KUBE_PARCEL_FAILURE_CONTEXT_LINES", 0); lines > 0 {
+		s.helm.OnFailure(func(chart string) {
+			s.promoteFailureContext(chart, lines)
+		})
+	}
 
 	s.extractor.OnImage(func(name string) {
 		s.state.IncrementImages()
+		s.metrics.countImage()
 		s.broadcastLog("runner", "info", fmt.Sprintf("Extracted image: %s", name))
 	})
 
 	s.extractor.OnChart(func(name string) {
 		s.state.IncrementCharts()
+		s.metrics.countChart()
 		s.broadcastLog("runner", "info", fmt.Sprintf("Extracted chart: %s", name))
 	})
 
@@ -84,26 +217,101 @@ func (s *Server) HandleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.state.Current() != shared.StateIdle {
+	// An upgrade reuses the running cluster once the previous run has finished; without a
+	// cluster it is an ordinary first run
+	upgrade := r.Header.Get(shared.HeaderUpgrade) == "true" && s.k3s.IsReady()
+	if upgrade && !s.idle.isFinished() {
+		w.Header().Set("Retry-After", strconv.Itoa(uploadRetryAfter(s.state.Current())))
+		http.Error(w, "A run is still in progress", http.StatusConflict)
+		return
+	}
+	if current := s.state.Current(); !upgrade && current != shared.StateIdle && current != shared.StateFailed {
+		w.Header().Set("Retry-After", strconv.Itoa(uploadRetryAfter(current)))
 		http.Error(w, "Server not in IDLE state", http.StatusConflict)
 		return
 	}
+	if s.k3s.StartFailed() {
+		http.Error(w, "K3s failed to start on this runner and can't be restarted; start a new runner", http.StatusConflict)
+		return
+	}
+	// After a failed run on a live cluster, a fresh install would clash with the previous
+	// releases and K3s can't be started twice
+	if !upgrade && s.k3s.IsReady() {
+		http.Error(w, "The cluster of a previous run is still running; upload with --upgrade to reuse it", http.StatusConflict)
+		return
+	}
+
+	pauseOn := r.Header.Get(shared.HeaderPauseOn)
+	if !validPausePoint(pauseOn) {
+		http.Error(w, fmt.Sprintf("Unknown pause point %q", pauseOn), http.StatusBadRequest)
+		return
+	}
+	deadline, err := parseDeadline(r.Header.Get(shared.HeaderDeadline))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.pauseGate.arm(pauseOn)
 
-	log.Println("📦 Receiving parcel stream...")
-	s.state.Transition(shared.StateTransferring)
+	// Another upload may have won the race since the check above
+	if err := s.state.Transition(shared.StateTransferring); err != nil {
+		http.Error(w, "Server not in IDLE state", http.StatusConflict)
+		return
+	}
+	s.runID = newRunID()
+	slog.Info("Receiving parcel stream", "run_id", s.runID, "upgrade", upgrade)
+	s.idle.setFinished(false)
+	s.wsMutex.Lock()
+	s.runStarted = time.Now()
+	s.wsMutex.Unlock()
+	s.runDeadline = deadline
+	s.helm.Upgrade = r.Header.Get(shared.HeaderUpgrade) == "true"
+	if upgrade {
+		s.broadcastLog("runner", "info", "Upgrade requested, replacing the previous parcel on the running cluster")
+	}
+	// Whatever an earlier (upgraded, failed or partially extracted) parcel left behind must
+	// not be installed together with this one
+	s.state.ResetCounts()
+	if err := s.extractor.Reset(); err != nil {
+		s.failRun(fmt.Sprintf("Clearing the previous parcel failed: %v", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	if err := s.extractor.Extract(r.Body); err != nil {
-		log.Printf("Extraction failed: %v", err)
+	// The checksum and size cover the bytes as sent, before any decompression
+	hash := sha256.New()
+	raw := &countingReader{r: io.TeeReader(r.Body, hash)}
+	err = s.extractUpload(r.Header.Get("Content-Encoding"), raw)
+	if err == nil {
+		// The tar reader stops at the end-of-archive marker; read the rest (padding) so the
+		// digest covers the whole body and the trailer is available
+		_, err = io.Copy(io.Discard, raw)
+	}
+	s.bundleBytes = raw.n
+	if err != nil {
+		slog.Error("Extraction failed", "error", err)
 		s.broadcastLog("runner", "error", fmt.Sprintf("Extraction failed: %v", err))
-		s.state.Transition(shared.StateIdle)
+		s.failRun(fmt.Sprintf("%s: %v", shared.FailureExtraction, err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("✅ Parcel extraction complete")
+	if err := verifyChecksum(r, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		slog.Error("Parcel checksum verification failed", "error", err)
+		s.broadcastLog("runner", "error", err.Error())
+		s.failRun("Parcel corrupted in transit")
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	slog.Info("Parcel extraction complete")
 	s.broadcastLog("runner", "info", "Parcel extraction complete")
 
-	go s.startK3s()
+	if upgrade {
+		go s.upgradeRun()
+	} else {
+		go s.startK3s()
+	}
 
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -116,56 +324,264 @@ func (s *Server) HandleUpload(w http.ResponseWriter, r *http.Request) {
 func (s *Server) startK3s() {
 	ctx := context.Background()
 
-	s.state.Transition(shared.StateStarting)
+	if !s.transition(shared.StateStarting) {
+		s.failRun("Run could not start")
+		return
+	}
 
-	var logWriter io.Writer
-	if s.debug {
-		logWriter = io.MultiWriter(os.Stdout, s.logBuffer)
-	} else {
-		f, err := os.Create("/tmp/k3s.log")
-		if err == nil {
-			logWriter = f
+	// K3s output always reaches clients (level-filtered, off the K3s goroutine); the
+	// file keeps everything, and debug mode mirrors it all to stdout
+	clientLog := newQueuedLogWriter("k3s", s.k3sLogLevel, k3sLogQueueSize, s.broadcastLog)
+	writers := []io.Writer{clientLog}
+	if s.k3sLogFile != "" {
+		// Left open: K3s keeps writing after the run finishes
+		if f, err := os.Create(s.k3sLogFile); err == nil {
+			writers = append(writers, f)
 		} else {
-			logWriter = io.Discard
+			slog.Warn("Could not create K3s log file", "path", s.k3sLogFile, "error", err)
 		}
 	}
+	if s.debug {
+		writers = append(writers, os.Stdout)
+	}
+	logWriter := io.MultiWriter(writers...)
 
+	k3sStarted := time.Now()
 	if err := s.k3s.Start(ctx, logWriter); err != nil {
-		log.Printf("K3s startup failed: %v", err)
+		slog.Error("K3s startup failed", "error", err)
+		clientLog.replaySkipped()
 		s.broadcastLog("k3s", "error", fmt.Sprintf("Startup failed: %v", err))
-		s.broadcastLog("runner", "complete", "COMPLETE:FAILED:K3s startup failed")
-		s.state.Transition(shared.StateIdle)
+		s.failRun("K3s startup failed")
 		return
 	}
 
-	s.state.Transition(shared.StateReady)
+	if !s.transition(shared.StateReady) {
+		s.failRun("Run could not start")
+		return
+	}
 	s.broadcastLog("k3s", "info", "K3s is ready")
+	s.metrics.setK3sReady(time.Since(k3sStarted))
+
+	runCtx, cancel := s.runContext()
+	defer cancel()
+	s.runCharts(runCtx, true)
+}
+
+// upgradeRun runs a new parcel's charts on the cluster a previous run left running
+func (s *Server) upgradeRun() {
+	if !s.transition(shared.StateReady) {
+		s.failRun("Run could not start")
+		return
+	}
+	s.broadcastLog("runner", "info", "Reusing the running cluster, releases are upgraded in place")
+	ctx, cancel := s.runContext()
+	defer cancel()
+	s.runCharts(ctx, false)
+}
+
+// errRunDeadline is the cancellation cause once a run outlives the client's deadline
+var errRunDeadline = errors.New("run deadline exceeded")
+
+// runContext bounds a run's chart work by the deadline the client sent with the upload.
+// K3s itself isn't tied to it: the cluster outlives the run.
+func (s *Server) runContext() (context.Context, context.CancelFunc) {
+	if s.runDeadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	ctx, cancel := context.WithDeadlineCause(context.Background(), s.runDeadline, errRunDeadline)
+	context.AfterFunc(ctx, func() {
+		if errors.Is(context.Cause(ctx), errRunDeadline) {
+			slog.Warn("Run deadline exceeded, aborting helm commands", "deadline", s.runDeadline)
+			s.broadcastLog("runner", "error", "Run deadline exceeded, aborting in-flight helm commands")
+		}
+	})
+	return ctx, cancel
+}
+
+// parseDeadline turns the upload's deadline header (time remaining, as a Go duration)
+// into an absolute time; an absent header means no deadline
+func parseDeadline(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("invalid %s %q (expected a positive duration such as 20m)", shared.HeaderDeadline, value)
+	}
+	return time.Now().Add(d), nil
+}
+
+// runCharts imports the parcel's images, installs and tests its charts on the ready
+// cluster, and reports the result. setupNetwork is false when the cluster's networking
+// was already set up by an earlier run.
+func (s *Server) runCharts(ctx context.Context, setupNetwork bool) {
+	s.collectImages()
 
 	s.broadcastLog("runner", "info", "Importing bundled images...")
-	if err := ImportImages(); err != nil {
-		log.Printf("Warning: image import failed: %v", err)
-		s.broadcastLog("runner", "warning", fmt.Sprintf("Image import warning: %v", err))
+	before, listErr := listContainerdImages()
+	importErr := ImportImages(s.importOpts)
+	failures := importFailures(importErr)
+	s.wsMutex.Lock()
+	s.importErrors = failures
+	s.wsMutex.Unlock()
+	if importErr != nil {
+		slog.Warn("Image import failed", "error", importErr)
+		if s.failOnImportError {
+			s.broadcastLog("runner", "error", fmt.Sprintf("%d image(s) failed to import: %s", len(failures), strings.Join(failures, "; ")))
+			s.failRun("Image import failed")
+			return
+		}
+		s.broadcastLog("runner", "warning", fmt.Sprintf("%d image(s) failed to import, charts using them will fail to pull: %s", len(failures), strings.Join(failures, "; ")))
+	}
+	s.helm.BundledImages = nil
+	if after, err := listContainerdImages(); listErr == nil && err == nil {
+		s.helm.BundledImages = newImages(before, after)
+	}
+
+	if setupNetwork {
+		s.broadcastLog("k3s", "info", "Waiting for cluster networking (nodes Ready)...")
+		if err := s.k3s.SetupNetwork(ctx); err != nil {
+			slog.Error("Cluster networking setup failed", "error", err)
+			s.broadcastLog("k3s", "error", fmt.Sprintf("Networking setup failed: %v", err))
+			s.failRun("Cluster networking not ready")
+			return
+		}
+	}
+
+	if n, err := s.helm.ApplyManifests(ctx); err != nil {
+		slog.Error("Applying bundled manifests failed", "error", err)
+		s.broadcastLog("runner", "error", fmt.Sprintf("Applying manifests failed: %v", err))
+		s.failRun(shared.FailureManifests)
+		return
+	} else if n > 0 {
+		s.broadcastLog("runner", "info", fmt.Sprintf("Applied %d bundled manifest file(s)", n))
 	}
 
-	err := s.helm.InstallCharts()
+	err := s.helm.InstallCharts(ctx)
+	if !s.helm.reachCheckpoint(shared.PauseOnTest, "after tests finished") && err == nil {
+		err = fmt.Errorf("run aborted")
+	}
 
 	allPassed := err == nil
 	if err != nil {
-		log.Printf("Helm installation warnings: %v", err)
+		slog.Warn("Helm installation reported failures", "error", err)
 		s.broadcastLog("helm", "warning", fmt.Sprintf("Installation warnings: %v", err))
 		for _, status := range s.helm.GetChartsStatus() {
-			if status.Phase == "Failed" {
+			if status.Phase == "Failed" || status.Phase == "LintFailed" || status.Phase == "ReadinessFailed" {
 				allPassed = false
 				break
 			}
 		}
 	}
 
+	s.writeResults(allPassed)
+	s.appendMetrics()
+
 	if allPassed {
 		s.broadcastLog("runner", "complete", "COMPLETE:SUCCESS:All tests passed")
 		return
 	}
-	s.broadcastLog("runner", "complete", "COMPLETE:FAILED:Tests failed")
+	if errors.Is(context.Cause(ctx), errRunDeadline) {
+		s.failRun("Run deadline exceeded")
+		return
+	}
+	if s.helm.Aborted() {
+		s.failRun("Run aborted")
+		return
+	}
+	s.failRun(shared.FailureTests)
+}
+
+// extractUpload extracts the parcel, decompressing it first if the client gzipped it
+func (s *Server) extractUpload(encoding string, body io.Reader) error {
+	switch encoding {
+	case "", "identity":
+		return s.extractor.Extract(body)
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		if err := s.extractor.Extract(gz); err != nil {
+			return err
+		}
+		// Reaching the end of the gzip stream verifies its CRC
+		_, err = io.Copy(io.Discard, gz)
+		return err
+	default:
+		return fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+}
+
+// verifyChecksum compares the received parcel's SHA-256 with the one the client sent as
+// a header or trailer. Uploads without one (e.g. plain curl) are accepted unverified.
+func verifyChecksum(r *http.Request, actual string) error {
+	expected := r.Header.Get(shared.HeaderChecksum)
+	if expected == "" {
+		expected = r.Trailer.Get(shared.HeaderChecksum)
+	}
+	if expected == "" {
+		slog.Info("Upload sent no checksum, skipping verification")
+		return nil
+	}
+	if !strings.EqualFold(expected, actual) {
+		return fmt.Errorf("parcel checksum mismatch: client sent sha256 %s, received %s; the stream was truncated or corrupted", expected, actual)
+	}
+	slog.Info("Parcel checksum verified", "sha256", actual)
+	return nil
+}
+
+// failRun ends the run in FAILED and sends clients the COMPLETE:FAILED result
+func (s *Server) failRun(reason string) {
+	if err := s.state.Fail(reason); err != nil {
+		slog.Error("State transition rejected", "error", err)
+	}
+	s.broadcastLog("runner", "complete", "COMPLETE:FAILED:"+reason)
+}
+
+// transition moves the run to the given state. An illegal move is a bug in the calling
+// path; it is logged and reported as false so the caller can stop the run.
+func (s *Server) transition(to shared.State) bool {
+	if err := s.state.Transition(to); err != nil {
+		slog.Error("State transition rejected", "error", err)
+		s.broadcastLog("runner", "error", fmt.Sprintf("Internal error: %v", err))
+		return false
+	}
+	return true
+}
+
+// broadcastImportProgress reports image import steps to connected clients
+func (s *Server) broadcastImportProgress(p ImportProgress) {
+	size := fmt.Sprintf("%.1f MB", float64(p.Bytes)/(1024*1024))
+	if len(p.Platforms) > 0 {
+		size += ", " + strings.Join(p.Platforms, " ")
+	}
+	switch {
+	case !p.Done:
+		s.broadcastLog("runner", "info", fmt.Sprintf("Importing image %d/%d: %s (%s)", p.Index, p.Total, p.Image, size))
+		if p.Missing {
+			s.broadcastLog("runner", "warning", fmt.Sprintf("Image %s has no %s variant (has %s); pods using it will fail to start",
+				p.Image, s.importOpts.Platform, strings.Join(p.Platforms, ", ")))
+		}
+	case p.Err != nil:
+		s.broadcastLog("runner", "warning", fmt.Sprintf("Failed to import image %d/%d: %s: %v", p.Index, p.Total, p.Image, p.Err))
+	default:
+		s.broadcastLog("runner", "info", fmt.Sprintf("Imported image %d/%d: %s (%s)", p.Index, p.Total, p.Image, size))
+	}
+}
+
+// uploadRetryAfter suggests how many seconds a rejected upload should wait before trying
+// again: a transfer ends soonest, a booting cluster takes a while, and a run in progress
+// is anyone's guess
+func uploadRetryAfter(state shared.State) int {
+	switch state {
+	case shared.StateTransferring:
+		return 10
+	case shared.StateStarting:
+		return 30
+	default:
+		return 15
+	}
 }
 
 // HandleStatus returns the current server status
@@ -183,25 +599,49 @@ func (s *Server) HandleStatus(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		} else {
-			log.Printf("Warning: failed to list containerd images: %v", err)
+			slog.Warn("Failed to list containerd images", "error", err)
 		}
 	}
 
 	clusterStatus := "Initializing"
+	nodes := 0
 	if s.k3s.IsReady() {
 		clusterStatus = "Ready"
+		nodes = s.k3s.NodeCount()
+	}
+
+	resources := s.helm.FetchAllClusterResources()
+	workloadStatus, unhealthy := "Unknown", 0
+	if s.k3s.IsReady() && resources != nil {
+		workloadStatus, unhealthy = workloadHealth(resources)
 	}
 
+	s.wsMutex.Lock()
+	importErrors := s.importErrors
+	s.wsMutex.Unlock()
+
+	state, failureReason := s.state.Describe()
 	status := shared.StatusResponse{
-		State:            s.state.Current().String(),
+		State:            state.String(),
+		FailureReason:    failureReason,
 		Uptime:           int(time.Since(s.startTime).Seconds()),
 		K3sReady:         s.k3s.IsReady(),
+		K3sStartFailed:   s.k3s.StartFailed(),
 		ClusterStatus:    clusterStatus,
+		AirgapEnforced:   s.k3s.AirgapEnforced(),
+		AirgapDisabled:   !s.k3s.Airgap,
+		K3sVersion:       s.k3s.RunningVersion(),
+		Nodes:            nodes,
+		Paused:           s.pauseGate.pausedAt(),
+		WorkloadHealth:   workloadStatus,
+		UnhealthyCount:   unhealthy,
 		ChartsCount:      charts,
 		ImagesCount:      images,
 		Images:           imageList,
+		ImportErrors:     importErrors,
+		LogsDropped:      s.logBuffer.Dropped(),
 		Charts:           s.helm.GetChartsStatus(),
-		ClusterResources: s.helm.FetchAllClusterResources(),
+		ClusterResources: resources,
 		StartTime:        s.startTime,
 	}
 
@@ -209,39 +649,235 @@ func (s *Server) HandleStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(status)
 }
 
-// HandleWebSocket handles WebSocket connections for log streaming
+// HTTPPort returns the port the parcel API should listen on (KUBE_PARCEL_HTTP_PORT)
+func (s *Server) HTTPPort() int {
+	return s.httpPort
+}
+
+// HandleHealthz is the liveness probe: the process is up and serving requests
+func (s *Server) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// HandleReadyz is the readiness probe. The runner is ready while it waits for a parcel
+// (IDLE) or once K3s is up (READY); it is not while a parcel is transferring, K3s is
+// starting, or the run failed.
+func (s *Server) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	state := s.state.Current()
+	if state == shared.StateIdle || (state == shared.StateReady && s.k3s.IsReady()) {
+		w.Write([]byte("ok\n"))
+		return
+	}
+	http.Error(w, state.String(), http.StatusServiceUnavailable)
+}
+
+// HandleWebSocket handles WebSocket connections for log streaming.
+// ?min_level=warn (or debug, info, error) only streams messages at or above that level.
 func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	minLevel, err := parseMinLevel(r.URL.Query().Get("min_level"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
+		slog.Warn("WebSocket upgrade failed", "error", err)
 		return
 	}
 
+	// run=current skips replaying earlier runs (and their COMPLETE messages) on a runner
+	// that has been reused
+	var since time.Time
 	s.wsMutex.Lock()
-	s.wsClients[conn] = true
+	if r.URL.Query().Get("run") == "current" {
+		since = s.runStarted
+	}
+	s.wsClients[conn] = minLevel
 	s.wsMutex.Unlock()
+	s.idle.touch()
 
+	live := make(chan shared.LogMessage, logFollowQueueSize)
 	defer func() {
+		s.logBuffer.Unsubscribe(live)
 		s.wsMutex.Lock()
 		delete(s.wsClients, conn)
 		s.wsMutex.Unlock()
+		s.idle.touch()
 		conn.Close()
 	}()
 
-	for _, logMsg := range s.logBuffer.GetAll() {
-		if err := conn.WriteJSON(logMsg); err != nil {
+	// Reads only handle control frames and notice the client going away; all writes happen
+	// on this goroutine, as the connection allows a single writer
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var last uint64
+	send := func(logMsg shared.LogMessage) error {
+		if !levelAtLeast(logMsg.Level, minLevel) || logMsg.Timestamp.Before(since) {
+			return nil
+		}
+		return conn.WriteJSON(logMsg)
+	}
+	// Subscribed with the replay's snapshot, so messages arrive once and in seq order
+	if err := s.logBuffer.Follow(live, func(logMsg shared.LogMessage) error {
+		last = max(last, logMsg.Seq)
+		return send(logMsg)
+	}); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-gone:
 			return
+		case logMsg, ok := <-live:
+			if !ok {
+				// Fell too far behind: close the connection so the client reconnects and
+				// picks up from the replay instead of missing messages
+				slog.Warn("Log client fell behind, closing its connection", "queue_size", logFollowQueueSize)
+				return
+			}
+			if logMsg.Seq <= last {
+				continue
+			}
+			last = logMsg.Seq
+			if err := send(logMsg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// logFollowQueueSize is how many messages may wait for a slow WebSocket or /parcel/logs
+// client before its connection is ended so it reconnects
+var logFollowQueueSize = 1000
+
+// HandleLogs serves the log buffer over plain HTTP, for clients behind proxies that don't
+// allow WebSocket upgrades, as newline-delimited JSON messages. It takes the same min_level
+// and run=current parameters as /ws/logs. ?follow=true keeps the response open and streams
+// new messages until a COMPLETE message is sent or the client disconnects.
+func (s *Server) HandleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	minLevel, err := parseMinLevel(query.Get("min_level"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	follow := query.Get("follow") == "true"
+
+	var since time.Time
+	s.wsMutex.Lock()
+	if query.Get("run") == "current" {
+		since = s.runStarted
+	}
+	s.wsMutex.Unlock()
+
+	var live chan shared.LogMessage
+	if follow {
+		live = make(chan shared.LogMessage, logFollowQueueSize)
+		s.wsMutex.Lock()
+		s.followers++
+		s.wsMutex.Unlock()
+		s.idle.touch()
+
+		defer func() {
+			s.logBuffer.Unsubscribe(live)
+			s.wsMutex.Lock()
+			s.followers--
+			s.wsMutex.Unlock()
+			s.idle.touch()
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	var last uint64
+	completed := false
+	send := func(logMsg shared.LogMessage) error {
+		if !levelAtLeast(logMsg.Level, minLevel) || logMsg.Timestamp.Before(since) {
+			return nil
 		}
+		completed = completed || logMsg.Level == "complete"
+		return enc.Encode(logMsg)
+	}
+
+	replay := func(logMsg shared.LogMessage) error {
+		last = max(last, logMsg.Seq)
+		return send(logMsg)
+	}
+	if follow {
+		// Subscribed with the replay's snapshot, so nothing is missed or sent twice
+		err = s.logBuffer.Follow(live, replay)
+	} else {
+		err = s.logBuffer.Replay(replay)
+	}
+	if err != nil || !follow || completed {
+		return
+	}
+	if flusher != nil {
+		flusher.Flush()
 	}
 
 	for {
-		if _, _, err := conn.ReadMessage(); err != nil {
-			break
+		select {
+		case <-r.Context().Done():
+			return
+		case logMsg, ok := <-live:
+			if !ok {
+				// Fell too far behind: end the response so the client reconnects and
+				// picks up from the replay instead of missing messages
+				slog.Warn("Log follower fell behind, closing its stream", "queue_size", logFollowQueueSize)
+				return
+			}
+			if logMsg.Seq <= last {
+				continue
+			}
+			last = logMsg.Seq
+			if err := send(logMsg); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if completed {
+				return
+			}
 		}
 	}
 }
 
-// broadcastLog sends a log message to all WebSocket clients
+// promoteFailureContext re-broadcasts the last lines of a failed chart's helm output at error
+// level, so clients that filter out info logs still see what led to the failure. The lines come
+// from the chart's own captured output: in the shared log, charts installed or tested in
+// parallel interleave theirs.
+func (s *Server) promoteFailureContext(chart string, maxLines int) {
+	output, _, _ := s.helm.ChartOutput(chart)
+	lines := lastLines(output, maxLines)
+	if len(lines) == 0 {
+		return
+	}
+
+	s.broadcastLog("runner", "error", fmt.Sprintf("Chart %s failed; last %d helm log line(s) before the failure:", chart, len(lines)))
+	for _, line := range lines {
+		s.broadcastLog("helm", "error", "[context] "+line)
+	}
+}
+
+// broadcastLog adds a log message to the log buffer, whose subscriptions deliver it to the
+// WebSocket and /parcel/logs clients
 func (s *Server) broadcastLog(source, level, message string) {
 	logMsg := shared.LogMessage{
 		Timestamp: time.Now(),
@@ -252,48 +888,181 @@ func (s *Server) broadcastLog(source, level, message string) {
 
 	s.logBuffer.Add(logMsg)
 
-	s.wsMutex.Lock()
-	defer s.wsMutex.Unlock()
-
-	for conn := range s.wsClients {
-		if err := conn.WriteJSON(logMsg); err != nil {
-			conn.Close()
-			delete(s.wsClients, conn)
-		}
+	if level == "complete" {
+		s.idle.setFinished(true)
 	}
 }
 
-// LogBuffer stores recent log messages
+// LogBuffer stores recent log messages. Messages evicted past maxSize are dropped, or
+// appended to a spill file as JSON lines if one is set, so Replay still sees them.
 type LogBuffer struct {
 	mu          sync.RWMutex
 	messages    []shared.LogMessage
-	maxSize     int
+	maxSize     int    // 0 = unbounded
+	dropped     int    // Messages evicted without being spilled
+	seq         uint64 // Seq of the last added message
 	subscribers []chan shared.LogMessage
+
+	spill     *os.File
+	spillSize int64 // Bytes of complete lines written to spill
 }
 
 func NewLogBuffer(maxSize int) *LogBuffer {
 	return &LogBuffer{
-		messages:    make([]shared.LogMessage, 0, maxSize),
+		messages:    make([]shared.LogMessage, 0, max(maxSize, 0)),
 		maxSize:     maxSize,
 		subscribers: make([]chan shared.LogMessage, 0),
 	}
 }
 
-func (lb *LogBuffer) Add(msg shared.LogMessage) {
+// Add appends msg with the next sequence number and returns it as stored. A subscriber whose
+// queue is full is unsubscribed and its channel closed rather than silently missing msg.
+func (lb *LogBuffer) Add(msg shared.LogMessage) shared.LogMessage {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 
+	lb.seq++
+	msg.Seq = lb.seq
 	lb.messages = append(lb.messages, msg)
-	if len(lb.messages) > lb.maxSize {
+	if lb.maxSize > 0 && len(lb.messages) > lb.maxSize {
+		if !lb.spillMessage(lb.messages[0]) {
+			lb.dropped++
+		}
 		lb.messages = lb.messages[1:]
 	}
 
+	subscribers := lb.subscribers[:0]
 	for _, ch := range lb.subscribers {
 		select {
 		case ch <- msg:
+			subscribers = append(subscribers, ch)
 		default:
+			close(ch)
+		}
+	}
+	lb.subscribers = subscribers
+	return msg
+}
+
+// SpillTo keeps evicted messages in the file at path (truncated first) instead of dropping them
+func (lb *LogBuffer) SpillTo(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.spill, lb.spillSize = f, 0
+	return nil
+}
+
+// spillMessage appends an evicted message to the spill file and reports whether it was kept;
+// callers hold lb.mu
+func (lb *LogBuffer) spillMessage(msg shared.LogMessage) bool {
+	if lb.spill == nil {
+		return false
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return false
+	}
+	n, err := lb.spill.Write(append(data, '\n'))
+	if err != nil {
+		slog.Warn("Log spill write failed, dropping older messages from now on", "error", err)
+		lb.spill.Close()
+		lb.spill = nil
+		return false
+	}
+	lb.spillSize += int64(n)
+	return true
+}
+
+// Dropped returns how many messages were evicted from the buffer without being spilled
+func (lb *LogBuffer) Dropped() int {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return lb.dropped
+}
+
+// Replay calls fn for every retained message in order: spilled messages first, then the
+// in-memory buffer. If messages were dropped, a warning saying how many comes first, so a
+// late client knows the history it sees is incomplete. It stops at the first error fn returns.
+func (lb *LogBuffer) Replay(fn func(shared.LogMessage) error) error {
+	return lb.replay(nil, fn)
+}
+
+// Follow is Replay for a client that keeps streaming: ch is subscribed in the same critical
+// section that takes the replayed snapshot, so it receives exactly the messages added after
+// it. ch is closed when it is unsubscribed or falls a full queue behind.
+func (lb *LogBuffer) Follow(ch chan shared.LogMessage, fn func(shared.LogMessage) error) error {
+	return lb.replay(ch, fn)
+}
+
+func (lb *LogBuffer) replay(subscribe chan shared.LogMessage, fn func(shared.LogMessage) error) error {
+	lb.mu.Lock()
+	messages := make([]shared.LogMessage, len(lb.messages))
+	copy(messages, lb.messages)
+	dropped := lb.dropped
+	var spillPath string
+	spillSize := lb.spillSize
+	if lb.spill != nil {
+		spillPath = lb.spill.Name()
+	}
+	if subscribe != nil {
+		lb.subscribers = append(lb.subscribers, subscribe)
+	}
+	lb.mu.Unlock()
+
+	if dropped > 0 {
+		// Stamped like the oldest retained message so it sorts (and dedups on reconnect) with it
+		marker := shared.LogMessage{
+			Timestamp: time.Now(),
+			Level:     "warning",
+			Source:    "runner",
+			Message:   fmt.Sprintf("[truncated %d earlier log line(s); raise KUBE_PARCEL_LOG_BUFFER_SIZE or set KUBE_PARCEL_LOG_SPILL_FILE to keep them]", dropped),
+		}
+		if len(messages) > 0 {
+			marker.Timestamp = messages[0].Timestamp
+		}
+		if err := fn(marker); err != nil {
+			return err
+		}
+	}
+
+	if spillPath != "" && spillSize > 0 {
+		f, err := os.Open(spillPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		// Only read what was spilled before the snapshot; later evictions are in messages
+		scanner := bufio.NewScanner(io.LimitReader(f, spillSize))
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			var msg shared.LogMessage
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				continue
+			}
+			if err := fn(msg); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, msg := range messages {
+		if err := fn(msg); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 func (lb *LogBuffer) GetAll() []shared.LogMessage {
@@ -305,12 +1074,6 @@ func (lb *LogBuffer) GetAll() []shared.LogMessage {
 	return result
 }
 
-func (lb *LogBuffer) Subscribe(ch chan shared.LogMessage) {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
-	lb.subscribers = append(lb.subscribers, ch)
-}
-
 func (lb *LogBuffer) Unsubscribe(ch chan shared.LogMessage) {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
@@ -331,7 +1094,7 @@ func (lb *LogBuffer) Write(p []byte) (n int, err error) {
 		}
 		lb.Add(shared.LogMessage{
 			Timestamp: time.Now(),
-			Level:     "info",
+			Level:     detectLogLevel(string(line)),
 			Source:    "k3s",
 			Message:   string(line),
 		})
@@ -353,12 +1116,13 @@ func (w *SourceLogWriter) Write(p []byte) (n int, err error) {
 			continue
 		}
 		// Use broadcast if available (includes websocket)
+		level := detectLogLevel(string(line))
 		if w.broadcast != nil {
-			w.broadcast(w.source, "info", string(line))
+			w.broadcast(w.source, level, string(line))
 		} else {
 			w.buffer.Add(shared.LogMessage{
 				Timestamp: time.Now(),
-				Level:     "info",
+				Level:     level,
 				Source:    w.source,
 				Message:   string(line),
 			})
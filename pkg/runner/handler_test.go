@@ -0,0 +1,655 @@
+package runner
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func newTestServer() *Server {
+	return &Server{
+		logBuffer: NewLogBuffer(100),
+		wsClients: make(map[*websocket.Conn]int),
+	}
+}
+
+func TestServer_PromoteFailureContext(t *testing.T) {
+	s := newTestServer()
+	s.helm = NewHelmManager(io.Discard)
+
+	// Another chart installing in parallel interleaves its output with the failed one's
+	myapp, other := s.helm.chartOutputWriter("myapp"), s.helm.chartOutputWriter("other")
+	for _, line := range []string{"line 1", "line 2", "line 3"} {
+		fmt.Fprintln(myapp, line)
+		fmt.Fprintln(other, "other "+line)
+		s.broadcastLog("helm", "info", line)
+		s.broadcastLog("helm", "info", "other "+line)
+	}
+	s.broadcastLog("k3s", "info", "unrelated")
+
+	s.promoteFailureContext("myapp", 2)
+	s.promoteFailureContext("never-ran", 2)
+
+	var promoted []string
+	for _, msg := range s.logBuffer.GetAll() {
+		if strings.HasPrefix(msg.Message, "[context] ") {
+			if msg.Level != "error" {
+				t.Errorf("expected promoted line to be error level, got %q", msg.Level)
+			}
+			promoted = append(promoted, strings.TrimPrefix(msg.Message, "[context] "))
+		}
+	}
+
+	expected := []string{"line 2", "line 3"}
+	if len(promoted) != len(expected) {
+		t.Fatalf("expected %d promoted lines, got %v", len(expected), promoted)
+	}
+	for i := range expected {
+		if promoted[i] != expected[i] {
+			t.Errorf("promoted[%d] = %q, expected %q", i, promoted[i], expected[i])
+		}
+	}
+}
+
+func TestServer_BroadcastImportProgress(t *testing.T) {
+	s := newTestServer()
+
+	s.broadcastImportProgress(ImportProgress{Image: "app.tar", Index: 1, Total: 2, Bytes: 3 * 1024 * 1024})
+	s.broadcastImportProgress(ImportProgress{Image: "app.tar", Index: 1, Total: 2, Bytes: 3 * 1024 * 1024, Done: true})
+	s.broadcastImportProgress(ImportProgress{Image: "db.tar", Index: 2, Total: 2, Done: true, Err: errors.New("boom")})
+
+	msgs := s.logBuffer.GetAll()
+	expected := []struct{ level, message string }{
+		{"info", "Importing image 1/2: app.tar (3.0 MB)"},
+		{"info", "Imported image 1/2: app.tar (3.0 MB)"},
+		{"warning", "Failed to import image 2/2: db.tar: boom"},
+	}
+	if len(msgs) != len(expected) {
+		t.Fatalf("expected %d messages, got %d", len(expected), len(msgs))
+	}
+	for i, want := range expected {
+		if msgs[i].Level != want.level || msgs[i].Message != want.message {
+			t.Errorf("message %d = [%s] %q, expected [%s] %q", i, msgs[i].Level, msgs[i].Message, want.level, want.message)
+		}
+	}
+}
+
+func TestNewServer_HelmTimeouts(t *testing.T) {
+	t.Setenv("KUBE_PARCEL_HELM_TIMEOUT", "30m")
+	s := NewServer()
+	if s.helm.InstallTimeout != 30*time.Minute || s.helm.TestTimeout != 30*time.Minute {
+		t.Errorf("expected install and test timeouts of 30m, got %v and %v", s.helm.InstallTimeout, s.helm.TestTimeout)
+	}
+
+	t.Setenv("KUBE_PARCEL_TEST_TIMEOUT", "2m")
+	s = NewServer()
+	if s.helm.InstallTimeout != 30*time.Minute || s.helm.TestTimeout != 2*time.Minute {
+		t.Errorf("expected install 30m and test 2m, got %v and %v", s.helm.InstallTimeout, s.helm.TestTimeout)
+	}
+}
+
+func TestNewServer_TakeOwnership(t *testing.T) {
+	if NewServer().helm.TakeOwnership {
+		t.Error("expected take-ownership to be off by default")
+	}
+	t.Setenv("KUBE_PARCEL_TAKE_OWNERSHIP", "true")
+	if !NewServer().helm.TakeOwnership {
+		t.Error("expected KUBE_PARCEL_TAKE_OWNERSHIP=true to enable take-ownership")
+	}
+}
+
+func TestLogBuffer_SpillReplay(t *testing.T) {
+	lb := NewLogBuffer(2)
+	if err := lb.SpillTo(filepath.Join(t.TempDir(), "logs.jsonl")); err != nil {
+		t.Fatalf("SpillTo failed: %v", err)
+	}
+	for i := range 5 {
+		lb.Add(shared.LogMessage{Source: "runner", Message: fmt.Sprintf("msg-%d", i)})
+	}
+
+	if got := len(lb.GetAll()); got != 2 {
+		t.Errorf("expected 2 messages in memory, got %d", got)
+	}
+
+	var replayed []string
+	if err := lb.Replay(func(msg shared.LogMessage) error {
+		replayed = append(replayed, msg.Message)
+		return nil
+	}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if want := "msg-0 msg-1 msg-2 msg-3 msg-4"; strings.Join(replayed, " ") != want {
+		t.Errorf("expected full history %q, got %q", want, strings.Join(replayed, " "))
+	}
+}
+
+func TestLogBuffer_Dropped(t *testing.T) {
+	lb := NewLogBuffer(2)
+	t0 := time.Now()
+	for i := range 5 {
+		lb.Add(shared.LogMessage{Timestamp: t0.Add(time.Duration(i) * time.Second), Source: "runner", Message: fmt.Sprintf("msg-%d", i)})
+	}
+	if got := lb.Dropped(); got != 3 {
+		t.Errorf("expected 3 dropped messages, got %d", got)
+	}
+
+	var replayed []shared.LogMessage
+	lb.Replay(func(msg shared.LogMessage) error {
+		replayed = append(replayed, msg)
+		return nil
+	})
+	if len(replayed) != 3 || !strings.Contains(replayed[0].Message, "truncated 3 earlier log line(s)") {
+		t.Fatalf("expected a truncation marker before the retained messages, got %+v", replayed)
+	}
+	if replayed[0].Level != "warning" || !replayed[0].Timestamp.Equal(replayed[1].Timestamp) {
+		t.Errorf("expected a warning stamped like the oldest retained message, got %+v", replayed[0])
+	}
+
+	spilled := NewLogBuffer(2)
+	spilled.SpillTo(filepath.Join(t.TempDir(), "logs.jsonl"))
+	for range 5 {
+		spilled.Add(shared.LogMessage{Message: "line"})
+	}
+	if got := spilled.Dropped(); got != 0 {
+		t.Errorf("expected spilled messages not to count as dropped, got %d", got)
+	}
+}
+
+func TestLogBuffer_Unbounded(t *testing.T) {
+	lb := NewLogBuffer(0)
+	for range 1500 {
+		lb.Add(shared.LogMessage{Message: "line"})
+	}
+	if got := len(lb.GetAll()); got != 1500 {
+		t.Errorf("expected an unbounded buffer to keep all 1500 messages, got %d", got)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	const digest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	withHeader := httptest.NewRequest(http.MethodPost, "/parcel/upload", nil)
+	withHeader.Header.Set(shared.HeaderChecksum, digest)
+	if err := verifyChecksum(withHeader, digest); err != nil {
+		t.Errorf("matching header rejected: %v", err)
+	}
+
+	withTrailer := httptest.NewRequest(http.MethodPost, "/parcel/upload", nil)
+	withTrailer.Trailer = http.Header{shared.HeaderChecksum: {strings.ToUpper(digest)}}
+	if err := verifyChecksum(withTrailer, digest); err != nil {
+		t.Errorf("matching trailer rejected: %v", err)
+	}
+	if err := verifyChecksum(withTrailer, strings.Repeat("0", 64)); err == nil {
+		t.Error("expected a mismatch to be rejected")
+	}
+
+	if err := verifyChecksum(httptest.NewRequest(http.MethodPost, "/parcel/upload", nil), digest); err != nil {
+		t.Errorf("upload without checksum rejected: %v", err)
+	}
+}
+
+func TestServer_HandleUploadChecksumMismatch(t *testing.T) {
+	s := NewServer()
+	s.extractor = &TarExtractor{imagesDir: t.TempDir(), chartsDir: t.TempDir(), binDir: t.TempDir(), manifestsDir: t.TempDir()}
+
+	var parcel bytes.Buffer
+	tar.NewWriter(&parcel).Close()
+	req := httptest.NewRequest(http.MethodPost, "/parcel/upload", &parcel)
+	req.Header.Set(shared.HeaderChecksum, strings.Repeat("0", 64))
+	w := httptest.NewRecorder()
+	s.HandleUpload(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a checksum mismatch, got %d", w.Code)
+	}
+	if state, reason := s.state.Describe(); state != shared.StateFailed || reason == "" {
+		t.Errorf("expected FAILED with a reason, got %s %q", state, reason)
+	}
+}
+
+func TestServer_HandleUploadAfterFailure(t *testing.T) {
+	s := NewServer()
+	s.extractor = &TarExtractor{imagesDir: t.TempDir(), chartsDir: t.TempDir(), binDir: t.TempDir(), manifestsDir: t.TempDir()}
+
+	// A first parcel whose checksum doesn't match leaves its chart on disk
+	var parcel bytes.Buffer
+	tw := tar.NewWriter(&parcel)
+	tw.WriteHeader(&tar.Header{Name: "charts/stale/Chart.yaml", Mode: 0644, Size: 2})
+	tw.Write([]byte("v2"))
+	tw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/parcel/upload", &parcel)
+	req.Header.Set(shared.HeaderChecksum, strings.Repeat("0", 64))
+	s.HandleUpload(httptest.NewRecorder(), req)
+	if s.state.Current() != shared.StateFailed {
+		t.Fatalf("expected the corrupted parcel to fail the run, got %s", s.state.Current())
+	}
+	if _, err := os.Stat(filepath.Join(s.extractor.chartsDir, "stale")); err != nil {
+		t.Fatalf("expected the corrupted parcel's chart to be extracted, stat err = %v", err)
+	}
+
+	// The retry is a parcel that fails too, so the run doesn't go on to start K3s
+	req = httptest.NewRequest(http.MethodPost, "/parcel/upload", strings.NewReader("not a tar"))
+	w := httptest.NewRecorder()
+	s.HandleUpload(w, req)
+	if w.Code == http.StatusConflict {
+		t.Fatalf("expected a new upload to be accepted after a failed run, got 409")
+	}
+	if _, err := os.Stat(filepath.Join(s.extractor.chartsDir, "stale")); !os.IsNotExist(err) {
+		t.Errorf("expected the failed parcel's chart to be removed before the retry, stat err = %v", err)
+	}
+	if images, charts := s.state.GetCounts(); images != 0 || charts != 0 {
+		t.Errorf("expected counts to be reset, got %d images, %d charts", images, charts)
+	}
+}
+
+func TestServer_ExtractUploadGzip(t *testing.T) {
+	s := &Server{extractor: &TarExtractor{imagesDir: t.TempDir(), chartsDir: t.TempDir(), binDir: t.TempDir(), manifestsDir: t.TempDir()}}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "bin/tool", Mode: 0755, Size: 2})
+	tw.Write([]byte("hi"))
+	tw.Close()
+	gz.Close()
+
+	if err := s.extractUpload("gzip", &compressed); err != nil {
+		t.Fatalf("extractUpload(gzip) failed: %v", err)
+	}
+	if err := s.extractUpload("gzip", strings.NewReader("not gzip")); err == nil {
+		t.Error("expected an error for an invalid gzip stream")
+	}
+	if err := s.extractUpload("br", strings.NewReader("")); err == nil {
+		t.Error("expected an error for an unsupported encoding")
+	}
+}
+
+func TestServer_HandleUploadUpgradeInProgress(t *testing.T) {
+	s := NewServer()
+	s.k3s.ready = true
+	for _, state := range []shared.State{shared.StateTransferring, shared.StateStarting, shared.StateReady} {
+		s.state.Transition(state)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/parcel/upload", strings.NewReader(""))
+	req.Header.Set(shared.HeaderUpgrade, "true")
+	w := httptest.NewRecorder()
+	s.HandleUpload(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while the previous run is still going, got %d", w.Code)
+	}
+	if s.state.Current() != shared.StateReady {
+		t.Errorf("rejected upgrade changed state to %v", s.state.Current())
+	}
+	if got := w.Header().Get("Retry-After"); got != "15" {
+		t.Errorf("Retry-After = %q, want 15", got)
+	}
+}
+
+func TestServer_HandleUploadAfterFailureOnLiveCluster(t *testing.T) {
+	s := NewServer()
+	s.k3s.ready = true
+	s.state.Transition(shared.StateTransferring)
+	s.state.Transition(shared.StateStarting)
+	s.state.Fail("Tests failed")
+
+	w := httptest.NewRecorder()
+	s.HandleUpload(w, httptest.NewRequest(http.MethodPost, "/parcel/upload", strings.NewReader("")))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a fresh install on the previous run's cluster, got %d", w.Code)
+	}
+	if s.state.Current() != shared.StateFailed {
+		t.Errorf("rejected upload changed state to %v", s.state.Current())
+	}
+}
+
+func TestServer_HandleUploadAfterK3sStartFailure(t *testing.T) {
+	s := NewServer()
+	s.k3s.started = true
+	s.k3s.startFailed = true
+	s.state.Transition(shared.StateTransferring)
+	s.state.Transition(shared.StateStarting)
+	s.state.Fail("K3s failed to start")
+
+	for _, upgrade := range []string{"", "true"} {
+		req := httptest.NewRequest(http.MethodPost, "/parcel/upload", strings.NewReader(""))
+		req.Header.Set(shared.HeaderUpgrade, upgrade)
+		w := httptest.NewRecorder()
+		s.HandleUpload(w, req)
+
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "start a new runner") {
+			t.Errorf("upgrade=%q: got %d %q, want 409 asking for a new runner", upgrade, w.Code, w.Body.String())
+		}
+		if s.state.Current() != shared.StateFailed {
+			t.Errorf("upgrade=%q: rejected upload changed state to %v", upgrade, s.state.Current())
+		}
+	}
+}
+
+func TestServer_HandleUploadBusy(t *testing.T) {
+	s := NewServer()
+	s.state.Transition(shared.StateTransferring)
+
+	w := httptest.NewRecorder()
+	s.HandleUpload(w, httptest.NewRequest(http.MethodPost, "/parcel/upload", strings.NewReader("")))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 during a transfer, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want 10", got)
+	}
+}
+
+func TestServer_HandleUploadInvalidDeadline(t *testing.T) {
+	s := NewServer()
+
+	req := httptest.NewRequest(http.MethodPost, "/parcel/upload", strings.NewReader(""))
+	req.Header.Set(shared.HeaderDeadline, "soon")
+	w := httptest.NewRecorder()
+	s.HandleUpload(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid deadline, got %d", w.Code)
+	}
+	if s.state.Current() != shared.StateIdle {
+		t.Errorf("rejected upload changed state to %v", s.state.Current())
+	}
+}
+
+func TestServer_HandleReadyz(t *testing.T) {
+	s := NewServer()
+	probe := func() int {
+		w := httptest.NewRecorder()
+		s.HandleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code
+	}
+
+	if code := probe(); code != http.StatusOK {
+		t.Errorf("IDLE: got %d, want 200", code)
+	}
+	s.state.Transition(shared.StateTransferring)
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("TRANSFERRING: got %d, want 503", code)
+	}
+	s.state.Transition(shared.StateStarting)
+	s.state.Transition(shared.StateReady)
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("READY before K3s: got %d, want 503", code)
+	}
+	s.k3s.ready = true
+	if code := probe(); code != http.StatusOK {
+		t.Errorf("READY: got %d, want 200", code)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleHealthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("healthz: got %d, want 200", w.Code)
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	if deadline, err := parseDeadline(""); err != nil || !deadline.IsZero() {
+		t.Errorf("parseDeadline(\"\") = %v, %v, want no deadline", deadline, err)
+	}
+
+	before := time.Now()
+	deadline, err := parseDeadline("20m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := deadline.Sub(before); d < 20*time.Minute || d > 21*time.Minute {
+		t.Errorf("parseDeadline(\"20m\") is %s from now, want 20m", d)
+	}
+
+	for _, value := range []string{"soon", "0s", "-5m"} {
+		if _, err := parseDeadline(value); err == nil {
+			t.Errorf("parseDeadline(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestServer_RunContextDeadline(t *testing.T) {
+	s := newTestServer()
+	ctx, cancel := s.runContext()
+	cancel()
+	if context.Cause(ctx) == errRunDeadline {
+		t.Error("a run without a deadline was cancelled by one")
+	}
+
+	s.runDeadline = time.Now().Add(10 * time.Millisecond)
+	ctx, cancel = s.runContext()
+	defer cancel()
+	<-ctx.Done()
+	if !errors.Is(context.Cause(ctx), errRunDeadline) {
+		t.Errorf("cause = %v, want %v", context.Cause(ctx), errRunDeadline)
+	}
+}
+
+func TestServer_HandleLogs(t *testing.T) {
+	s := newTestServer()
+	s.broadcastLog("runner", "info", "Parcel extraction complete")
+	s.broadcastLog("helm", "warning", "Installation warnings")
+
+	w := httptest.NewRecorder()
+	s.HandleLogs(w, httptest.NewRequest(http.MethodGet, "/parcel/logs?min_level=warn", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "Installation warnings") {
+		t.Errorf("expected only the warning, got %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.HandleLogs(w, httptest.NewRequest(http.MethodGet, "/parcel/logs?min_level=loud", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown level, got %d", w.Code)
+	}
+}
+
+func TestServer_HandleLogsFollow(t *testing.T) {
+	s := newTestServer()
+	s.broadcastLog("runner", "info", "Parcel extraction complete")
+
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleLogs))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "?follow=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The replay arrives first; new messages follow until the run completes
+	scanner := bufio.NewScanner(resp.Body)
+	if !scanner.Scan() || !strings.Contains(scanner.Text(), "Parcel extraction complete") {
+		t.Fatalf("expected the replayed message first, got %q", scanner.Text())
+	}
+	s.broadcastLog("helm", "info", "Chart installed")
+	s.broadcastLog("runner", "complete", "COMPLETE:SUCCESS:All tests passed")
+
+	var got []string
+	for scanner.Scan() {
+		var msg shared.LogMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, msg.Message)
+	}
+	if want := []string{"Chart installed", "COMPLETE:SUCCESS:All tests passed"}; !slices.Equal(got, want) {
+		t.Errorf("followed %q, want %q (and the stream to end)", got, want)
+	}
+}
+
+// stalledWriter is a response writer whose first write blocks until release is closed, like a
+// slow client still reading the replay
+type stalledWriter struct {
+	*httptest.ResponseRecorder
+	stalled chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.stalled)
+		<-w.release
+	})
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestServer_HandleLogsFollowOverflow(t *testing.T) {
+	defer func(size int) { logFollowQueueSize = size }(logFollowQueueSize)
+	logFollowQueueSize = 2
+
+	s := newTestServer()
+	s.broadcastLog("runner", "info", "Parcel extraction complete")
+
+	w := &stalledWriter{ResponseRecorder: httptest.NewRecorder(), stalled: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		s.HandleLogs(w, httptest.NewRequest(http.MethodGet, "/parcel/logs?follow=true", nil))
+		close(done)
+	}()
+
+	// The queue overflows, and the COMPLETE message can't be queued, while the replay is stuck
+	<-w.stalled
+	for i := range 5 {
+		s.broadcastLog("helm", "info", fmt.Sprintf("line %d", i))
+	}
+	s.broadcastLog("runner", "complete", "COMPLETE:SUCCESS:All tests passed")
+	close(w.release)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("follow did not end after its queue overflowed")
+	}
+
+	// What was sent is in order without gaps, so a reconnect can resume from the last seq
+	var seqs []uint64
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		var msg shared.LogMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+		seqs = append(seqs, msg.Seq)
+	}
+	if want := []uint64{1, 2, 3}; !slices.Equal(seqs, want) {
+		t.Errorf("sent seqs %v, want %v", seqs, want)
+	}
+}
+
+func TestLogBuffer_Seq(t *testing.T) {
+	lb := NewLogBuffer(2)
+	for i := range 3 {
+		if msg := lb.Add(shared.LogMessage{Message: fmt.Sprint(i)}); msg.Seq != uint64(i+1) {
+			t.Errorf("Add() #%d seq = %d, want %d", i, msg.Seq, i+1)
+		}
+	}
+	if all := lb.GetAll(); all[0].Seq != 2 || all[1].Seq != 3 {
+		t.Errorf("expected the retained messages to keep seqs 2 and 3, got %+v", all)
+	}
+}
+
+func TestServer_HandleWebSocketCurrentRun(t *testing.T) {
+	s := newTestServer()
+	s.broadcastLog("runner", "complete", "COMPLETE:SUCCESS:All tests passed")
+	time.Sleep(time.Millisecond)
+	s.runStarted = time.Now()
+	s.broadcastLog("runner", "info", "Upgrade requested")
+
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+	defer srv.Close()
+
+	read := func(query string) []string {
+		c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+query, nil)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		defer c.Close()
+		var messages []string
+		for {
+			c.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			var msg shared.LogMessage
+			if err := c.ReadJSON(&msg); err != nil {
+				return messages
+			}
+			messages = append(messages, msg.Message)
+		}
+	}
+
+	if got := read("/ws/logs"); len(got) != 2 {
+		t.Errorf("expected the full history without run=current, got %v", got)
+	}
+	if got := read("/ws/logs?run=current"); len(got) != 1 || got[0] != "Upgrade requested" {
+		t.Errorf("expected only the current run with run=current, got %v", got)
+	}
+}
+
+func TestServer_HandleWebSocketConcurrentBroadcast(t *testing.T) {
+	s := newTestServer()
+	s.logBuffer = NewLogBuffer(0)
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+	defer srv.Close()
+
+	// Broadcasters race with the connect's replay and with each other
+	var wg sync.WaitGroup
+	for g := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				s.broadcastLog("helm", "info", fmt.Sprintf("chart %d line %d", g, i))
+			}
+		}()
+	}
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer c.Close()
+	wg.Wait()
+	s.broadcastLog("runner", "complete", "COMPLETE:SUCCESS:All tests passed")
+
+	// Every message arrives exactly once, in seq order
+	var last uint64
+	for {
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg shared.LogMessage
+		if err := c.ReadJSON(&msg); err != nil {
+			t.Fatalf("read failed after seq %d: %v", last, err)
+		}
+		if msg.Seq != last+1 {
+			t.Fatalf("got seq %d after %d", msg.Seq, last)
+		}
+		last = msg.Seq
+		if msg.Level == "complete" {
+			break
+		}
+	}
+	if last != 201 {
+		t.Errorf("received %d messages, want 201", last)
+	}
+}
@@ -5,13 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tiborv/kube-parcel/pkg/config"
@@ -20,56 +23,221 @@ import (
 
 // HelmManager handles Helm operations
 type HelmManager struct {
-	chartsDir   string
-	logger      io.Writer
-	chartStatus map[string]shared.ChartStatus
-	mu          sync.RWMutex
+	chartsDir    string
+	manifestsDir string
+	logger       io.Writer
+	chartStatus  map[string]shared.ChartStatus
+	startedAt    map[string]time.Time
+	testedAt     map[string]time.Time // When each chart's tests started
+	endedAt      map[string]time.Time // When each chart reached Succeeded, Skipped or a failed phase
+	artifacts    map[string][]string
+	outputs      map[string]*chartOutput // Each chart's helm install and test output
+	depBuilds    sync.Map                // Chart path → *sync.Mutex serializing dependency builds of its cases
+	caps         *Capabilities           // Detected at the start of InstallCharts; nil if detection failed
+	onFailure    func(chart string)
+	onPhase      func(chart, from, to string)
+	checkpoint   func(point, where string) bool // Returns false to abort the run
+	aborted      atomic.Bool
+	mu           sync.RWMutex
+
+	InstallTimeout     time.Duration                  // Timeout passed to helm install --wait
+	TestTimeout        time.Duration                  // Timeout passed to helm test
+	TestParallelism    int                            // Max number of charts tested concurrently (1 = serial)
+	InstallParallelism int                            // Max number of charts installed concurrently (1 = serial)
+	ArtifactsDir       string                         // Where test logs and results are written ("" disables artifacts)
+	PostRenderer       string                         // Executable passed to helm install --post-renderer ("" disables)
+	Airgap             bool                           // No external access: subcharts must be vendored in the bundle
+	TakeOwnership      bool                           // Pass --take-ownership so installs adopt existing resources
+	Upgrade            bool                           // Run helm upgrade --install, so releases left by a previous run are upgraded in place
+	Lint               bool                           // Run helm lint before each install; lint errors fail the chart as LintFailed
+	BundledImages      []string                       // Images imported from the bundle, checked for use by the charts
+	PullSecrets        map[string]shared.RegistryAuth // Registry host → credentials, set up as each chart namespace's pull secret
+	listImages         func() (*imageSet, error)
 }
 
 // NewHelmManager creates a new Helm manager
 func NewHelmManager(logger io.Writer) *HelmManager {
 	return &HelmManager{
-		chartsDir:   config.DefaultChartsDir,
-		logger:      logger,
-		chartStatus: make(map[string]shared.ChartStatus),
+		chartsDir:          config.DefaultChartsDir,
+		manifestsDir:       config.DefaultManifestsDir,
+		logger:             logger,
+		chartStatus:        make(map[string]shared.ChartStatus),
+		startedAt:          make(map[string]time.Time),
+		testedAt:           make(map[string]time.Time),
+		endedAt:            make(map[string]time.Time),
+		artifacts:          make(map[string][]string),
+		outputs:            make(map[string]*chartOutput),
+		InstallTimeout:     config.DefaultHelmTimeout,
+		TestTimeout:        config.DefaultHelmTimeout,
+		TestParallelism:    1,
+		InstallParallelism: 1,
+		ArtifactsDir:       config.DefaultArtifactsDir,
+		listImages:         listContainerdImages,
 	}
 }
 
-// InstallCharts installs all charts in the charts directory
-func (hm *HelmManager) InstallCharts() error {
+// InstallCharts installs all charts in the charts directory. Cancelling ctx kills
+// in-flight helm commands and fails the charts that haven't started.
+func (hm *HelmManager) InstallCharts(ctx context.Context) error {
 	if err := hm.ensureHelmBinary(); err != nil {
 		return fmt.Errorf("failed to ensure helm binary: %w", err)
 	}
 
+	if hm.PostRenderer != "" {
+		if err := checkExecutable(hm.PostRenderer); err != nil {
+			return fmt.Errorf("invalid post-renderer: %w", err)
+		}
+		slog.Info("Using helm post-renderer", "path", hm.PostRenderer)
+	}
+
+	if hm.TakeOwnership {
+		if !helmSupportsFlag("install", "--take-ownership") {
+			return fmt.Errorf("helm in the runner image does not support --take-ownership (requires helm 3.17 or newer)")
+		}
+		slog.Info("Helm installs will take ownership of existing resources")
+	}
+
 	charts, err := hm.discoverCharts()
 	if err != nil {
 		return err
 	}
 
+	hm.resetStatus()
 	if len(charts) == 0 {
-		log.Println("No charts found to install")
+		slog.Info("No charts found to install")
 		return nil
 	}
+	// Listed up front so status shows every chart, not just the ones already started
+	for _, chart := range charts {
+		hm.updateStatus(chart.Name, "Pending", "Waiting to install")
+	}
 
 	// Wait for default namespace to be fully bootstrapped
-	if err := hm.waitForDefaultServiceAccount(); err != nil {
-		log.Printf("Warning: could not wait for default serviceaccount: %v", err)
+	if err := waitForServiceAccount(defaultNamespace); err != nil {
+		slog.Warn("Could not wait for default serviceaccount", "error", err)
 		// Continue anyway, some charts may not need it
 	}
 
-	log.Printf("Found %d chart(s) to install", len(charts))
+	slog.Info("Discovered charts", "count", len(charts))
+
+	if caps, err := DetectCapabilities(); err != nil {
+		slog.Warn("Could not detect cluster capabilities", "error", err)
+		fmt.Fprintf(hm.logger, "⚠️ Could not detect cluster capabilities: %v\n", err)
+	} else {
+		hm.caps = &caps
+		slog.Info("Detected cluster capabilities", "default_storage_class", caps.DefaultStorageClass,
+			"ingress_controller", caps.IngressController, "metrics_server", caps.MetricsServer)
+		fmt.Fprintf(hm.logger, "Cluster capabilities: defaultStorageClass=%v ingressController=%v metricsServer=%v\n",
+			caps.DefaultStorageClass, caps.IngressController, caps.MetricsServer)
+	}
 
+	// installSlots bounds concurrent helm installs; testSlots bounds charts under test.
+	// With serial installs a test slot is taken before installing, so a single slot is the
+	// original install → test → install → test sequence. Parallel installs only take it once
+	// installed, so slow tests don't hold back other charts' installs.
+	serialInstalls := hm.InstallParallelism <= 1
+	installSlots := make(chan struct{}, max(hm.InstallParallelism, 1))
+	testSlots := make(chan struct{}, max(hm.TestParallelism, 1))
+	var wg sync.WaitGroup
+	var failuresMu sync.Mutex
 	var testFailures []string
+
+	recordFailure := func(chart string) {
+		failuresMu.Lock()
+		testFailures = append(testFailures, chart)
+		failuresMu.Unlock()
+		hm.reachCheckpoint(shared.PauseOnFailure, "after "+chart+" failed")
+	}
+
+	missingImages := hm.checkImages(ctx, charts)
+	installs := newInstallTracker(charts)
+
+	hm.aborted.Store(false)
 	for _, chart := range charts {
-		if err := hm.installChart(chart); err != nil {
-			log.Printf("Warning: failed to install chart %s: %v", chart, err)
-			testFailures = append(testFailures, chart)
+		if hm.aborted.Load() {
+			break
+		}
+		if ctx.Err() != nil {
+			hm.updateStatus(chart.Name, "Failed", fmt.Sprintf("Not installed: %v", context.Cause(ctx)))
+			recordFailure(chart.Name)
+			installs.finish(chart.Name, false)
 			continue
 		}
-		if err := hm.runTests(chart); err != nil {
-			log.Printf("Warning: failed to run tests for chart %s: %v", chart, err)
-			testFailures = append(testFailures, chart)
+		if missing := missingImages[chart.Name]; len(missing) > 0 {
+			hm.updateStatus(chart.Name, "Failed", "Images not bundled (airgap): "+strings.Join(missing, ", "))
+			recordFailure(chart.Name)
+			installs.finish(chart.Name, false)
+			continue
+		}
+		if serialInstalls {
+			testSlots <- struct{}{}
 		}
+		installSlots <- struct{}{}
+
+		wg.Add(1)
+		go func(chart chartSpec) {
+			defer wg.Done()
+
+			// Dependencies were started earlier (charts are in install order), so this only
+			// waits with parallel installs
+			if dep := installs.wait(chart.DependsOn); dep != "" {
+				<-installSlots
+				hm.updateStatus(chart.Name, "Failed", "Not installed: dependency "+dep+" failed")
+				recordFailure(chart.Name)
+				installs.finish(chart.Name, false)
+				if serialInstalls {
+					<-testSlots
+				}
+				return
+			}
+
+			err := hm.installChart(ctx, chart)
+			<-installSlots
+			installs.finish(chart.Name, err == nil)
+			if err != nil {
+				slog.Warn("Chart install failed", "chart", chart.Name, "error", err)
+				recordFailure(chart.Name)
+				if serialInstalls {
+					<-testSlots
+				}
+				return
+			}
+
+			if !hm.reachCheckpoint(shared.PauseOnInstall, "after installing "+chart.Name) {
+				hm.updateStatus(chart.Name, "Failed", "Run aborted before tests")
+				recordFailure(chart.Name)
+				if serialInstalls {
+					<-testSlots
+				}
+				return
+			}
+
+			if chart.SkipTests {
+				slog.Info("Skipping helm tests", "chart", chart.Name)
+				fmt.Fprintf(hm.logger, "⏭️ Tests skipped for %s\n", chart.Release)
+				hm.updateStatus(chart.Name, "Skipped", "Installed, tests skipped")
+				if serialInstalls {
+					<-testSlots
+				}
+				return
+			}
+
+			if !serialInstalls {
+				testSlots <- struct{}{}
+			}
+			defer func() { <-testSlots }()
+
+			if err := hm.runTests(ctx, chart); err != nil {
+				slog.Warn("Chart tests failed", "chart", chart.Name, "error", err)
+				recordFailure(chart.Name)
+			}
+		}(chart)
+	}
+	wg.Wait()
+	slices.Sort(testFailures)
+
+	if hm.aborted.Load() {
+		return fmt.Errorf("run aborted (failed charts: %v)", testFailures)
 	}
 
 	if len(testFailures) > 0 {
@@ -79,10 +247,10 @@ func (hm *HelmManager) InstallCharts() error {
 	return nil
 }
 
-// waitForDefaultServiceAccount waits for the default namespace to have a default serviceaccount
+// waitForServiceAccount waits for a namespace to have a default serviceaccount
 // This is needed because K8s namespaces take a moment to fully bootstrap
-func (hm *HelmManager) waitForDefaultServiceAccount() error {
-	log.Println("Waiting for default serviceaccount to be created...")
+func waitForServiceAccount(namespace string) error {
+	slog.Info("Waiting for default serviceaccount", "namespace", namespace)
 	timeout := time.After(60 * time.Second)
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
@@ -90,21 +258,67 @@ func (hm *HelmManager) waitForDefaultServiceAccount() error {
 	for {
 		select {
 		case <-timeout:
-			return fmt.Errorf("timeout waiting for default serviceaccount")
+			return fmt.Errorf("timeout waiting for default serviceaccount in namespace %s", namespace)
 		case <-ticker.C:
-			cmd := exec.Command("kubectl", "get", "serviceaccount", "default", "-n", "default")
+			cmd := exec.Command("kubectl", "get", "serviceaccount", "default", "-n", namespace)
 			cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
 			if err := cmd.Run(); err == nil {
-				log.Println("✅ Default serviceaccount is ready")
+				slog.Info("Default serviceaccount is ready", "namespace", namespace)
 				return nil
 			}
 		}
 	}
 }
 
-// discoverCharts finds all Helm charts in the charts directory
-func (hm *HelmManager) discoverCharts() ([]string, error) {
-	var charts []string
+// ensureNamespace creates a namespace if it doesn't exist and waits until it can run pods
+func ensureNamespace(namespace string) error {
+	cmd := exec.Command("kubectl", "create", "namespace", namespace)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+	if out, err := cmd.CombinedOutput(); err != nil && !strings.Contains(string(out), "AlreadyExists") {
+		return fmt.Errorf("failed to create namespace %s: %v (output: %s)", namespace, err, strings.TrimSpace(string(out)))
+	}
+	return waitForServiceAccount(namespace)
+}
+
+// chartSpec is a discovered chart and the metadata used to install and test it
+type chartSpec struct {
+	Path      string   // Chart directory
+	Name      string   // Directory name, or <dir>/<case> for a case; keys chart status and artifacts
+	Dir       string   // Directory name, shared by the cases of a chart
+	Release   string   // Helm release name
+	Namespace string   // Namespace the release is installed into
+	DependsOn []string // Charts (by Name, or Dir for all cases) that must be deployed before this one installs
+
+	CaseValues []ValuesFile // The case's values files, applied after the chart's
+
+	SkipTests  bool     // Don't run helm test; the chart ends as Skipped once installed
+	TestFilter []string // helm test --filter expressions
+}
+
+// releaseNamePattern is the DNS-1123 subdomain format helm requires for release names
+var releaseNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// namespacePattern is the DNS-1123 label format Kubernetes requires for namespaces
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+const (
+	// maxReleaseNameLength is helm's limit on release name length
+	maxReleaseNameLength = 53
+
+	// maxNamespaceLength is the Kubernetes limit on namespace name length
+	maxNamespaceLength = 63
+
+	// defaultNamespace is where charts without a manifest namespace are installed
+	defaultNamespace = "default"
+)
+
+// discoverCharts finds all Helm charts in the charts directory and resolves their release
+// names and namespaces. A --release-name override wins over the chart manifest's releaseName,
+// which wins over the lowercased directory name. Invalid or colliding release names are an error.
+// Charts are returned in install order: dependencies first, then the run manifest's order.
+func (hm *HelmManager) discoverCharts() ([]chartSpec, error) {
+	var charts []chartSpec
+	releases := make(map[string]string)
 
 	if _, err := os.Stat(hm.chartsDir); os.IsNotExist(err) {
 		return charts, nil
@@ -123,78 +337,401 @@ func (hm *HelmManager) discoverCharts() ([]string, error) {
 		chartPath := filepath.Join(hm.chartsDir, entry.Name())
 		chartYaml := filepath.Join(chartPath, "Chart.yaml")
 
-		if _, err := os.Stat(chartYaml); err == nil {
-			charts = append(charts, chartPath)
+		if _, err := os.Stat(chartYaml); err != nil {
+			continue
+		}
+
+		chart, err := resolveChart(chartPath)
+		if err != nil {
+			return nil, fmt.Errorf("chart %s: %w", entry.Name(), err)
+		}
+		cases, err := chartCases(chart)
+		if err != nil {
+			return nil, fmt.Errorf("chart %s: %w", entry.Name(), err)
+		}
+		for _, chart := range cases {
+			if other, ok := releases[chart.Release]; ok {
+				return nil, fmt.Errorf("charts %s and %s both use release name %q (set one with --release-name dir=name or releaseName in kube-parcel.yaml)",
+					other, chart.Name, chart.Release)
+			}
+			releases[chart.Release] = chart.Name
+		}
+
+		charts = append(charts, cases...)
+	}
+
+	manifest, err := loadRunManifest(hm.chartsDir)
+	if err != nil {
+		return nil, err
+	}
+	return orderCharts(charts, manifest.Order)
+}
+
+// resolveChart resolves and validates the release name and namespace of a chart directory.
+// An unreadable manifest falls back to the defaults; installChart reports the manifest error.
+func resolveChart(chartPath string) (chartSpec, error) {
+	chart := chartSpec{
+		Path:      chartPath,
+		Name:      filepath.Base(chartPath),
+		Dir:       filepath.Base(chartPath),
+		Release:   strings.ToLower(filepath.Base(chartPath)),
+		Namespace: defaultNamespace,
+	}
+	if manifest, err := loadChartManifest(chartPath); err == nil {
+		if manifest.ReleaseName != "" {
+			chart.Release = manifest.ReleaseName
+		}
+		if manifest.Namespace != "" {
+			chart.Namespace = manifest.Namespace
 		}
+		chart.SkipTests = manifest.SkipTests
+		chart.TestFilter = manifest.TestFilter
+		chart.DependsOn = manifest.DependsOn
+	}
+	overrides, err := loadChartOverrides(chartPath)
+	if err != nil {
+		return chartSpec{}, err
+	}
+	if overrides.ReleaseName != "" {
+		chart.Release = overrides.ReleaseName
+	}
+	// Command-line filters replace the manifest's; either side can skip tests
+	chart.SkipTests = chart.SkipTests || overrides.SkipTests
+	if len(overrides.TestFilter) > 0 {
+		chart.TestFilter = overrides.TestFilter
+	}
+
+	if err := chart.validate(); err != nil {
+		return chartSpec{}, err
+	}
+	return chart, nil
+}
+
+// validate checks the release name and namespace are valid Kubernetes names
+func (c chartSpec) validate() error {
+	if len(c.Release) > maxReleaseNameLength || !releaseNamePattern.MatchString(c.Release) {
+		return fmt.Errorf("invalid release name %q (lowercase alphanumerics, '-' and '.', at most %d characters)",
+			c.Release, maxReleaseNameLength)
 	}
+	if len(c.Namespace) > maxNamespaceLength || !namespacePattern.MatchString(c.Namespace) {
+		return fmt.Errorf("invalid namespace %q (lowercase alphanumerics and '-', at most %d characters)",
+			c.Namespace, maxNamespaceLength)
+	}
+	return nil
+}
+
+// chartCases expands a chart into one spec per case of its manifest, or returns it as-is
+// when it has no cases. A case's release defaults to the chart's release suffixed with the
+// case name, so cases of one chart never collide.
+func chartCases(chart chartSpec) ([]chartSpec, error) {
+	manifest, err := loadChartManifest(chart.Path)
+	if err != nil || len(manifest.Cases) == 0 {
+		return []chartSpec{chart}, nil // A manifest error is reported by installChart
+	}
+
+	specs := make([]chartSpec, 0, len(manifest.Cases))
+	seen := make(map[string]bool, len(manifest.Cases))
+	for _, c := range manifest.Cases {
+		if !namespacePattern.MatchString(c.Name) {
+			return nil, fmt.Errorf("invalid case name %q (lowercase alphanumerics and '-')", c.Name)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("case %q is defined more than once", c.Name)
+		}
+		seen[c.Name] = true
 
-	return charts, nil
+		spec := chart
+		spec.Name = chart.Dir + "/" + c.Name
+		spec.Release = chart.Release + "-" + c.Name
+		if c.ReleaseName != "" {
+			spec.Release = c.ReleaseName
+		}
+		if c.Namespace != "" {
+			spec.Namespace = c.Namespace
+		}
+		spec.CaseValues = c.ValuesFiles
+		if err := spec.validate(); err != nil {
+			return nil, fmt.Errorf("case %s: %w", c.Name, err)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
 }
 
 // installChart installs a single chart
-func (hm *HelmManager) installChart(chartPath string) error {
-	chartName := filepath.Base(chartPath)
-	releaseName := strings.ToLower(chartName)
+func (hm *HelmManager) installChart(ctx context.Context, chart chartSpec) error {
+	chartPath, chartName, releaseName := chart.Path, chart.Name, chart.Release
 
-	log.Printf("📦 Installing chart: %s (release: %s)", chartName, releaseName)
+	slog.Info("Installing chart", "chart", chartName, "release", releaseName, "namespace", chart.Namespace)
 	fmt.Fprintf(hm.logger, "Installing chart: %s\n", chartName)
 	hm.updateStatus(chartName, "Installing", "Helm install started")
 
-	cmd := exec.Command("helm", "install", releaseName, chartPath, "--wait", "--timeout=15m")
+	if chart.Namespace != defaultNamespace {
+		if err := ensureNamespace(chart.Namespace); err != nil {
+			slog.Warn("Namespace not ready, installing anyway", "namespace", chart.Namespace, "error", err)
+		}
+	}
+	if len(hm.PullSecrets) > 0 {
+		if err := hm.ensurePullSecret(chart.Namespace); err != nil {
+			errMsg := fmt.Sprintf("Pull secret setup failed: %v", err)
+			slog.Error("Pull secret setup failed", "chart", chartName, "namespace", chart.Namespace, "error", err)
+			fmt.Fprintf(hm.logger, "❌ %s\n", errMsg)
+			hm.updateStatus(chartName, "Failed", errMsg)
+			return fmt.Errorf("pull secret setup failed: %w", err)
+		}
+	}
+
+	if err := hm.buildDependencies(ctx, chart); err != nil {
+		errMsg := fmt.Sprintf("Dependency build failed: %v", err)
+		slog.Error("Helm dependency build failed", "chart", chartName, "error", err)
+		fmt.Fprintf(hm.logger, "❌ %s\n", errMsg)
+		hm.updateStatus(chartName, "Failed", errMsg)
+		return fmt.Errorf("helm dependency build failed: %w", err)
+	}
+
+	args := []string{"install", releaseName, chartPath}
+	if hm.Upgrade {
+		args = []string{"upgrade", "--install", releaseName, chartPath}
+	}
+	args = append(args, "--namespace", chart.Namespace, "--create-namespace", "--wait", "--timeout="+hm.InstallTimeout.String())
+	if hm.TakeOwnership {
+		args = append(args, "--take-ownership")
+	}
+
+	manifestArgs, notes, err := hm.manifestArgs(chart)
+	if err != nil {
+		errMsg := fmt.Sprintf("Invalid chart manifest: %v", err)
+		slog.Error("Chart manifest rejected", "chart", chartName, "error", err)
+		fmt.Fprintf(hm.logger, "❌ %s\n", errMsg)
+		hm.updateStatus(chartName, "Failed", errMsg)
+		return fmt.Errorf("chart manifest: %w", err)
+	}
+	for _, note := range notes {
+		fmt.Fprintln(hm.logger, note)
+	}
+	if hm.Lint {
+		if err := hm.lintChart(ctx, chart, manifestArgs); err != nil {
+			return err
+		}
+	}
+	if hm.PostRenderer != "" {
+		manifestArgs = append(manifestArgs, "--post-renderer", hm.PostRenderer)
+	}
+	args = append(args, manifestArgs...)
+
+	cmd := exec.CommandContext(ctx, "helm", args...)
 	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
 
-	cmd.Stdout = hm.logger
-	cmd.Stderr = hm.logger
+	var stderr tailBuffer
+	output := hm.chartOutputWriter(chartName)
+	cmd.Stdout = io.MultiWriter(hm.logger, output)
+	cmd.Stderr = io.MultiWriter(hm.logger, &stderr, output)
 
 	if err := cmd.Run(); err != nil {
-		errMsg := fmt.Sprintf("Install failed: %v", err)
-		log.Printf("❌ Chart %s install failed: %v", chartName, err)
+		errMsg := withOutputTail(fmt.Sprintf("Install failed: %v", err), output)
+		if ctx.Err() != nil {
+			errMsg = fmt.Sprintf("Install aborted: %v", context.Cause(ctx))
+		} else if detail := hm.captureFailedManifest(chart, manifestArgs, stderr.String()); detail != "" {
+			errMsg += " (" + detail + ")"
+		}
+		slog.Error("Helm install failed", "chart", chartName, "error", err)
 		fmt.Fprintf(hm.logger, "❌ Install failed: %s\n", errMsg)
 		hm.updateStatus(chartName, "Failed", errMsg)
 		return fmt.Errorf("helm install failed: %w", err)
 	}
+	if err := hm.waitForReadiness(ctx, chart, output); err != nil {
+		return err
+	}
 
-	log.Printf("✅ Chart %s installed successfully", chartName)
+	slog.Info("Chart installed", "chart", chartName)
 	fmt.Fprintf(hm.logger, "✅ Chart %s installed successfully\n", chartName)
 	hm.updateStatus(chartName, "Deployed", "Helm install succeeded")
 	return nil
 }
 
+// buildDependencies makes sure the chart's declared subcharts are present under charts/.
+// Vendored subcharts are used as-is; otherwise it runs helm dependency build, falling back
+// to helm dependency update (which resolves versions without a Chart.lock) when online.
+func (hm *HelmManager) buildDependencies(ctx context.Context, chart chartSpec) error {
+	missing, err := shared.MissingDependencies(chart.Path)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Cases of a chart share its directory; only one of them builds its dependencies
+	lock, _ := hm.depBuilds.LoadOrStore(chart.Path, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	if missing, err = shared.MissingDependencies(chart.Path); err != nil || len(missing) == 0 {
+		return err
+	}
+
+	fmt.Fprintf(hm.logger, "Building dependencies for %s (missing: %s)\n", chart.Name, strings.Join(missing, ", "))
+	buildErr := hm.runHelm(ctx, "dependency", "build", chart.Path)
+	if buildErr == nil {
+		return nil
+	}
+	if hm.Airgap {
+		return fmt.Errorf("%w; subcharts %s are not vendored and airgap mode blocks downloads (run 'helm dependency build' before bundling)",
+			buildErr, strings.Join(missing, ", "))
+	}
+
+	slog.Warn("helm dependency build failed, trying update", "chart", chart.Name, "error", buildErr)
+	return hm.runHelm(ctx, "dependency", "update", chart.Path)
+}
+
+// helmSupportsFlag reports whether a helm subcommand lists flag in its help output
+func helmSupportsFlag(subcommand, flag string) bool {
+	out, err := exec.Command("helm", subcommand, "--help").Output()
+	return err == nil && strings.Contains(string(out), flag)
+}
+
+// runHelm runs a helm command with its output going to the helm log
+func (hm *HelmManager) runHelm(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "helm", args...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+	cmd.Stdout = hm.logger
+	cmd.Stderr = hm.logger
+	return cmd.Run()
+}
+
+// manifestArgs turns the chart manifest into helm install arguments: its values files
+// (-f, in precedence order) and the case's, followed by matching capability overlays (--set).
+// It has no side effects so the image check can call it too; notes describes the skipped
+// files and applied overlays and overrides, for the install to log.
+func (hm *HelmManager) manifestArgs(chart chartSpec) (args, notes []string, err error) {
+	chartPath, chartName := chart.Path, chart.Name
+	manifest, err := loadChartManifest(chartPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args, skipped, err := manifest.valuesArgs(chartPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(chart.CaseValues) > 0 {
+		caseArgs, caseSkipped, err := (&ChartManifest{ValuesFiles: chart.CaseValues}).valuesArgs(chartPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("case: %w", err)
+		}
+		args, skipped = append(args, caseArgs...), append(skipped, caseSkipped...)
+	}
+	for _, path := range skipped {
+		notes = append(notes, fmt.Sprintf("Skipping optional values file %s for %s (not found)", path, chartName))
+	}
+
+	if len(manifest.Overlays) > 0 {
+		if hm.caps == nil {
+			return nil, nil, fmt.Errorf("chart has capability overlays but cluster capabilities could not be detected")
+		}
+
+		overlayArgs, applied, err := manifest.overlayArgs(*hm.caps)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, when := range applied {
+			notes = append(notes, fmt.Sprintf("Applying values overlay for %s (when %s)", chartName, when))
+		}
+		args = append(args, overlayArgs...)
+	}
+
+	// User overrides (--set/--values) come last so they win over the chart's manifest
+	userArgs, err := overrideArgs(chartPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(userArgs) > 0 {
+		notes = append(notes, fmt.Sprintf("Applying values overrides for %s", chartName))
+	}
+	return append(args, userArgs...), notes, nil
+}
+
 // runTests runs helm test for a release
-func (hm *HelmManager) runTests(chartPath string) error {
-	chartName := filepath.Base(chartPath)
-	releaseName := strings.ToLower(chartName)
+func (hm *HelmManager) runTests(ctx context.Context, chart chartSpec) error {
+	chartName, releaseName := chart.Name, chart.Release
 
-	log.Printf("🧪 Running tests for release: %s", releaseName)
+	slog.Info("Running helm tests", "release", releaseName)
 	fmt.Fprintf(hm.logger, "Running tests for: %s\n", releaseName)
 	hm.updateStatus(chartName, "Testing", "Running integration tests")
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go hm.streamTestLogs(ctx, releaseName)
-
-	cmd := exec.Command("helm", "test", releaseName, "--logs", "--timeout=15m")
+	// Scope the log streamer to this test run: it is cancelled and awaited before returning
+	// so concurrent tests never leave streamers behind for another release
+	streamCtx, cancel := context.WithCancel(ctx)
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		hm.streamTestLogs(streamCtx, releaseName, chart.Namespace)
+	}()
+	defer func() {
+		cancel()
+		<-streamDone
+	}()
+
+	args := []string{"test", releaseName, "--namespace", chart.Namespace, "--logs", "--timeout=" + hm.TestTimeout.String()}
+	for _, filter := range chart.TestFilter {
+		args = append(args, "--filter", filter)
+	}
+	cmd := exec.CommandContext(ctx, "helm", args...)
 	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
 
-	cmd.Stdout = hm.logger
-	cmd.Stderr = hm.logger
+	output := hm.chartOutputWriter(chartName)
+	cmd.Stdout = io.MultiWriter(hm.logger, output)
+	cmd.Stderr = io.MultiWriter(hm.logger, output)
 
-	if err := cmd.Run(); err != nil {
-		errMsg := fmt.Sprintf("Tests failed: %v", err)
-		log.Printf("❌ Tests failed for %s: %v", releaseName, err)
+	err := cmd.Run()
+	hm.collectTestLogs(chart)
+
+	if err != nil && ctx.Err() != nil {
+		errMsg := fmt.Sprintf("Tests aborted: %v", context.Cause(ctx))
+		slog.Error("Helm tests aborted", "release", releaseName, "error", context.Cause(ctx))
+		fmt.Fprintf(hm.logger, "❌ %s\n", errMsg)
+		hm.updateStatus(chartName, "Failed", errMsg)
+		return fmt.Errorf("helm test aborted: %w", context.Cause(ctx))
+	}
+
+	// Declared expectations, if any, decide the verdict instead of helm test's exit status
+	if manifest, merr := loadChartManifest(chart.Path); merr == nil && manifest.hasExpectations() {
+		_, pods, podErr := testPods(releaseName, chart.Namespace)
+		if podErr != nil {
+			slog.Warn("Could not list test pods", "release", releaseName, "error", podErr)
+		}
+		mismatches := manifest.evaluateExpectations(chart.Namespace, err, pods, hm.FetchAllClusterResources())
+		if len(mismatches) > 0 {
+			errMsg := "Expectation failed: " + strings.Join(mismatches, "; ")
+			slog.Error("Chart expectations not met", "release", releaseName, "mismatches", len(mismatches))
+			for _, m := range mismatches {
+				fmt.Fprintf(hm.logger, "❌ Expectation failed for %s: %s\n", releaseName, m)
+			}
+			hm.updateStatus(chartName, "Failed", errMsg)
+			return fmt.Errorf("%s", errMsg)
+		}
+
+		slog.Info("Chart expectations met", "release", releaseName)
+		fmt.Fprintf(hm.logger, "✅ Expectations met for %s\n", releaseName)
+		hm.updateStatus(chartName, "Succeeded", "All expectations met")
+		return nil
+	}
+
+	if err != nil {
+		errMsg := withOutputTail(fmt.Sprintf("Tests failed: %v", err), output)
+		slog.Error("Helm tests failed", "release", releaseName, "error", err)
 		fmt.Fprintf(hm.logger, "❌ Tests failed: %s\n", errMsg)
 		hm.updateStatus(chartName, "Failed", errMsg)
 		return fmt.Errorf("helm test failed: %w", err)
 	}
 
-	log.Printf("✅ Tests passed for %s", releaseName)
+	slog.Info("Helm tests passed", "release", releaseName)
 	fmt.Fprintf(hm.logger, "✅ Tests passed for %s\n", releaseName)
 	hm.updateStatus(chartName, "Succeeded", "All tests passed")
 	return nil
 }
 
-// streamTestLogs streams logs from the test pod(s)
-func (hm *HelmManager) streamTestLogs(ctx context.Context, releaseName string) {
+// streamTestLogs streams logs from the test pod(s) of a release in namespace
+func (hm *HelmManager) streamTestLogs(ctx context.Context, releaseName, namespace string) {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
@@ -206,7 +743,7 @@ func (hm *HelmManager) streamTestLogs(ctx context.Context, releaseName string) {
 			return
 		case <-ticker.C:
 			labelSelector := fmt.Sprintf("helm.sh/hook=test,app.kubernetes.io/instance=%s", releaseName)
-			cmd := exec.Command("kubectl", "get", "pods", "-l", labelSelector, "-o", "jsonpath={.items[0].metadata.name}")
+			cmd := exec.Command("kubectl", "get", "pods", "-n", namespace, "-l", labelSelector, "-o", "jsonpath={.items[0].metadata.name}")
 			cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
 			out, err := cmd.Output()
 			if err == nil && len(out) > 0 {
@@ -219,10 +756,10 @@ func (hm *HelmManager) streamTestLogs(ctx context.Context, releaseName string) {
 		}
 	}
 
-	log.Printf("📡 Found test pod %s, streaming logs...", podName)
+	slog.Info("Streaming test pod logs", "pod", podName)
 	fmt.Fprintf(hm.logger, "📡 Found test pod %s, streaming logs...\n", podName)
 
-	cmd := exec.CommandContext(ctx, "kubectl", "logs", "-f", podName)
+	cmd := exec.CommandContext(ctx, "kubectl", "logs", "-f", podName, "-n", namespace)
 	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
 	cmd.Stdout = hm.logger
 	cmd.Stderr = hm.logger
@@ -234,18 +771,18 @@ func (hm *HelmManager) streamTestLogs(ctx context.Context, releaseName string) {
 func (hm *HelmManager) ensureHelmBinary() error {
 	for _, helmPath := range []string{"/bin/helm", "/usr/local/bin/helm", "/usr/bin/helm"} {
 		if _, err := os.Stat(helmPath); err == nil {
-			log.Printf("✅ Found Helm at %s", helmPath)
+			slog.Info("Found helm binary", "path", helmPath)
 			os.Setenv("PATH", filepath.Dir(helmPath)+":"+os.Getenv("PATH"))
 			return nil
 		}
 	}
 
 	if _, err := exec.LookPath("helm"); err == nil {
-		log.Println("✅ Found Helm in PATH")
+		slog.Info("Found helm binary in PATH")
 		return nil
 	}
 
-	log.Println("🔧 Helm binary not found in any location, downloading...")
+	slog.Info("Helm binary not found, downloading", "url", config.HelmDownloadURL)
 	fmt.Fprintf(hm.logger, "Helm not found, downloading...\n")
 
 	tmpDir, err := os.MkdirTemp("", "helm-install")
@@ -281,7 +818,7 @@ func (hm *HelmManager) ensureHelmBinary() error {
 		}
 	}
 
-	log.Printf("✅ Installed Helm to %s", destPath)
+	slog.Info("Installed helm binary", "path", destPath)
 	return nil
 }
 
@@ -306,15 +843,115 @@ func downloadFile(url, dest string) error {
 	return err
 }
 
-func (hm *HelmManager) updateStatus(chart, phase, message string) {
+// OnFailure registers a callback invoked when a chart enters the Failed phase
+func (hm *HelmManager) OnFailure(fn func(chart string)) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.onFailure = fn
+}
+
+// OnPhase registers a callback invoked when a chart moves from one phase to another
+func (hm *HelmManager) OnPhase(fn func(chart, from, to string)) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.onPhase = fn
+}
+
+// OnCheckpoint registers a callback run when the install loop reaches a pause point;
+// returning false aborts the run, leaving charts that haven't started uninstalled
+func (hm *HelmManager) OnCheckpoint(fn func(point, where string) bool) {
 	hm.mu.Lock()
 	defer hm.mu.Unlock()
-	hm.chartStatus[chart] = shared.ChartStatus{
-		Phase:   phase,
-		Message: message,
+	hm.checkpoint = fn
+}
+
+// Aborted reports whether the last run was aborted at a checkpoint
+func (hm *HelmManager) Aborted() bool {
+	return hm.aborted.Load()
+}
+
+// reachCheckpoint runs the checkpoint callback, recording an abort. It reports false if aborted.
+func (hm *HelmManager) reachCheckpoint(point, where string) bool {
+	hm.mu.RLock()
+	checkpoint := hm.checkpoint
+	hm.mu.RUnlock()
+	if checkpoint == nil || checkpoint(point, where) {
+		return true
+	}
+	hm.aborted.Store(true)
+	return false
+}
+
+// resetStatus forgets the charts of a previous run
+func (hm *HelmManager) resetStatus() {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	clear(hm.chartStatus)
+	clear(hm.startedAt)
+	clear(hm.testedAt)
+	clear(hm.endedAt)
+	clear(hm.artifacts)
+	clear(hm.outputs)
+}
+
+func (hm *HelmManager) updateStatus(chart, phase, message string) {
+	hm.mu.Lock()
+	switch phase {
+	case "Installing":
+		hm.startedAt[chart] = time.Now()
+	case "Testing":
+		hm.testedAt[chart] = time.Now()
+	case "Succeeded", "Skipped", "Failed", "LintFailed", "ReadinessFailed":
+		hm.endedAt[chart] = time.Now()
+	}
+	onFailure, onPhase, since := hm.onFailure, hm.onPhase, hm.startedAt[chart]
+	from := hm.chartStatus[chart].Phase
+	status := shared.ChartStatus{
+		Phase:     phase,
+		Message:   message,
+		Artifacts: hm.artifacts[chart],
+	}
+	if !since.IsZero() {
+		status.Duration = time.Since(since).Seconds()
+	}
+	install, test := hm.durationsLocked(chart)
+	status.InstallDuration, status.TestDuration = install.Seconds(), test.Seconds()
+	hm.chartStatus[chart] = status
+	hm.mu.Unlock()
+
+	if onPhase != nil && from != phase {
+		onPhase(chart, from, phase)
+	}
+	if (phase == "Failed" || phase == "LintFailed" || phase == "ReadinessFailed") && onFailure != nil {
+		onFailure(chart)
 	}
 }
 
+// chartDurations returns how long a chart spent installing and testing. The test duration
+// is zero if tests never started.
+func (hm *HelmManager) chartDurations(chart string) (install, test time.Duration) {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+	return hm.durationsLocked(chart)
+}
+
+// durationsLocked is chartDurations for callers holding hm.mu
+func (hm *HelmManager) durationsLocked(chart string) (install, test time.Duration) {
+	started, tested, ended := hm.startedAt[chart], hm.testedAt[chart], hm.endedAt[chart]
+	if ended.IsZero() {
+		ended = time.Now()
+	}
+	switch {
+	case started.IsZero():
+	case tested.IsZero():
+		install = ended.Sub(started)
+	default:
+		install = tested.Sub(started)
+		test = ended.Sub(tested)
+	}
+	return install, test
+}
+
 func (hm *HelmManager) GetChartsStatus() map[string]shared.ChartStatus {
 	hm.mu.RLock()
 	defer hm.mu.RUnlock()
@@ -329,43 +966,80 @@ func (hm *HelmManager) GetChartsStatus() map[string]shared.ChartStatus {
 
 // FetchAllClusterResources returns all resources in the cluster across all namespaces
 func (hm *HelmManager) FetchAllClusterResources() []shared.KubeResource {
-	var resources []shared.KubeResource
-
 	cmd := exec.Command("kubectl", "get", "pods,svc,deploy,sts,ds,job,ing,pvc,configmap,secret", "-A", "-o", "json")
 	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
 
 	out, err := cmd.Output()
 	if err != nil {
-		log.Printf("Warning: failed to fetch cluster resources: %v", err)
+		slog.Warn("Failed to fetch cluster resources", "error", err)
 		return nil
 	}
 
+	resources, err := parseClusterResources(out)
+	if err != nil {
+		slog.Warn("Failed to unmarshal kubectl output", "error", err)
+		return nil
+	}
+	return resources
+}
+
+// unhealthyWaitingReasons are container waiting reasons that won't resolve on their own
+var unhealthyWaitingReasons = []string{
+	"CrashLoopBackOff",
+	"ImagePullBackOff",
+	"ErrImagePull",
+	"ErrImageNeverPull",
+	"InvalidImageName",
+	"CreateContainerConfigError",
+	"CreateContainerError",
+}
+
+// parseClusterResources converts `kubectl get -o json` output into resources, marking
+// unhealthy workloads with a Reason
+func parseClusterResources(out []byte) ([]shared.KubeResource, error) {
 	var data struct {
 		Items []struct {
 			Kind     string `json:"kind"`
 			Metadata struct {
-				Name        string            `json:"name"`
-				Namespace   string            `json:"namespace"`
-				Annotations map[string]string `json:"annotations"`
+				Name            string            `json:"name"`
+				Namespace       string            `json:"namespace"`
+				Annotations     map[string]string `json:"annotations"`
+				OwnerReferences []struct {
+					Kind string `json:"kind"`
+				} `json:"ownerReferences"`
 			} `json:"metadata"`
+			Spec struct {
+				Replicas *int `json:"replicas"`
+			} `json:"spec"`
 			Status struct {
 				Phase             string `json:"phase"`
 				ContainerStatuses []struct {
 					State struct {
+						Waiting *struct {
+							Reason string `json:"reason"`
+						} `json:"waiting,omitempty"`
 						Terminated *struct {
 							ExitCode int `json:"exitCode"`
 						} `json:"terminated,omitempty"`
 					} `json:"state"`
 				} `json:"containerStatuses,omitempty"`
+				ReadyReplicas          int `json:"readyReplicas"`
+				DesiredNumberScheduled int `json:"desiredNumberScheduled"`
+				NumberReady            int `json:"numberReady"`
+				Active                 int `json:"active"`
+				Conditions             []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
 			} `json:"status"`
 		} `json:"items"`
 	}
 
 	if err := json.Unmarshal(out, &data); err != nil {
-		log.Printf("Warning: failed to unmarshal kubectl output: %v", err)
-		return nil
+		return nil, err
 	}
 
+	var resources []shared.KubeResource
 	for _, item := range data.Items {
 		status := item.Status.Phase
 		if status == "" {
@@ -374,12 +1048,57 @@ func (hm *HelmManager) FetchAllClusterResources() []shared.KubeResource {
 
 		// Extract exit code from terminated containers (for Pods)
 		var exitCode *int
+		var reason string
 		if item.Kind == "Pod" && len(item.Status.ContainerStatuses) > 0 {
 			for _, cs := range item.Status.ContainerStatuses {
-				if cs.State.Terminated != nil {
+				if cs.State.Terminated != nil && exitCode == nil {
 					code := cs.State.Terminated.ExitCode
 					exitCode = &code
-					break
+				}
+				if cs.State.Waiting != nil && slices.Contains(unhealthyWaitingReasons, cs.State.Waiting.Reason) {
+					reason = cs.State.Waiting.Reason
+				}
+			}
+		}
+
+		switch item.Kind {
+		case "Pod":
+			// A failed Job pod may be retried; the owning Job reports whether the Job failed
+			ownedByJob := false
+			for _, owner := range item.Metadata.OwnerReferences {
+				ownedByJob = ownedByJob || owner.Kind == "Job"
+			}
+			if item.Status.Phase == "Failed" && reason == "" && !ownedByJob {
+				reason = "Failed"
+			}
+		case "Deployment", "StatefulSet":
+			desired := 1
+			if item.Spec.Replicas != nil {
+				desired = *item.Spec.Replicas
+			}
+			if item.Status.ReadyReplicas < desired {
+				reason = fmt.Sprintf("%d/%d replicas ready", item.Status.ReadyReplicas, desired)
+			}
+		case "DaemonSet":
+			if item.Status.NumberReady < item.Status.DesiredNumberScheduled {
+				reason = fmt.Sprintf("%d/%d pods ready", item.Status.NumberReady, item.Status.DesiredNumberScheduled)
+			}
+		case "Job":
+			// Jobs have no Ready condition: they are Pending, Running, Succeeded or Failed
+			status = "Pending"
+			if item.Status.Active > 0 {
+				status = "Running"
+			}
+			for _, cond := range item.Status.Conditions {
+				if cond.Status != "True" {
+					continue
+				}
+				switch cond.Type {
+				case "Complete":
+					status = "Succeeded"
+				case "Failed":
+					status = "Failed"
+					reason = "Failed"
 				}
 			}
 		}
@@ -389,9 +1108,26 @@ func (hm *HelmManager) FetchAllClusterResources() []shared.KubeResource {
 			Name:      item.Metadata.Name,
 			Namespace: item.Metadata.Namespace,
 			Status:    status,
+			IsTest:    strings.Contains(item.Metadata.Annotations["helm.sh/hook"], "test"),
 			ExitCode:  exitCode,
+			Reason:    reason,
 		})
 	}
 
-	return resources
+	return resources, nil
+}
+
+// workloadHealth summarizes resources as "Healthy" or "Degraded" with the number of
+// unhealthy resources. Helm test pods are excluded: their failures are test results.
+func workloadHealth(resources []shared.KubeResource) (string, int) {
+	unhealthy := 0
+	for _, r := range resources {
+		if r.Reason != "" && !r.IsTest {
+			unhealthy++
+		}
+	}
+	if unhealthy > 0 {
+		return "Degraded", unhealthy
+	}
+	return "Healthy", 0
 }
@@ -0,0 +1,307 @@
+package runner
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClusterResources_Health(t *testing.T) {
+	out := []byte(`{"items": [
+		{"kind": "Pod", "metadata": {"name": "web-1", "namespace": "default"},
+		 "status": {"phase": "Running", "containerStatuses": [{"state": {"running": {}}}]}},
+		{"kind": "Pod", "metadata": {"name": "web-2", "namespace": "default"},
+		 "status": {"phase": "Running", "containerStatuses": [{"state": {"waiting": {"reason": "CrashLoopBackOff"}}}]}},
+		{"kind": "Pod", "metadata": {"name": "web-test", "namespace": "default", "annotations": {"helm.sh/hook": "test"}},
+		 "status": {"phase": "Failed", "containerStatuses": [{"state": {"terminated": {"exitCode": 1}}}]}},
+		{"kind": "Deployment", "metadata": {"name": "web", "namespace": "default"},
+		 "spec": {"replicas": 2}, "status": {"readyReplicas": 1}},
+		{"kind": "DaemonSet", "metadata": {"name": "agent", "namespace": "kube-system"},
+		 "status": {"desiredNumberScheduled": 1, "numberReady": 1}},
+		{"kind": "Service", "metadata": {"name": "web", "namespace": "default"}, "status": {}}
+	]}`)
+
+	resources, err := parseClusterResources(out)
+	if err != nil {
+		t.Fatalf("parseClusterResources failed: %v", err)
+	}
+	if len(resources) != 6 {
+		t.Fatalf("expected 6 resources, got %d", len(resources))
+	}
+
+	wantReasons := map[string]string{
+		"web-1":    "",
+		"web-2":    "CrashLoopBackOff",
+		"web-test": "Failed",
+		"web":      "",
+		"agent":    "",
+	}
+	for _, r := range resources {
+		if r.Kind == "Deployment" {
+			if r.Reason != "1/2 replicas ready" {
+				t.Errorf("deployment reason = %q", r.Reason)
+			}
+			continue
+		}
+		if want, ok := wantReasons[r.Name]; ok && r.Reason != want {
+			t.Errorf("%s/%s reason = %q, want %q", r.Kind, r.Name, r.Reason, want)
+		}
+	}
+
+	test := resources[2]
+	if !test.IsTest || test.ExitCode == nil || *test.ExitCode != 1 {
+		t.Errorf("expected test pod with exit code 1, got %+v", test)
+	}
+
+	health, unhealthy := workloadHealth(resources)
+	if health != "Degraded" || unhealthy != 2 {
+		t.Errorf("workloadHealth() = %s, %d; want Degraded, 2 (test pods excluded)", health, unhealthy)
+	}
+
+	health, unhealthy = workloadHealth(resources[:1])
+	if health != "Healthy" || unhealthy != 0 {
+		t.Errorf("workloadHealth() = %s, %d; want Healthy, 0", health, unhealthy)
+	}
+}
+
+func TestParseClusterResources_Jobs(t *testing.T) {
+	out := []byte(`{"items": [
+		{"kind": "Job", "metadata": {"name": "migrate", "namespace": "default"},
+		 "status": {"succeeded": 1, "conditions": [{"type": "Complete", "status": "True"}]}},
+		{"kind": "Job", "metadata": {"name": "seed", "namespace": "default"}, "status": {"active": 1}},
+		{"kind": "Job", "metadata": {"name": "queued", "namespace": "default"}, "status": {}},
+		{"kind": "Job", "metadata": {"name": "broken", "namespace": "default"},
+		 "status": {"conditions": [{"type": "Failed", "status": "True"}]}},
+		{"kind": "Pod", "metadata": {"name": "migrate-retry", "namespace": "default", "ownerReferences": [{"kind": "Job"}]},
+		 "status": {"phase": "Failed"}},
+		{"kind": "ConfigMap", "metadata": {"name": "settings", "namespace": "default"}},
+		{"kind": "Secret", "metadata": {"name": "creds", "namespace": "default"}}
+	]}`)
+
+	resources, err := parseClusterResources(out)
+	if err != nil {
+		t.Fatalf("parseClusterResources failed: %v", err)
+	}
+
+	want := map[string]struct{ status, reason string }{
+		"migrate":       {"Succeeded", ""},
+		"seed":          {"Running", ""},
+		"queued":        {"Pending", ""},
+		"broken":        {"Failed", "Failed"},
+		"migrate-retry": {"Failed", ""},
+		"settings":      {"Active", ""},
+		"creds":         {"Active", ""},
+	}
+	for _, r := range resources {
+		w := want[r.Name]
+		if r.Status != w.status || r.Reason != w.reason {
+			t.Errorf("%s/%s = (%q, %q), want (%q, %q)", r.Kind, r.Name, r.Status, r.Reason, w.status, w.reason)
+		}
+	}
+
+	if health, unhealthy := workloadHealth(resources); health != "Degraded" || unhealthy != 1 {
+		t.Errorf("workloadHealth() = %s, %d; want Degraded, 1 (only the failed Job)", health, unhealthy)
+	}
+	if health, _ := workloadHealth(resources[:3]); health != "Healthy" {
+		t.Errorf("job-only chart without failures should be Healthy, got %s", health)
+	}
+}
+
+func TestHelmManager_DiscoverChartsReleaseNames(t *testing.T) {
+	dir := t.TempDir()
+	addChart := func(name, manifest string) string {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(path, 0755)
+		os.WriteFile(filepath.Join(path, "Chart.yaml"), []byte("name: "+name+"\n"), 0644)
+		if manifest != "" {
+			os.WriteFile(filepath.Join(path, "kube-parcel.yaml"), []byte(manifest), 0644)
+		}
+		return path
+	}
+	addChart("WebApp", "")
+	addChart("api", "releaseName: backend\nnamespace: services\n")
+	overridden := addChart("db", "releaseName: database\n")
+	os.MkdirAll(filepath.Join(overridden, ".kube-parcel"), 0755)
+	os.WriteFile(filepath.Join(overridden, ".kube-parcel", "overrides.yaml"), []byte("releaseName: postgres\n"), 0644)
+
+	hm := &HelmManager{chartsDir: dir}
+	charts, err := hm.discoverCharts()
+	if err != nil {
+		t.Fatalf("discoverCharts failed: %v", err)
+	}
+
+	releases := make(map[string]string)
+	for _, chart := range charts {
+		releases[chart.Name] = chart.Release
+	}
+	want := map[string]string{"WebApp": "webapp", "api": "backend", "db": "postgres"}
+	for name, release := range want {
+		if releases[name] != release {
+			t.Errorf("chart %s: expected release %q, got %q", name, release, releases[name])
+		}
+	}
+	for _, chart := range charts {
+		wantNamespace := "default"
+		if chart.Name == "api" {
+			wantNamespace = "services"
+		}
+		if chart.Namespace != wantNamespace {
+			t.Errorf("chart %s: expected namespace %q, got %q", chart.Name, wantNamespace, chart.Namespace)
+		}
+	}
+
+	addChart("webapp", "")
+	if _, err := hm.discoverCharts(); err == nil || !strings.Contains(err.Error(), `"webapp"`) {
+		t.Errorf("expected a release name collision error, got %v", err)
+	}
+
+	os.RemoveAll(filepath.Join(dir, "webapp"))
+	addChart("bad", "releaseName: Not_Valid\n")
+	if _, err := hm.discoverCharts(); err == nil || !strings.Contains(err.Error(), "invalid release name") {
+		t.Errorf("expected an invalid release name error, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "bad", "kube-parcel.yaml"), []byte("namespace: my.ns\n"), 0644)
+	if _, err := hm.discoverCharts(); err == nil || !strings.Contains(err.Error(), "invalid namespace") {
+		t.Errorf("expected an invalid namespace error, got %v", err)
+	}
+}
+
+func TestResolveChart_TestSelection(t *testing.T) {
+	dir := t.TempDir()
+	chartPath := filepath.Join(dir, "web")
+	os.MkdirAll(filepath.Join(chartPath, ".kube-parcel"), 0755)
+	os.WriteFile(filepath.Join(chartPath, "kube-parcel.yaml"), []byte("testFilter:\n  - name=smoke\n"), 0644)
+
+	chart, err := resolveChart(chartPath)
+	if err != nil {
+		t.Fatalf("resolveChart failed: %v", err)
+	}
+	if chart.SkipTests || !slices.Equal(chart.TestFilter, []string{"name=smoke"}) {
+		t.Errorf("expected the manifest's filter, got skip=%v filter=%v", chart.SkipTests, chart.TestFilter)
+	}
+
+	// Command-line filters replace the manifest's, and either side can skip
+	os.WriteFile(filepath.Join(chartPath, ".kube-parcel", "overrides.yaml"), []byte("skipTests: true\ntestFilter:\n  - \"!name=soak\"\n"), 0644)
+	chart, err = resolveChart(chartPath)
+	if err != nil {
+		t.Fatalf("resolveChart failed: %v", err)
+	}
+	if !chart.SkipTests || !slices.Equal(chart.TestFilter, []string{"!name=soak"}) {
+		t.Errorf("expected overrides to win, got skip=%v filter=%v", chart.SkipTests, chart.TestFilter)
+	}
+}
+
+func TestHelmManager_UpdateStatusDurations(t *testing.T) {
+	hm := NewHelmManager(io.Discard)
+	start := time.Now().Add(-time.Minute)
+
+	hm.updateStatus("web", "Installing", "")
+	hm.mu.Lock()
+	hm.startedAt["web"] = start
+	hm.mu.Unlock()
+	hm.updateStatus("web", "Testing", "")
+	hm.mu.Lock()
+	hm.testedAt["web"] = start.Add(40 * time.Second)
+	hm.mu.Unlock()
+	hm.updateStatus("web", "Succeeded", "")
+
+	status := hm.GetChartsStatus()["web"]
+	if status.InstallDuration != 40 {
+		t.Errorf("InstallDuration = %v, want 40", status.InstallDuration)
+	}
+	if status.TestDuration < 19 || status.TestDuration > 21 {
+		t.Errorf("TestDuration = %v, want about 20", status.TestDuration)
+	}
+
+	hm.updateStatus("db", "Pending", "")
+	if status := hm.GetChartsStatus()["db"]; status.InstallDuration != 0 || status.TestDuration != 0 {
+		t.Errorf("expected no durations before install, got %+v", status)
+	}
+}
+
+func TestHelmManager_ManifestArgsNotes(t *testing.T) {
+	chartPath := t.TempDir()
+	os.WriteFile(filepath.Join(chartPath, "Chart.yaml"), []byte("name: app\n"), 0644)
+	os.WriteFile(filepath.Join(chartPath, "kube-parcel.yaml"), []byte(`valuesFiles:
+  - path: values-local.yaml
+    optional: true
+`), 0644)
+
+	// The image check builds the arguments too; only the install logs what they apply
+	var logged strings.Builder
+	hm := &HelmManager{logger: &logged}
+	args, notes, err := hm.manifestArgs(chartSpec{Name: "app", Path: chartPath})
+	if err != nil {
+		t.Fatalf("manifestArgs failed: %v", err)
+	}
+	if len(args) != 0 {
+		t.Errorf("args = %v, want none", args)
+	}
+	if want := []string{"Skipping optional values file values-local.yaml for app (not found)"}; !slices.Equal(notes, want) {
+		t.Errorf("notes = %q, want %q", notes, want)
+	}
+	if logged.Len() != 0 {
+		t.Errorf("manifestArgs logged %q, want nothing", logged.String())
+	}
+}
+
+func TestHelmManager_DiscoverChartsCases(t *testing.T) {
+	dir := t.TempDir()
+	chartPath := filepath.Join(dir, "app")
+	os.MkdirAll(chartPath, 0755)
+	os.WriteFile(filepath.Join(chartPath, "Chart.yaml"), []byte("name: app\n"), 0644)
+	os.WriteFile(filepath.Join(chartPath, "values-ci.yaml"), []byte("replicas: 1\n"), 0644)
+	os.WriteFile(filepath.Join(chartPath, "values-postgres.yaml"), []byte("db: postgres\n"), 0644)
+	os.WriteFile(filepath.Join(chartPath, "kube-parcel.yaml"), []byte(`namespace: apps
+valuesFiles: [values-ci.yaml]
+cases:
+  - name: postgres
+    valuesFiles: [values-postgres.yaml]
+  - name: mysql
+    releaseName: app-mysql-8
+    namespace: mysql
+`), 0644)
+
+	hm := &HelmManager{chartsDir: dir}
+	charts, err := hm.discoverCharts()
+	if err != nil {
+		t.Fatalf("discoverCharts failed: %v", err)
+	}
+	if len(charts) != 2 {
+		t.Fatalf("expected one chart per case, got %+v", charts)
+	}
+	want := []chartSpec{
+		{Name: "app/postgres", Dir: "app", Release: "app-postgres", Namespace: "apps"},
+		{Name: "app/mysql", Dir: "app", Release: "app-mysql-8", Namespace: "mysql"},
+	}
+	for i, chart := range charts {
+		if chart.Name != want[i].Name || chart.Dir != want[i].Dir || chart.Release != want[i].Release || chart.Namespace != want[i].Namespace {
+			t.Errorf("case %d = %+v, want %+v", i, chart, want[i])
+		}
+	}
+
+	args, _, err := hm.manifestArgs(charts[0])
+	if err != nil {
+		t.Fatalf("manifestArgs failed: %v", err)
+	}
+	wantArgs := []string{"-f", filepath.Join(chartPath, "values-ci.yaml"), "-f", filepath.Join(chartPath, "values-postgres.yaml")}
+	if !slices.Equal(args, wantArgs) {
+		t.Errorf("manifestArgs = %v, want %v", args, wantArgs)
+	}
+
+	for manifest, wantErr := range map[string]string{
+		"cases:\n  - name: Bad_Name\n":       "invalid case name",
+		"cases:\n  - name: a\n  - name: a\n": `case "a" is defined more than once`,
+		"cases:\n  - name: a\n    releaseName: same\n  - name: b\n    releaseName: same\n": `both use release name "same"`,
+	} {
+		os.WriteFile(filepath.Join(chartPath, "kube-parcel.yaml"), []byte(manifest), 0644)
+		if _, err := hm.discoverCharts(); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("manifest %q: expected error containing %q, got %v", manifest, wantErr, err)
+		}
+	}
+}
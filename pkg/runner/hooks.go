@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// RunPreStartHook executes the script named by KUBE_PARCEL_PRE_START_HOOK once, before the
+// runner starts serving. Its output is broadcast with the "hook" source so clients see it
+// when they connect. A non-zero exit fails startup; an unset variable is a no-op.
+func (s *Server) RunPreStartHook(ctx context.Context) error {
+	hookPath := os.Getenv("KUBE_PARCEL_PRE_START_HOOK")
+	if hookPath == "" {
+		return nil
+	}
+
+	if err := checkExecutable(hookPath); err != nil {
+		return fmt.Errorf("pre-start hook: %w", err)
+	}
+
+	timeout := envDuration("KUBE_PARCEL_PRE_START_HOOK_TIMEOUT", 5*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	slog.Info("Running pre-start hook", "path", hookPath, "timeout", timeout)
+	s.broadcastLog("runner", "info", fmt.Sprintf("Running pre-start hook: %s", hookPath))
+
+	hookWriter := &SourceLogWriter{buffer: s.logBuffer, source: "hook", broadcast: s.broadcastLog}
+	cmd := exec.CommandContext(ctx, hookPath)
+	cmd.Stdout = io.MultiWriter(os.Stdout, hookWriter)
+	cmd.Stderr = io.MultiWriter(os.Stderr, hookWriter)
+
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		s.broadcastLog("runner", "error", fmt.Sprintf("Pre-start hook failed: %v", err))
+		return fmt.Errorf("pre-start hook %s failed: %w", hookPath, err)
+	}
+
+	slog.Info("Pre-start hook completed", "path", hookPath, "duration", time.Since(start))
+	s.broadcastLog("runner", "info", "Pre-start hook completed")
+	return nil
+}
+
+// checkExecutable verifies that path exists and is an executable regular file
+func checkExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s not found: %w", path, err)
+	}
+	if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%s is not an executable file", path)
+	}
+	return nil
+}
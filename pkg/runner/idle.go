@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// idleTracker records when the runner last saw activity after a run reached a terminal state
+type idleTracker struct {
+	mu           sync.Mutex
+	finished     bool
+	lastActivity time.Time
+}
+
+// touch records activity (an upload or a log client connect/disconnect)
+func (it *idleTracker) touch() {
+	it.mu.Lock()
+	it.lastActivity = time.Now()
+	it.mu.Unlock()
+}
+
+// setFinished marks whether the current run has reached a terminal state
+func (it *idleTracker) setFinished(finished bool) {
+	it.mu.Lock()
+	it.finished = finished
+	it.lastActivity = time.Now()
+	it.mu.Unlock()
+}
+
+// isFinished reports whether the current run has reached a terminal state
+func (it *idleTracker) isFinished() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.finished
+}
+
+// idleFor returns how long the runner has been idle in a terminal state, or 0 if it isn't
+func (it *idleTracker) idleFor(now time.Time) time.Duration {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if !it.finished {
+		return 0
+	}
+	return now.Sub(it.lastActivity)
+}
+
+// IdleShutdown returns a channel that is closed once the runner has been in a terminal
+// state with no uploads and no connected log clients for KUBE_PARCEL_IDLE_TIMEOUT.
+// It returns nil (never ready) when the idle timeout is disabled.
+func (s *Server) IdleShutdown() <-chan struct{} {
+	if s.idleTimeout <= 0 {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(min(s.idleTimeout/4, time.Minute))
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.wsMutex.Lock()
+			clients := len(s.wsClients) + s.followers
+			s.wsMutex.Unlock()
+			if clients > 0 {
+				continue
+			}
+
+			if idle := s.idle.idleFor(time.Now()); idle >= s.idleTimeout {
+				slog.Warn("Runner idle timeout reached, shutting down", "idle", idle.Round(time.Second), "timeout", s.idleTimeout)
+				s.broadcastLog("runner", "warning", "Idle timeout reached, runner is shutting down")
+				close(done)
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// TTLShutdown returns a channel that is closed once the runner has been up for
+// KUBE_PARCEL_TTL, whatever state the run is in, so a runner whose client went away
+// without cleaning up does not live forever. It returns nil (never ready) when no TTL is set.
+func (s *Server) TTLShutdown() <-chan struct{} {
+	if s.ttl <= 0 {
+		return nil
+	}
+
+	done := make(chan struct{})
+	time.AfterFunc(time.Until(s.startTime.Add(s.ttl)), func() {
+		slog.Warn("Runner TTL reached, shutting down", "ttl", s.ttl)
+		s.broadcastLog("runner", "warning", "TTL reached, runner is shutting down")
+		close(done)
+	})
+	return done
+}
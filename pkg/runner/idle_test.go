@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleTracker(t *testing.T) {
+	var it idleTracker
+
+	it.touch()
+	if idle := it.idleFor(time.Now().Add(time.Hour)); idle != 0 {
+		t.Errorf("expected no idle time before a run finished, got %v", idle)
+	}
+
+	it.setFinished(true)
+	if idle := it.idleFor(time.Now().Add(time.Hour)); idle < 59*time.Minute {
+		t.Errorf("expected ~1h idle after finishing, got %v", idle)
+	}
+
+	it.touch()
+	if idle := it.idleFor(time.Now()); idle > time.Second {
+		t.Errorf("expected activity to reset idle time, got %v", idle)
+	}
+
+	it.setFinished(false)
+	if idle := it.idleFor(time.Now().Add(time.Hour)); idle != 0 {
+		t.Errorf("expected a new upload to stop the idle clock, got %v", idle)
+	}
+}
+
+func TestServer_IdleShutdownDisabled(t *testing.T) {
+	s := newTestServer()
+	if s.IdleShutdown() != nil {
+		t.Error("expected nil channel when the idle timeout is disabled")
+	}
+}
+
+func TestServer_TTLShutdown(t *testing.T) {
+	s := newTestServer()
+	if s.TTLShutdown() != nil {
+		t.Error("expected nil channel when no TTL is set")
+	}
+
+	s.startTime = time.Now()
+	s.ttl = 20 * time.Millisecond
+	select {
+	case <-s.TTLShutdown():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the TTL channel to close after the TTL elapsed")
+	}
+}
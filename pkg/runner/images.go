@@ -0,0 +1,223 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// imageSet is the set of images present in containerd, keyed by normalized reference
+type imageSet struct {
+	refs    map[string]string // Normalized ref -> manifest digest
+	digests map[string]bool
+}
+
+// has reports whether an image reference (by tag or digest) resolves to a present image
+func (s *imageSet) has(ref string) bool {
+	ref = normalizeImageRef(ref)
+	if _, ok := s.refs[ref]; ok {
+		return true
+	}
+	_, digest, ok := strings.Cut(ref, "@")
+	return ok && s.digests[digest]
+}
+
+// listContainerdImages returns the images in the Kubernetes containerd namespace
+func listContainerdImages() (*imageSet, error) {
+	out, err := exec.Command("ctr", "-a", config.ContainerdSocket,
+		"-n", config.ContainerdNamespace, "images", "list").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	return parseImageList(string(out)), nil
+}
+
+// parseImageList parses `ctr images list` output (REF TYPE DIGEST SIZE PLATFORMS LABELS)
+func parseImageList(output string) *imageSet {
+	set := &imageSet{refs: make(map[string]string), digests: make(map[string]bool)}
+	for i, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 3 || strings.HasPrefix(fields[0], "sha256:") {
+			continue
+		}
+		set.refs[normalizeImageRef(fields[0])] = fields[2]
+		set.digests[fields[2]] = true
+	}
+	return set
+}
+
+// normalizeImageRef expands a reference the way Kubernetes does, so "nginx" and
+// "docker.io/library/nginx:latest" compare equal
+func normalizeImageRef(ref string) string {
+	name, digest, hasDigest := strings.Cut(ref, "@")
+	if !hasDigest && strings.LastIndex(name, ":") <= strings.LastIndex(name, "/") {
+		name += ":latest"
+	}
+
+	domain, rest, hasDomain := strings.Cut(name, "/")
+	if !hasDomain || (!strings.ContainsAny(domain, ".:") && domain != "localhost") {
+		name, rest = "docker.io/"+name, name
+		domain = "docker.io"
+	}
+	if domain == "docker.io" && !strings.Contains(rest, "/") {
+		name = "docker.io/library/" + rest
+	}
+
+	if hasDigest {
+		return name + "@" + digest
+	}
+	return name
+}
+
+// manifestImages returns the container images referenced by rendered manifests
+func manifestImages(manifest string) ([]string, error) {
+	var images []string
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc any
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		collectContainerImages(doc, &images)
+	}
+	slices.Sort(images)
+	return slices.Compact(images), nil
+}
+
+// collectContainerImages walks a manifest for the images of pod containers
+func collectContainerImages(v any, images *[]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		for key, child := range val {
+			switch key {
+			case "containers", "initContainers", "ephemeralContainers":
+				list, _ := child.([]any)
+				for _, c := range list {
+					if container, ok := c.(map[string]any); ok {
+						if image, ok := container["image"].(string); ok && image != "" {
+							*images = append(*images, image)
+						}
+					}
+				}
+			default:
+				collectContainerImages(child, images)
+			}
+		}
+	case []any:
+		for _, child := range val {
+			collectContainerImages(child, images)
+		}
+	}
+}
+
+// imageReport is the outcome of matching the charts' images against containerd
+type imageReport struct {
+	Missing map[string][]string // Chart -> referenced images that aren't present
+	Unused  []string            // Bundled images no chart references
+}
+
+// reconcileImages matches the images each chart references against the available images
+// and reports the ones that are missing, and the bundled ones that nothing uses
+func reconcileImages(referenced map[string][]string, available *imageSet, bundled []string) imageReport {
+	report := imageReport{Missing: make(map[string][]string)}
+	used := make(map[string]bool)
+	for chart, images := range referenced {
+		for _, image := range images {
+			ref := normalizeImageRef(image)
+			used[ref] = true
+			if _, digest, ok := strings.Cut(ref, "@"); ok {
+				used[digest] = true
+			}
+			if !available.has(image) {
+				report.Missing[chart] = append(report.Missing[chart], image)
+			}
+		}
+	}
+
+	for _, image := range bundled {
+		ref := normalizeImageRef(image)
+		if !used[ref] && !used[available.refs[ref]] && !slices.Contains(report.Unused, ref) {
+			report.Unused = append(report.Unused, ref)
+		}
+	}
+	sort.Strings(report.Unused)
+	return report
+}
+
+// newImages returns the images in after that weren't in before
+func newImages(before, after *imageSet) []string {
+	var added []string
+	for ref := range after.refs {
+		if _, ok := before.refs[ref]; !ok {
+			added = append(added, ref)
+		}
+	}
+	sort.Strings(added)
+	return added
+}
+
+// checkImages renders each chart and reconciles its container images with the images in
+// containerd. It logs images a chart needs that weren't bundled and bundled images nothing
+// uses, and in airgap mode returns the missing images by chart so those charts fail early.
+func (hm *HelmManager) checkImages(ctx context.Context, charts []chartSpec) map[string][]string {
+	available, err := hm.listImages()
+	if err != nil {
+		slog.Warn("Skipping image reconciliation", "error", err)
+		return nil
+	}
+
+	referenced := make(map[string][]string)
+	for _, chart := range charts {
+		valueArgs, _, err := hm.manifestArgs(chart)
+		if err != nil {
+			continue // Reported by the install
+		}
+		if hm.buildDependencies(ctx, chart) != nil {
+			continue
+		}
+		manifest, err := hm.renderChart(chart, valueArgs)
+		if err != nil {
+			fmt.Fprintf(hm.logger, "⚠️ Could not render %s to check its images: %v\n", chart.Name, err)
+			continue
+		}
+		images, err := manifestImages(string(manifest))
+		if err != nil {
+			fmt.Fprintf(hm.logger, "⚠️ Could not parse rendered %s to check its images: %v\n", chart.Name, err)
+			continue
+		}
+		referenced[chart.Name] = images
+	}
+
+	report := reconcileImages(referenced, available, hm.BundledImages)
+	for _, chart := range charts {
+		missing := report.Missing[chart.Name]
+		if len(missing) == 0 {
+			continue
+		}
+		if hm.Airgap {
+			fmt.Fprintf(hm.logger, "❌ %s references images that were not bundled (airgap): %s\n", chart.Name, strings.Join(missing, ", "))
+		} else {
+			fmt.Fprintf(hm.logger, "⚠️ %s references images that were not bundled and will be pulled: %s\n", chart.Name, strings.Join(missing, ", "))
+		}
+	}
+	if len(report.Unused) > 0 {
+		fmt.Fprintf(hm.logger, "⚠️ Bundled images not referenced by any chart: %s\n", strings.Join(report.Unused, ", "))
+	}
+
+	if !hm.Airgap {
+		return nil
+	}
+	return report.Missing
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeImageRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"nginx", "docker.io/library/nginx:latest"},
+		{"nginx:1.25", "docker.io/library/nginx:1.25"},
+		{"docker.io/nginx:1.25", "docker.io/library/nginx:1.25"},
+		{"bitnami/redis:7", "docker.io/bitnami/redis:7"},
+		{"ghcr.io/org/app", "ghcr.io/org/app:latest"},
+		{"localhost:5000/app", "localhost:5000/app:latest"},
+		{"registry:5000/app:v1", "registry:5000/app:v1"},
+		{"nginx@sha256:abc", "docker.io/library/nginx@sha256:abc"},
+	}
+	for _, tt := range tests {
+		if got := normalizeImageRef(tt.ref); got != tt.want {
+			t.Errorf("normalizeImageRef(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestManifestImages(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox:1.36
+      containers:
+        - name: web
+          image: nginx:1.25
+        - name: sidecar
+          image: busybox:1.36
+---
+apiVersion: v1
+kind: ConfigMap
+data:
+  image: not-a-container:1
+---
+apiVersion: batch/v1
+kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: job
+              image: ghcr.io/org/job:v2
+`
+	images, err := manifestImages(manifest)
+	if err != nil {
+		t.Fatalf("manifestImages failed: %v", err)
+	}
+	want := []string{"busybox:1.36", "ghcr.io/org/job:v2", "nginx:1.25"}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("got %v, want %v", images, want)
+	}
+}
+
+func TestReconcileImages(t *testing.T) {
+	available := parseImageList(`REF                                   TYPE                                       DIGEST          SIZE    PLATFORMS   LABELS
+docker.io/library/nginx:1.25          application/vnd.oci.image.index.v1+json    sha256:aaa      60 MiB  linux/amd64 -
+docker.io/rancher/mirrored-pause:3.6  application/vnd.oci.image.index.v1+json    sha256:bbb      300 KiB linux/amd64 io.cri-containerd.pinned=pinned
+ghcr.io/org/unused:v1                 application/vnd.oci.image.manifest.v1+json sha256:ccc      10 MiB  linux/amd64 -
+ghcr.io/org/pinned:v1                 application/vnd.oci.image.manifest.v1+json sha256:ddd      10 MiB  linux/amd64 -
+sha256:aaa                            application/vnd.oci.image.index.v1+json    sha256:aaa      60 MiB  linux/amd64 -
+`)
+	referenced := map[string][]string{
+		"web": {"nginx:1.25", "ghcr.io/org/pinned@sha256:ddd"},
+		"api": {"ghcr.io/org/api:v3"},
+	}
+	bundled := []string{"docker.io/library/nginx:1.25", "nginx:1.25", "ghcr.io/org/unused:v1", "ghcr.io/org/pinned:v1"}
+
+	report := reconcileImages(referenced, available, bundled)
+	if want := map[string][]string{"api": {"ghcr.io/org/api:v3"}}; !reflect.DeepEqual(report.Missing, want) {
+		t.Errorf("missing = %v, want %v", report.Missing, want)
+	}
+	if want := []string{"ghcr.io/org/unused:v1"}; !reflect.DeepEqual(report.Unused, want) {
+		t.Errorf("unused = %v, want %v", report.Unused, want)
+	}
+}
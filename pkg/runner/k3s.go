@@ -3,48 +3,110 @@ package runner
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"maps"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/tiborv/kube-parcel/pkg/config"
+	"github.com/tiborv/kube-parcel/pkg/shared"
+	"gopkg.in/yaml.v3"
 )
 
 // K3sManager manages the K3s lifecycle
 type K3sManager struct {
 	cmd            *exec.Cmd
+	started        bool // Start ran once; K3s and the airgap rules can't be set up twice
+	startFailed    bool // Start returned an error, so this runner can't run K3s
 	ready          bool
 	kubeconfigPath string
-	Airgap         bool // If true (default), K3s won't pull external images
+	airgapEnforced bool
+	version        string // K3s release actually running
+	dialProbe      func(network, address string, timeout time.Duration) (net.Conn, error)
+	Airgap         bool   // If true (default), K3s won't pull external images
+	Version        string // K3s release to run (e.g. v1.30.2+k3s1), downloaded if the image ships another; "" = bundled
+
+	FlannelBackend       string // K3s --flannel-backend (empty = K3s default vxlan, "none" for a custom CNI)
+	DisableNetworkPolicy bool   // Disable K3s's embedded network policy controller
+	CNIManifest          string // Path or URL of a CNI manifest applied once the API is up
+
+	// Disable lists packaged K3s components to skip. Nil means config.DefaultK3sDisable,
+	// plus metrics-server in airgap mode; an empty, non-nil list disables nothing.
+	Disable []string
+
+	// ExtraArgs are passed to "k3s server" after the defaults, so single-value flags
+	// override them. --disable flags among them are merged into the disable list.
+	ExtraArgs []string
+
+	Agents int    // Number of K3s agent nodes expected to join the server (0 = single node)
+	Token  string // Shared secret agents use to join; required when Agents > 0
+
+	Registries shared.RegistryConfig // Registry mirrors and credentials written to registries.yaml
 }
 
+// kubeletArgs are the kubelet flags needed to run K3s nested in a container
+var kubeletArgs = []string{
+	"--kubelet-arg=--cgroup-driver=cgroupfs",
+	"--kubelet-arg=--eviction-hard=",
+	"--kubelet-arg=--eviction-soft=",
+	"--kubelet-arg=--fail-swap-on=false",
+	"--kubelet-arg=--cgroups-per-qos=false",
+	"--kubelet-arg=--enforce-node-allocatable=",
+}
+
+// flannelBackends are the --flannel-backend values K3s accepts
+var flannelBackends = []string{"vxlan", "host-gw", "wireguard-native", "none"}
+
 // NewK3sManager creates a new K3s manager
 func NewK3sManager() *K3sManager {
 	return &K3sManager{
 		kubeconfigPath: config.DefaultKubeconfigPath,
 		Airgap:         true, // Default to airgap mode
+		dialProbe:      net.DialTimeout,
 	}
 }
 
-// Start starts the K3s server process
+// Start starts the K3s server process. It only runs once per runner, whether or not it
+// succeeds: a failed start may leave the process, its data directory or the airgap rules behind.
 func (km *K3sManager) Start(ctx context.Context, logWriter io.Writer) error {
-	log.Println("� Starting K3s server...")
+	if km.started {
+		return fmt.Errorf("k3s was already started on this runner; start a new runner to retry")
+	}
+	km.started = true
+	if err := km.start(ctx, logWriter); err != nil {
+		km.startFailed = true
+		return err
+	}
+	return nil
+}
+
+func (km *K3sManager) start(ctx context.Context, logWriter io.Writer) error {
+	slog.Info("Starting K3s server")
 
 	// Prepare cgroups for K3s in Cgroupv2 environment
 	if err := km.setupCgroups(); err != nil {
-		log.Printf("Warning: cgroup setup failed (might be non-cgroupv2): %v", err)
+		slog.Warn("Cgroup setup failed (might be non-cgroupv2)", "error", err)
+	}
+
+	// Before airgap isolation, which would block the download
+	if err := km.ensureK3sVersion(); err != nil {
+		return err
 	}
 
 	// Skip airgap for nested K3s
 	if km.Airgap && os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
 		if err := km.setupAirgapNetwork(); err != nil {
-			log.Printf("Warning: airgap network setup failed: %v", err)
+			return fmt.Errorf("airgap could not be enforced: %w", err)
 		}
 	}
 
@@ -55,29 +117,65 @@ func (km *K3sManager) Start(ctx context.Context, logWriter io.Writer) error {
 		serviceCIDR = "10.53.0.0/16"
 	}
 
+	disabled, err := km.disabledComponents()
+	if err != nil {
+		return err
+	}
+
+	if err := km.writeRegistriesConfig(km.Agents > 0); err != nil {
+		return err
+	}
+
 	args := []string{
 		"server",
-		"--disable=traefik",
-		"--disable=servicelb",
 		"--disable-cloud-controller",
 		"--write-kubeconfig-mode=644",
 		"--write-kubeconfig=" + km.kubeconfigPath,
-		"--kubelet-arg=--cgroup-driver=cgroupfs",
-		"--kubelet-arg=--eviction-hard=",
-		"--kubelet-arg=--eviction-soft=",
-		"--kubelet-arg=--fail-swap-on=false",
-		"--kubelet-arg=--cgroups-per-qos=false",
-		"--kubelet-arg=--enforce-node-allocatable=",
 		"--cluster-cidr=" + clusterCIDR,
 		"--service-cidr=" + serviceCIDR,
 	}
+	args = append(args, kubeletArgs...)
 
 	if km.Airgap {
-		log.Println("🔒 Airgap mode enabled - blocking external network access")
-		args = append(args, "--disable=metrics-server")
+		slog.Info("Airgap mode enabled, blocking external network access")
+	}
+
+	slog.Info("Disabling packaged K3s components", "components", disabled)
+	for _, component := range disabled {
+		args = append(args, "--disable="+component)
+	}
+
+	if km.FlannelBackend != "" {
+		if !slices.Contains(flannelBackends, km.FlannelBackend) {
+			return fmt.Errorf("unsupported flannel backend %q (supported: %s)", km.FlannelBackend, strings.Join(flannelBackends, ", "))
+		}
+		if km.FlannelBackend == "none" && km.CNIManifest == "" {
+			slog.Warn("Flannel disabled without a CNI manifest; nodes will not become Ready until a CNI is installed")
+		}
+		args = append(args, "--flannel-backend="+km.FlannelBackend)
+	}
+	if km.DisableNetworkPolicy {
+		args = append(args, "--disable-network-policy")
+	}
+
+	if km.Agents > 0 {
+		if km.Token == "" {
+			return fmt.Errorf("a join token is required for %d agent node(s)", km.Agents)
+		}
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("failed to get hostname: %w", err)
+		}
+		slog.Info("Multi-node mode enabled", "agents", km.Agents)
+		args = append(args, "--token="+km.Token, "--tls-san="+hostname, "--embedded-registry")
+	}
+
+	if extra, _ := splitDisableArgs(km.ExtraArgs); len(extra) > 0 {
+		slog.Info("Passing extra K3s server args", "args", extra)
+		args = append(args, extra...)
 	}
 
-	km.cmd = exec.CommandContext(ctx, "/bin/k3s", args...)
+	km.cmd = exec.CommandContext(ctx, config.K3sBinary, args...)
 	km.cmd.Env = append(os.Environ(), "KUBECONFIG="+km.kubeconfigPath)
 
 	km.cmd.Stdout = logWriter
@@ -87,26 +185,26 @@ func (km *K3sManager) Start(ctx context.Context, logWriter io.Writer) error {
 		return fmt.Errorf("failed to start k3s: %w", err)
 	}
 
-	log.Printf("K3s started with PID %d", km.cmd.Process.Pid)
+	slog.Info("K3s started", "pid", km.cmd.Process.Pid)
 
 	if err := km.waitForKubeconfig(); err != nil {
 		return err
 	}
 
 	os.Setenv("KUBECONFIG", km.kubeconfigPath)
-	log.Printf("KUBECONFIG set to %s", km.kubeconfigPath)
+	slog.Debug("KUBECONFIG set", "path", km.kubeconfigPath)
 
 	if err := km.waitForReady(); err != nil {
 		return err
 	}
 
 	km.ready = true
-	log.Println("✅ K3s is ready!")
+	slog.Info("K3s is ready")
 	return nil
 }
 
 func (km *K3sManager) waitForKubeconfig() error {
-	log.Println("Waiting for kubeconfig generation...")
+	slog.Info("Waiting for kubeconfig generation")
 
 	timeout := time.After(60 * time.Second)
 	ticker := time.NewTicker(500 * time.Millisecond)
@@ -118,7 +216,7 @@ func (km *K3sManager) waitForKubeconfig() error {
 			return fmt.Errorf("timeout waiting for kubeconfig at %s", km.kubeconfigPath)
 		case <-ticker.C:
 			if _, err := os.Stat(km.kubeconfigPath); err == nil {
-				log.Println("Kubeconfig generated")
+				slog.Info("Kubeconfig generated")
 				return nil
 			}
 		}
@@ -126,7 +224,7 @@ func (km *K3sManager) waitForKubeconfig() error {
 }
 
 func (km *K3sManager) waitForReady() error {
-	log.Println("Checking K3s API readiness...")
+	slog.Info("Checking K3s API readiness")
 
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
@@ -155,20 +253,248 @@ func (km *K3sManager) waitForReady() error {
 				if err == nil {
 					resp.Body.Close()
 					if resp.StatusCode == http.StatusOK {
-						log.Printf("K3s API is ready (via %s)", url)
+						slog.Info("K3s API is ready", "url", url)
 						return nil
 					}
 					if resp.StatusCode == http.StatusUnauthorized {
-						log.Printf("K3s API %s is ready (401 = auth required, API is up)", url)
+						slog.Info("K3s API is ready (401 = auth required, API is up)", "url", url)
 						return nil
 					}
-					log.Printf("K3s API %s returned status: %d, continuing to wait...", url, resp.StatusCode)
+					slog.Debug("K3s API not ready yet", "url", url, "status", resp.StatusCode)
 				}
 			}
 		}
 	}
 }
 
+// disabledComponents resolves and validates the K3s disable list, including components
+// disabled through ExtraArgs
+func (km *K3sManager) disabledComponents() ([]string, error) {
+	_, extra := splitDisableArgs(km.ExtraArgs)
+
+	var disabled, components []string
+	if km.Disable == nil {
+		disabled = slices.Clone(config.DefaultK3sDisable)
+		if km.Airgap {
+			disabled = append(disabled, "metrics-server")
+		}
+		components = extra
+	} else {
+		components = append(slices.Clone(km.Disable), extra...)
+	}
+
+	for _, component := range components {
+		component = strings.TrimSpace(component)
+		if component == "" || slices.Contains(disabled, component) {
+			continue
+		}
+		if !slices.Contains(config.K3sComponents, component) {
+			return nil, fmt.Errorf("unknown K3s component %q (known: %s)", component, strings.Join(config.K3sComponents, ", "))
+		}
+		disabled = append(disabled, component)
+	}
+	return disabled, nil
+}
+
+// splitDisableArgs separates --disable flags (--disable=a,b or --disable a) from other
+// K3s args, returning the remaining args and the components the flags name
+func splitDisableArgs(args []string) (rest, disabled []string) {
+	for i := 0; i < len(args); i++ {
+		value, ok := strings.CutPrefix(args[i], "--disable=")
+		if !ok && args[i] == "--disable" && i+1 < len(args) {
+			i++
+			value, ok = args[i], true
+		}
+		if !ok {
+			rest = append(rest, args[i])
+			continue
+		}
+		disabled = append(disabled, strings.Split(value, ",")...)
+	}
+	return rest, disabled
+}
+
+// splitK3sArgs splits a KUBE_PARCEL_K3S_ARGS value into args. Args are separated by
+// whitespace, or by a comma when the next arg is a flag, so values such as
+// --kube-apiserver-arg=feature-gates=A=true,B=true keep their commas.
+func splitK3sArgs(value string) []string {
+	var args []string
+	for _, field := range strings.Fields(value) {
+		for i, arg := range strings.Split(field, ",--") {
+			if i > 0 {
+				arg = "--" + arg
+			}
+			if arg = strings.Trim(arg, ","); arg != "" {
+				args = append(args, arg)
+			}
+		}
+	}
+	return args
+}
+
+// SetupNetwork applies the configured CNI manifest (if any) and waits until every node
+// reports Ready, which requires a working CNI. It must run after bundled images are
+// imported, since an airgapped CNI needs its images before its pods can start.
+func (km *K3sManager) SetupNetwork(ctx context.Context) error {
+	if km.CNIManifest != "" {
+		slog.Info("Applying CNI manifest", "manifest", km.CNIManifest)
+		cmd := exec.CommandContext(ctx, "kubectl", "apply", "-f", km.CNIManifest)
+		cmd.Env = append(os.Environ(), "KUBECONFIG="+km.kubeconfigPath)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to apply CNI manifest %s: %v (output: %s)", km.CNIManifest, err, out)
+		}
+	}
+
+	if km.Agents > 0 {
+		if err := km.waitForNodes(ctx, 1+km.Agents); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Waiting for nodes to be Ready", "timeout", config.NodeReadyTimeout)
+	cmd := exec.CommandContext(ctx, "kubectl", "wait", "--for=condition=Ready", "nodes", "--all",
+		"--timeout="+config.NodeReadyTimeout.String())
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+km.kubeconfigPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nodes did not become Ready (is the CNI working?): %v (output: %s)", err, out)
+	}
+
+	slog.Info("All nodes are Ready")
+	return nil
+}
+
+// waitForNodes waits until at least want nodes have registered with the API server
+func (km *K3sManager) waitForNodes(ctx context.Context, want int) error {
+	slog.Info("Waiting for agent nodes to join", "nodes", want)
+
+	ctx, cancel := context.WithTimeout(ctx, config.NodeReadyTimeout)
+	defer cancel()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if n := km.NodeCount(); n >= want {
+			slog.Info("All nodes registered", "nodes", n)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("only %d of %d nodes joined the cluster", km.NodeCount(), want)
+		case <-ticker.C:
+		}
+	}
+}
+
+// NodeCount returns the number of nodes registered with the API server (0 if unknown)
+func (km *K3sManager) NodeCount() int {
+	cmd := exec.Command("kubectl", "get", "nodes", "--no-headers", "-o", "name")
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+km.kubeconfigPath)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	return len(strings.Fields(string(out)))
+}
+
+// StartAgent starts a K3s agent that joins the server at serverURL. Agents run the same
+// container preparation as the server (cgroups, airgap) and pull bundled images from the
+// server through the embedded registry mirror.
+func (km *K3sManager) StartAgent(ctx context.Context, serverURL string, logWriter io.Writer) error {
+	slog.Info("Starting K3s agent", "server", serverURL)
+
+	if km.Token == "" {
+		return fmt.Errorf("a join token is required to start an agent")
+	}
+
+	if err := km.setupCgroups(); err != nil {
+		slog.Warn("Cgroup setup failed (might be non-cgroupv2)", "error", err)
+	}
+	if err := km.ensureK3sVersion(); err != nil {
+		return err
+	}
+	if km.Airgap {
+		if err := km.setupAirgapNetwork(); err != nil {
+			return fmt.Errorf("airgap could not be enforced: %w", err)
+		}
+	}
+	if err := km.writeRegistriesConfig(true); err != nil {
+		return err
+	}
+
+	args := append([]string{"agent", "--server=" + serverURL, "--token=" + km.Token}, kubeletArgs...)
+	km.cmd = exec.CommandContext(ctx, config.K3sBinary, args...)
+	km.cmd.Stdout = logWriter
+	km.cmd.Stderr = logWriter
+
+	if err := km.cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start k3s agent: %w", err)
+	}
+
+	slog.Info("K3s agent started", "pid", km.cmd.Process.Pid)
+	return nil
+}
+
+// k3sRegistries is the layout of K3s's registries.yaml
+type k3sRegistries struct {
+	Mirrors map[string]k3sMirror         `yaml:"mirrors,omitempty"`
+	Configs map[string]k3sRegistryConfig `yaml:"configs,omitempty"`
+}
+
+type k3sMirror struct {
+	Endpoint []string `yaml:"endpoint,omitempty"`
+}
+
+type k3sRegistryConfig struct {
+	Auth *shared.RegistryAuth `yaml:"auth,omitempty"`
+}
+
+// registriesConfig renders registries.yaml from the configured mirrors and credentials.
+// With embedded, every registry is also resolvable through K3s's embedded registry so
+// images imported on one node can be pulled by the others without external access.
+// It returns nil when there is nothing to configure.
+func (km *K3sManager) registriesConfig(embedded bool) ([]byte, error) {
+	regs := k3sRegistries{Mirrors: make(map[string]k3sMirror), Configs: make(map[string]k3sRegistryConfig)}
+	if embedded {
+		regs.Mirrors["*"] = k3sMirror{}
+	}
+	for registry, endpoints := range km.Registries.Mirrors {
+		regs.Mirrors[registry] = k3sMirror{Endpoint: endpoints}
+	}
+	for host, auth := range km.Registries.Auth {
+		regs.Configs[host] = k3sRegistryConfig{Auth: &auth}
+	}
+	if len(regs.Mirrors) == 0 && len(regs.Configs) == 0 {
+		return nil, nil
+	}
+	return yaml.Marshal(regs)
+}
+
+// writeRegistriesConfig writes registries.yaml if there is anything to configure
+func (km *K3sManager) writeRegistriesConfig(embedded bool) error {
+	data, err := km.registriesConfig(embedded)
+	if err != nil {
+		return fmt.Errorf("failed to render registries config: %w", err)
+	}
+	if data == nil {
+		return nil
+	}
+	if len(km.Registries.Mirrors) > 0 {
+		slog.Info("Configuring registry mirrors", "registries", slices.Sorted(maps.Keys(km.Registries.Mirrors)))
+		if km.Airgap {
+			slog.Warn("Registry mirrors are configured in airgap mode; only mirrors on private networks are reachable")
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.K3sRegistriesPath), 0755); err != nil {
+		return fmt.Errorf("failed to create registries config directory: %w", err)
+	}
+	// Owner-only: the file may contain registry credentials
+	if err := os.WriteFile(config.K3sRegistriesPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write registries config: %w", err)
+	}
+	return nil
+}
+
 // setupCgroups prepares the cgroupv2 hierarchy for nested K3s.
 func (km *K3sManager) setupCgroups() error {
 	cgroupRoot := "/sys/fs/cgroup"
@@ -176,7 +502,7 @@ func (km *K3sManager) setupCgroups() error {
 		return nil // Not cgroupv2 or not mounted
 	}
 
-	log.Println("Setting up cgroupv2 hierarchy for K3s...")
+	slog.Info("Setting up cgroupv2 hierarchy for K3s")
 
 	initCgroup := filepath.Join(cgroupRoot, "init")
 	if err := os.MkdirAll(initCgroup, 0755); err != nil {
@@ -219,17 +545,17 @@ func (km *K3sManager) setupCgroups() error {
 		if err := os.WriteFile(filepath.Join(cgroupRoot, "cgroup.subtree_control"), []byte(subtree), 0644); err != nil {
 			return fmt.Errorf("failed to write subtree_control: %w", err)
 		}
-		log.Printf("Enabled essential cgroup controllers: %v", enabledControllers)
+		slog.Info("Enabled essential cgroup controllers", "controllers", enabledControllers)
 	}
 
-	log.Println("Cgroupv2 hierarchy prepared successfully")
+	slog.Info("Cgroupv2 hierarchy prepared")
 	return nil
 }
 
 // setupAirgapNetwork configures iptables to block external network access
 // while allowing internal cluster traffic (pod-to-pod, service traffic, etc.)
 func (km *K3sManager) setupAirgapNetwork() error {
-	log.Println("Setting up airgap network isolation...")
+	slog.Info("Setting up airgap network isolation")
 
 	iptablesRules := [][]string{
 		{"-A", "OUTPUT", "-m", "state", "--state", "ESTABLISHED,RELATED", "-j", "ACCEPT"},
@@ -244,21 +570,62 @@ func (km *K3sManager) setupAirgapNetwork() error {
 		{"-A", "OUTPUT", "-j", "DROP"},
 	}
 
+	failedRules := 0
 	for _, rule := range iptablesRules {
 		cmd := exec.Command("iptables", rule...)
 		if output, err := cmd.CombinedOutput(); err != nil {
-			log.Printf("Warning: iptables rule failed: %v (output: %s)", err, string(output))
+			slog.Warn("iptables rule failed", "rule", strings.Join(rule, " "), "error", err, "output", string(output))
+			failedRules++
 		}
 	}
 
-	log.Println("🔒 Airgap network isolation configured - external traffic blocked")
+	// Individual rule failures are only warnings; the probe decides whether egress is really blocked
+	if err := verifyAirgapEgress(km.dialProbe, config.AirgapProbeAddress, config.AirgapProbeTimeout); err != nil {
+		return fmt.Errorf("%w (%d of %d iptables rules failed)", err, failedRules, len(iptablesRules))
+	}
+
+	km.airgapEnforced = true
+	slog.Info("Airgap network isolation configured, external traffic blocked")
 	return nil
 }
 
+// verifyAirgapEgress attempts a connection to a public IP address and returns an error unless
+// it times out or is refused. Other failures (no route, DNS) don't prove the rules are in place.
+func verifyAirgapEgress(dial func(network, address string, timeout time.Duration) (net.Conn, error), address string, timeout time.Duration) error {
+	slog.Info("Verifying airgap isolation", "probe", address)
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) == nil {
+		return fmt.Errorf("airgap probe %s is not an IP address and port", address)
+	}
+
+	conn, err := dial("tcp", address, timeout)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("egress to %s is still reachable", address)
+	}
+	var netErr net.Error
+	if (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, syscall.ECONNREFUSED) {
+		slog.Info("Airgap verified, egress blocked", "probe", address, "result", err)
+		return nil
+	}
+	return fmt.Errorf("egress probe to %s failed without showing it is blocked: %w", address, err)
+}
+
 func (km *K3sManager) IsReady() bool {
 	return km.ready
 }
 
+// StartFailed reports whether K3s failed to start, which leaves the runner unable to run
+func (km *K3sManager) StartFailed() bool {
+	return km.startFailed
+}
+
+// AirgapEnforced reports whether airgap isolation was set up and verified
+func (km *K3sManager) AirgapEnforced() bool {
+	return km.airgapEnforced
+}
+
 // Wait waits for the K3s process to exit
 func (km *K3sManager) Wait() error {
 	if km.cmd == nil || km.cmd.Process == nil {
@@ -273,6 +640,6 @@ func (km *K3sManager) Stop() error {
 		return nil
 	}
 
-	log.Println("Stopping K3s...")
+	slog.Info("Stopping K3s")
 	return km.cmd.Process.Signal(os.Interrupt)
 }
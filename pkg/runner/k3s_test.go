@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+	"gopkg.in/yaml.v3"
+)
+
+func TestK3sManager_DisabledComponents(t *testing.T) {
+	tests := []struct {
+		name    string
+		airgap  bool
+		disable []string
+		want    []string
+		wantErr bool
+	}{
+		{"default online", false, nil, []string{"traefik", "servicelb"}, false},
+		{"default airgap", true, nil, []string{"traefik", "servicelb", "metrics-server"}, false},
+		{"explicit list replaces defaults", true, []string{"servicelb"}, []string{"servicelb"}, false},
+		{"empty value disables nothing", true, []string{""}, nil, false},
+		{"trims and dedupes", false, []string{" traefik", "traefik", "local-storage"}, []string{"traefik", "local-storage"}, false},
+		{"unknown component", false, []string{"istio"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			km := &K3sManager{Airgap: tt.airgap, Disable: tt.disable}
+			got, err := km.disabledComponents()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("disabledComponents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("disabledComponents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestK3sManager_DisabledComponentsExtraArgs(t *testing.T) {
+	km := &K3sManager{ExtraArgs: []string{"--disable=coredns,traefik", "--node-label=ci=true", "--disable", "local-storage"}}
+	got, err := km.disabledComponents()
+	if err != nil {
+		t.Fatalf("disabledComponents() failed: %v", err)
+	}
+	if want := []string{"traefik", "servicelb", "coredns", "local-storage"}; !slices.Equal(got, want) {
+		t.Errorf("disabledComponents() = %v, want %v", got, want)
+	}
+	if rest, _ := splitDisableArgs(km.ExtraArgs); !slices.Equal(rest, []string{"--node-label=ci=true"}) {
+		t.Errorf("splitDisableArgs() kept %v, want only the node label", rest)
+	}
+
+	km = &K3sManager{ExtraArgs: []string{"--disable=istio"}}
+	if _, err := km.disabledComponents(); err == nil {
+		t.Error("expected error for an unknown component in extra args")
+	}
+}
+
+func TestSplitK3sArgs(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"--disable=coredns --node-label=ci=true", []string{"--disable=coredns", "--node-label=ci=true"}},
+		{"--disable=coredns,--node-label=ci=true", []string{"--disable=coredns", "--node-label=ci=true"}},
+		{"--kube-apiserver-arg=feature-gates=A=true,B=true, --disable=traefik,servicelb",
+			[]string{"--kube-apiserver-arg=feature-gates=A=true,B=true", "--disable=traefik,servicelb"}},
+	}
+	for _, tt := range tests {
+		if got := splitK3sArgs(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("splitK3sArgs(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestK3sManager_StartAgentRequiresToken(t *testing.T) {
+	km := NewK3sManager()
+	err := km.StartAgent(context.Background(), "https://server:6443", io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "token") {
+		t.Fatalf("expected a missing token error, got %v", err)
+	}
+	if km.cmd != nil {
+		t.Error("agent should not be started without a token")
+	}
+}
+
+func TestK3sManager_RegistriesConfig(t *testing.T) {
+	km := &K3sManager{}
+	if data, err := km.registriesConfig(false); data != nil || err != nil {
+		t.Errorf("expected no registries.yaml without mirrors, got %q, %v", data, err)
+	}
+
+	data, err := km.registriesConfig(true)
+	if err != nil {
+		t.Fatalf("registriesConfig failed: %v", err)
+	}
+	if got := string(data); got != "mirrors:\n    '*': {}\n" {
+		t.Errorf("unexpected embedded registry config:\n%s", got)
+	}
+
+	km.Registries = shared.RegistryConfig{
+		Mirrors: map[string][]string{"docker.io": {"https://mirror.internal"}},
+		Auth:    map[string]shared.RegistryAuth{"mirror.internal": {Username: "ci", Password: "s3cr:et"}},
+	}
+	data, err = km.registriesConfig(false)
+	if err != nil {
+		t.Fatalf("registriesConfig failed: %v", err)
+	}
+	var got k3sRegistries
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("registries.yaml does not parse: %v\n%s", err, data)
+	}
+	if !slices.Equal(got.Mirrors["docker.io"].Endpoint, []string{"https://mirror.internal"}) {
+		t.Errorf("unexpected docker.io mirror: %+v", got.Mirrors)
+	}
+	if _, ok := got.Mirrors["*"]; ok {
+		t.Error("expected no wildcard mirror without the embedded registry")
+	}
+	if auth := got.Configs["mirror.internal"].Auth; auth == nil || *auth != km.Registries.Auth["mirror.internal"] {
+		t.Errorf("unexpected mirror.internal auth: %+v", got.Configs)
+	}
+}
+
+func TestK3sManager_StartOnce(t *testing.T) {
+	km := NewK3sManager()
+	km.started = true
+	if err := km.Start(context.Background(), io.Discard); err == nil || !strings.Contains(err.Error(), "already started") {
+		t.Errorf("Start() on a started manager = %v, want an already-started error", err)
+	}
+}
+
+func TestVerifyAirgapEgress(t *testing.T) {
+	dialErr := func(err error) *net.OpError {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+	tests := []struct {
+		name    string
+		address string
+		err     error // Returned by the dialer; nil connects
+		blocked bool
+	}{
+		{"timeout", "1.1.1.1:443", dialErr(os.ErrDeadlineExceeded), true},
+		{"refused", "1.1.1.1:443", dialErr(&os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}), true},
+		{"reachable", "1.1.1.1:443", nil, false},
+		{"no route", "1.1.1.1:443", dialErr(&os.SyscallError{Syscall: "connect", Err: syscall.EHOSTUNREACH}), false},
+		{"dns", "1.1.1.1:443", dialErr(&net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}), false},
+		{"hostname", "one.one.one.one:443", dialErr(os.ErrDeadlineExceeded), false},
+	}
+	for _, tt := range tests {
+		dial := func(network, address string, timeout time.Duration) (net.Conn, error) {
+			if tt.err != nil {
+				return nil, tt.err
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+		err := verifyAirgapEgress(dial, tt.address, time.Second)
+		if blocked := err == nil; blocked != tt.blocked {
+			t.Errorf("%s: verifyAirgapEgress() = %v, want blocked=%v", tt.name, err, tt.blocked)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+)
+
+// ensureK3sVersion downloads the requested K3s release over the bundled binary unless it
+// already matches, and records the version that will run. Downloads need online mode.
+func (km *K3sManager) ensureK3sVersion() error {
+	installed, err := k3sBinaryVersion(config.K3sBinary)
+	if err != nil {
+		slog.Warn("Could not determine the bundled K3s version", "error", err)
+	}
+
+	if want := normalizeK3sVersion(km.Version); want != "" && want != installed {
+		if km.Airgap {
+			return fmt.Errorf("K3s %s was requested but the runner image ships %s, and airgap mode can't download it (use --no-airgap)", want, installed)
+		}
+		slog.Info("Downloading K3s", "version", want, "bundled", installed)
+		if err := downloadK3s(want); err != nil {
+			return fmt.Errorf("failed to download K3s %s: %w", want, err)
+		}
+		if installed, err = k3sBinaryVersion(config.K3sBinary); err != nil {
+			return fmt.Errorf("downloaded K3s %s does not run: %w", want, err)
+		}
+	}
+
+	km.version = installed
+	slog.Info("Using K3s", "version", installed)
+	return nil
+}
+
+// RunningVersion returns the K3s version the manager started ("" before Start)
+func (km *K3sManager) RunningVersion() string {
+	return km.version
+}
+
+// normalizeK3sVersion accepts versions with or without the leading "v" (1.30.2+k3s1)
+func normalizeK3sVersion(version string) string {
+	version = strings.TrimSpace(version)
+	if version != "" && !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version
+}
+
+// k3sBinaryVersion runs "k3s --version" and returns the release, e.g. v1.30.2+k3s1
+func k3sBinaryVersion(binary string) (string, error) {
+	out, err := exec.Command(binary, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return parseK3sVersion(string(out))
+}
+
+// parseK3sVersion extracts the release from "k3s version v1.30.2+k3s1 (faaf2f20)"
+func parseK3sVersion(output string) (string, error) {
+	line, _, _ := strings.Cut(output, "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "k3s" || fields[1] != "version" {
+		return "", fmt.Errorf("unexpected k3s --version output: %q", line)
+	}
+	return fields[2], nil
+}
+
+// k3sAsset returns the release asset name of the K3s binary and its checksum file for arch
+func k3sAsset(arch string) (binary, checksums string, err error) {
+	switch arch {
+	case "amd64":
+		return "k3s", "sha256sum-amd64.txt", nil
+	case "arm64":
+		return "k3s-arm64", "sha256sum-arm64.txt", nil
+	case "arm":
+		return "k3s-armhf", "sha256sum-arm.txt", nil
+	}
+	return "", "", fmt.Errorf("no K3s release for architecture %s", arch)
+}
+
+// k3sReleaseURL returns the download URL of a release asset; the "+" in K3s tags is escaped
+func k3sReleaseURL(version, asset string) string {
+	return config.K3sReleaseURL + "/" + strings.ReplaceAll(version, "+", "%2B") + "/" + asset
+}
+
+// downloadK3s replaces the K3s binary with the given release after checking it against the
+// release's published SHA-256 sums
+func downloadK3s(version string) error {
+	asset, checksums, err := k3sAsset(runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	want, err := fetchK3sChecksum(k3sReleaseURL(version, checksums), asset)
+	if err != nil {
+		return err
+	}
+
+	// Downloaded next to the binary so the final rename is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(config.K3sBinary), ".k3s-download-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Close()
+
+	if err := downloadFile(k3sReleaseURL(version, asset), tmp.Name()); err != nil {
+		return err
+	}
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, release lists %s", asset, got, want)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), config.K3sBinary); err != nil {
+		return fmt.Errorf("failed to install K3s binary: %w", err)
+	}
+	slog.Info("Installed K3s binary", "version", version, "path", config.K3sBinary)
+	return nil
+}
+
+// fetchK3sChecksum returns the SHA-256 listed for asset in a release's checksum file
+func fetchK3sChecksum(url, asset string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksums: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch checksums: bad status: %s", resp.Status)
+	}
+	return findChecksum(resp.Body, asset)
+}
+
+// findChecksum reads "<sha256>  <file>" lines and returns the sum for file
+func findChecksum(r io.Reader, file string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[1] == file {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum listed for %s", file)
+}
@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseK3sVersion(t *testing.T) {
+	got, err := parseK3sVersion("k3s version v1.30.2+k3s1 (faaf2f20)\ngo version go1.22.4\n")
+	if err != nil || got != "v1.30.2+k3s1" {
+		t.Errorf("parseK3sVersion() = %q, %v; want v1.30.2+k3s1", got, err)
+	}
+	if _, err := parseK3sVersion("command not found"); err == nil {
+		t.Error("expected error for unexpected output")
+	}
+}
+
+func TestNormalizeK3sVersion(t *testing.T) {
+	for in, want := range map[string]string{"": "", "1.30.2+k3s1": "v1.30.2+k3s1", " v1.29.0+k3s1 ": "v1.29.0+k3s1"} {
+		if got := normalizeK3sVersion(in); got != want {
+			t.Errorf("normalizeK3sVersion(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestK3sReleaseURL(t *testing.T) {
+	want := "https://github.com/k3s-io/k3s/releases/download/v1.30.2%2Bk3s1/k3s-arm64"
+	if got := k3sReleaseURL("v1.30.2+k3s1", "k3s-arm64"); got != want {
+		t.Errorf("k3sReleaseURL() = %q, want %q", got, want)
+	}
+	if _, _, err := k3sAsset("s390x"); err == nil {
+		t.Error("expected error for an architecture without a K3s release")
+	}
+}
+
+func TestFindChecksum(t *testing.T) {
+	sums := "aaa  k3s\nbbb  k3s-airgap-images-amd64.tar\n"
+	if got, err := findChecksum(strings.NewReader(sums), "k3s"); err != nil || got != "aaa" {
+		t.Errorf("findChecksum() = %q, %v; want aaa", got, err)
+	}
+	if _, err := findChecksum(strings.NewReader(sums), "k3s-arm64"); err == nil {
+		t.Error("expected error for an unlisted file")
+	}
+}
+
+func TestK3sManager_EnsureVersionAirgap(t *testing.T) {
+	km := &K3sManager{Airgap: true, Version: "v0.0.1+k3s1"}
+	err := km.ensureK3sVersion()
+	if err == nil || !strings.Contains(err.Error(), "airgap") {
+		t.Errorf("expected airgap error, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+)
+
+// lintFindings are the messages helm lint reported for a chart, by severity
+type lintFindings struct {
+	errors   []string
+	warnings []string
+}
+
+// parseLintOutput picks the [ERROR] and [WARNING] lines out of helm lint output.
+// [INFO] lines are style hints and are ignored.
+func parseLintOutput(output string) lintFindings {
+	var findings lintFindings
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if msg, ok := strings.CutPrefix(line, "[ERROR]"); ok {
+			findings.errors = append(findings.errors, strings.TrimSpace(msg))
+		} else if msg, ok := strings.CutPrefix(line, "[WARNING]"); ok {
+			findings.warnings = append(findings.warnings, strings.TrimSpace(msg))
+		}
+	}
+	return findings
+}
+
+// lintChart runs helm lint with the values the chart is installed with. Errors mark the
+// chart LintFailed and are returned; warnings are reported but don't stop the install.
+func (hm *HelmManager) lintChart(ctx context.Context, chart chartSpec, valuesArgs []string) error {
+	args := append([]string{"lint", chart.Path}, valuesArgs...)
+	cmd := exec.CommandContext(ctx, "helm", args...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+
+	var output strings.Builder
+	cmd.Stdout = io.MultiWriter(hm.logger, &output)
+	cmd.Stderr = io.MultiWriter(hm.logger, &output)
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		errMsg := fmt.Sprintf("Lint aborted: %v", context.Cause(ctx))
+		hm.updateStatus(chart.Name, "Failed", errMsg)
+		return fmt.Errorf("helm lint aborted: %w", context.Cause(ctx))
+	}
+
+	findings := parseLintOutput(output.String())
+	if err == nil && len(findings.errors) == 0 {
+		if len(findings.warnings) > 0 {
+			slog.Warn("Helm lint reported warnings", "chart", chart.Name, "warnings", len(findings.warnings))
+			fmt.Fprintf(hm.logger, "⚠️ Lint found %d warning(s) in %s, installing anyway\n", len(findings.warnings), chart.Name)
+		}
+		return nil
+	}
+
+	errMsg := fmt.Sprintf("Lint failed: %v", err)
+	if len(findings.errors) > 0 {
+		errMsg = "Lint failed: " + findings.errors[0]
+		if more := len(findings.errors) - 1; more > 0 {
+			errMsg += fmt.Sprintf(" (and %d more)", more)
+		}
+	}
+	slog.Error("Helm lint failed", "chart", chart.Name, "errors", len(findings.errors), "error", err)
+	fmt.Fprintf(hm.logger, "❌ %s\n", errMsg)
+	hm.updateStatus(chart.Name, "LintFailed", errMsg)
+	return fmt.Errorf("helm lint failed: %s", errMsg)
+}
@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseLintOutput(t *testing.T) {
+	output := `==> Linting /var/lib/kube-parcel/charts/web
+[INFO] Chart.yaml: icon is recommended
+[WARNING] templates/deployment.yaml: object name does not conform to Kubernetes naming requirements
+[ERROR] templates/: template: web/templates/service.yaml:7:12: executing "web/templates/service.yaml" at <.Values.port>: nil pointer
+  [ERROR] values.yaml: unable to parse YAML
+
+Error: 1 chart(s) linted, 1 chart(s) failed
+`
+	findings := parseLintOutput(output)
+
+	wantErrors := []string{
+		`templates/: template: web/templates/service.yaml:7:12: executing "web/templates/service.yaml" at <.Values.port>: nil pointer`,
+		"values.yaml: unable to parse YAML",
+	}
+	if !slices.Equal(findings.errors, wantErrors) {
+		t.Errorf("errors = %q, want %q", findings.errors, wantErrors)
+	}
+	wantWarnings := []string{"templates/deployment.yaml: object name does not conform to Kubernetes naming requirements"}
+	if !slices.Equal(findings.warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", findings.warnings, wantWarnings)
+	}
+}
+
+func TestParseLintOutput_WarningsOnly(t *testing.T) {
+	findings := parseLintOutput("==> Linting web\n[INFO] Chart.yaml: icon is recommended\n[WARNING] templates/: directory is empty\n\n1 chart(s) linted, 0 chart(s) failed\n")
+	if len(findings.errors) != 0 {
+		t.Errorf("expected no errors, got %q", findings.errors)
+	}
+	if len(findings.warnings) != 1 {
+		t.Errorf("expected 1 warning, got %q", findings.warnings)
+	}
+}
@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SetupLogging configures the runner's internal structured logger.
+// KUBE_PARCEL_LOG_LEVEL selects the minimum level (debug, info, warn, error; default info)
+// and KUBE_PARCEL_LOG_FORMAT selects the handler (text or json; default text).
+// The broadcast LogMessage stream sent to clients is not affected.
+func SetupLogging() {
+	opts := &slog.HandlerOptions{Level: parseLogLevel(os.Getenv("KUBE_PARCEL_LOG_LEVEL"))}
+
+	var handler slog.Handler
+	if strings.EqualFold(os.Getenv("KUBE_PARCEL_LOG_FORMAT"), "json") {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+// parseLogLevel maps a level name to a slog level, defaulting to info
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Broadcast log levels, from least to most severe. "complete" marks the end of a run
+// and is never filtered out.
+var logLevelRank = map[string]int{
+	"debug":   0,
+	"info":    1,
+	"warning": 2,
+	"error":   3,
+}
+
+// parseMinLevel maps a min_level query value to a level rank ("" = everything)
+func parseMinLevel(level string) (int, error) {
+	switch strings.ToLower(level) {
+	case "":
+		return 0, nil
+	case "warn":
+		return logLevelRank["warning"], nil
+	}
+	rank, ok := logLevelRank[strings.ToLower(level)]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", level)
+	}
+	return rank, nil
+}
+
+// levelAtLeast reports whether a message level passes a minimum rank
+func levelAtLeast(level string, minRank int) bool {
+	rank, ok := logLevelRank[level]
+	return !ok || rank >= minRank
+}
+
+var (
+	// klogPrefix matches klog headers like "E0101 12:00:00.000000   1234 file.go:12]"
+	klogPrefix = regexp.MustCompile(`^([IWEF])\d{4} \d{2}:\d{2}:\d{2}`)
+	// levelField matches logrus/slog style "level=error" or level="warning" fields
+	levelField = regexp.MustCompile(`(?i)\blevel="?(debug|info|warn|warning|error|fatal|panic)\b`)
+)
+
+// detectLogLevel infers the level of a line of helm or K3s output, defaulting to info
+func detectLogLevel(line string) string {
+	if m := klogPrefix.FindStringSubmatch(line); m != nil {
+		switch m[1] {
+		case "W":
+			return "warning"
+		case "E", "F":
+			return "error"
+		}
+		return "info"
+	}
+	if m := levelField.FindStringSubmatch(line); m != nil {
+		switch strings.ToLower(m[1]) {
+		case "debug":
+			return "debug"
+		case "warn", "warning":
+			return "warning"
+		case "error", "fatal", "panic":
+			return "error"
+		}
+		return "info"
+	}
+
+	switch trimmed := strings.TrimSpace(line); {
+	case strings.HasPrefix(trimmed, "Error:"), strings.HasPrefix(trimmed, "❌"):
+		return "error"
+	case strings.HasPrefix(trimmed, "WARNING:"), strings.HasPrefix(trimmed, "Warning:"), strings.HasPrefix(trimmed, "⚠️"):
+		return "warning"
+	}
+	return "info"
+}
+
+// k3sLogQueueSize is how many K3s lines may wait for broadcast before new ones are dropped
+const k3sLogQueueSize = 1000
+
+// skippedContextLines is how many of the latest filtered-out lines a queuedLogWriter keeps
+// to replay after a failure
+const skippedContextLines = 50
+
+// queuedLogWriter broadcasts the lines of a chatty source (K3s) from its own goroutine,
+// so the process writing them never waits on slow WebSocket clients. Lines below minLevel
+// are skipped, but the latest are kept for replaySkipped; lines arriving while the queue
+// is full are dropped and reported as a count.
+type queuedLogWriter struct {
+	source    string
+	minLevel  int
+	broadcast func(source, level, message string)
+	queue     chan [2]string // level, message; an empty level marks a flush
+	flushed   chan struct{}
+	dropped   atomic.Int64
+
+	mu      sync.Mutex
+	skipped [][2]string // Latest lines below minLevel, oldest first
+}
+
+func newQueuedLogWriter(source string, minLevel, size int, broadcast func(source, level, message string)) *queuedLogWriter {
+	w := &queuedLogWriter{
+		source:    source,
+		minLevel:  minLevel,
+		broadcast: broadcast,
+		queue:     make(chan [2]string, size),
+		flushed:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *queuedLogWriter) Write(p []byte) (n int, err error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		level := detectLogLevel(string(line))
+		if !levelAtLeast(level, w.minLevel) {
+			w.mu.Lock()
+			w.skipped = append(w.skipped, [2]string{level, string(line)})
+			if len(w.skipped) > skippedContextLines {
+				w.skipped = w.skipped[len(w.skipped)-skippedContextLines:]
+			}
+			w.mu.Unlock()
+			continue
+		}
+		select {
+		case w.queue <- [2]string{level, string(line)}:
+		default:
+			w.dropped.Add(1)
+		}
+	}
+	return len(p), nil
+}
+
+// replaySkipped queues the latest lines the level filter held back and waits until
+// everything queued has been broadcast, so a failure reported next comes after them.
+// When the source fails, these lines usually explain why.
+func (w *queuedLogWriter) replaySkipped() {
+	w.mu.Lock()
+	lines := w.skipped
+	w.skipped = nil
+	w.mu.Unlock()
+
+	if len(lines) > 0 {
+		w.queue <- [2]string{"info", fmt.Sprintf("Last %d %s log lines below the log level:", len(lines), w.source)}
+		for _, line := range lines {
+			w.queue <- line
+		}
+	}
+	w.queue <- [2]string{}
+	<-w.flushed
+}
+
+func (w *queuedLogWriter) run() {
+	for line := range w.queue {
+		if line[0] == "" {
+			w.flushed <- struct{}{}
+			continue
+		}
+		if n := w.dropped.Swap(0); n > 0 {
+			w.broadcast(w.source, "warning", fmt.Sprintf("Dropped %d %s log lines (stream falling behind)", n, w.source))
+		}
+		w.broadcast(w.source, line[0], line[1])
+	}
+}
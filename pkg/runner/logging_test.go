@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected slog.Level
+	}{
+		{"", slog.LevelInfo},
+		{"info", slog.LevelInfo},
+		{"DEBUG", slog.LevelDebug},
+		{"warn", slog.LevelWarn},
+		{"warning", slog.LevelWarn},
+		{" error ", slog.LevelError},
+		{"verbose", slog.LevelInfo},
+	}
+
+	for _, tc := range tests {
+		if result := parseLogLevel(tc.input); result != tc.expected {
+			t.Errorf("parseLogLevel(%q) = %v, expected %v", tc.input, result, tc.expected)
+		}
+	}
+}
+
+func TestDetectLogLevel(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"E0101 12:00:00.000000    1234 controller.go:42] sync failed", "error"},
+		{"W0101 12:00:00.000000    1234 reflector.go:42] watch closed", "warning"},
+		{"I0101 12:00:00.000000    1234 server.go:42] serving", "info"},
+		{`time="2025-01-01T12:00:00Z" level=error msg="failed to start"`, "error"},
+		{`time="2025-01-01T12:00:00Z" level=warning msg="deprecated flag"`, "warning"},
+		{`time=2025-01-01T12:00:00Z level=DEBUG msg=probe`, "debug"},
+		{"Error: INSTALLATION FAILED: timed out waiting for the condition", "error"},
+		{"WARNING: Kubernetes configuration file is group-readable", "warning"},
+		{"❌ Install failed: exit status 1", "error"},
+		{"⚠️ Could not detect cluster capabilities", "warning"},
+		{"NAME: myapp", "info"},
+		{"Error handling is fine in this sentence", "info"},
+	}
+
+	for _, tc := range tests {
+		if result := detectLogLevel(tc.line); result != tc.expected {
+			t.Errorf("detectLogLevel(%q) = %q, expected %q", tc.line, result, tc.expected)
+		}
+	}
+}
+
+func TestMinLevelFilter(t *testing.T) {
+	minLevel, err := parseMinLevel("warn")
+	if err != nil {
+		t.Fatalf("parseMinLevel failed: %v", err)
+	}
+	for level, want := range map[string]bool{"debug": false, "info": false, "warning": true, "error": true, "complete": true} {
+		if got := levelAtLeast(level, minLevel); got != want {
+			t.Errorf("levelAtLeast(%q, warn) = %v, expected %v", level, got, want)
+		}
+	}
+	if _, err := parseMinLevel("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestQueuedLogWriter(t *testing.T) {
+	release := make(chan struct{})
+	got := make(chan string, 10)
+	w := newQueuedLogWriter("k3s", logLevelRank["warning"], 1, func(source, level, message string) {
+		<-release
+		got <- level + " " + message
+	})
+
+	// The first line is taken off the queue and held in a slow broadcast
+	w.Write([]byte("E0101 00:00:00.000000 1 a.go:1] first\n"))
+	for deadline := time.Now().Add(time.Second); len(w.queue) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("first line never dequeued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Info is filtered, the next error fills the queue and the last one is dropped
+	w.Write([]byte("I0101 00:00:00.000000 1 a.go:1] chatter\nE0101 00:00:00.000000 1 a.go:1] second\nE0101 00:00:00.000000 1 a.go:1] third\n"))
+	close(release)
+
+	want := []string{
+		"error E0101 00:00:00.000000 1 a.go:1] first",
+		"warning Dropped 1 k3s log lines (stream falling behind)",
+		"error E0101 00:00:00.000000 1 a.go:1] second",
+	}
+	for _, w := range want {
+		select {
+		case line := <-got:
+			if line != w {
+				t.Errorf("broadcast %q, want %q", line, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+}
+
+func TestQueuedLogWriterReplaySkipped(t *testing.T) {
+	var got []string
+	w := newQueuedLogWriter("k3s", logLevelRank["warning"], 10, func(source, level, message string) {
+		got = append(got, level+" "+message)
+	})
+
+	for i := range skippedContextLines + 2 {
+		fmt.Fprintf(w, "I0101 00:00:00.000000 1 a.go:1] line %d\n", i)
+	}
+	w.Write([]byte("E0101 00:00:00.000000 1 a.go:1] failed\n"))
+	w.replaySkipped()
+
+	// Returns once everything is broadcast: the error, then the latest skipped lines
+	if len(got) != skippedContextLines+2 {
+		t.Fatalf("got %d lines, want %d: %v", len(got), skippedContextLines+2, got)
+	}
+	if got[0] != "error E0101 00:00:00.000000 1 a.go:1] failed" {
+		t.Errorf("first line = %q, want the error", got[0])
+	}
+	if want := fmt.Sprintf("info Last %d k3s log lines below the log level:", skippedContextLines); got[1] != want {
+		t.Errorf("got %q, want %q", got[1], want)
+	}
+	if got[2] != "info I0101 00:00:00.000000 1 a.go:1] line 2" {
+		t.Errorf("oldest replayed line = %q, want line 2", got[2])
+	}
+
+	// Nothing is replayed twice
+	got = nil
+	w.replaySkipped()
+	if len(got) != 0 {
+		t.Errorf("second replay broadcast %v", got)
+	}
+}
@@ -0,0 +1,197 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+	"github.com/tiborv/kube-parcel/pkg/shared"
+	"gopkg.in/yaml.v3"
+)
+
+// manifestFiles are the chart manifest names looked up in a chart directory, in order
+var manifestFiles = []string{"kube-parcel.yaml", "parcel.yaml"}
+
+// ChartManifest is the optional per-chart kube-parcel metadata shipped in the chart directory
+type ChartManifest struct {
+	ReleaseName string          `yaml:"releaseName"` // Helm release name (default: lowercased directory name)
+	Namespace   string          `yaml:"namespace"`   // Namespace to install into, created if missing (default: "default")
+	DependsOn   []string        `yaml:"dependsOn"`   // Charts (directory names) that must be deployed before this one installs
+	Cases       []ChartCase     `yaml:"cases"`       // Install the chart once per case instead of once
+	ValuesFiles []ValuesFile    `yaml:"valuesFiles"`
+	Overlays    []ValuesOverlay `yaml:"overlays"`
+
+	WaitFor []WaitCondition `yaml:"waitFor"` // kubectl wait conditions that must hold after install, before tests
+
+	SkipTests  bool     `yaml:"skipTests"`  // Install the chart without running helm test
+	TestFilter []string `yaml:"testFilter"` // helm test --filter expressions, e.g. "name=smoke" or "!name=soak"
+
+	// Expected outcomes replacing "helm test passed" as the chart's verdict
+	ExpectTestExitCode *int                  `yaml:"expectTestExitCode"` // Every test pod must exit with this code
+	ExpectResources    []ResourceExpectation `yaml:"expectResources"`    // Resources that must be in a given state after the tests
+}
+
+// ChartCase is one variation of a chart in a matrix run: the chart is installed as a
+// separate release per case, each with its own values on top of the chart's
+type ChartCase struct {
+	Name        string       `yaml:"name"`        // Identifies the case; status keys are <chart dir>/<name>
+	ReleaseName string       `yaml:"releaseName"` // Default: the chart's release name suffixed with -<name>
+	Namespace   string       `yaml:"namespace"`   // Default: the chart's namespace
+	ValuesFiles []ValuesFile `yaml:"valuesFiles"` // Applied after the chart's valuesFiles
+}
+
+// WaitCondition is a kubectl wait run after the chart installed, e.g. for a migration Job
+// to complete: {resource: job/migrate, for: condition=complete}
+type WaitCondition struct {
+	Resource  string        `yaml:"resource"`  // kubectl resource, e.g. job/migrate or deployment/web
+	For       string        `yaml:"for"`       // kubectl wait --for, e.g. condition=complete or jsonpath='{.status.phase}'=Ready
+	Namespace string        `yaml:"namespace"` // Default: the chart's namespace
+	Timeout   time.Duration `yaml:"timeout"`   // Default: the helm install timeout
+}
+
+// ResourceExpectation asserts the state of a resource once the chart's tests have run
+type ResourceExpectation struct {
+	Kind      string `yaml:"kind"` // e.g. Deployment, Job, Pod
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"` // Default: the chart's namespace
+	Status    string `yaml:"status"`    // Resource status (e.g. Succeeded, Failed, Running), or Healthy/Unhealthy
+}
+
+// ValuesFile is a values file passed to helm with -f. Files are listed in precedence
+// order: later files override earlier ones, as with repeated -f flags.
+type ValuesFile struct {
+	Path     string `yaml:"path"`     // Relative to the chart directory
+	Optional bool   `yaml:"optional"` // Skip instead of failing when the file is missing
+}
+
+// UnmarshalYAML accepts either a plain path or a {path, optional} mapping
+func (v *ValuesFile) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		v.Path = node.Value
+		return nil
+	}
+	type plain ValuesFile
+	return node.Decode((*plain)(v))
+}
+
+// ValuesOverlay sets values when a cluster capability check holds
+type ValuesOverlay struct {
+	When string         `yaml:"when"` // Capability check, e.g. "!defaultStorageClass"
+	Set  map[string]any `yaml:"set"`  // Values passed to helm install as --set key=value
+}
+
+// loadChartManifest reads the chart's manifest, returning an empty manifest if it has none
+func loadChartManifest(chartPath string) (*ChartManifest, error) {
+	for _, name := range manifestFiles {
+		data, err := os.ReadFile(filepath.Join(chartPath, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var m ChartManifest
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return &m, nil
+	}
+	return &ChartManifest{}, nil
+}
+
+// overlayArgs returns the helm --set arguments of every overlay whose condition holds.
+// Later overlays win when they set the same key.
+func (m *ChartManifest) overlayArgs(caps Capabilities) (args []string, applied []string, err error) {
+	for _, overlay := range m.Overlays {
+		if overlay.When == "" {
+			return nil, nil, fmt.Errorf("overlay is missing a 'when' condition")
+		}
+		ok, err := caps.Has(overlay.When)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		keys := make([]string, 0, len(overlay.Set))
+		for key := range overlay.Set {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			args = append(args, "--set", fmt.Sprintf("%s=%v", key, overlay.Set[key]))
+		}
+		applied = append(applied, overlay.When)
+	}
+	return args, applied, nil
+}
+
+// valuesArgs resolves the manifest's values files against the chart directory into ordered
+// helm -f arguments. A missing required file is an error; missing optional files are skipped.
+func (m *ChartManifest) valuesArgs(chartPath string) (args []string, skipped []string, err error) {
+	for _, vf := range m.ValuesFiles {
+		if vf.Path == "" {
+			return nil, nil, fmt.Errorf("values file entry is missing a path")
+		}
+		if filepath.IsAbs(vf.Path) || !filepath.IsLocal(vf.Path) {
+			return nil, nil, fmt.Errorf("values file %q must be relative to the chart directory", vf.Path)
+		}
+
+		path := filepath.Join(chartPath, vf.Path)
+		if _, err := os.Stat(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) && vf.Optional {
+				skipped = append(skipped, vf.Path)
+				continue
+			}
+			return nil, nil, fmt.Errorf("values file %q: %w", vf.Path, err)
+		}
+		args = append(args, "-f", path)
+	}
+	return args, skipped, nil
+}
+
+// loadChartOverrides reads the command-line overrides the client bundled under the chart's
+// .kube-parcel directory, returning empty overrides if there are none
+func loadChartOverrides(chartPath string) (*shared.ChartOverrides, error) {
+	data, err := os.ReadFile(filepath.Join(chartPath, config.ChartOverridesDir, config.ChartOverridesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &shared.ChartOverrides{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides shared.ChartOverrides
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", config.ChartOverridesFile, err)
+	}
+	return &overrides, nil
+}
+
+// overrideArgs returns the helm -f/--set arguments for the chart's bundled overrides,
+// or nil if there are none
+func overrideArgs(chartPath string) ([]string, error) {
+	overrides, err := loadChartOverrides(chartPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(chartPath, config.ChartOverridesDir)
+	var args []string
+	for _, name := range overrides.Values {
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("values override %q must be relative to %s", name, config.ChartOverridesDir)
+		}
+		args = append(args, "-f", filepath.Join(dir, name))
+	}
+	for _, set := range overrides.Set {
+		args = append(args, "--set", set)
+	}
+	return args, nil
+}
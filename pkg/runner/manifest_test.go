@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadChartManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := loadChartManifest(dir)
+	if err != nil {
+		t.Fatalf("missing manifest should not error: %v", err)
+	}
+	if len(m.Overlays) != 0 {
+		t.Errorf("expected empty manifest, got %+v", m)
+	}
+
+	os.WriteFile(filepath.Join(dir, "parcel.yaml"), []byte(`
+overlays:
+  - when: "!defaultStorageClass"
+    set:
+      persistence.enabled: false
+`), 0644)
+
+	m, err = loadChartManifest(dir)
+	if err != nil {
+		t.Fatalf("loadChartManifest failed: %v", err)
+	}
+	if len(m.Overlays) != 1 || m.Overlays[0].When != "!defaultStorageClass" {
+		t.Errorf("unexpected overlays from parcel.yaml: %+v", m.Overlays)
+	}
+
+	os.WriteFile(filepath.Join(dir, "kube-parcel.yaml"), []byte("overlays: [{when: ingressController}]\n"), 0644)
+	m, err = loadChartManifest(dir)
+	if err != nil {
+		t.Fatalf("loadChartManifest failed: %v", err)
+	}
+	if len(m.Overlays) != 1 || m.Overlays[0].When != "ingressController" {
+		t.Errorf("expected kube-parcel.yaml to take precedence, got %+v", m.Overlays)
+	}
+}
+
+func TestChartManifest_OverlayArgs(t *testing.T) {
+	m := &ChartManifest{Overlays: []ValuesOverlay{
+		{When: "!defaultStorageClass", Set: map[string]any{"persistence.enabled": false, "persistence.size": "1Gi"}},
+		{When: "ingressController", Set: map[string]any{"ingress.enabled": true}},
+		{When: "api:monitoring.coreos.com/v1", Set: map[string]any{"serviceMonitor.enabled": true}},
+	}}
+	caps := Capabilities{
+		DefaultStorageClass: false,
+		IngressController:   false,
+		APIVersions:         []string{"v1", "monitoring.coreos.com/v1"},
+	}
+
+	args, applied, err := m.overlayArgs(caps)
+	if err != nil {
+		t.Fatalf("overlayArgs failed: %v", err)
+	}
+	want := []string{
+		"--set", "persistence.enabled=false",
+		"--set", "persistence.size=1Gi",
+		"--set", "serviceMonitor.enabled=true",
+	}
+	if !slices.Equal(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+	if !slices.Equal(applied, []string{"!defaultStorageClass", "api:monitoring.coreos.com/v1"}) {
+		t.Errorf("unexpected applied overlays: %v", applied)
+	}
+
+	bad := &ChartManifest{Overlays: []ValuesOverlay{{When: "gpu", Set: map[string]any{"a": 1}}}}
+	if _, _, err := bad.overlayArgs(caps); err == nil {
+		t.Error("expected error for unknown capability")
+	}
+}
+
+func TestChartManifest_ValuesArgs(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "values"), 0755)
+	os.WriteFile(filepath.Join(dir, "values", "base.yaml"), []byte("a: 1\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "values", "ci.yaml"), []byte("a: 2\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "kube-parcel.yaml"), []byte(`
+valuesFiles:
+  - values/base.yaml
+  - values/ci.yaml
+  - path: values/secrets.yaml
+    optional: true
+`), 0644)
+
+	m, err := loadChartManifest(dir)
+	if err != nil {
+		t.Fatalf("loadChartManifest failed: %v", err)
+	}
+
+	args, skipped, err := m.valuesArgs(dir)
+	if err != nil {
+		t.Fatalf("valuesArgs failed: %v", err)
+	}
+	want := []string{
+		"-f", filepath.Join(dir, "values", "base.yaml"),
+		"-f", filepath.Join(dir, "values", "ci.yaml"),
+	}
+	if !slices.Equal(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+	if !slices.Equal(skipped, []string{"values/secrets.yaml"}) {
+		t.Errorf("skipped = %v", skipped)
+	}
+
+	for _, bad := range []ValuesFile{
+		{Path: "values/missing.yaml"},
+		{Path: "../other/values.yaml"},
+		{Path: "/etc/passwd"},
+	} {
+		m := &ChartManifest{ValuesFiles: []ValuesFile{bad}}
+		if _, _, err := m.valuesArgs(dir); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestOverrideArgs(t *testing.T) {
+	chartPath := t.TempDir()
+
+	args, err := overrideArgs(chartPath)
+	if err != nil || args != nil {
+		t.Fatalf("expected no args without overrides, got %v (%v)", args, err)
+	}
+
+	dir := filepath.Join(chartPath, ".kube-parcel")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "overrides.yaml"), []byte("values: [values-0.yaml]\nset: [a=1, b=2]\n"), 0644)
+
+	args, err = overrideArgs(chartPath)
+	if err != nil {
+		t.Fatalf("overrideArgs failed: %v", err)
+	}
+	want := []string{"-f", filepath.Join(dir, "values-0.yaml"), "--set", "a=1", "--set", "b=2"}
+	if !slices.Equal(args, want) {
+		t.Errorf("expected %v, got %v", want, args)
+	}
+
+	os.WriteFile(filepath.Join(dir, "overrides.yaml"), []byte("values: [../values.yaml]\n"), 0644)
+	if _, err := overrideArgs(chartPath); err == nil {
+		t.Error("expected error for values override outside the overrides directory")
+	}
+}
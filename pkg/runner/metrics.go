@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ChartMetric is one line of the metrics file: a chart's timings in a single run
+type ChartMetric struct {
+	RunID          string    `json:"run_id"`
+	Timestamp      time.Time `json:"timestamp"`
+	Chart          string    `json:"chart"`
+	Phase          string    `json:"phase"`
+	InstallSeconds float64   `json:"install_seconds"`
+	TestSeconds    float64   `json:"test_seconds"`
+	Images         int       `json:"images"`
+	BundleBytes    int64     `json:"bundle_bytes"`
+}
+
+// newRunID returns a sortable, unique run identifier like 20250101T120000-1a2b3c4d
+func newRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// chartMetrics builds one metric per chart for the finished run, ordered by chart name
+func (s *Server) chartMetrics(now time.Time) []ChartMetric {
+	images, _ := s.state.GetCounts()
+	statuses := s.helm.GetChartsStatus()
+
+	names := make([]string, 0, len(statuses))
+	for name := range statuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]ChartMetric, 0, len(names))
+	for _, name := range names {
+		install, test := s.helm.chartDurations(name)
+		metrics = append(metrics, ChartMetric{
+			RunID:          s.runID,
+			Timestamp:      now,
+			Chart:          name,
+			Phase:          statuses[name].Phase,
+			InstallSeconds: install.Seconds(),
+			TestSeconds:    test.Seconds(),
+			Images:         images,
+			BundleBytes:    s.bundleBytes,
+		})
+	}
+	return metrics
+}
+
+// appendMetrics appends the run's chart metrics as JSON lines to the metrics file.
+// A relative path is resolved against the artifacts directory so the file can be downloaded.
+func (s *Server) appendMetrics() {
+	if s.metricsFile == "" {
+		return
+	}
+
+	path := s.metricsFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.helm.ArtifactsDir, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Warn("Failed to create metrics directory", "path", path, "error", err)
+		return
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		slog.Warn("Failed to open metrics file", "path", path, "error", err)
+		return
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, m := range s.chartMetrics(time.Now()) {
+		if err := enc.Encode(m); err != nil {
+			slog.Warn("Failed to write metrics", "path", path, "error", err)
+			return
+		}
+	}
+	slog.Info("Appended run metrics", "path", path, "run_id", s.runID)
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_AppendMetrics(t *testing.T) {
+	s := newTestServer()
+	s.state = NewStateMachine()
+	s.state.IncrementImages()
+	s.helm = NewHelmManager(io.Discard)
+	s.helm.ArtifactsDir = t.TempDir()
+	s.metricsFile = "metrics.jsonl"
+	s.runID = "run-1"
+	s.bundleBytes = 2048
+
+	start := time.Now().Add(-time.Minute)
+	s.helm.updateStatus("web", "Installing", "")
+	s.helm.updateStatus("web", "Testing", "")
+	s.helm.updateStatus("web", "Succeeded", "")
+	s.helm.mu.Lock()
+	s.helm.startedAt["web"] = start
+	s.helm.testedAt["web"] = start.Add(40 * time.Second)
+	s.helm.endedAt["web"] = start.Add(50 * time.Second)
+	s.helm.mu.Unlock()
+	s.helm.updateStatus("db", "Installing", "")
+	s.helm.updateStatus("db", "Failed", "Install failed")
+
+	s.appendMetrics()
+	s.runID = "run-2"
+	s.appendMetrics()
+
+	f, err := os.Open(filepath.Join(s.helm.ArtifactsDir, "metrics.jsonl"))
+	if err != nil {
+		t.Fatalf("metrics file not written: %v", err)
+	}
+	defer f.Close()
+
+	var metrics []ChartMetric
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m ChartMetric
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			t.Fatalf("invalid metrics line %q: %v", scanner.Text(), err)
+		}
+		metrics = append(metrics, m)
+	}
+
+	if len(metrics) != 4 {
+		t.Fatalf("expected 4 lines (2 charts x 2 runs), got %d", len(metrics))
+	}
+	db, web := metrics[0], metrics[1]
+	if db.Chart != "db" || db.Phase != "Failed" || db.TestSeconds != 0 {
+		t.Errorf("unexpected db metric: %+v", db)
+	}
+	if web.Chart != "web" || web.InstallSeconds != 40 || web.TestSeconds != 10 {
+		t.Errorf("unexpected web timings: %+v", web)
+	}
+	if web.RunID != "run-1" || web.Images != 1 || web.BundleBytes != 2048 {
+		t.Errorf("unexpected web run data: %+v", web)
+	}
+	if metrics[3].RunID != "run-2" {
+		t.Errorf("expected second run to be appended, got %+v", metrics[3])
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+	a, b := newRunID(), newRunID()
+	if a == b || !strings.Contains(a, "-") {
+		t.Errorf("expected unique run IDs, got %q and %q", a, b)
+	}
+}
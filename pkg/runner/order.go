@@ -0,0 +1,196 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+	"gopkg.in/yaml.v3"
+)
+
+// loadRunManifest reads the run-level manifest at the top of the charts directory (next to
+// the chart directories), returning an empty manifest if there is none
+func loadRunManifest(chartsDir string) (*shared.RunManifest, error) {
+	for _, name := range manifestFiles {
+		data, err := os.ReadFile(filepath.Join(chartsDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var m shared.RunManifest
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return &m, nil
+	}
+	return &shared.RunManifest{}, nil
+}
+
+// orderCharts sorts charts so every chart comes after the charts it depends on and the charts
+// listed in order keep that sequence. Among the charts ready to install, those listed in order
+// go first; the rest keep their discovery (directory) order. Unknown chart names and cycles
+// (through dependsOn or order) are errors. The directory name of a chart with cases stands
+// for all of its cases; dependencies are returned expanded to chart names.
+func orderCharts(charts []chartSpec, order []string) ([]chartSpec, error) {
+	expand := make(map[string][]string, len(charts))
+	for _, chart := range charts {
+		expand[chart.Name] = []string{chart.Name}
+		if chart.Dir != "" && chart.Dir != chart.Name {
+			expand[chart.Dir] = append(expand[chart.Dir], chart.Name)
+		}
+	}
+
+	var sequence []string
+	for _, name := range order {
+		names, ok := expand[name]
+		if !ok {
+			return nil, fmt.Errorf("order lists unknown chart %q", name)
+		}
+		for _, name := range names {
+			if slices.Contains(sequence, name) {
+				return nil, fmt.Errorf("order lists chart %q more than once", name)
+			}
+			sequence = append(sequence, name)
+		}
+	}
+	order = sequence
+
+	rank := make(map[string]int, len(charts))
+	for i, chart := range charts {
+		rank[chart.Name] = len(order) + i
+	}
+	for i, name := range order {
+		rank[name] = i
+	}
+
+	// after lists the charts each chart is sorted after: its dependencies and its
+	// predecessor in order
+	byName := make(map[string]chartSpec, len(charts))
+	after := make(map[string][]string, len(charts))
+	for _, chart := range charts {
+		var deps []string
+		for _, dep := range chart.DependsOn {
+			names, ok := expand[dep]
+			if !ok {
+				return nil, fmt.Errorf("chart %s depends on unknown chart %q", chart.Name, dep)
+			}
+			if slices.Contains(names, chart.Name) {
+				return nil, fmt.Errorf("chart %s depends on itself", chart.Name)
+			}
+			deps = append(deps, names...)
+		}
+		chart.DependsOn = deps
+		byName[chart.Name] = chart
+		after[chart.Name] = deps
+	}
+	for i := 1; i < len(order); i++ {
+		after[order[i]] = append(slices.Clone(after[order[i]]), order[i-1])
+	}
+
+	// Kahn's algorithm, always picking the best-ranked chart whose dependencies are placed
+	placed := make(map[string]bool, len(charts))
+	sorted := make([]chartSpec, 0, len(charts))
+	for len(sorted) < len(charts) {
+		next := ""
+		for _, chart := range charts {
+			if placed[chart.Name] || !allPlaced(after[chart.Name], placed) {
+				continue
+			}
+			if next == "" || rank[chart.Name] < rank[next] {
+				next = chart.Name
+			}
+		}
+		if next == "" {
+			return nil, fmt.Errorf("dependency cycle: %s", findCycle(charts, after, placed))
+		}
+		placed[next] = true
+		sorted = append(sorted, byName[next])
+	}
+	return sorted, nil
+}
+
+func allPlaced(names []string, placed map[string]bool) bool {
+	for _, name := range names {
+		if !placed[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// findCycle describes a cycle among the charts not yet placed, e.g. "a → b → a". Every
+// unplaced chart comes after another unplaced chart, so following them must loop.
+func findCycle(charts []chartSpec, after map[string][]string, placed map[string]bool) string {
+	start := ""
+	for _, chart := range charts {
+		if !placed[chart.Name] {
+			start = chart.Name
+			break
+		}
+	}
+
+	var path []string
+	seen := make(map[string]int)
+	for name := start; ; {
+		if i, ok := seen[name]; ok {
+			return strings.Join(append(path[i:], name), " → ")
+		}
+		seen[name] = len(path)
+		path = append(path, name)
+		for _, dep := range after[name] {
+			if !placed[dep] {
+				name = dep
+				break
+			}
+		}
+	}
+}
+
+// installTracker lets charts wait for the charts they depend on to finish installing
+type installTracker struct {
+	mu       sync.Mutex
+	done     map[string]chan struct{}
+	deployed map[string]bool
+}
+
+func newInstallTracker(charts []chartSpec) *installTracker {
+	t := &installTracker{
+		done:     make(map[string]chan struct{}, len(charts)),
+		deployed: make(map[string]bool, len(charts)),
+	}
+	for _, chart := range charts {
+		t.done[chart.Name] = make(chan struct{})
+	}
+	return t
+}
+
+// finish records that a chart's install ended (or will never start) and wakes its dependents
+func (t *installTracker) finish(chart string, deployed bool) {
+	t.mu.Lock()
+	t.deployed[chart] = deployed
+	t.mu.Unlock()
+	close(t.done[chart])
+}
+
+// wait blocks until the given charts finished installing and returns the first that
+// failed to deploy, or "" if all of them were deployed
+func (t *installTracker) wait(charts []string) string {
+	for _, chart := range charts {
+		<-t.done[chart]
+		t.mu.Lock()
+		deployed := t.deployed[chart]
+		t.mu.Unlock()
+		if !deployed {
+			return chart
+		}
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrderCharts(t *testing.T) {
+	charts := func(deps map[string][]string, names ...string) []chartSpec {
+		var specs []chartSpec
+		for _, name := range names {
+			specs = append(specs, chartSpec{Name: name, DependsOn: deps[name]})
+		}
+		return specs
+	}
+
+	tests := []struct {
+		name    string
+		charts  []chartSpec
+		order   []string
+		want    []string
+		wantErr string
+	}{
+		{"directory order", charts(nil, "a", "b", "c"), nil, []string{"a", "b", "c"}, ""},
+		{"explicit order first", charts(nil, "a", "b", "c", "d"), []string{"c", "a"}, []string{"c", "a", "b", "d"}, ""},
+		{"dependencies first", charts(map[string][]string{"a": {"db"}, "b": {"a"}}, "a", "b", "db"), nil, []string{"db", "a", "b"}, ""},
+		{"order waits for dependencies", charts(map[string][]string{"api": {"db"}}, "api", "db", "web"), []string{"api", "web"},
+			[]string{"db", "api", "web"}, ""},
+		{"order against dependency", charts(map[string][]string{"a": {"b"}}, "a", "b"), []string{"a", "b"}, nil,
+			"dependency cycle: a → b → a"},
+		{"directory stands for its cases", []chartSpec{
+			{Name: "app/pg", Dir: "app"}, {Name: "app/mysql", Dir: "app"}, {Name: "db", Dir: "db"}, {Name: "web", Dir: "web", DependsOn: []string{"app"}},
+		}, []string{"db", "app"}, []string{"db", "app/pg", "app/mysql", "web"}, ""},
+		{"unknown order entry", charts(nil, "a"), []string{"b"}, nil, `unknown chart "b"`},
+		{"duplicate order entry", charts(nil, "a", "b"), []string{"a", "a"}, nil, "more than once"},
+		{"unknown dependency", charts(map[string][]string{"a": {"cache"}}, "a"), nil, nil, `a depends on unknown chart "cache"`},
+		{"self dependency", charts(map[string][]string{"a": {"a"}}, "a"), nil, nil, "depends on itself"},
+		{"cycle", charts(map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}}, "a", "b", "c", "d"), nil, nil,
+			"dependency cycle: a → b → c → a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted, err := orderCharts(tt.charts, tt.order)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("orderCharts failed: %v", err)
+			}
+			var names []string
+			for _, chart := range sorted {
+				names = append(names, chart.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("order = %v, want %v", names, tt.want)
+			}
+
+		})
+	}
+}
+
+func TestOrderCharts_ExpandsCaseDependencies(t *testing.T) {
+	sorted, err := orderCharts([]chartSpec{
+		{Name: "web", Dir: "web", DependsOn: []string{"app"}}, {Name: "app/pg", Dir: "app"}, {Name: "app/mysql", Dir: "app"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("orderCharts failed: %v", err)
+	}
+	if web := sorted[len(sorted)-1]; web.Name != "web" || !slices.Equal(web.DependsOn, []string{"app/pg", "app/mysql"}) {
+		t.Errorf("expected web last, depending on every app case, got %+v", web)
+	}
+}
+
+func TestHelmManager_DiscoverChartsOrder(t *testing.T) {
+	dir := t.TempDir()
+	for name, manifest := range map[string]string{"api": "dependsOn: [db]\n", "db": "", "web": ""} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(path, 0755)
+		os.WriteFile(filepath.Join(path, "Chart.yaml"), []byte("name: "+name+"\n"), 0644)
+		os.WriteFile(filepath.Join(path, "kube-parcel.yaml"), []byte(manifest), 0644)
+	}
+	os.WriteFile(filepath.Join(dir, "kube-parcel.yaml"), []byte("order: [web, api]\n"), 0644)
+
+	hm := &HelmManager{chartsDir: dir}
+	charts, err := hm.discoverCharts()
+	if err != nil {
+		t.Fatalf("discoverCharts failed: %v", err)
+	}
+	var names []string
+	for _, chart := range charts {
+		names = append(names, chart.Name)
+	}
+	if want := []string{"web", "db", "api"}; !slices.Equal(names, want) {
+		t.Errorf("install order = %v, want %v", names, want)
+	}
+
+	os.WriteFile(filepath.Join(dir, "db", "kube-parcel.yaml"), []byte("dependsOn: [api]\n"), 0644)
+	if _, err := hm.discoverCharts(); err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Errorf("expected a dependency cycle error, got %v", err)
+	}
+}
+
+func TestInstallTracker(t *testing.T) {
+	tracker := newInstallTracker([]chartSpec{{Name: "db"}, {Name: "cache"}, {Name: "api"}})
+
+	result := make(chan string)
+	go func() { result <- tracker.wait([]string{"db", "cache"}) }()
+
+	tracker.finish("db", true)
+	select {
+	case dep := <-result:
+		t.Fatalf("wait returned %q before all dependencies finished", dep)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	tracker.finish("cache", false)
+	if dep := <-result; dep != "cache" {
+		t.Errorf("expected failed dependency cache, got %q", dep)
+	}
+	if dep := tracker.wait([]string{"db"}); dep != "" {
+		t.Errorf("expected deployed dependency to pass, got %q", dep)
+	}
+	if dep := tracker.wait(nil); dep != "" {
+		t.Errorf("expected no dependencies to pass, got %q", dep)
+	}
+}
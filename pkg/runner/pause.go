@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+// pauseGate halts a run once at a configured point until a client continues or aborts it
+type pauseGate struct {
+	mu     sync.Mutex
+	point  string    // Armed pause point ("" = never pause)
+	where  string    // Where the run is paused ("" = running)
+	resume chan bool // Receives true to continue, false to abort
+}
+
+// arm sets the point the next run pauses at ("" disables pausing)
+func (g *pauseGate) arm(point string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.point = point
+}
+
+// wait blocks if the gate is armed for point, disarming it so a run pauses at most once.
+// notify is called once the run is paused. It returns false if the run was aborted.
+func (g *pauseGate) wait(point, where string, notify func(where string)) bool {
+	g.mu.Lock()
+	if g.point == "" || g.point != point || g.resume != nil {
+		g.mu.Unlock()
+		return true
+	}
+	resume := make(chan bool, 1)
+	g.point, g.where, g.resume = "", where, resume
+	g.mu.Unlock()
+
+	notify(where)
+	return <-resume
+}
+
+// release resumes a paused run, continuing or aborting it. It reports false if the run isn't paused.
+func (g *pauseGate) release(proceed bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resume == nil {
+		return false
+	}
+	g.resume <- proceed
+	g.where, g.resume = "", nil
+	return true
+}
+
+// pausedAt describes where the run is paused ("" if it isn't)
+func (g *pauseGate) pausedAt() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.where
+}
+
+// pause blocks the run at point if the upload asked for it, broadcasting how to resume
+func (s *Server) pause(point, where string) bool {
+	return s.pauseGate.wait(point, where, func(where string) {
+		slog.Info("Run paused", "point", point, "where", where)
+		s.broadcastLog("runner", "warning",
+			fmt.Sprintf("Paused %s: POST /parcel/continue to proceed or /parcel/abort to stop", where))
+	})
+}
+
+// HandleContinue resumes a paused run
+func (s *Server) HandleContinue(w http.ResponseWriter, r *http.Request) {
+	s.handleRelease(w, r, true)
+}
+
+// HandleAbort stops a paused run, failing the remaining charts
+func (s *Server) HandleAbort(w http.ResponseWriter, r *http.Request) {
+	s.handleRelease(w, r, false)
+}
+
+func (s *Server) handleRelease(w http.ResponseWriter, r *http.Request, proceed bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	where := s.pauseGate.pausedAt()
+	if !s.pauseGate.release(proceed) {
+		http.Error(w, "Run is not paused", http.StatusConflict)
+		return
+	}
+
+	if proceed {
+		s.broadcastLog("runner", "info", fmt.Sprintf("Continuing run paused %s", where))
+	} else {
+		s.broadcastLog("runner", "warning", fmt.Sprintf("Run aborted while paused %s", where))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validPausePoint reports whether point is empty or a known pause point
+func validPausePoint(point string) bool {
+	switch point {
+	case "", shared.PauseOnInstall, shared.PauseOnTest, shared.PauseOnFailure:
+		return true
+	}
+	return false
+}
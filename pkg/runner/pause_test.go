@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func TestPauseGate(t *testing.T) {
+	var g pauseGate
+
+	if !g.wait(shared.PauseOnInstall, "after installing a", func(string) { t.Error("unexpected pause while disarmed") }) {
+		t.Error("expected a disarmed gate to proceed")
+	}
+	if g.release(true) {
+		t.Error("expected release to fail while not paused")
+	}
+
+	g.arm(shared.PauseOnFailure)
+	if !g.wait(shared.PauseOnInstall, "after installing a", func(string) { t.Error("unexpected pause at another point") }) {
+		t.Error("expected other points to proceed")
+	}
+
+	paused := make(chan string, 1)
+	result := make(chan bool, 1)
+	go func() {
+		result <- g.wait(shared.PauseOnFailure, "after a failed", func(where string) { paused <- where })
+	}()
+	if where := <-paused; where != "after a failed" || g.pausedAt() != where {
+		t.Errorf("expected to be paused after a failed, got %q (pausedAt %q)", where, g.pausedAt())
+	}
+	if !g.release(false) {
+		t.Fatal("expected release to resume the paused run")
+	}
+	if <-result {
+		t.Error("expected the run to be aborted")
+	}
+	if g.pausedAt() != "" {
+		t.Errorf("expected no pause after release, got %q", g.pausedAt())
+	}
+
+	// A run pauses at most once
+	if !g.wait(shared.PauseOnFailure, "after b failed", func(string) { t.Error("unexpected second pause") }) {
+		t.Error("expected the gate to be disarmed after pausing")
+	}
+}
+
+func TestServer_HandleContinueNotPaused(t *testing.T) {
+	s := &Server{}
+	w := httptest.NewRecorder()
+	s.HandleContinue(w, httptest.NewRequest(http.MethodPost, "/parcel/continue", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 when not paused, got %d", w.Code)
+	}
+}
+
+func TestServer_HandleUploadRejectsUnknownPausePoint(t *testing.T) {
+	s := NewServer()
+	req := httptest.NewRequest(http.MethodPost, "/parcel/upload", nil)
+	req.Header.Set(shared.HeaderPauseOn, "deploy")
+	w := httptest.NewRecorder()
+	s.HandleUpload(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown pause point, got %d", w.Code)
+	}
+	if s.state.Current() != shared.StateIdle {
+		t.Errorf("expected the server to stay idle, got %s", s.state.Current())
+	}
+}
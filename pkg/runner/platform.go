@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// maxImageMetadataSize bounds the tar entries kept in memory while looking for an image's
+// platforms; manifests, indexes and configs are far smaller, layers are skipped
+const maxImageMetadataSize = 4 << 20
+
+// ociDescriptor is the subset of an OCI descriptor needed to find an image's platforms
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Platform  *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	} `json:"platform"`
+}
+
+// imageConfig is the platform part of an image config blob
+type imageConfig struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant"`
+}
+
+func (c imageConfig) String() string {
+	platform := c.OS + "/" + c.Architecture
+	if c.Variant != "" {
+		platform += "/" + c.Variant
+	}
+	return platform
+}
+
+// imagePlatforms returns the platforms (os/arch[/variant]) an image tar provides: the
+// entries of a multi-arch index, or the config of a single-platform image. Both OCI
+// layouts and docker save tars are understood, optionally gzipped.
+func imagePlatforms(tarPath string) ([]string, error) {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(tarPath, ".gz") || strings.HasSuffix(tarPath, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || header.Size > maxImageMetadataSize {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(header.Name)] = data
+	}
+
+	var platforms []string
+	if index, ok := files["index.json"]; ok {
+		if err := indexPlatforms(files, index, &platforms, 0); err != nil {
+			return nil, err
+		}
+	} else if manifest, ok := files["manifest.json"]; ok {
+		var entries []struct {
+			Config string `json:"Config"`
+		}
+		if err := json.Unmarshal(manifest, &entries); err != nil {
+			return nil, fmt.Errorf("invalid manifest.json: %w", err)
+		}
+		for _, entry := range entries {
+			var config imageConfig
+			if err := json.Unmarshal(files[path.Clean(entry.Config)], &config); err != nil {
+				return nil, fmt.Errorf("invalid image config %s: %w", entry.Config, err)
+			}
+			platforms = append(platforms, config.String())
+		}
+	} else {
+		return nil, fmt.Errorf("no index.json or manifest.json")
+	}
+
+	slices.Sort(platforms)
+	return slices.Compact(platforms), nil
+}
+
+// indexPlatforms collects the platforms of an OCI index, descending into nested indexes
+// and reading the config of manifests that don't declare a platform
+func indexPlatforms(files map[string][]byte, data []byte, platforms *[]string, depth int) error {
+	if depth > 4 {
+		return fmt.Errorf("image index nested too deeply")
+	}
+	var index struct {
+		Manifests []ociDescriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return fmt.Errorf("invalid image index: %w", err)
+	}
+
+	for _, desc := range index.Manifests {
+		if p := desc.Platform; p != nil && p.OS != "" && p.OS != "unknown" {
+			*platforms = append(*platforms, imageConfig{p.OS, p.Architecture, p.Variant}.String())
+			continue
+		}
+		blob, ok := files[blobPath(desc.Digest)]
+		if !ok {
+			continue
+		}
+		if strings.Contains(desc.MediaType, "index") || strings.Contains(desc.MediaType, "manifest.list") {
+			if err := indexPlatforms(files, blob, platforms, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var manifest struct {
+			Config ociDescriptor `json:"config"`
+		}
+		if err := json.Unmarshal(blob, &manifest); err != nil {
+			return fmt.Errorf("invalid image manifest %s: %w", desc.Digest, err)
+		}
+		var config imageConfig
+		if err := json.Unmarshal(files[blobPath(manifest.Config.Digest)], &config); err != nil || config.OS == "" {
+			continue // Attestations and other artifacts have no platform
+		}
+		*platforms = append(*platforms, config.String())
+	}
+	return nil
+}
+
+// blobPath returns where an OCI layout stores the blob with the given digest
+func blobPath(digest string) string {
+	algorithm, hex, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algorithm, hex)
+}
+
+// platformMatches reports whether an image platform satisfies a requested one. The
+// variant is only compared when both specify it (linux/arm64 matches linux/arm64/v8).
+func platformMatches(want, have string) bool {
+	wantParts, haveParts := strings.Split(want, "/"), strings.Split(have, "/")
+	if len(wantParts) < 2 || len(haveParts) < 2 {
+		return want == have
+	}
+	if wantParts[0] != haveParts[0] || wantParts[1] != haveParts[1] {
+		return false
+	}
+	return len(wantParts) < 3 || len(haveParts) < 3 || wantParts[2] == haveParts[2]
+}
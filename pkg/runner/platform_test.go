@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeImageTar writes files into an image tar, gzipped when the name ends in .gz
+func writeImageTar(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if filepath.Ext(name) == ".gz" {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	return path
+}
+
+func TestImagePlatforms(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "docker save",
+			file: "nginx.tar",
+			files: map[string]string{
+				"manifest.json": `[{"Config":"abc.json","RepoTags":["nginx:1.27"],"Layers":[]}]`,
+				"abc.json":      `{"os":"linux","architecture":"arm64","variant":"v8"}`,
+			},
+			want: []string{"linux/arm64/v8"},
+		},
+		{
+			name: "multi-arch index",
+			file: "nginx.tar.gz",
+			files: map[string]string{
+				"index.json": `{"manifests":[{"mediaType":"application/vnd.oci.image.index.v1+json","digest":"sha256:aa"}]}`,
+				"blobs/sha256/aa": `{"manifests":[
+					{"digest":"sha256:b1","platform":{"os":"linux","architecture":"amd64"}},
+					{"digest":"sha256:b2","platform":{"os":"linux","architecture":"arm64","variant":"v8"}},
+					{"digest":"sha256:b3","platform":{"os":"unknown","architecture":"unknown"}}]}`,
+			},
+			want: []string{"linux/amd64", "linux/arm64/v8"},
+		},
+		{
+			name: "single manifest without platform",
+			file: "app.tar",
+			files: map[string]string{
+				"index.json":      `{"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:m1"}]}`,
+				"blobs/sha256/m1": `{"config":{"digest":"sha256:c1"}}`,
+				"blobs/sha256/c1": `{"os":"linux","architecture":"amd64"}`,
+			},
+			want: []string{"linux/amd64"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := imagePlatforms(writeImageTar(t, tc.file, tc.files))
+			if err != nil {
+				t.Fatalf("imagePlatforms failed: %v", err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := imagePlatforms(writeImageTar(t, "junk.tar", map[string]string{"README": "hi"})); err == nil {
+		t.Error("expected an error for a tar that isn't an image")
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	tests := []struct {
+		want, have string
+		match      bool
+	}{
+		{"linux/amd64", "linux/amd64", true},
+		{"linux/arm64", "linux/arm64/v8", true},
+		{"linux/arm64/v8", "linux/arm64", true},
+		{"linux/arm/v7", "linux/arm/v6", false},
+		{"linux/amd64", "linux/arm64", false},
+		{"linux/amd64", "windows/amd64", false},
+	}
+	for _, tc := range tests {
+		if got := platformMatches(tc.want, tc.have); got != tc.match {
+			t.Errorf("platformMatches(%q, %q) = %v, want %v", tc.want, tc.have, got, tc.match)
+		}
+	}
+}
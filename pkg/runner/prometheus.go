@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+// installBuckets are the upper bounds, in seconds, of the install duration histogram
+var installBuckets = []float64{5, 10, 30, 60, 120, 300, 600, 1200}
+
+// histogram is a Prometheus histogram over installBuckets
+type histogram struct {
+	buckets []uint64 // Observations per bucket (not cumulative)
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.buckets == nil {
+		h.buckets = make([]uint64, len(installBuckets))
+	}
+	if i, _ := slices.BinarySearch(installBuckets, v); i < len(installBuckets) {
+		h.buckets[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// testResult labels the helm test counter
+type testResult struct {
+	chart  string
+	result string // "passed" or "failed"
+}
+
+// promMetrics accumulates the runner's Prometheus metrics over its lifetime, across runs
+type promMetrics struct {
+	mu               sync.Mutex
+	imagesExtracted  int
+	chartsExtracted  int
+	installDurations map[string]*histogram // By chart
+	testResults      map[testResult]int
+	k3sReady         time.Duration // How long K3s took to become ready (0 until it has)
+}
+
+func newPromMetrics() *promMetrics {
+	return &promMetrics{
+		installDurations: make(map[string]*histogram),
+		testResults:      make(map[testResult]int),
+	}
+}
+
+func (m *promMetrics) countImage() {
+	m.mu.Lock()
+	m.imagesExtracted++
+	m.mu.Unlock()
+}
+
+func (m *promMetrics) countChart() {
+	m.mu.Lock()
+	m.chartsExtracted++
+	m.mu.Unlock()
+}
+
+func (m *promMetrics) setK3sReady(d time.Duration) {
+	m.mu.Lock()
+	m.k3sReady = d
+	m.mu.Unlock()
+}
+
+// observePhase records a chart's phase change: a finished install (whether it succeeded
+// or not) and a finished helm test
+func (m *promMetrics) observePhase(hm *HelmManager, chart, from, to string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case from == "Installing" && (to == "Deployed" || to == "WaitingReadiness" || to == "Failed"):
+		install, _ := hm.chartDurations(chart)
+		h := m.installDurations[chart]
+		if h == nil {
+			h = &histogram{}
+			m.installDurations[chart] = h
+		}
+		h.observe(install.Seconds())
+	case from == "Testing" && to == "Succeeded":
+		m.testResults[testResult{chart, "passed"}]++
+	case from == "Testing" && to == "Failed":
+		m.testResults[testResult{chart, "failed"}]++
+	}
+}
+
+// labelEscaper escapes label values for the text exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// write renders the metrics in the Prometheus text exposition format
+func (m *promMetrics) write(w io.Writer, current shared.State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP kube_parcel_state Current runner state (1 for the current state, 0 otherwise).")
+	fmt.Fprintln(w, "# TYPE kube_parcel_state gauge")
+	for state := shared.StateIdle; state <= shared.StateFailed; state++ {
+		value := 0
+		if state == current {
+			value = 1
+		}
+		fmt.Fprintf(w, "kube_parcel_state{state=%q} %d\n", state.String(), value)
+	}
+
+	fmt.Fprintln(w, "# HELP kube_parcel_images_extracted_total Images extracted from uploaded parcels.")
+	fmt.Fprintln(w, "# TYPE kube_parcel_images_extracted_total counter")
+	fmt.Fprintf(w, "kube_parcel_images_extracted_total %d\n", m.imagesExtracted)
+	fmt.Fprintln(w, "# HELP kube_parcel_charts_extracted_total Charts extracted from uploaded parcels.")
+	fmt.Fprintln(w, "# TYPE kube_parcel_charts_extracted_total counter")
+	fmt.Fprintf(w, "kube_parcel_charts_extracted_total %d\n", m.chartsExtracted)
+
+	fmt.Fprintln(w, "# HELP kube_parcel_chart_install_duration_seconds Time helm install took per chart, including failed installs.")
+	fmt.Fprintln(w, "# TYPE kube_parcel_chart_install_duration_seconds histogram")
+	charts := make([]string, 0, len(m.installDurations))
+	for chart := range m.installDurations {
+		charts = append(charts, chart)
+	}
+	slices.Sort(charts)
+	for _, chart := range charts {
+		h := m.installDurations[chart]
+		label := labelEscaper.Replace(chart)
+		var cumulative uint64
+		for i, le := range installBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(w, "kube_parcel_chart_install_duration_seconds_bucket{chart=\"%s\",le=\"%s\"} %d\n", label, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(w, "kube_parcel_chart_install_duration_seconds_bucket{chart=\"%s\",le=\"+Inf\"} %d\n", label, h.count)
+		fmt.Fprintf(w, "kube_parcel_chart_install_duration_seconds_sum{chart=\"%s\"} %s\n", label, formatFloat(h.sum))
+		fmt.Fprintf(w, "kube_parcel_chart_install_duration_seconds_count{chart=\"%s\"} %d\n", label, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP kube_parcel_helm_tests_total Finished helm test runs per chart, by result.")
+	fmt.Fprintln(w, "# TYPE kube_parcel_helm_tests_total counter")
+	results := make([]testResult, 0, len(m.testResults))
+	for key := range m.testResults {
+		results = append(results, key)
+	}
+	slices.SortFunc(results, func(a, b testResult) int {
+		return strings.Compare(a.chart+"\x00"+a.result, b.chart+"\x00"+b.result)
+	})
+	for _, key := range results {
+		fmt.Fprintf(w, "kube_parcel_helm_tests_total{chart=\"%s\",result=\"%s\"} %d\n", labelEscaper.Replace(key.chart), key.result, m.testResults[key])
+	}
+
+	if m.k3sReady > 0 {
+		fmt.Fprintln(w, "# HELP kube_parcel_k3s_ready_seconds Time K3s took to become ready.")
+		fmt.Fprintln(w, "# TYPE kube_parcel_k3s_ready_seconds gauge")
+		fmt.Fprintf(w, "kube_parcel_k3s_ready_seconds %s\n", formatFloat(m.k3sReady.Seconds()))
+	}
+}
+
+// HandleMetrics serves Prometheus metrics
+func (s *Server) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.metrics.write(w, s.state.Current())
+}
@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func TestServer_HandleMetrics(t *testing.T) {
+	s := newTestServer()
+	s.state = NewStateMachine()
+	s.state.Transition(shared.StateTransferring)
+	s.metrics = newPromMetrics()
+	s.helm = NewHelmManager(io.Discard)
+	s.helm.OnPhase(func(chart, from, to string) {
+		s.metrics.observePhase(s.helm, chart, from, to)
+	})
+
+	s.metrics.countImage()
+	s.metrics.countImage()
+	s.metrics.countChart()
+	s.metrics.setK3sReady(42 * time.Second)
+
+	s.helm.updateStatus("web", "Installing", "")
+	s.helm.mu.Lock()
+	s.helm.startedAt["web"] = time.Now().Add(-20 * time.Second)
+	s.helm.mu.Unlock()
+	s.helm.updateStatus("web", "Deployed", "")
+	s.helm.updateStatus("web", "Testing", "")
+	s.helm.updateStatus("web", "Succeeded", "")
+	s.helm.updateStatus("db", "Installing", "")
+	s.helm.updateStatus("db", "Failed", "Install failed")
+
+	w := httptest.NewRecorder()
+	s.HandleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	for _, want := range []string{
+		`kube_parcel_state{state="TRANSFERRING"} 1`,
+		`kube_parcel_state{state="IDLE"} 0`,
+		"kube_parcel_images_extracted_total 2",
+		"kube_parcel_charts_extracted_total 1",
+		`kube_parcel_chart_install_duration_seconds_bucket{chart="web",le="10"} 0`,
+		`kube_parcel_chart_install_duration_seconds_bucket{chart="web",le="30"} 1`,
+		`kube_parcel_chart_install_duration_seconds_bucket{chart="web",le="+Inf"} 1`,
+		`kube_parcel_chart_install_duration_seconds_count{chart="db"} 1`,
+		`kube_parcel_helm_tests_total{chart="web",result="passed"} 1`,
+		"kube_parcel_k3s_ready_seconds 42",
+		"# TYPE kube_parcel_chart_install_duration_seconds histogram",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `result="failed"`) {
+		t.Errorf("a failed install counted as a failed test:\n%s", body)
+	}
+}
@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+// pullSecretName is the dockerconfigjson secret created in each chart namespace from --pull-secret
+const pullSecretName = "kube-parcel-pull-secret"
+
+// pullSecretManifest renders the dockerconfigjson secret holding the registry credentials
+func pullSecretManifest(namespace string, auths map[string]shared.RegistryAuth) ([]byte, error) {
+	type dockerAuth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	cfg := struct {
+		Auths map[string]dockerAuth `json:"auths"`
+	}{Auths: make(map[string]dockerAuth, len(auths))}
+	for host, auth := range auths {
+		cfg.Auths[host] = dockerAuth{
+			Username: auth.Username,
+			Password: auth.Password,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password)),
+		}
+	}
+	dockerConfig, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "kubernetes.io/dockerconfigjson",
+		"metadata":   map[string]string{"name": pullSecretName, "namespace": namespace},
+		"data":       map[string]string{".dockerconfigjson": base64.StdEncoding.EncodeToString(dockerConfig)},
+	})
+}
+
+// ensurePullSecret creates the pull secret in a namespace and adds it to the imagePullSecrets
+// of the namespace's default serviceaccount, so pods pull from the private registries
+// without the chart referencing the secret
+func (hm *HelmManager) ensurePullSecret(namespace string) error {
+	manifest, err := pullSecretManifest(namespace, hm.PullSecrets)
+	if err != nil {
+		return err
+	}
+	apply := exec.Command("kubectl", "apply", "-f", "-")
+	apply.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+	apply.Stdin = bytes.NewReader(manifest)
+	if out, err := apply.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create pull secret: %v (output: %s)", err, strings.TrimSpace(string(out)))
+	}
+
+	patch := fmt.Sprintf(`{"imagePullSecrets":[{"name":%q}]}`, pullSecretName)
+	cmd := exec.Command("kubectl", "patch", "serviceaccount", "default", "-n", namespace, "-p", patch)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to patch default serviceaccount: %v (output: %s)", err, strings.TrimSpace(string(out)))
+	}
+
+	slog.Info("Pull secret ready", "namespace", namespace, "registries", len(hm.PullSecrets))
+	return nil
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/tiborv/kube-parcel/pkg/shared"
+)
+
+func TestPullSecretManifest(t *testing.T) {
+	data, err := pullSecretManifest("team-a", map[string]shared.RegistryAuth{"ghcr.io": {Username: "ci", Password: "t0k:en"}})
+	if err != nil {
+		t.Fatalf("pullSecretManifest failed: %v", err)
+	}
+
+	var secret struct {
+		Type     string            `json:"type"`
+		Metadata map[string]string `json:"metadata"`
+		Data     map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(data, &secret); err != nil {
+		t.Fatalf("invalid secret JSON: %v", err)
+	}
+	if secret.Type != "kubernetes.io/dockerconfigjson" || secret.Metadata["name"] != pullSecretName || secret.Metadata["namespace"] != "team-a" {
+		t.Errorf("unexpected secret: %s", data)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(secret.Data[".dockerconfigjson"])
+	if err != nil {
+		t.Fatalf("invalid .dockerconfigjson encoding: %v", err)
+	}
+	var cfg struct {
+		Auths map[string]struct{ Username, Password, Auth string } `json:"auths"`
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		t.Fatalf("invalid .dockerconfigjson: %v", err)
+	}
+	auth := cfg.Auths["ghcr.io"]
+	if auth.Username != "ci" || auth.Password != "t0k:en" || auth.Auth != base64.StdEncoding.EncodeToString([]byte("ci:t0k:en")) {
+		t.Errorf("unexpected ghcr.io auth: %+v", auth)
+	}
+}
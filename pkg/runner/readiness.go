@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/tiborv/kube-parcel/pkg/config"
+)
+
+// args returns the kubectl wait arguments for the condition
+func (c WaitCondition) args(namespace string, timeout time.Duration) []string {
+	if c.Namespace != "" {
+		namespace = c.Namespace
+	}
+	if c.Timeout > 0 {
+		timeout = c.Timeout
+	}
+	return []string{"wait", c.Resource, "--for=" + c.For, "--namespace", namespace, "--timeout=" + timeout.String()}
+}
+
+// waitForReadiness runs the chart's waitFor conditions once it is installed, in order.
+// A condition that doesn't hold in time marks the chart ReadinessFailed and is returned.
+func (hm *HelmManager) waitForReadiness(ctx context.Context, chart chartSpec, output *chartOutput) error {
+	manifest, err := loadChartManifest(chart.Path)
+	if err != nil || len(manifest.WaitFor) == 0 {
+		return nil // A manifest error was already reported by the install
+	}
+
+	fail := func(phase, errMsg string) error {
+		slog.Error("Chart readiness failed", "chart", chart.Name, "error", errMsg)
+		fmt.Fprintf(hm.logger, "❌ %s\n", errMsg)
+		hm.updateStatus(chart.Name, phase, errMsg)
+		return fmt.Errorf("readiness wait failed: %s", errMsg)
+	}
+
+	hm.updateStatus(chart.Name, "WaitingReadiness", fmt.Sprintf("Waiting for %d readiness condition(s)", len(manifest.WaitFor)))
+	for _, c := range manifest.WaitFor {
+		if c.Resource == "" || c.For == "" {
+			return fail("ReadinessFailed", "Readiness failed: waitFor entries need a resource and a for condition")
+		}
+		desc := fmt.Sprintf("%s (%s)", c.Resource, c.For)
+		slog.Info("Waiting for readiness condition", "chart", chart.Name, "resource", c.Resource, "for", c.For)
+		fmt.Fprintf(hm.logger, "Waiting for %s before testing %s\n", desc, chart.Name)
+
+		cmd := exec.CommandContext(ctx, "kubectl", c.args(chart.Namespace, hm.InstallTimeout)...)
+		cmd.Env = append(os.Environ(), "KUBECONFIG="+config.DefaultKubeconfigPath)
+		cmd.Stdout = io.MultiWriter(hm.logger, output)
+		cmd.Stderr = io.MultiWriter(hm.logger, output)
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return fail("Failed", fmt.Sprintf("Readiness wait aborted: %v", context.Cause(ctx)))
+			}
+			return fail("ReadinessFailed", withOutputTail(fmt.Sprintf("Readiness failed: %s: %v", desc, err), output))
+		}
+	}
+
+	fmt.Fprintf(hm.logger, "✅ Readiness conditions met for %s\n", chart.Name)
+	return nil
+}
@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWaitCondition_Args(t *testing.T) {
+	tests := []struct {
+		name string
+		cond WaitCondition
+		want []string
+	}{
+		{"defaults", WaitCondition{Resource: "job/migrate", For: "condition=complete"},
+			[]string{"wait", "job/migrate", "--for=condition=complete", "--namespace", "apps", "--timeout=5m0s"}},
+		{"overrides", WaitCondition{Resource: "deployment/web", For: "condition=available", Namespace: "web", Timeout: 30 * time.Second},
+			[]string{"wait", "deployment/web", "--for=condition=available", "--namespace", "web", "--timeout=30s"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.args("apps", 5*time.Minute); !slices.Equal(got, tt.want) {
+				t.Errorf("args() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadChartManifest_WaitFor(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "kube-parcel.yaml"), []byte(`waitFor:
+  - resource: job/migrate
+    for: condition=complete
+    timeout: 2m
+`), 0644)
+
+	manifest, err := loadChartManifest(dir)
+	if err != nil {
+		t.Fatalf("loadChartManifest failed: %v", err)
+	}
+	want := []WaitCondition{{Resource: "job/migrate", For: "condition=complete", Timeout: 2 * time.Minute}}
+	if !slices.Equal(manifest.WaitFor, want) {
+		t.Errorf("WaitFor = %+v, want %+v", manifest.WaitFor, want)
+	}
+}
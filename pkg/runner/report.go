@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JUnit XML report, as consumed by GitLab, Jenkins and most CI systems
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	ID        string          `xml:"id,attr,omitempty"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       float64         `xml:"time,attr"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport renders the run's chart results as a JUnit document with one testcase per chart.
+// A chart's time covers its install and tests, which are also reported separately as the
+// install_seconds and test_seconds properties; charts that never finished are skipped.
+func (s *Server) junitReport(now time.Time) ([]byte, error) {
+	statuses := s.helm.GetChartsStatus()
+	names := make([]string, 0, len(statuses))
+	for name := range statuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	suite := junitTestSuite{
+		Name:      "kube-parcel",
+		ID:        s.runID,
+		Timestamp: now.UTC().Format(time.RFC3339),
+	}
+	for _, name := range names {
+		status := statuses[name]
+		install, test := s.helm.chartDurations(name)
+		tc := junitTestCase{
+			Name:      name,
+			Classname: "helm." + name,
+			Time:      (install + test).Round(time.Millisecond).Seconds(),
+			Properties: []junitProperty{
+				{Name: "install_seconds", Value: formatSeconds(install)},
+				{Name: "test_seconds", Value: formatSeconds(test)},
+			},
+		}
+		if len(status.Artifacts) > 0 {
+			tc.SystemOut = "Artifacts: " + strings.Join(status.Artifacts, ", ")
+		}
+
+		switch status.Phase {
+		case "Succeeded":
+		case "Skipped":
+			tc.Skipped = &junitMessage{Message: status.Message}
+			suite.Skipped++
+		case "Failed":
+			tc.Failure = &junitMessage{Message: status.Message, Type: "HelmFailure", Text: status.Message}
+			suite.Failures++
+		case "LintFailed":
+			tc.Failure = &junitMessage{Message: status.Message, Type: "HelmLintFailure", Text: status.Message}
+			suite.Failures++
+		case "ReadinessFailed":
+			tc.Failure = &junitMessage{Message: status.Message, Type: "ReadinessFailure", Text: status.Message}
+			suite.Failures++
+		default:
+			tc.Skipped = &junitMessage{Message: fmt.Sprintf("Chart did not finish (phase %s)", status.Phase)}
+			suite.Skipped++
+		}
+		suite.Tests++
+		suite.Time += tc.Time
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{
+		Name:     "kube-parcel",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// HandleReport serves the run's results as a report document (/parcel/report?format=junit)
+func (s *Server) HandleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "junit" {
+		http.Error(w, fmt.Sprintf("Unsupported report format %q (supported: junit)", format), http.StatusBadRequest)
+		return
+	}
+
+	data, err := s.junitReport(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(data)
+}
+
+// formatSeconds renders a duration as seconds with millisecond precision
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Round(time.Millisecond).Seconds(), 'f', -1, 64)
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_HandleReportJUnit(t *testing.T) {
+	s := newTestServer()
+	s.helm = NewHelmManager(io.Discard)
+	s.runID = "run-1"
+
+	start := time.Now().Add(-time.Minute)
+	s.helm.updateStatus("web", "Installing", "")
+	s.helm.updateStatus("web", "Testing", "")
+	s.helm.updateStatus("web", "Succeeded", "All tests passed")
+	s.helm.mu.Lock()
+	s.helm.startedAt["web"] = start
+	s.helm.testedAt["web"] = start.Add(40 * time.Second)
+	s.helm.endedAt["web"] = start.Add(50 * time.Second)
+	s.helm.mu.Unlock()
+	s.helm.updateStatus("db", "Installing", "")
+	s.helm.updateStatus("db", "Failed", "Install failed: timed out")
+	s.helm.updateStatus("cache", "Pending", "")
+	s.helm.updateStatus("queue", "Installing", "")
+	s.helm.updateStatus("queue", "ReadinessFailed", "Readiness failed: job/migrate (condition=complete)")
+
+	w := httptest.NewRecorder()
+	s.HandleReport(w, httptest.NewRequest(http.MethodGet, "/parcel/report?format=junit", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JUnit XML: %v", err)
+	}
+	if report.Tests != 4 || report.Failures != 2 || report.Skipped != 1 || len(report.Suites) != 1 {
+		t.Fatalf("unexpected totals: %+v", report)
+	}
+	if report.Suites[0].ID != "run-1" {
+		t.Errorf("expected suite id run-1, got %q", report.Suites[0].ID)
+	}
+
+	cases := make(map[string]junitTestCase)
+	for _, tc := range report.Suites[0].Cases {
+		cases[tc.Name] = tc
+	}
+	if web := cases["web"]; web.Failure != nil || web.Skipped != nil || web.Time != 50 {
+		t.Errorf("expected web to pass in 50s, got %+v", web)
+	}
+	props := make(map[string]string)
+	for _, p := range cases["web"].Properties {
+		props[p.Name] = p.Value
+	}
+	if props["install_seconds"] != "40" || props["test_seconds"] != "10" {
+		t.Errorf("expected web install/test of 40s/10s, got %v", props)
+	}
+	if db := cases["db"]; db.Failure == nil || db.Failure.Message != "Install failed: timed out" {
+		t.Errorf("expected db failure message, got %+v", db.Failure)
+	}
+	if queue := cases["queue"]; queue.Failure == nil || queue.Failure.Type != "ReadinessFailure" {
+		t.Errorf("expected queue readiness failure, got %+v", queue.Failure)
+	}
+	if cache := cases["cache"]; cache.Skipped == nil {
+		t.Error("expected unfinished chart to be skipped")
+	}
+
+	w = httptest.NewRecorder()
+	s.HandleReport(w, httptest.NewRequest(http.MethodGet, "/parcel/report?format=tap", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unsupported format, got %d", w.Code)
+	}
+}
@@ -1,6 +1,8 @@
 package runner
 
 import (
+	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/tiborv/kube-parcel/pkg/shared"
@@ -10,6 +12,7 @@ import (
 type StateMachine struct {
 	mu           sync.RWMutex
 	current      shared.State
+	reason       string // Why the run failed, while current is StateFailed
 	onTransition func(from, to shared.State)
 	imagesCount  int
 	chartsCount  int
@@ -28,12 +31,39 @@ func (sm *StateMachine) Current() shared.State {
 	return sm.current
 }
 
+// allowedTransitions lists the states each state may move to. READY and FAILED return to
+// IDLE on reset; a FAILED run may also be retried with a new upload. An upgrade upload
+// moves a finished READY run back to TRANSFERRING and, reusing the running cluster,
+// straight on to READY.
+var allowedTransitions = map[shared.State][]shared.State{
+	shared.StateIdle:         {shared.StateTransferring},
+	shared.StateTransferring: {shared.StateStarting, shared.StateReady, shared.StateFailed},
+	shared.StateStarting:     {shared.StateReady, shared.StateFailed},
+	shared.StateReady:        {shared.StateIdle, shared.StateTransferring, shared.StateFailed},
+	shared.StateFailed:       {shared.StateIdle, shared.StateTransferring},
+}
+
+// Transition moves to the given state, returning an error (and staying put) if the move
+// isn't in allowedTransitions
 func (sm *StateMachine) Transition(to shared.State) error {
+	return sm.transition(to, "")
+}
+
+// Fail moves to StateFailed, recording why the run failed
+func (sm *StateMachine) Fail(reason string) error {
+	return sm.transition(shared.StateFailed, reason)
+}
+
+func (sm *StateMachine) transition(to shared.State, reason string) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	from := sm.current
+	if !slices.Contains(allowedTransitions[from], to) {
+		return fmt.Errorf("illegal state transition %s → %s", from, to)
+	}
 	sm.current = to
+	sm.reason = reason
 
 	if sm.onTransition != nil {
 		go sm.onTransition(from, to)
@@ -42,6 +72,13 @@ func (sm *StateMachine) Transition(to shared.State) error {
 	return nil
 }
 
+// Describe returns the current state and, in StateFailed, why the run failed
+func (sm *StateMachine) Describe() (shared.State, string) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.current, sm.reason
+}
+
 func (sm *StateMachine) OnTransition(fn func(from, to shared.State)) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -60,6 +97,13 @@ func (sm *StateMachine) IncrementCharts() {
 	sm.chartsCount++
 }
 
+// ResetCounts zeroes the image and chart counts before a new parcel replaces the old one
+func (sm *StateMachine) ResetCounts() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.imagesCount, sm.chartsCount = 0, 0
+}
+
 func (sm *StateMachine) GetCounts() (images, charts int) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
@@ -41,6 +41,86 @@ func TestStateMachine_Transition(t *testing.T) {
 	}
 }
 
+func TestStateMachine_IllegalTransition(t *testing.T) {
+	tests := []struct {
+		name string
+		path []shared.State // Legal moves made first
+		to   shared.State
+	}{
+		{"idle to ready", nil, shared.StateReady},
+		{"idle to failed", nil, shared.StateFailed},
+		{"restart transfer mid-run", []shared.State{shared.StateTransferring, shared.StateStarting}, shared.StateTransferring},
+		{"ready to starting", []shared.State{shared.StateTransferring, shared.StateStarting, shared.StateReady}, shared.StateStarting},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sm := NewStateMachine()
+			for _, state := range tc.path {
+				if err := sm.Transition(state); err != nil {
+					t.Fatalf("setup transition to %v failed: %v", state, err)
+				}
+			}
+			before := sm.Current()
+			if err := sm.Transition(tc.to); err == nil {
+				t.Errorf("expected %v → %v to be rejected", before, tc.to)
+			}
+			if sm.Current() != before {
+				t.Errorf("rejected transition changed state to %v", sm.Current())
+			}
+		})
+	}
+}
+
+func TestStateMachine_FailedRetry(t *testing.T) {
+	sm := NewStateMachine()
+	for _, state := range []shared.State{shared.StateTransferring, shared.StateFailed, shared.StateTransferring, shared.StateStarting, shared.StateFailed, shared.StateIdle} {
+		if err := sm.Transition(state); err != nil {
+			t.Fatalf("Transition to %v failed: %v", state, err)
+		}
+	}
+}
+
+func TestStateMachine_Upgrade(t *testing.T) {
+	sm := NewStateMachine()
+	path := []shared.State{shared.StateTransferring, shared.StateStarting, shared.StateReady,
+		shared.StateTransferring, shared.StateReady, shared.StateFailed, shared.StateTransferring, shared.StateReady}
+	for _, state := range path {
+		if err := sm.Transition(state); err != nil {
+			t.Fatalf("Transition to %v failed: %v", state, err)
+		}
+	}
+
+	sm.IncrementImages()
+	sm.IncrementCharts()
+	sm.ResetCounts()
+	if images, charts := sm.GetCounts(); images != 0 || charts != 0 {
+		t.Errorf("expected counts reset, got %d images, %d charts", images, charts)
+	}
+}
+
+func TestStateMachine_Fail(t *testing.T) {
+	sm := NewStateMachine()
+	if err := sm.Fail("too early"); err == nil {
+		t.Error("expected IDLE → FAILED to be rejected")
+	}
+
+	sm.Transition(shared.StateTransferring)
+	if err := sm.Fail("Extraction failed: unexpected EOF"); err != nil {
+		t.Fatalf("Fail() = %v", err)
+	}
+	state, reason := sm.Describe()
+	if state != shared.StateFailed || reason != "Extraction failed: unexpected EOF" {
+		t.Errorf("Describe() = %v, %q", state, reason)
+	}
+
+	// Leaving FAILED clears the reason
+	sm.Transition(shared.StateTransferring)
+	if _, reason := sm.Describe(); reason != "" {
+		t.Errorf("reason %q kept after retrying", reason)
+	}
+}
+
 func TestStateMachine_OnTransition(t *testing.T) {
 	sm := NewStateMachine()
 
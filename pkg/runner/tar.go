@@ -4,24 +4,76 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/tiborv/kube-parcel/pkg/config"
 )
 
-// ImportImages looks for any tarballs in the images directory and imports them into K3s
-func ImportImages() error {
-	log.Printf("🔍 Scanning images directory: %s", config.DefaultImagesDir)
+// ImportOptions controls how bundled image tars are imported into containerd
+type ImportOptions struct {
+	Platform     string // Platform to import and unpack (ignored with AllPlatforms)
+	AllPlatforms bool   // Import content for all platforms in the index
+	NoUnpack     bool   // Skip unpacking; layers are unpacked lazily on first pod start
 
+	OnProgress func(ImportProgress) // Called when each image import starts and finishes
+}
+
+// DefaultImportOptions unpacks the default platform at import time
+func DefaultImportOptions() ImportOptions {
+	return ImportOptions{Platform: config.DefaultImagePlatform}
+}
+
+// args returns the ctr flags for these options
+func (o ImportOptions) args() []string {
+	var args []string
+	if o.AllPlatforms {
+		args = append(args, "--all-platforms")
+	} else if o.Platform != "" {
+		args = append(args, "--platform", o.Platform)
+	}
+	if o.NoUnpack {
+		args = append(args, "--no-unpack")
+	}
+	return args
+}
+
+// ImportProgress describes one step of the image import phase
+type ImportProgress struct {
+	Image     string   // Image tar file name
+	Index     int      // 1-based position of this image
+	Total     int      // Number of image tars being imported
+	Bytes     int64    // Size of the image tar on disk
+	Platforms []string // Platforms the tar provides (os/arch[/variant]); nil if unreadable
+	Missing   bool     // None of Platforms is the platform being imported
+	Done      bool     // False when the import starts, true when it finished
+	Err       error    // Set when a finished import failed
+}
+
+// ImportImages looks for any tarballs in the images directory and imports them into K3s.
+// Every image is attempted; the error joins the failures of those that didn't import.
+func ImportImages(opts ImportOptions) error {
+	slog.Info("Scanning images directory", "dir", config.DefaultImagesDir,
+		"platform", opts.Platform, "all_platforms", opts.AllPlatforms, "unpack", !opts.NoUnpack)
+	importArgs := append([]string{"-a", config.ContainerdSocket,
+		"-n", config.ContainerdNamespace, "images", "import"}, opts.args()...)
+	importArgs = append(importArgs, "-")
+
+	type imageFile struct {
+		path string
+		size int64
+	}
+	var images []imageFile
 	err := filepath.Walk(config.DefaultImagesDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.Printf("Error accessing path %s: %v", path, err)
+			slog.Error("Error accessing path", "path", path, "error", err)
 			return err
 		}
 
@@ -33,51 +85,107 @@ func ImportImages() error {
 		if !strings.HasSuffix(name, ".tar") && !strings.HasSuffix(name, ".tar.gz") && !strings.HasSuffix(name, ".tgz") {
 			return nil
 		}
+		images = append(images, imageFile{path: path, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		log.Printf("📦 Importing image: %s", name)
+	report := func(p ImportProgress) {
+		if opts.OnProgress != nil {
+			opts.OnProgress(p)
+		}
+	}
 
-		f, err := os.Open(path)
+	var failures []error
+	for i, img := range images {
+		progress := ImportProgress{Image: filepath.Base(img.path), Index: i + 1, Total: len(images), Bytes: img.size}
+		platforms, err := imagePlatforms(img.path)
 		if err != nil {
-			log.Printf("Warning: failed to open %s: %v", name, err)
-			return nil
-		}
-		defer f.Close()
-
-		var r io.Reader = f
-		if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
-			gz, err := gzip.NewReader(f)
-			if err != nil {
-				log.Printf("Warning: failed to create gzip reader for %s: %v", name, err)
-				return nil
+			slog.Warn("Could not read image platforms", "image", progress.Image, "error", err)
+		} else {
+			progress.Platforms = platforms
+			progress.Missing = !opts.AllPlatforms && opts.Platform != "" && len(platforms) > 0 &&
+				!slices.ContainsFunc(platforms, func(p string) bool { return platformMatches(opts.Platform, p) })
+			slog.Info("Image platforms", "image", progress.Image, "platforms", platforms)
+			if progress.Missing {
+				slog.Warn("Image has no variant for the import platform", "image", progress.Image,
+					"platform", opts.Platform, "platforms", platforms)
 			}
-			defer gz.Close()
-			r = gz
 		}
+		report(progress)
 
-		// Use ctr to import into containerd (K3s uses k3s ctr)
-		// We pipe the reader to stdin and use '-' as filename for import
-		ctx, cancel := context.WithTimeout(context.Background(), config.ImageImportTimeout)
-		defer cancel()
+		progress.Done = true
+		progress.Err = importImage(img.path, importArgs)
+		report(progress)
+		if progress.Err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", progress.Image, progress.Err))
+		}
+	}
 
-		cmd := exec.CommandContext(ctx, "ctr", "-a", config.ContainerdSocket,
-			"-n", config.ContainerdNamespace, "images", "import", "-")
-		cmd.Stdin = r
+	return errors.Join(failures...)
+}
 
-		output, err := cmd.CombinedOutput()
+// importFailures lists the per-image failures in an ImportImages error
+func importFailures(err error) []string {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []string{err.Error()}
+	}
+	var failures []string
+	for _, e := range joined.Unwrap() {
+		failures = append(failures, e.Error())
+	}
+	return failures
+}
+
+// importImage pipes a single (optionally gzipped) image tar into ctr import
+func importImage(path string, importArgs []string) error {
+	name := filepath.Base(path)
+	slog.Info("Importing image", "image", name)
+
+	f, err := os.Open(path)
+	if err != nil {
+		slog.Warn("Failed to open image tar", "image", name, "error", err)
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gz, err := gzip.NewReader(f)
 		if err != nil {
-			log.Printf("Warning: failed to import %s: %v (output: %s)", name, err, string(output))
-			return nil // Continue walking
+			slog.Warn("Failed to create gzip reader", "image", name, "error", err)
+			return err
 		}
-		log.Printf("✅ Imported image: %s", name)
+		defer gz.Close()
+		r = gz
+	}
 
-		// Normalize tags: if image has a short name (no registry prefix), add docker.io/library/ prefix
-		// This fixes ErrImageNeverPull because Kubernetes normalizes short names to docker.io/library/
-		normalizeImageTags()
+	// Use ctr to import into containerd (K3s uses k3s ctr)
+	// We pipe the reader to stdin and use '-' as filename for import
+	ctx, cancel := context.WithTimeout(context.Background(), config.ImageImportTimeout)
+	defer cancel()
 
-		return nil
-	})
+	cmd := exec.CommandContext(ctx, "ctr", importArgs...)
+	cmd.Stdin = r
 
-	return err
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		slog.Warn("Failed to import image", "image", name, "error", err, "output", string(output))
+		return fmt.Errorf("ctr import failed: %w", err)
+	}
+	slog.Info("Imported image", "image", name)
+
+	// Normalize tags: if image has a short name (no registry prefix), add docker.io/library/ prefix
+	// This fixes ErrImageNeverPull because Kubernetes normalizes short names to docker.io/library/
+	normalizeImageTags()
+
+	return nil
 }
 
 // normalizeImageTags adds docker.io/library/ prefix to images with short names
@@ -86,7 +194,7 @@ func normalizeImageTags() {
 		"-n", config.ContainerdNamespace, "images", "list", "-q")
 	output, err := listCmd.Output()
 	if err != nil {
-		log.Printf("Warning: failed to list images for normalization: %v", err)
+		slog.Warn("Failed to list images for normalization", "error", err)
 		return
 	}
 
@@ -104,9 +212,9 @@ func normalizeImageTags() {
 			tagCmd := exec.Command("ctr", "-a", config.ContainerdSocket,
 				"-n", config.ContainerdNamespace, "images", "tag", img, targetTag)
 			if tagOut, err := tagCmd.CombinedOutput(); err != nil {
-				log.Printf("Warning: failed to add normalized tag %s: %v (output: %s)", targetTag, err, string(tagOut))
+				slog.Warn("Failed to add normalized tag", "tag", targetTag, "error", err, "output", string(tagOut))
 			} else {
-				log.Printf("🏷️  Tagged %s → %s", img, targetTag)
+				slog.Info("Tagged image", "image", img, "tag", targetTag)
 			}
 		}
 	}
@@ -114,17 +222,21 @@ func normalizeImageTags() {
 
 // TarExtractor handles tar-in-tar stream extraction
 type TarExtractor struct {
-	imagesDir string
-	chartsDir string
-	onImage   func(name string)
-	onChart   func(name string)
+	imagesDir    string
+	chartsDir    string
+	binDir       string
+	manifestsDir string
+	onImage      func(name string)
+	onChart      func(name string)
 }
 
 // NewTarExtractor creates a new extractor
 func NewTarExtractor() *TarExtractor {
 	return &TarExtractor{
-		imagesDir: config.DefaultImagesDir,
-		chartsDir: config.DefaultChartsDir,
+		imagesDir:    config.DefaultImagesDir,
+		chartsDir:    config.DefaultChartsDir,
+		binDir:       config.DefaultBinDir,
+		manifestsDir: config.DefaultManifestsDir,
 	}
 }
 
@@ -138,6 +250,17 @@ func (te *TarExtractor) OnChart(fn func(name string)) {
 	te.onChart = fn
 }
 
+// Reset removes everything extracted from a previous parcel, so a new one replaces it
+// instead of adding to it
+func (te *TarExtractor) Reset() error {
+	for _, dir := range []string{te.imagesDir, te.chartsDir, te.binDir, te.manifestsDir} {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
 // Extract processes the tar-in-tar stream
 func (te *TarExtractor) Extract(r io.Reader) error {
 	if err := os.MkdirAll(te.imagesDir, 0755); err != nil {
@@ -159,15 +282,25 @@ func (te *TarExtractor) Extract(r io.Reader) error {
 
 		if te.isImageTar(header.Name) {
 			if err := te.extractImage(tr, header); err != nil {
-				log.Printf("Warning: failed to extract image %s: %v", header.Name, err)
+				slog.Warn("Failed to extract image", "file", header.Name, "error", err)
 				continue
 			}
 			if te.onImage != nil {
 				te.onImage(header.Name)
 			}
+		} else if strings.HasPrefix(header.Name, "bin/") {
+			if err := te.extractBinary(tr, header); err != nil {
+				slog.Warn("Failed to extract binary", "file", header.Name, "error", err)
+				continue
+			}
+		} else if strings.HasPrefix(header.Name, "manifests/") {
+			if err := te.extractManifest(tr, header); err != nil {
+				slog.Warn("Failed to extract manifest", "file", header.Name, "error", err)
+				continue
+			}
 		} else if te.isChartFile(header.Name) {
 			if err := te.extractChart(tr, header); err != nil {
-				log.Printf("Warning: failed to extract chart file %s: %v", header.Name, err)
+				slog.Warn("Failed to extract chart file", "file", header.Name, "error", err)
 				continue
 			}
 		}
@@ -176,9 +309,13 @@ func (te *TarExtractor) Extract(r io.Reader) error {
 	return nil
 }
 
-// isImageTar checks if the file is a Docker image tar
+// isImageTar checks if the file is a Docker image tar, either at the top level
+// of the bundle or anywhere under the images/ directory
 func (te *TarExtractor) isImageTar(name string) bool {
-	return (strings.HasSuffix(name, ".tar") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")) && !strings.Contains(name, "/")
+	if !strings.HasSuffix(name, ".tar") && !strings.HasSuffix(name, ".tar.gz") && !strings.HasSuffix(name, ".tgz") {
+		return false
+	}
+	return strings.HasPrefix(name, "images/") || !strings.Contains(name, "/")
 }
 
 // isChartFile checks if the file belongs to a Helm chart
@@ -187,9 +324,17 @@ func (te *TarExtractor) isChartFile(name string) bool {
 	return strings.HasPrefix(name, "charts/") || strings.Contains(name, "Chart.yaml")
 }
 
-// extractImage extracts an image tar to the images directory
+// extractImage extracts an image tar to the images directory, keeping its path below
+// images/ so tars with the same name in different subdirectories don't overwrite each other
 func (te *TarExtractor) extractImage(r io.Reader, header *tar.Header) error {
-	targetPath := filepath.Join(te.imagesDir, filepath.Base(header.Name))
+	relativePath := filepath.FromSlash(strings.TrimPrefix(header.Name, "images/"))
+	if !filepath.IsLocal(relativePath) {
+		return fmt.Errorf("unsafe image path %q", header.Name)
+	}
+	targetPath := filepath.Join(te.imagesDir, relativePath)
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
 
 	outFile, err := os.Create(targetPath)
 	if err != nil {
@@ -201,7 +346,7 @@ func (te *TarExtractor) extractImage(r io.Reader, header *tar.Header) error {
 		return err
 	}
 
-	log.Printf("Extracted image: %s -> %s", header.Name, targetPath)
+	slog.Info("Extracted image", "file", header.Name, "path", targetPath)
 	return nil
 }
 
@@ -221,13 +366,7 @@ func (te *TarExtractor) extractChart(r io.Reader, header *tar.Header) error {
 		return err
 	}
 
-	outFile, err := os.Create(targetPath)
-	if err != nil {
-		return err
-	}
-	defer outFile.Close()
-
-	if _, err := io.Copy(outFile, r); err != nil {
+	if err := writeFileFromTar(targetPath, r, header); err != nil {
 		return err
 	}
 
@@ -239,3 +378,65 @@ func (te *TarExtractor) extractChart(r io.Reader, header *tar.Header) error {
 
 	return nil
 }
+
+// extractBinary extracts an executable shipped under bin/ (e.g. a helm post-renderer)
+// to the bin directory, keeping its file mode
+func (te *TarExtractor) extractBinary(r io.Reader, header *tar.Header) error {
+	if header.Typeflag == tar.TypeDir {
+		return nil
+	}
+	if err := os.MkdirAll(te.binDir, 0755); err != nil {
+		return err
+	}
+
+	targetPath := filepath.Join(te.binDir, filepath.Base(header.Name))
+	if err := writeFileFromTar(targetPath, r, header); err != nil {
+		return err
+	}
+
+	slog.Info("Extracted binary", "file", header.Name, "path", targetPath)
+	return nil
+}
+
+// extractManifest extracts a Kubernetes manifest shipped under manifests/ to the manifests
+// directory, keeping its relative path so files apply in the bundled order
+func (te *TarExtractor) extractManifest(r io.Reader, header *tar.Header) error {
+	relativePath := filepath.FromSlash(strings.TrimPrefix(header.Name, "manifests/"))
+	if relativePath == "" {
+		return nil // The manifests/ directory itself
+	}
+	if !filepath.IsLocal(relativePath) {
+		return fmt.Errorf("unsafe manifest path %q", header.Name)
+	}
+	targetPath := filepath.Join(te.manifestsDir, relativePath)
+
+	if header.Typeflag == tar.TypeDir {
+		return os.MkdirAll(targetPath, 0755)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+	if err := writeFileFromTar(targetPath, r, header); err != nil {
+		return err
+	}
+
+	slog.Info("Extracted manifest", "file", header.Name, "path", targetPath)
+	return nil
+}
+
+// writeFileFromTar writes a tar entry to path with the permission bits from its header
+func writeFileFromTar(path string, r io.Reader, header *tar.Header) error {
+	mode := header.FileInfo().Mode().Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+
+	outFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	_, err = io.Copy(outFile, r)
+	return err
+}
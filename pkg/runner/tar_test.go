@@ -0,0 +1,216 @@
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTarExtractor_IsImageTar(t *testing.T) {
+	te := NewTarExtractor()
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"nginx.tar", true},
+		{"nginx.tar.gz", true},
+		{"nginx.tgz", true},
+		{"images/nginx.tar", true},
+		{"images/team-a/nginx.tgz", true},
+		{"charts/myapp/charts/postgres-1.0.0.tgz", false},
+		{"vendor/nginx.tar", false},
+		{"images/README.md", false},
+		{"Chart.yaml", false},
+	}
+
+	for _, tc := range tests {
+		if result := te.isImageTar(tc.name); result != tc.expected {
+			t.Errorf("isImageTar(%q) = %v, expected %v", tc.name, result, tc.expected)
+		}
+	}
+}
+
+func TestTarExtractor_ExtractImagesSubdirectory(t *testing.T) {
+	dir := t.TempDir()
+	te := &TarExtractor{
+		imagesDir: filepath.Join(dir, "images"),
+		chartsDir: filepath.Join(dir, "charts"),
+	}
+
+	var extracted []string
+	te.OnImage(func(name string) {
+		extracted = append(extracted, name)
+	})
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"top.tar", "images/a/app.tar", "images/b/app.tar", "images/../escape.tar"} {
+		content := []byte("image-" + name)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(content)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+
+	if err := te.Extract(&buf); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if len(extracted) != 3 {
+		t.Fatalf("expected 3 extracted images, got %d: %v", len(extracted), extracted)
+	}
+	for name, content := range map[string]string{
+		"top.tar":   "image-top.tar",
+		"a/app.tar": "image-images/a/app.tar",
+		"b/app.tar": "image-images/b/app.tar",
+	} {
+		data, err := os.ReadFile(filepath.Join(te.imagesDir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("expected %s in images dir with %q, got %q, %v", name, content, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.tar")); err == nil {
+		t.Error("expected an image path escaping images/ to be rejected")
+	}
+}
+
+func TestTarExtractor_ExtractBinaryKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	te := &TarExtractor{
+		imagesDir: filepath.Join(dir, "images"),
+		chartsDir: filepath.Join(dir, "charts"),
+		binDir:    filepath.Join(dir, "bin"),
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	files := []struct {
+		name string
+		mode int64
+	}{
+		{"bin/inject-sidecar", 0755},
+		{"charts/myapp/Chart.yaml", 0644},
+		{"charts/myapp/scripts/render.sh", 0750},
+	}
+	for _, f := range files {
+		content := []byte("content")
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Size: int64(len(content)), Mode: f.mode}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(content)
+	}
+	tw.Close()
+
+	if err := te.Extract(&buf); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for path, want := range map[string]os.FileMode{
+		filepath.Join(dir, "bin", "inject-sidecar"):                0755,
+		filepath.Join(dir, "charts", "myapp", "Chart.yaml"):        0644,
+		filepath.Join(dir, "charts", "myapp", "scripts/render.sh"): 0750,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Errorf("expected %s to be extracted: %v", path, err)
+			continue
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %o, want %o", path, got, want)
+		}
+	}
+}
+
+func TestTarExtractor_ExtractManifests(t *testing.T) {
+	dir := t.TempDir()
+	te := &TarExtractor{
+		imagesDir:    filepath.Join(dir, "images"),
+		chartsDir:    filepath.Join(dir, "charts"),
+		binDir:       filepath.Join(dir, "bin"),
+		manifestsDir: filepath.Join(dir, "manifests"),
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "manifests/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, name := range []string{"manifests/01-secrets/db.yaml", "manifests/00-namespace.yaml", "manifests/../escape.yaml", "manifests/notes.txt"} {
+		content := []byte("kind: Secret\n")
+		tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(content)), Mode: 0644})
+		tw.Write(content)
+	}
+	tw.Close()
+
+	if err := te.Extract(&buf); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.yaml")); err == nil {
+		t.Error("expected a manifest path escaping manifests/ to be rejected")
+	}
+
+	files, err := bundledManifests(te.manifestsDir)
+	if err != nil {
+		t.Fatalf("bundledManifests failed: %v", err)
+	}
+	want := []string{filepath.Join(te.manifestsDir, "00-namespace.yaml"), filepath.Join(te.manifestsDir, "01-secrets", "db.yaml")}
+	if !slices.Equal(files, want) {
+		t.Errorf("manifests = %v, want %v", files, want)
+	}
+
+	if files, err := bundledManifests(filepath.Join(dir, "none")); err != nil || len(files) != 0 {
+		t.Errorf("expected no manifests without a manifests dir, got %v, %v", files, err)
+	}
+}
+
+func TestImportOptions_Args(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ImportOptions
+		want []string
+	}{
+		{"default", DefaultImportOptions(), []string{"--platform", "linux/amd64"}},
+		{"custom platform", ImportOptions{Platform: "linux/arm64"}, []string{"--platform", "linux/arm64"}},
+		{"all platforms wins", ImportOptions{Platform: "linux/arm64", AllPlatforms: true}, []string{"--all-platforms"}},
+		{"no unpack", ImportOptions{Platform: "linux/amd64", NoUnpack: true}, []string{"--platform", "linux/amd64", "--no-unpack"}},
+		{"empty", ImportOptions{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.args(); !slices.Equal(got, tt.want) {
+				t.Errorf("args() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{"none", nil, nil},
+		{"single", errors.New("ctr failed"), []string{"ctr failed"}},
+		{"joined", errors.Join(
+			fmt.Errorf("app.tar: %w", errors.New("exit status 1")),
+			fmt.Errorf("db.tar: %w", errors.New("unexpected EOF")),
+		), []string{"app.tar: exit status 1", "db.tar: unexpected EOF"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := importFailures(tt.err); !slices.Equal(got, tt.want) {
+				t.Errorf("importFailures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// selfSignedValidity is how long a generated certificate is valid; runners are short-lived
+const selfSignedValidity = 7 * 24 * time.Hour
+
+// TLSConfig returns the configuration for serving the parcel API over HTTPS, or nil when
+// TLS is off. KUBE_PARCEL_TLS_CERT and KUBE_PARCEL_TLS_KEY name a PEM certificate and key;
+// with only KUBE_PARCEL_TLS=true a self-signed certificate is generated.
+func (s *Server) TLSConfig() (*tls.Config, error) {
+	if !s.tlsEnabled {
+		return nil, nil
+	}
+
+	var cert tls.Certificate
+	var err error
+	if s.tlsCertFile != "" || s.tlsKeyFile != "" {
+		if s.tlsCertFile == "" || s.tlsKeyFile == "" {
+			return nil, fmt.Errorf("KUBE_PARCEL_TLS_CERT and KUBE_PARCEL_TLS_KEY must be set together")
+		}
+		cert, err = tls.LoadX509KeyPair(s.tlsCertFile, s.tlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		slog.Info("Loaded TLS certificate", "cert", s.tlsCertFile)
+	} else {
+		cert, err = selfSignedCert()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate self-signed certificate: %w", err)
+		}
+		fingerprint := sha256.Sum256(cert.Certificate[0])
+		slog.Info("Generated self-signed TLS certificate", "sha256", hex.EncodeToString(fingerprint[:]))
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// selfSignedCert generates a certificate for localhost and the runner's hostname
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	names := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		names = append(names, hostname)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "kube-parcel-runner"},
+		DNSNames:     names,
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    now.Add(-time.Minute), // Tolerate clients whose clock is slightly behind
+		NotAfter:     now.Add(selfSignedValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
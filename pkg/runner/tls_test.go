@@ -0,0 +1,31 @@
+package runner
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestServer_TLSConfig(t *testing.T) {
+	if config, err := (&Server{}).TLSConfig(); config != nil || err != nil {
+		t.Errorf("TLS off: got %v, %v, want nil", config, err)
+	}
+
+	config, err := (&Server{tlsEnabled: true}).TLSConfig()
+	if err != nil {
+		t.Fatalf("self-signed: %v", err)
+	}
+	if len(config.Certificates) != 1 {
+		t.Fatalf("expected one certificate, got %d", len(config.Certificates))
+	}
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse generated certificate: %v", err)
+	}
+	if err := cert.VerifyHostname("localhost"); err != nil {
+		t.Errorf("generated certificate doesn't cover localhost: %v", err)
+	}
+
+	if _, err := (&Server{tlsEnabled: true, tlsCertFile: "cert.pem"}).TLSConfig(); err == nil {
+		t.Error("expected an error for a certificate without a key")
+	}
+}
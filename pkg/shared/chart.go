@@ -0,0 +1,62 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChartDependency is a subchart declared in Chart.yaml (or a v1 chart's requirements.yaml)
+type ChartDependency struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	Repository string `yaml:"repository"`
+}
+
+// ChartDependencies returns the dependencies declared by the chart in chartDir
+func ChartDependencies(chartDir string) ([]ChartDependency, error) {
+	var deps []ChartDependency
+	for _, name := range []string{"Chart.yaml", "requirements.yaml"} {
+		data, err := os.ReadFile(filepath.Join(chartDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var meta struct {
+			Dependencies []ChartDependency `yaml:"dependencies"`
+		}
+		if err := yaml.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		deps = append(deps, meta.Dependencies...)
+	}
+	return deps, nil
+}
+
+// MissingDependencies returns the names of declared dependencies that have no vendored
+// subchart (a charts/<name> directory or charts/<name>-<version>.tgz archive)
+func MissingDependencies(chartDir string) ([]string, error) {
+	deps, err := ChartDependencies(chartDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, dep := range deps {
+		subchartsDir := filepath.Join(chartDir, "charts")
+		if _, err := os.Stat(filepath.Join(subchartsDir, dep.Name, "Chart.yaml")); err == nil {
+			continue
+		}
+		if archives, _ := filepath.Glob(filepath.Join(subchartsDir, dep.Name+"-*.tgz")); len(archives) > 0 {
+			continue
+		}
+		missing = append(missing, dep.Name)
+	}
+	return missing, nil
+}
@@ -0,0 +1,60 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMissingDependencies(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(`apiVersion: v2
+name: app
+version: 0.1.0
+dependencies:
+  - name: redis
+    version: 18.x
+    repository: https://charts.bitnami.com/bitnami
+  - name: common
+    version: 2.0.0
+    repository: file://../common
+  - name: postgresql
+    version: 13.0.0
+    repository: https://charts.bitnami.com/bitnami
+`), 0644)
+
+	missing, err := MissingDependencies(dir)
+	if err != nil {
+		t.Fatalf("MissingDependencies failed: %v", err)
+	}
+	if !slices.Equal(missing, []string{"redis", "common", "postgresql"}) {
+		t.Errorf("expected all dependencies missing, got %v", missing)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "charts", "common"), 0755)
+	os.WriteFile(filepath.Join(dir, "charts", "common", "Chart.yaml"), []byte("name: common\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "charts", "redis-18.1.0.tgz"), []byte("archive"), 0644)
+
+	missing, err = MissingDependencies(dir)
+	if err != nil {
+		t.Fatalf("MissingDependencies failed: %v", err)
+	}
+	if !slices.Equal(missing, []string{"postgresql"}) {
+		t.Errorf("expected only postgresql missing, got %v", missing)
+	}
+}
+
+func TestChartDependencies_RequirementsYaml(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("apiVersion: v1\nname: legacy\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "requirements.yaml"), []byte("dependencies:\n  - name: mysql\n    version: 1.0.0\n"), 0644)
+
+	deps, err := ChartDependencies(dir)
+	if err != nil {
+		t.Fatalf("ChartDependencies failed: %v", err)
+	}
+	if len(deps) != 1 || deps[0].Name != "mysql" {
+		t.Errorf("expected mysql from requirements.yaml, got %+v", deps)
+	}
+}
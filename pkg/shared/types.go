@@ -10,6 +10,7 @@ const (
 	StateTransferring              // Receiving/unpacking stream
 	StateStarting                  // K3s booting
 	StateReady                     // K3s running
+	StateFailed                    // Run ended in failure (see StatusResponse.FailureReason)
 )
 
 func (s State) String() string {
@@ -22,6 +23,8 @@ func (s State) String() string {
 		return "STARTING"
 	case StateReady:
 		return "READY"
+	case StateFailed:
+		return "FAILED"
 	default:
 		return "UNKNOWN"
 	}
@@ -30,21 +33,73 @@ func (s State) String() string {
 // StatusResponse is returned by the status endpoint
 type StatusResponse struct {
 	State            string                 `json:"state"`
+	FailureReason    string                 `json:"failure_reason,omitempty"` // Why the run failed (only in FAILED)
 	Uptime           int                    `json:"uptime"`
 	K3sReady         bool                   `json:"k3s_ready"`
+	K3sStartFailed   bool                   `json:"k3s_start_failed,omitempty"` // K3s failed to start and can't be restarted: the runner takes no more uploads
+	K3sVersion       string                 `json:"k3s_version,omitempty"`      // K3s release running (set once K3s starts)
 	ChartsCount      int                    `json:"charts_count"`
 	ImagesCount      int                    `json:"images_count"`
 	Images           []string               `json:"images"`
+	ImportErrors     []string               `json:"import_errors,omitempty"` // Bundled image tars that failed to import, with the error
+	LogsDropped      int                    `json:"logs_dropped,omitempty"`  // Log messages evicted from the runner's buffer (not replayed to new clients)
 	StartTime        time.Time              `json:"start_time"`
 	ClusterStatus    string                 `json:"cluster_status"` // "Initializing", "Ready", "Error"
+	AirgapEnforced   bool                   `json:"airgap_enforced"`
+	AirgapDisabled   bool                   `json:"airgap_disabled,omitempty"` // Airgap was turned off (--no-airgap), so it was never enforced
+	Nodes            int                    `json:"nodes"`                     // Registered K3s nodes (server + agents)
+	Paused           string                 `json:"paused,omitempty"`          // Where the run is paused ("" if running)
+	WorkloadHealth   string                 `json:"workload_health"`           // "Unknown", "Healthy", "Degraded"
+	UnhealthyCount   int                    `json:"unhealthy_count"`
 	Charts           map[string]ChartStatus `json:"charts"`
 	ClusterResources []KubeResource         `json:"cluster_resources"`
 }
 
 // ChartStatus represents the state of a Helm chart
 type ChartStatus struct {
-	Phase   string `json:"phase"`   // Pending, Installing, Deployed, Testing, Succeeded, Failed
-	Message string `json:"message"` // Additional details
+	Phase           string   `json:"phase"`                      // Pending, Installing, WaitingReadiness, Deployed, Testing, Succeeded, Skipped, Failed, LintFailed, ReadinessFailed
+	Message         string   `json:"message"`                    // Additional details
+	Duration        float64  `json:"duration_seconds,omitempty"` // Seconds since the install started
+	InstallDuration float64  `json:"install_seconds,omitempty"`  // Seconds spent in helm install (until tests started)
+	TestDuration    float64  `json:"test_seconds,omitempty"`     // Seconds spent in helm test
+	Artifacts       []string `json:"artifacts,omitempty"`        // Artifact paths relative to /parcel/artifacts/
+}
+
+// RegistryConfig is the private registry setup the client passes to the runner, which
+// turns it into K3s's registries.yaml
+type RegistryConfig struct {
+	Mirrors map[string][]string     `json:"mirrors,omitempty"` // Registry host → mirror endpoints, tried in order
+	Auth    map[string]RegistryAuth `json:"auth,omitempty"`    // Registry or mirror host → credentials
+}
+
+// RegistryAuth holds basic auth credentials for a registry
+type RegistryAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RunResults is the results document written to the artifacts directory when a run ends
+type RunResults struct {
+	RunID      string                 `json:"run_id,omitempty"`
+	Passed     bool                   `json:"passed"`
+	FinishedAt time.Time              `json:"finished_at"`
+	Charts     map[string]ChartStatus `json:"charts"`
+}
+
+// RunManifest is the run-level kube-parcel.yaml at the top of the bundle's charts directory
+type RunManifest struct {
+	Order []string `json:"order,omitempty" yaml:"order,omitempty"` // Chart directory names, installed in this order before any other chart
+}
+
+// ChartOverrides are command-line settings bundled next to a chart. Values and Set are
+// applied after the chart's own values files; Values lists files relative to the overrides
+// directory. ReleaseName replaces the release name derived from the chart directory.
+type ChartOverrides struct {
+	Values      []string `json:"values,omitempty" yaml:"values,omitempty"`
+	Set         []string `json:"set,omitempty" yaml:"set,omitempty"`
+	ReleaseName string   `json:"release_name,omitempty" yaml:"releaseName,omitempty"`
+	SkipTests   bool     `json:"skip_tests,omitempty" yaml:"skipTests,omitempty"`
+	TestFilter  []string `json:"test_filter,omitempty" yaml:"testFilter,omitempty"` // helm test --filter expressions
 }
 
 // KubeResource represents a Kubernetes resource managed by a chart
@@ -55,6 +110,7 @@ type KubeResource struct {
 	Status    string `json:"status"` // e.g., "Running", "Created", "Ready", "Succeeded", "Failed"
 	IsTest    bool   `json:"is_test,omitempty"`
 	ExitCode  *int   `json:"exit_code,omitempty"` // Pod exit code (nil if not applicable)
+	Reason    string `json:"reason,omitempty"`    // Why the resource is unhealthy (empty when healthy)
 }
 
 // LogMessage represents a log entry
@@ -63,10 +119,30 @@ type LogMessage struct {
 	Level     string    `json:"level"`
 	Source    string    `json:"source"` // "k3s", "helm", "server"
 	Message   string    `json:"message"`
+	Seq       uint64    `json:"seq,omitempty"` // Position in the runner's log, increasing by one per message (0 = not from the log)
 }
 
 // Protocol constants
 const (
 	MagicHeader       = "KUBE-PARCEL-V1"
 	ContentTypeParcel = "application/x-parcel-tar"
+	HeaderPauseOn     = "X-Kube-Parcel-Pause-On" // Upload header naming where the run should pause
+	HeaderChecksum    = "X-Kube-Parcel-Sha256"   // Hex SHA-256 of the parcel: a header, or a trailer for streamed uploads
+	HeaderUpgrade     = "X-Kube-Parcel-Upgrade"  // Upload header: "true" reuses a running cluster and upgrades releases in place
+	HeaderDeadline    = "X-Kube-Parcel-Deadline" // Upload header: time the run may take from now (Go duration), after which helm is aborted
+)
+
+// Reasons of a COMPLETE:FAILED:<reason> result the client maps to its own exit codes; any
+// other reason means the run failed before reaching a verdict
+const (
+	FailureTests      = "Tests failed"              // A chart failed to install or its tests failed
+	FailureManifests  = "Applying manifests failed" // The bundled manifests were rejected
+	FailureExtraction = "Extraction failed"         // The parcel could not be unpacked; followed by ": <error>"
+)
+
+// Pause points a run can halt at for inspection
+const (
+	PauseOnInstall = "install" // After a chart installs, before its tests
+	PauseOnTest    = "test"    // After all tests finish, before the run completes
+	PauseOnFailure = "failure" // At the first failed install or test
 )
@@ -11,6 +11,7 @@ func TestState_String(t *testing.T) {
 		{StateTransferring, "TRANSFERRING"},
 		{StateStarting, "STARTING"},
 		{StateReady, "READY"},
+		{StateFailed, "FAILED"},
 		{State(999), "UNKNOWN"},
 	}
 